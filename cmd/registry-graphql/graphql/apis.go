@@ -40,6 +40,11 @@ var apiType = graphql.NewObject(
 				Args:    argumentsForCollectionQuery,
 				Resolve: resolveVersions,
 			},
+			"deployments": &graphql.Field{
+				Type:    connectionType(deploymentType),
+				Args:    argumentsForCollectionQuery,
+				Resolve: resolveDeployments,
+			},
 			"artifacts": &graphql.Field{
 				Type:    connectionType(artifactType),
 				Args:    argumentsForCollectionQuery,
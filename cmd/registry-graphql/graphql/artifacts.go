@@ -35,15 +35,19 @@ var artifactType = graphql.NewObject(
 			"updated": &graphql.Field{
 				Type: timestampType,
 			},
+			"revision_id": &graphql.Field{
+				Type: graphql.String,
+			},
 		},
 	},
 )
 
 func representationForArtifact(artifact *rpc.Artifact) map[string]interface{} {
 	return map[string]interface{}{
-		"id":      artifact.Name,
-		"created": representationForTimestamp(artifact.CreateTime),
-		"updated": representationForTimestamp(artifact.UpdateTime),
+		"id":          artifact.Name,
+		"created":     representationForTimestamp(artifact.CreateTime),
+		"updated":     representationForTimestamp(artifact.UpdateTime),
+		"revision_id": artifact.RevisionId,
 	}
 }
 
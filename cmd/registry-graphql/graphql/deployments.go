@@ -0,0 +1,138 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/rpc"
+	"github.com/graphql-go/graphql"
+)
+
+var deploymentType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Deployment",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"display_name": &graphql.Field{
+				Type: graphql.String,
+			},
+			"description": &graphql.Field{
+				Type: graphql.String,
+			},
+			"revision_id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"api_spec_revision": &graphql.Field{
+				Type: graphql.String,
+			},
+			"endpoint_uri": &graphql.Field{
+				Type: graphql.String,
+			},
+			"environment": &graphql.Field{
+				Type: graphql.String,
+			},
+			"gateway": &graphql.Field{
+				Type: graphql.String,
+			},
+			"access_guidance": &graphql.Field{
+				Type: graphql.String,
+			},
+			"created": &graphql.Field{
+				Type: timestampType,
+			},
+			"updated": &graphql.Field{
+				Type: timestampType,
+			},
+		},
+	},
+)
+
+func representationForDeployment(deployment *rpc.ApiDeployment) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                deployment.Name,
+		"display_name":      deployment.DisplayName,
+		"description":       deployment.Description,
+		"revision_id":       deployment.RevisionId,
+		"api_spec_revision": deployment.ApiSpecRevision,
+		"endpoint_uri":      deployment.EndpointUri,
+		"environment":       deployment.Environment,
+		"gateway":           deployment.Gateway,
+		"access_guidance":   deployment.AccessGuidance,
+		"created":           representationForTimestamp(deployment.CreateTime),
+		"updated":           representationForTimestamp(deployment.RevisionUpdateTime),
+	}
+}
+
+func resolveDeployments(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := connection.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := &rpc.ListApiDeploymentsRequest{
+		Parent: getParentFromParams(p),
+	}
+	filter, isFound := p.Args["filter"].(string)
+	if isFound {
+		req.Filter = filter
+	}
+	pageToken, isFound := p.Args["after"].(string)
+	if isFound {
+		req.PageToken = pageToken
+	}
+	pageSize, isFound := p.Args["first"].(int)
+	if isFound {
+		req.PageSize = int32(pageSize)
+	} else {
+		pageSize = 50
+	}
+	var response *rpc.ListApiDeploymentsResponse
+	edges := []map[string]interface{}{}
+	for len(edges) < pageSize {
+		response, err = c.GrpcClient().ListApiDeployments(ctx, req)
+		for _, deployment := range response.GetApiDeployments() {
+			edges = append(edges, representationForEdge(representationForDeployment(deployment)))
+		}
+		req.PageToken = response.GetNextPageToken()
+		if req.PageToken == "" {
+			break
+		}
+	}
+	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+}
+
+func resolveDeployment(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := connection.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name, isFound := p.Args["id"].(string)
+	if !isFound {
+		return nil, errors.New("missing id field")
+	}
+	req := &rpc.GetApiDeploymentRequest{
+		Name: name,
+	}
+	deployment, err := c.GetApiDeployment(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return representationForDeployment(deployment), err
+}
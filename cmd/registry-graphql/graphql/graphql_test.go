@@ -175,7 +175,11 @@ func deleteTestProject(ctx context.Context, registryClient connection.Client, t
 	req := &rpc.DeleteProjectRequest{
 		Name: "projects/" + name,
 	}
-	if err := registryClient.DeleteProject(ctx, req); status.Code(err) != codes.NotFound {
+	op, err := registryClient.DeleteProject(ctx, req)
+	if err == nil {
+		err = op.Wait(ctx)
+	}
+	if status.Code(err) != codes.NotFound {
 		check(t, "Failed to delete test project: %+v", err)
 	}
 }
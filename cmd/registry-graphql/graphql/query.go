@@ -40,6 +40,11 @@ var queryType = graphql.NewObject(
 				Args:    argumentsForParentedCollectionQuery,
 				Resolve: resolveSpecs,
 			},
+			"deployments": &graphql.Field{
+				Type:    connectionType(deploymentType),
+				Args:    argumentsForParentedCollectionQuery,
+				Resolve: resolveDeployments,
+			},
 			"artifacts": &graphql.Field{
 				Type:    connectionType(artifactType),
 				Args:    argumentsForParentedCollectionQuery,
@@ -65,6 +70,11 @@ var queryType = graphql.NewObject(
 				Args:    argumentsForResourceQuery,
 				Resolve: resolveSpec,
 			},
+			"deployment": &graphql.Field{
+				Type:    deploymentType,
+				Args:    argumentsForResourceQuery,
+				Resolve: resolveDeployment,
+			},
 			"artifact": &graphql.Field{
 				Type:    artifactType,
 				Args:    argumentsForResourceQuery,
@@ -26,6 +26,7 @@ import (
 	"syscall"
 
 	"github.com/apigee/registry/server"
+	"github.com/apigee/registry/server/notify"
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 )
@@ -44,6 +45,15 @@ func main() {
 		}
 	}
 
+	if pflag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), config, pflag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %s", err)
+		}
+		return
+	} else if pflag.NArg() > 0 {
+		log.Fatalf("Unknown command %q", pflag.Arg(0))
+	}
+
 	addr := &net.TCPAddr{Port: 8080}
 	if v, ok := os.LookupEnv("PORT"); ok {
 		if port, err := strconv.Atoi(v); err != nil {
@@ -99,9 +109,9 @@ func parseConfig(config *server.Config, filepath string) error {
 
 func validateConfig(c server.Config) error {
 	switch c.Database {
-	case "sqlite3", "postgres", "cloudsqlpostgres":
+	case "sqlite3", "postgres", "cloudsqlpostgres", "mysql", "memory":
 	default:
-		return fmt.Errorf("invalid database value %q: must be one of [sqlite3, postgres, cloudsqlpostgres]", c.Database)
+		return fmt.Errorf("invalid database value %q: must be one of [sqlite3, postgres, cloudsqlpostgres, mysql, memory]", c.Database)
 	}
 
 	switch c.Log {
@@ -110,7 +120,7 @@ func validateConfig(c server.Config) error {
 		return fmt.Errorf("invalid log value %q: must be one of [fatal, error, warn, info, debug]", c.Log)
 	}
 
-	if c.DBConfig == "" {
+	if c.DBConfig == "" && c.Database != "memory" {
 		return fmt.Errorf("invalid dbconfig %q: must not be empty", c.DBConfig)
 	}
 
@@ -118,5 +128,27 @@ func validateConfig(c server.Config) error {
 		return fmt.Errorf("invalid project %q: notifications cannot be enabled without GCP project ID", c.ProjectID)
 	}
 
+	if p := c.Pool; p.MaxOpenConns < 0 || p.MaxIdleConns < 0 || p.ConnMaxLifetime < 0 || p.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("invalid pool %+v: values must not be negative", p)
+	}
+
+	if err := c.Blobs.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Retention.Validate(); err != nil {
+		return fmt.Errorf("invalid retention: %s", err)
+	}
+
+	if c.Notifications.Type != "" {
+		n := c.Notifications
+		if n.Type == notify.PubSub && n.Project == "" {
+			n.Project = c.ProjectID
+		}
+		if err := n.Validate(); err != nil {
+			return fmt.Errorf("invalid notifications: %s", err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,116 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/apigee/registry/server"
+	"github.com/apigee/registry/server/gorm"
+)
+
+const migrateUsage = `usage: registry-server [-c config] migrate up [version]
+       registry-server [-c config] migrate down [version]
+       registry-server [-c config] migrate status
+
+up applies pending migrations, up to and including the version if specified.
+down reverts migrations until the version is the latest applied one. If no
+version is specified, only the latest applied migration is reverted.
+status lists all migrations and when they were applied.`
+
+// runMigrate runs the migrate command with the arguments that follow it.
+func runMigrate(ctx context.Context, config server.Config, args []string) error {
+	if len(args) == 0 || len(args) > 2 || (args[0] == "status" && len(args) > 1) {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	version := -1
+	if len(args) == 2 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid version %q: must be a non-negative integer", args[1])
+		}
+		version = v
+	}
+
+	database, dbConfig := config.Database, config.DBConfig
+	if database == "" {
+		database, dbConfig = "sqlite3", "/tmp/registry.db"
+	}
+	if database == "memory" {
+		return fmt.Errorf("the memory database has no schema to migrate")
+	}
+
+	m, err := gorm.NewMigrator(ctx, database, dbConfig)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %s", err)
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		if version < 0 {
+			version = 0
+		}
+		applied, err := m.Up(ctx, version)
+		for _, s := range applied {
+			fmt.Printf("Applied migration %d: %s\n", s.Version, s.Description)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No migrations to apply")
+		}
+		return err
+	case "down":
+		if version < 0 {
+			current, err := m.Version(ctx)
+			if err != nil {
+				return err
+			} else if current == 0 {
+				fmt.Println("No migrations to revert")
+				return nil
+			}
+			version = current - 1
+		}
+		reverted, err := m.Down(ctx, version)
+		for _, s := range reverted {
+			fmt.Printf("Reverted migration %d: %s\n", s.Version, s.Description)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("No migrations to revert")
+		}
+		return err
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied() {
+				applied = s.AppliedTime.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, applied, s.Description)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
@@ -100,6 +100,8 @@ func matchAndHandleAnnotateCmd(
 		return annotateVersions(ctx, client, m, filter, labeling, taskQueue)
 	} else if m := names.SpecsRegexp().FindStringSubmatch(name); m != nil {
 		return annotateSpecs(ctx, client, m, filter, labeling, taskQueue)
+	} else if m := names.DeploymentsRegexp().FindStringSubmatch(name); m != nil {
+		return annotateDeployments(ctx, client, m, filter, labeling, taskQueue)
 	}
 
 	// Then try to match resource names.
@@ -109,6 +111,8 @@ func matchAndHandleAnnotateCmd(
 		return annotateVersions(ctx, client, m, filter, labeling, taskQueue)
 	} else if m := names.SpecRegexp().FindStringSubmatch(name); m != nil {
 		return annotateSpecs(ctx, client, m, filter, labeling, taskQueue)
+	} else if m := names.DeploymentRegexp().FindStringSubmatch(name); m != nil {
+		return annotateDeployments(ctx, client, m, filter, labeling, taskQueue)
 	} else {
 		return fmt.Errorf("unsupported resource name %s", name)
 	}
@@ -161,6 +165,22 @@ func annotateSpecs(
 	})
 }
 
+func annotateDeployments(
+	ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	filterFlag string,
+	labeling *core.Labeling,
+	taskQueue chan<- core.Task) error {
+	return core.ListDeployments(ctx, client, segments, filterFlag, func(deployment *rpc.ApiDeployment) {
+		taskQueue <- &annotateDeploymentTask{
+			client:     client,
+			deployment: deployment,
+			labeling:   labeling,
+		}
+	})
+}
+
 type annotateApiTask struct {
 	client   connection.Client
 	api      *rpc.Api
@@ -172,19 +192,24 @@ func (task *annotateApiTask) String() string {
 }
 
 func (task *annotateApiTask) Run(ctx context.Context) error {
-	var err error
-	task.api.Annotations, err = task.labeling.Apply(task.api.Annotations)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.api.Annotations, err = task.labeling.Apply(task.api.Annotations)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApi(ctx,
+			&rpc.UpdateApiRequest{
+				Api: task.api,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"annotations"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApi(ctx,
-		&rpc.UpdateApiRequest{
-			Api: task.api,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"annotations"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.api, err = task.client.GetApi(ctx, &rpc.GetApiRequest{Name: task.api.Name})
+		return err
+	})
 }
 
 type annotateVersionTask struct {
@@ -198,19 +223,24 @@ func (task *annotateVersionTask) String() string {
 }
 
 func (task *annotateVersionTask) Run(ctx context.Context) error {
-	var err error
-	task.version.Annotations, err = task.labeling.Apply(task.version.Annotations)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.version.Annotations, err = task.labeling.Apply(task.version.Annotations)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiVersion(ctx,
+			&rpc.UpdateApiVersionRequest{
+				ApiVersion: task.version,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"annotations"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApiVersion(ctx,
-		&rpc.UpdateApiVersionRequest{
-			ApiVersion: task.version,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"annotations"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.version, err = task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{Name: task.version.Name})
+		return err
+	})
 }
 
 type annotateSpecTask struct {
@@ -224,17 +254,53 @@ func (task *annotateSpecTask) String() string {
 }
 
 func (task *annotateSpecTask) Run(ctx context.Context) error {
-	var err error
-	task.spec.Annotations, err = task.labeling.Apply(task.spec.Annotations)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.spec.Annotations, err = task.labeling.Apply(task.spec.Annotations)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiSpec(ctx,
+			&rpc.UpdateApiSpecRequest{
+				ApiSpec: task.spec,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"annotations"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApiSpec(ctx,
-		&rpc.UpdateApiSpecRequest{
-			ApiSpec: task.spec,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"annotations"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.spec, err = task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: task.spec.Name})
+		return err
+	})
+}
+
+type annotateDeploymentTask struct {
+	client     connection.Client
+	deployment *rpc.ApiDeployment
+	labeling   *core.Labeling
+}
+
+func (task *annotateDeploymentTask) String() string {
+	return "annotate " + task.deployment.Name
+}
+
+func (task *annotateDeploymentTask) Run(ctx context.Context) error {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.deployment.Annotations, err = task.labeling.Apply(task.deployment.Annotations)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiDeployment(ctx,
+			&rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: task.deployment,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"annotations"},
+				},
+			})
+		return err
+	}, func() (err error) {
+		task.deployment, err = task.client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: task.deployment.Name})
+		return err
+	})
 }
@@ -0,0 +1,190 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/diff"
+	"github.com/apigee/registry/server/names"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+func breakingChangesCommand(ctx context.Context) *cobra.Command {
+	var compare string
+	cmd := &cobra.Command{
+		Use:   "breaking-changes",
+		Short: "Classify the changes of API specs as breaking or non-breaking",
+		Long: "Classify the changes of API specs as breaking or non-breaking. Each spec is compared with its\n" +
+			"previous revision or with the spec of the same name in the previous version of its API.",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := cmd.Flags().GetString("filter")
+			if err != nil {
+				log.Fatalf("Failed to get filter from flags: %s", err)
+			}
+			if compare != "revision" && compare != "version" {
+				log.Fatalf("Invalid --compare value %q: must be \"revision\" or \"version\"", compare)
+			}
+
+			client, err := connection.NewClient(ctx)
+			if err != nil {
+				log.Fatalf("%s", err.Error())
+			}
+			// Initialize task queue.
+			taskQueue := make(chan core.Task, 1024)
+			workerCount := 64
+			for i := 0; i < workerCount; i++ {
+				core.WaitGroup().Add(1)
+				go core.Worker(ctx, taskQueue)
+			}
+			// Generate tasks.
+			name := args[0]
+			if m := names.SpecRegexp().FindStringSubmatch(name); m != nil {
+				err = core.ListSpecs(ctx, client, m, filter, func(spec *rpc.ApiSpec) {
+					taskQueue <- &computeBreakingChangesTask{
+						client:      client,
+						specName:    spec.Name,
+						withVersion: compare == "version",
+					}
+				})
+				if err != nil {
+					log.Fatalf("%s", err.Error())
+				}
+			}
+			close(taskQueue)
+			core.WaitGroup().Wait()
+		},
+	}
+
+	cmd.Flags().StringVar(&compare, "compare", "revision", "Compare each spec with its previous \"revision\" or with the previous \"version\" of its API")
+	return cmd
+}
+
+type computeBreakingChangesTask struct {
+	client      connection.Client
+	specName    string
+	withVersion bool
+}
+
+func (task *computeBreakingChangesTask) String() string {
+	return "compute breaking-changes " + task.specName
+}
+
+func (task *computeBreakingChangesTask) Run(ctx context.Context) error {
+	relation := "breaking-changes"
+	log.Printf("computing %s/artifacts/%s", task.specName, relation)
+
+	var (
+		changes *rpc.DiffApiSpecRevisionsResponse
+		err     error
+	)
+	if task.withVersion {
+		changes, err = task.diffWithPreviousVersion(ctx)
+	} else {
+		changes, err = task.client.DiffApiSpecRevisions(ctx, &rpc.DiffApiSpecRevisionsRequest{
+			Name: task.specName,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	breakingChanges, err := diff.Classify(changes)
+	if err != nil {
+		return err
+	}
+
+	messageData, err := proto.Marshal(breakingChanges)
+	if err != nil {
+		return err
+	}
+	artifact := &rpc.Artifact{
+		Name:     task.specName + "/artifacts/" + relation,
+		MimeType: core.MimeTypeForMessageType("google.cloud.apigee.registry.applications.v1alpha1.BreakingChanges"),
+		Contents: messageData,
+	}
+	return core.SetArtifact(ctx, task.client, artifact)
+}
+
+// diffWithPreviousVersion compares the spec with the spec of the same name in the
+// version of its API that was created immediately before the spec's version.
+func (task *computeBreakingChangesTask) diffWithPreviousVersion(ctx context.Context) (*rpc.DiffApiSpecRevisionsResponse, error) {
+	name, err := names.ParseSpec(task.specName)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{
+		Name: name.Version().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *rpc.ApiVersion
+	segments := []string{"", name.ProjectID, name.ApiID}
+	err = core.ListVersions(ctx, task.client, segments, "", func(v *rpc.ApiVersion) {
+		if !v.GetCreateTime().AsTime().Before(version.GetCreateTime().AsTime()) {
+			return
+		}
+		if previous == nil || v.GetCreateTime().AsTime().After(previous.GetCreateTime().AsTime()) {
+			previous = v
+		}
+	})
+	if err != nil {
+		return nil, err
+	} else if previous == nil {
+		return nil, fmt.Errorf("%s has no previous version", name.Version())
+	}
+
+	base, err := specDescription(ctx, task.client, previous.GetName()+"/specs/"+name.SpecID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := specDescription(ctx, task.client, task.specName)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Diff(base, revision)
+}
+
+func specDescription(ctx context.Context, client connection.Client, name string) (diff.Description, error) {
+	spec, err := client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: name})
+	if err != nil {
+		return diff.Description{}, err
+	}
+
+	contents, err := client.GetApiSpecContents(ctx, &rpc.GetApiSpecContentsRequest{
+		Name: fmt.Sprintf("%s/contents", spec.GetName()),
+	})
+	if err != nil {
+		return diff.Description{}, err
+	}
+
+	return diff.Description{
+		Name:     fmt.Sprintf("%s@%s", spec.GetName(), spec.GetRevisionId()),
+		MimeType: contents.GetContentType(),
+		Contents: contents.GetData(),
+	}, nil
+}
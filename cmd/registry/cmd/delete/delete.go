@@ -28,7 +28,11 @@ import (
 )
 
 func Command(ctx context.Context) *cobra.Command {
-	var filter string
+	var (
+		filter         string
+		pruneRevisions bool
+		dryRun         bool
+	)
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete resources from the API Registry",
@@ -48,7 +52,13 @@ func Command(ctx context.Context) *cobra.Command {
 				go core.Worker(ctx, taskQueue)
 			}
 
-			err = matchAndHandleDeleteCmd(ctx, client, taskQueue, args[0], filter)
+			if pruneRevisions {
+				err = matchAndHandlePruneCmd(ctx, client, taskQueue, args[0], filter, dryRun)
+			} else if dryRun {
+				log.Fatalf("--dry-run is only supported with --prune-revisions")
+			} else {
+				err = matchAndHandleDeleteCmd(ctx, client, taskQueue, args[0], filter)
+			}
 			if err != nil {
 				log.Fatalf("%s", err.Error())
 			}
@@ -59,6 +69,8 @@ func Command(ctx context.Context) *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&filter, "filter", "", "Filter selected resources")
+	cmd.Flags().BoolVar(&pruneRevisions, "prune-revisions", false, "Delete the revisions of selected specs that aren't kept by their retention policies")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "With --prune-revisions, list the revisions that would be deleted without deleting them")
 	return cmd
 }
 
@@ -81,6 +93,8 @@ func (task *deleteTask) Run(ctx context.Context) error {
 		return task.client.DeleteApiVersion(ctx, &rpc.DeleteApiVersionRequest{Name: task.resourceName})
 	case "spec":
 		return task.client.DeleteApiSpec(ctx, &rpc.DeleteApiSpecRequest{Name: task.resourceName})
+	case "deployment":
+		return task.client.DeleteApiDeployment(ctx, &rpc.DeleteApiDeploymentRequest{Name: task.resourceName})
 	case "artifact":
 		return task.client.DeleteArtifact(ctx, &rpc.DeleteArtifactRequest{Name: task.resourceName})
 	default:
@@ -101,6 +115,8 @@ func matchAndHandleDeleteCmd(
 		return deleteVersions(ctx, client, m, filter, taskQueue)
 	} else if m := names.SpecRegexp().FindStringSubmatch(name); m != nil {
 		return deleteSpecs(ctx, client, m, filter, taskQueue)
+	} else if m := names.DeploymentRegexp().FindStringSubmatch(name); m != nil {
+		return deleteDeployments(ctx, client, m, filter, taskQueue)
 	} else if m := names.ArtifactRegexp().FindStringSubmatch(name); m != nil {
 		return deleteArtifacts(ctx, client, m, filter, taskQueue)
 	} else {
@@ -153,6 +169,21 @@ func deleteSpecs(
 	})
 }
 
+func deleteDeployments(
+	ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	filterFlag string,
+	taskQueue chan core.Task) error {
+	return core.ListDeployments(ctx, client, segments, filterFlag, func(deployment *rpc.ApiDeployment) {
+		taskQueue <- &deleteTask{
+			client:       client,
+			resourceName: deployment.Name,
+			resourceKind: "deployment",
+		}
+	})
+}
+
 func deleteArtifacts(
 	ctx context.Context,
 	client *gapic.RegistryClient,
@@ -167,3 +198,54 @@ func deleteArtifacts(
 		}
 	})
 }
+
+type pruneTask struct {
+	client   connection.Client
+	specName string
+	dryRun   bool
+}
+
+func (task *pruneTask) String() string {
+	return "prune " + task.specName
+}
+
+func (task *pruneTask) Run(ctx context.Context) error {
+	resp, err := task.client.PruneApiSpecRevisions(ctx, &rpc.PruneApiSpecRevisionsRequest{
+		Name:         task.specName,
+		ValidateOnly: task.dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range resp.GetRevisionNames() {
+		if task.dryRun {
+			log.Printf("would delete revision %s", name)
+		} else {
+			log.Printf("deleted revision %s", name)
+		}
+	}
+	return nil
+}
+
+func matchAndHandlePruneCmd(
+	ctx context.Context,
+	client connection.Client,
+	taskQueue chan core.Task,
+	name string,
+	filter string,
+	dryRun bool,
+) error {
+	m := names.SpecRegexp().FindStringSubmatch(name)
+	if m == nil {
+		return fmt.Errorf("unsupported resource name: revisions can only be pruned for specs")
+	}
+
+	return core.ListSpecs(ctx, client, m, filter, func(spec *rpc.ApiSpec) {
+		taskQueue <- &pruneTask{
+			client:   client,
+			specName: spec.Name,
+			dryRun:   dryRun,
+		}
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"log"
+
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/rpc"
+	"github.com/spf13/cobra"
+)
+
+func Command(ctx context.Context) *cobra.Command {
+	var (
+		base    string
+		jsonOut bool
+	)
+	cmd := &cobra.Command{
+		Use:   "diff SPEC[@REVISION]",
+		Short: "Compare a spec revision with an earlier revision",
+		Long: "Compare a spec revision with an earlier revision. OpenAPI and Protocol Buffer specs are compared\n" +
+			"structurally and other specs are compared as text. By default, the revision is compared with the\n" +
+			"revision that was created immediately before it.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := connection.NewClient(ctx)
+			if err != nil {
+				log.Fatalf("%s", err.Error())
+			}
+
+			diff, err := client.DiffApiSpecRevisions(ctx, &rpc.DiffApiSpecRevisionsRequest{
+				Name:           args[0],
+				BaseRevisionId: base,
+			})
+			if err != nil {
+				log.Fatalf("%s", err.Error())
+			}
+
+			if jsonOut {
+				core.PrintMessage(diff)
+			} else {
+				core.PrintSpecDiff(diff)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&base, "base", "", "Revision ID or tag of the revision to compare with")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print the changes as JSON")
+	return cmd
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/server/names"
+	"github.com/spf13/cobra"
+)
+
+func archiveCommand(ctx context.Context) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "archive PROJECT [--output FILE]",
+		Short: "Export a project with all of its revisions and contents to an archive",
+		Long: "Export a project with all of its revisions and contents to an archive, " +
+			"which is a gzipped tar file that can be imported with \"registry upload archive\".",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected one project argument, got %d", len(args))
+			} else if re := names.ProjectRegexp(); !re.MatchString(args[0]) {
+				return fmt.Errorf("invalid project argument %q: must match %q", args[0], re)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			client, err := connection.NewClient(ctx)
+			if err != nil {
+				log.Fatalf("Failed to create client: %s", err)
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					log.Fatalf("Failed to create %s: %s", output, err)
+				}
+				defer file.Close()
+				w = file
+			}
+
+			if err := core.ExportProject(ctx, client, args[0], w); err != nil {
+				log.Fatalf("Failed to export %s: %s", args[0], err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "File to write the archive to, instead of standard output")
+	return cmd
+}
@@ -44,9 +44,12 @@ func TestExportCSV(t *testing.T) {
 	)
 
 	// Setup
-	err = client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
+	op, err := client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
 		Name: "projects/" + projectID,
 	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
 	if err != nil && status.Code(err) != codes.NotFound {
 		t.Fatalf("Setup: Failed to delete test project: %s", err)
 	}
@@ -26,6 +26,7 @@ func Command(ctx context.Context) *cobra.Command {
 		Short: "Export resources from the API Registry",
 	}
 
+	cmd.AddCommand(archiveCommand(ctx))
 	cmd.AddCommand(csvCommand(ctx))
 	cmd.AddCommand(sheetCommand(ctx))
 	cmd.AddCommand(yamlCommand(ctx))
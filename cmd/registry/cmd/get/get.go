@@ -54,6 +54,8 @@ func Command(ctx context.Context) *cobra.Command {
 				} else {
 					_, err = core.GetSpec(ctx, client, m, getContents, core.PrintSpecDetail)
 				}
+			} else if m := names.DeploymentRegexp().FindStringSubmatch(name); m != nil {
+				_, err = core.GetDeployment(ctx, client, m, core.PrintDeploymentDetail)
 			} else if m := names.ArtifactRegexp().FindStringSubmatch(name); m != nil {
 				if getContents {
 					_, err = core.GetArtifact(ctx, client, m, getContents, core.PrintArtifactContents)
@@ -101,6 +101,8 @@ func matchAndHandleLabelCmd(
 		return labelVersions(ctx, client, m, filter, labeling, taskQueue)
 	} else if m := names.SpecsRegexp().FindStringSubmatch(name); m != nil {
 		return labelSpecs(ctx, client, m, filter, labeling, taskQueue)
+	} else if m := names.DeploymentsRegexp().FindStringSubmatch(name); m != nil {
+		return labelDeployments(ctx, client, m, filter, labeling, taskQueue)
 	}
 
 	// Then try to match resource names.
@@ -110,6 +112,8 @@ func matchAndHandleLabelCmd(
 		return labelVersions(ctx, client, m, filter, labeling, taskQueue)
 	} else if m := names.SpecRegexp().FindStringSubmatch(name); m != nil {
 		return labelSpecs(ctx, client, m, filter, labeling, taskQueue)
+	} else if m := names.DeploymentRegexp().FindStringSubmatch(name); m != nil {
+		return labelDeployments(ctx, client, m, filter, labeling, taskQueue)
 	} else {
 		return fmt.Errorf("unsupported resource name %s", name)
 	}
@@ -162,6 +166,22 @@ func labelSpecs(
 	})
 }
 
+func labelDeployments(
+	ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	filterFlag string,
+	labeling *core.Labeling,
+	taskQueue chan<- core.Task) error {
+	return core.ListDeployments(ctx, client, segments, filterFlag, func(deployment *rpc.ApiDeployment) {
+		taskQueue <- &labelDeploymentTask{
+			client:     client,
+			deployment: deployment,
+			labeling:   labeling,
+		}
+	})
+}
+
 type labelApiTask struct {
 	client   connection.Client
 	api      *rpc.Api
@@ -173,19 +193,24 @@ func (task *labelApiTask) String() string {
 }
 
 func (task *labelApiTask) Run(ctx context.Context) error {
-	var err error
-	task.api.Labels, err = task.labeling.Apply(task.api.Labels)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.api.Labels, err = task.labeling.Apply(task.api.Labels)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApi(ctx,
+			&rpc.UpdateApiRequest{
+				Api: task.api,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"labels"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApi(ctx,
-		&rpc.UpdateApiRequest{
-			Api: task.api,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"labels"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.api, err = task.client.GetApi(ctx, &rpc.GetApiRequest{Name: task.api.Name})
+		return err
+	})
 }
 
 type labelVersionTask struct {
@@ -199,19 +224,24 @@ func (task *labelVersionTask) String() string {
 }
 
 func (task *labelVersionTask) Run(ctx context.Context) error {
-	var err error
-	task.version.Labels, err = task.labeling.Apply(task.version.Labels)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.version.Labels, err = task.labeling.Apply(task.version.Labels)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiVersion(ctx,
+			&rpc.UpdateApiVersionRequest{
+				ApiVersion: task.version,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"labels"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApiVersion(ctx,
-		&rpc.UpdateApiVersionRequest{
-			ApiVersion: task.version,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"labels"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.version, err = task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{Name: task.version.Name})
+		return err
+	})
 }
 
 type labelSpecTask struct {
@@ -225,17 +255,53 @@ func (task *labelSpecTask) String() string {
 }
 
 func (task *labelSpecTask) Run(ctx context.Context) error {
-	var err error
-	task.spec.Labels, err = task.labeling.Apply(task.spec.Labels)
-	if err != nil {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.spec.Labels, err = task.labeling.Apply(task.spec.Labels)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiSpec(ctx,
+			&rpc.UpdateApiSpecRequest{
+				ApiSpec: task.spec,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"labels"},
+				},
+			})
 		return err
-	}
-	_, err = task.client.UpdateApiSpec(ctx,
-		&rpc.UpdateApiSpecRequest{
-			ApiSpec: task.spec,
-			UpdateMask: &field_mask.FieldMask{
-				Paths: []string{"labels"},
-			},
-		})
-	return err
+	}, func() (err error) {
+		task.spec, err = task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: task.spec.Name})
+		return err
+	})
+}
+
+type labelDeploymentTask struct {
+	client     connection.Client
+	deployment *rpc.ApiDeployment
+	labeling   *core.Labeling
+}
+
+func (task *labelDeploymentTask) String() string {
+	return "label " + task.deployment.Name
+}
+
+func (task *labelDeploymentTask) Run(ctx context.Context) error {
+	return core.RetryOnConflict(func() error {
+		var err error
+		task.deployment.Labels, err = task.labeling.Apply(task.deployment.Labels)
+		if err != nil {
+			return err
+		}
+		_, err = task.client.UpdateApiDeployment(ctx,
+			&rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: task.deployment,
+				UpdateMask: &field_mask.FieldMask{
+					Paths: []string{"labels"},
+				},
+			})
+		return err
+	}, func() (err error) {
+		task.deployment, err = task.client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: task.deployment.Name})
+		return err
+	})
 }
@@ -45,9 +45,12 @@ func TestLabel(t *testing.T) {
 	}
 	defer registryClient.Close()
 	// Clear the test project.
-	err = registryClient.DeleteProject(ctx, &rpc.DeleteProjectRequest{
+	op, err := registryClient.DeleteProject(ctx, &rpc.DeleteProjectRequest{
 		Name: projectName,
 	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
 	if err != nil && status.Code(err) != codes.NotFound {
 		t.Fatalf("error deleting test project: %+v", err)
 	}
@@ -168,7 +171,10 @@ func TestLabel(t *testing.T) {
 		req := &rpc.DeleteProjectRequest{
 			Name: projectName,
 		}
-		err = registryClient.DeleteProject(ctx, req)
+		op, err := registryClient.DeleteProject(ctx, req)
+		if err == nil {
+			err = op.Wait(ctx)
+		}
 		if err != nil {
 			t.Fatalf("failed to delete test project: %s", err)
 		}
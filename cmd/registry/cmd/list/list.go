@@ -64,6 +64,8 @@ func matchAndHandleListCmd(
 		return core.ListVersions(ctx, client, m, filter, core.PrintVersion)
 	} else if m := names.SpecsRegexp().FindStringSubmatch(name); m != nil {
 		return core.ListSpecs(ctx, client, m, filter, core.PrintSpec)
+	} else if m := names.DeploymentsRegexp().FindStringSubmatch(name); m != nil {
+		return core.ListDeployments(ctx, client, m, filter, core.PrintDeployment)
 	} else if m := names.ArtifactsRegexp().FindStringSubmatch(name); m != nil {
 		return core.ListArtifacts(ctx, client, m, filter, false, core.PrintArtifact)
 	}
@@ -77,6 +79,8 @@ func matchAndHandleListCmd(
 		return core.ListVersions(ctx, client, m, filter, core.PrintVersion)
 	} else if m := names.SpecRegexp().FindStringSubmatch(name); m != nil {
 		return core.ListSpecs(ctx, client, m, filter, core.PrintSpec)
+	} else if m := names.DeploymentRegexp().FindStringSubmatch(name); m != nil {
+		return core.ListDeployments(ctx, client, m, filter, core.PrintDeployment)
 	} else if m := names.ArtifactRegexp().FindStringSubmatch(name); m != nil {
 		return core.ListArtifacts(ctx, client, m, filter, false, core.PrintArtifact)
 	}
@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package move
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/names"
+	"github.com/spf13/cobra"
+)
+
+func Command(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "move API DESTINATION",
+		Short: "Move an API with all of its versions, specs, deployments and artifacts",
+		Long: "Move an API with all of its versions, specs, deployments and artifacts to a new name, " +
+			"which may be in another project. Resource names in artifacts with known message types are changed.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("expected API and DESTINATION arguments, got %d arguments", len(args))
+			}
+			for _, arg := range args {
+				if re := names.ApiRegexp(); !re.MatchString(arg) {
+					return fmt.Errorf("invalid API argument %q: must match %q", arg, re)
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			client, err := connection.NewClient(ctx)
+			if err != nil {
+				log.Fatalf("Failed to create client: %s", err)
+			}
+
+			req := &rpc.MoveApiRequest{
+				Name:        args[0],
+				Destination: args[1],
+				RequestId:   core.NewRequestID(),
+			}
+			var api *rpc.Api
+			if err := core.RetryRequest(ctx, func() (err error) {
+				api, err = client.MoveApi(ctx, req)
+				return err
+			}); err != nil {
+				log.Fatalf("Failed to move %s: %s", args[0], err)
+			}
+			log.Printf("Moved %s to %s", args[0], api.GetName())
+		},
+	}
+	return cmd
+}
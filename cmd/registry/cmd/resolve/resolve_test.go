@@ -57,9 +57,12 @@ func TestResolve(t *testing.T) {
 
 	testProject := "controller-demo"
 
-	err = client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
+	op, err := client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
 		Name: "projects/" + testProject,
 	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
 	if err != nil && status.Code(err) != codes.NotFound {
 		t.Fatalf("Setup: Failed to delete test project: %s", err)
 	}
@@ -223,9 +226,12 @@ func TestResolve(t *testing.T) {
 	}
 
 	// Delete the demo project
-	err = client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
+	op, err = client.DeleteProject(ctx, &rpc.DeleteProjectRequest{
 		Name: "projects/" + testProject,
 	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
 	if err != nil && status.Code(err) != codes.NotFound {
 		t.Fatalf("Setup: Failed to delete test project: %s", err)
 	}
@@ -19,12 +19,15 @@ import (
 
 	"github.com/apigee/registry/cmd/registry/cmd/annotate"
 	"github.com/apigee/registry/cmd/registry/cmd/compute"
+	"github.com/apigee/registry/cmd/registry/cmd/copy"
 	"github.com/apigee/registry/cmd/registry/cmd/delete"
+	"github.com/apigee/registry/cmd/registry/cmd/diff"
 	"github.com/apigee/registry/cmd/registry/cmd/export"
 	"github.com/apigee/registry/cmd/registry/cmd/get"
 	"github.com/apigee/registry/cmd/registry/cmd/index"
 	"github.com/apigee/registry/cmd/registry/cmd/label"
 	"github.com/apigee/registry/cmd/registry/cmd/list"
+	"github.com/apigee/registry/cmd/registry/cmd/move"
 	"github.com/apigee/registry/cmd/registry/cmd/resolve"
 	"github.com/apigee/registry/cmd/registry/cmd/search"
 	"github.com/apigee/registry/cmd/registry/cmd/upload"
@@ -40,13 +43,16 @@ func Command(ctx context.Context) *cobra.Command {
 
 	cmd.AddCommand(annotate.Command(ctx))
 	cmd.AddCommand(compute.Command(ctx))
+	cmd.AddCommand(copy.Command(ctx))
 	cmd.AddCommand(resolve.Command(ctx))
 	cmd.AddCommand(delete.Command(ctx))
+	cmd.AddCommand(diff.Command(ctx))
 	cmd.AddCommand(export.Command(ctx))
 	cmd.AddCommand(get.Command(ctx))
 	cmd.AddCommand(index.Command(ctx))
 	cmd.AddCommand(label.Command(ctx))
 	cmd.AddCommand(list.Command(ctx))
+	cmd.AddCommand(move.Command(ctx))
 	cmd.AddCommand(search.Command(ctx))
 	cmd.AddCommand(upload.Command(ctx))
 	cmd.AddCommand(vocabulary.Command(ctx))
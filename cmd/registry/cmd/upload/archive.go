@@ -0,0 +1,54 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/spf13/cobra"
+)
+
+func archiveCommand(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "archive FILE",
+		Short: "Create a project from an archive that was exported with \"registry export archive\"",
+		Long: "Create a project from an archive that was exported with \"registry export archive\". " +
+			"The project must not already exist, and it is restored with its original revision ids and timestamps.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			file, err := os.Open(args[0])
+			if err != nil {
+				log.Fatalf("Failed to open %s: %s", args[0], err)
+			}
+			defer file.Close()
+
+			ctx := context.Background()
+			client, err := connection.NewClient(ctx)
+			if err != nil {
+				log.Fatalf("Failed to create client: %s", err)
+			}
+
+			project, err := core.ImportProject(ctx, client, file)
+			if err != nil {
+				log.Fatalf("Failed to import %s: %s", args[0], err)
+			}
+			log.Printf("Imported %s", project.GetName())
+		},
+	}
+}
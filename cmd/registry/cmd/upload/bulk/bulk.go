@@ -16,7 +16,11 @@ package bulk
 
 import (
 	"context"
+	"log"
 
+	"github.com/apigee/registry/cmd/registry/core"
+	"github.com/apigee/registry/connection"
+	"github.com/apigee/registry/rpc"
 	"github.com/spf13/cobra"
 )
 
@@ -34,3 +38,50 @@ func Command(ctx context.Context) *cobra.Command {
 	cmd.MarkFlagRequired("project_id")
 	return cmd
 }
+
+// uploadSpec creates or updates a spec and its parent API and version with one request for each.
+// Existing resources are updated, and a new spec revision is created when the contents change.
+// Failures are logged so that other uploads can continue.
+func uploadSpec(ctx context.Context, client connection.Client, api *rpc.Api, version *rpc.ApiVersion, spec *rpc.ApiSpec) {
+	// Requests have IDs so that they can be retried without repeating requests that were completed.
+	apiRequest := &rpc.UpdateApiRequest{
+		Api:          api,
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	if err := core.RetryRequest(ctx, func() error {
+		_, err := client.UpdateApi(ctx, apiRequest)
+		return err
+	}); err != nil {
+		log.Printf("error %s: %s", api.GetName(), err.Error())
+		return
+	}
+
+	versionRequest := &rpc.UpdateApiVersionRequest{
+		ApiVersion:   version,
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	if err := core.RetryRequest(ctx, func() error {
+		_, err := client.UpdateApiVersion(ctx, versionRequest)
+		return err
+	}); err != nil {
+		log.Printf("error %s: %s", version.GetName(), err.Error())
+		return
+	}
+
+	specRequest := &rpc.UpdateApiSpecRequest{
+		ApiSpec:      spec,
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	var response *rpc.ApiSpec
+	if err := core.RetryRequest(ctx, func() (err error) {
+		response, err = client.UpdateApiSpec(ctx, specRequest)
+		return err
+	}); err != nil {
+		log.Printf("error %s: %s [contents-length: %d]", spec.GetName(), err.Error(), len(spec.GetContents()))
+	} else {
+		log.Printf("uploaded %s@%s", response.GetName(), response.GetRevisionId())
+	}
+}
@@ -23,9 +23,7 @@ import (
 	"github.com/apigee/registry/connection"
 	"github.com/apigee/registry/rpc"
 	discovery "github.com/googleapis/gnostic/discovery"
-	"github.com/nsf/jsondiff"
 	"github.com/spf13/cobra"
-	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func discoveryCommand(ctx context.Context) *cobra.Command {
@@ -91,138 +89,26 @@ func (task *uploadDiscoveryTask) String() string {
 
 func (task *uploadDiscoveryTask) Run(ctx context.Context) error {
 	log.Printf("^^ apis/%s/versions/%s/specs/%s", task.apiID, task.versionID, task.specID)
-	// If the API does not exist, create it.
-	if err := task.createAPI(ctx); err != nil {
-		return err
-	}
-	// If the API version does not exist, create it.
-	if err := task.createVersion(ctx); err != nil {
-		return err
-	}
-	// If the API spec does not exist, create it.
-	if err := task.createSpec(ctx); err != nil {
-		return err
-	}
-	// If the API spec needs a new revision, create it.
-	return task.updateSpec(ctx)
-}
-
-func (task *uploadDiscoveryTask) createAPI(ctx context.Context) error {
-	if _, err := task.client.GetApi(ctx, &rpc.GetApiRequest{
-		Name: task.apiName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when API is found without error.
-	}
-
-	response, err := task.client.CreateApi(ctx, &rpc.CreateApiRequest{
-		Parent: task.projectName(),
-		ApiId:  task.apiID,
-		Api: &rpc.Api{
-			DisplayName: task.apiID,
-		},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.apiName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadDiscoveryTask) createVersion(ctx context.Context) error {
-	if _, err := task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{
-		Name: task.versionName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when version is found without error.
-	}
-
-	response, err := task.client.CreateApiVersion(ctx, &rpc.CreateApiVersionRequest{
-		Parent:       task.apiName(),
-		ApiVersionId: task.versionID,
-		ApiVersion:   &rpc.ApiVersion{},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.versionName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadDiscoveryTask) createSpec(ctx context.Context) error {
 	contents, err := task.gzipContents()
 	if err != nil {
 		return err
 	}
 
-	if _, err := task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when spec is found without error.
-	}
-
-	response, err := task.client.CreateApiSpec(ctx, &rpc.CreateApiSpecRequest{
-		Parent:    task.versionName(),
-		ApiSpecId: task.specID,
-		ApiSpec: &rpc.ApiSpec{
+	uploadSpec(ctx, task.client,
+		&rpc.Api{
+			Name:        task.apiName(),
+			DisplayName: task.apiID,
+		},
+		&rpc.ApiVersion{
+			Name: task.versionName(),
+		},
+		&rpc.ApiSpec{
+			Name:      task.specName(),
 			MimeType:  core.DiscoveryMimeType("+gzip"),
 			Filename:  "discovery.json",
 			Contents:  contents,
 			SourceUri: task.path,
-		},
-	})
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", task.specName(), err.Error(), len(contents))
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadDiscoveryTask) updateSpec(ctx context.Context) error {
-	refSpec, err := task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	})
-	if err != nil && !core.NotFound(err) {
-		return err
-	}
-
-	refBytes, err := core.GetBytesForSpec(ctx, task.client, refSpec)
-	if err != nil {
-		return nil
-	}
-
-	docBytes, err := discovery.FetchDocumentBytes(task.path)
-	if err != nil {
-		return err
-	}
-
-	opts := jsondiff.DefaultJSONOptions()
-	if diff, _ := jsondiff.Compare(refBytes, docBytes, &opts); diff == jsondiff.FullMatch {
-		return nil
-	}
-
-	docZipped, err := core.GZippedBytes(docBytes)
-	if err != nil {
-		return err
-	}
-
-	response, err := task.client.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
-		ApiSpec: &rpc.ApiSpec{
-			Name:     task.specName(),
-			Contents: docZipped,
-		},
-		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"contents"}},
-	})
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", task.specName(), err.Error(), len(docZipped))
-	} else if response.RevisionId != refSpec.RevisionId {
-		log.Printf("updated %s", response.Name)
-	}
-
+		})
 	return nil
 }
 
@@ -28,7 +28,6 @@ import (
 	"github.com/apigee/registry/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/spf13/cobra"
-	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func openAPICommand(ctx context.Context) *cobra.Command {
@@ -141,21 +140,31 @@ func (task *uploadOpenAPITask) Run(ctx context.Context) error {
 		return err
 	}
 	log.Printf("^^ apis/%s/versions/%s/specs/%s", task.apiID, task.versionID, task.specID)
-
-	// If the API does not exist, create it.
-	if err := task.createAPI(ctx); err != nil {
+	contents, err := task.gzipContents()
+	if err != nil {
 		return err
 	}
-	// If the API version does not exist, create it.
-	if err := task.createVersion(ctx); err != nil {
-		return err
+
+	spec := &rpc.ApiSpec{
+		Name:     task.specName(),
+		MimeType: core.OpenAPIMimeType("+gzip", task.version),
+		Filename: task.fileName(),
+		Contents: contents,
 	}
-	// If the API spec does not exist, create it.
-	if err := task.createSpec(ctx); err != nil {
-		return err
+	if task.baseURI != "" {
+		spec.SourceUri = fmt.Sprintf("%s/%s", task.baseURI, task.apiPath())
 	}
-	// If the API spec needs a new revision, create it.
-	return task.updateSpec(ctx)
+
+	uploadSpec(ctx, task.client,
+		&rpc.Api{
+			Name:        task.apiName(),
+			DisplayName: task.apiID,
+		},
+		&rpc.ApiVersion{
+			Name: task.versionName(),
+		},
+		spec)
+	return nil
 }
 
 func (task *uploadOpenAPITask) populateFields() error {
@@ -177,116 +186,6 @@ func (task *uploadOpenAPITask) populateFields() error {
 	return nil
 }
 
-func (task *uploadOpenAPITask) createAPI(ctx context.Context) error {
-	if _, err := task.client.GetApi(ctx, &rpc.GetApiRequest{
-		Name: task.apiName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when API is found without error.
-	}
-
-	response, err := task.client.CreateApi(ctx, &rpc.CreateApiRequest{
-		Parent: task.projectName(),
-		ApiId:  task.apiID,
-		Api: &rpc.Api{
-			DisplayName: task.apiID,
-		},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.apiName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadOpenAPITask) createVersion(ctx context.Context) error {
-	if _, err := task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{
-		Name: task.versionName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when version is found without error.
-	}
-
-	response, err := task.client.CreateApiVersion(ctx, &rpc.CreateApiVersionRequest{
-		Parent:       task.apiName(),
-		ApiVersionId: task.versionID,
-		ApiVersion:   &rpc.ApiVersion{},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.versionName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadOpenAPITask) createSpec(ctx context.Context) error {
-	contents, err := task.gzipContents()
-	if err != nil {
-		return err
-	}
-
-	if _, err = task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when spec is found without error.
-	}
-
-	request := &rpc.CreateApiSpecRequest{
-		Parent:    task.versionName(),
-		ApiSpecId: task.fileName(),
-		ApiSpec: &rpc.ApiSpec{
-			MimeType: core.OpenAPIMimeType("+gzip", task.version),
-			Filename: task.fileName(),
-			Contents: contents,
-		},
-	}
-	if task.baseURI != "" {
-		request.ApiSpec.SourceUri = fmt.Sprintf("%s/%s", task.baseURI, task.apiPath())
-	}
-
-	response, err := task.client.CreateApiSpec(ctx, request)
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", task.specName(), err.Error(), len(contents))
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadOpenAPITask) updateSpec(ctx context.Context) error {
-	contents, err := task.gzipContents()
-	if err != nil {
-		return err
-	}
-
-	spec, err := task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	})
-	if err != nil && !core.NotFound(err) {
-		return err
-	}
-
-	request := &rpc.UpdateApiSpecRequest{
-		ApiSpec: &rpc.ApiSpec{
-			Name:     task.specName(),
-			Contents: contents,
-		},
-		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"contents"}},
-	}
-
-	response, err := task.client.UpdateApiSpec(ctx, request)
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", request.ApiSpec.Name, err.Error(), len(contents))
-	} else if response.RevisionId != spec.RevisionId {
-		log.Printf("updated %s", response.Name)
-	}
-
-	return nil
-}
-
 func (task *uploadOpenAPITask) projectName() string {
 	return fmt.Sprintf("projects/%s", task.projectID)
 }
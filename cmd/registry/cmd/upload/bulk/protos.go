@@ -28,7 +28,6 @@ import (
 	"github.com/apigee/registry/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/spf13/cobra"
-	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func protosCommand(ctx context.Context) *cobra.Command {
@@ -114,138 +113,39 @@ func (task *uploadProtoTask) Run(ctx context.Context) error {
 	// Populate API path fields using the file's path.
 	task.populateFields()
 	log.Printf("^^ apis/%s/versions/%s/specs/%s", task.apiID, task.versionID, task.specID)
-
-	// If the API does not exist, create it.
-	if err := task.createAPI(ctx); err != nil {
-		return err
-	}
-	// If the API version does not exist, create it.
-	if err := task.createVersion(ctx); err != nil {
-		return err
-	}
-	// If the API spec does not exist, create it.
-	if err := task.createSpec(ctx); err != nil {
-		return err
-	}
-	// If the API spec needs a new revision, create it.
-	return task.updateSpec(ctx)
-}
-
-func (task *uploadProtoTask) populateFields() {
-	parts := strings.Split(task.apiPath(), "/")
-	apiParts := parts[0 : len(parts)-1]
-
-	task.apiID = strings.ReplaceAll(strings.Join(apiParts, "-"), "/", "-")
-	task.versionID = parts[len(parts)-1]
-	task.specID = task.fileName()
-}
-
-func (task *uploadProtoTask) createAPI(ctx context.Context) error {
-	if _, err := task.client.GetApi(ctx, &rpc.GetApiRequest{
-		Name: task.apiName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when API is found without error.
-	}
-
-	response, err := task.client.CreateApi(ctx, &rpc.CreateApiRequest{
-		Parent: task.projectName(),
-		ApiId:  task.apiID,
-		Api:    &rpc.Api{},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.apiName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadProtoTask) createVersion(ctx context.Context) error {
-	if _, err := task.client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{
-		Name: task.versionName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when version is found without error.
-	}
-
-	response, err := task.client.CreateApiVersion(ctx, &rpc.CreateApiVersionRequest{
-		Parent:       task.apiName(),
-		ApiVersionId: task.versionID,
-		ApiVersion:   &rpc.ApiVersion{},
-	})
-	if err != nil {
-		log.Printf("error %s: %s", task.versionName(), err.Error())
-	} else {
-		log.Printf("created %s", response.Name)
-	}
-
-	return nil
-}
-
-func (task *uploadProtoTask) createSpec(ctx context.Context) error {
 	contents, err := task.zipContents()
 	if err != nil {
 		return err
 	}
 
-	if _, err := task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	}); !core.NotFound(err) {
-		return err // Returns nil when spec is found without error.
-	}
-
-	request := &rpc.CreateApiSpecRequest{
-		Parent:    task.versionName(),
-		ApiSpecId: task.fileName(),
-		ApiSpec: &rpc.ApiSpec{
-			MimeType: core.ProtobufMimeType("+zip"),
-			Filename: task.fileName(),
-			Contents: contents,
-		},
+	spec := &rpc.ApiSpec{
+		Name:     task.specName(),
+		MimeType: core.ProtobufMimeType("+zip"),
+		Filename: task.fileName(),
+		Contents: contents,
 	}
 	if task.baseURI != "" {
-		request.ApiSpec.SourceUri = fmt.Sprintf("%s/%s", task.baseURI, task.apiPath())
-	}
-
-	response, err := task.client.CreateApiSpec(ctx, request)
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", task.specName(), err.Error(), len(contents))
-	} else {
-		log.Printf("created %s", response.Name)
+		spec.SourceUri = fmt.Sprintf("%s/%s", task.baseURI, task.apiPath())
 	}
 
+	uploadSpec(ctx, task.client,
+		&rpc.Api{
+			Name: task.apiName(),
+		},
+		&rpc.ApiVersion{
+			Name: task.versionName(),
+		},
+		spec)
 	return nil
 }
 
-func (task *uploadProtoTask) updateSpec(ctx context.Context) error {
-	contents, err := task.zipContents()
-	if err != nil {
-		return err
-	}
-
-	spec, err := task.client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
-		Name: task.specName(),
-	})
-	if err != nil && !core.NotFound(err) {
-		return err
-	}
-
-	request := &rpc.UpdateApiSpecRequest{
-		ApiSpec: &rpc.ApiSpec{
-			Name:     task.specName(),
-			Contents: contents,
-		},
-		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"contents"}},
-	}
-
-	response, err := task.client.UpdateApiSpec(ctx, request)
-	if err != nil {
-		log.Printf("error %s: %s [contents-length: %d]", request.ApiSpec.Name, err.Error(), len(contents))
-	} else if response.RevisionId != spec.RevisionId {
-		log.Printf("updated %s", response.Name)
-	}
+func (task *uploadProtoTask) populateFields() {
+	parts := strings.Split(task.apiPath(), "/")
+	apiParts := parts[0 : len(parts)-1]
 
-	return nil
+	task.apiID = strings.ReplaceAll(strings.Join(apiParts, "-"), "/", "-")
+	task.versionID = parts[len(parts)-1]
+	task.specID = task.fileName()
 }
 
 func (task *uploadProtoTask) projectName() string {
@@ -28,8 +28,6 @@ import (
 	"github.com/apigee/registry/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 func csvCommand(ctx context.Context) *cobra.Command {
@@ -178,37 +176,34 @@ type uploadSpecTask struct {
 }
 
 func (t uploadSpecTask) Run(ctx context.Context) error {
-	api, err := t.client.CreateApi(ctx, &rpc.CreateApiRequest{
-		Parent: fmt.Sprintf("projects/%s", t.projectID),
-		ApiId:  t.apiID,
-		Api:    &rpc.Api{},
-	})
-
-	switch status.Code(err) {
-	case codes.OK:
-		log.Printf("Created API: %s", api.GetName())
-	case codes.AlreadyExists:
-		api = &rpc.Api{
+	// Requests have IDs so that they can be retried without repeating requests that were completed.
+	apiRequest := &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
 			Name: fmt.Sprintf("projects/%s/apis/%s", t.projectID, t.apiID),
-		}
-	default:
+		},
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	var api *rpc.Api
+	if err := core.RetryRequest(ctx, func() (err error) {
+		api, err = t.client.UpdateApi(ctx, apiRequest)
+		return err
+	}); err != nil {
 		return fmt.Errorf("failed to ensure API exists: %s", err)
 	}
 
-	version, err := t.client.CreateApiVersion(ctx, &rpc.CreateApiVersionRequest{
-		Parent:       api.GetName(),
-		ApiVersionId: t.versionID,
-		ApiVersion:   &rpc.ApiVersion{},
-	})
-
-	switch status.Code(err) {
-	case codes.OK:
-		log.Printf("Created API version: %s", version.GetName())
-	case codes.AlreadyExists:
-		version = &rpc.ApiVersion{
-			Name: fmt.Sprintf("projects/%s/apis/%s/versions/%s", t.projectID, t.apiID, t.versionID),
-		}
-	default:
+	versionRequest := &rpc.UpdateApiVersionRequest{
+		ApiVersion: &rpc.ApiVersion{
+			Name: fmt.Sprintf("%s/versions/%s", api.GetName(), t.versionID),
+		},
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	var version *rpc.ApiVersion
+	if err := core.RetryRequest(ctx, func() (err error) {
+		version, err = t.client.UpdateApiVersion(ctx, versionRequest)
+		return err
+	}); err != nil {
 		return fmt.Errorf("failed to ensure API version exists: %s", err)
 	}
 
@@ -222,25 +217,26 @@ func (t uploadSpecTask) Run(ctx context.Context) error {
 		return err
 	}
 
-	spec, err := t.client.CreateApiSpec(ctx, &rpc.CreateApiSpecRequest{
-		Parent:    version.GetName(),
-		ApiSpecId: t.specID,
+	// Specs that already exist are updated, with a new revision if their contents changed.
+	specRequest := &rpc.UpdateApiSpecRequest{
 		ApiSpec: &rpc.ApiSpec{
+			Name: fmt.Sprintf("%s/specs/%s", version.GetName(), t.specID),
 			// TODO: How do we choose a mime type?
 			MimeType: core.OpenAPIMimeType("+gzip", "3.0.0"),
 			Contents: compressed,
 		},
-	})
-
-	switch status.Code(err) {
-	case codes.OK:
-		log.Printf("Created API spec: %s", spec.GetName())
-	case codes.AlreadyExists:
-		// When the spec already exists we can silently continue.
-	default:
+		AllowMissing: true,
+		RequestId:    core.NewRequestID(),
+	}
+	var spec *rpc.ApiSpec
+	if err := core.RetryRequest(ctx, func() (err error) {
+		spec, err = t.client.UpdateApiSpec(ctx, specRequest)
+		return err
+	}); err != nil {
 		return fmt.Errorf("failed to upload API spec: %s", err)
 	}
 
+	log.Printf("Uploaded API spec: %s@%s", spec.GetName(), spec.GetRevisionId())
 	return nil
 }
 
@@ -44,7 +44,10 @@ func deleteProject(
 	req := &rpc.DeleteProjectRequest{
 		Name: "projects/" + projectID,
 	}
-	err := client.DeleteProject(ctx, req)
+	op, err := client.DeleteProject(ctx, req)
+	if err == nil {
+		err = op.Wait(ctx)
+	}
 	if err != nil && status.Code(err) != codes.NotFound {
 		t.Fatalf("Failed DeleteProject(%v): %s", req, err.Error())
 	}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/longrunning"
+	"github.com/apigee/registry/gapic"
+	rpcpb "github.com/apigee/registry/rpc"
+)
+
+// archiveChunkSize is the largest amount of archive data that is sent in a single request.
+const archiveChunkSize = 1 << 20
+
+// ExportProject writes an archive of a project to w.
+func ExportProject(ctx context.Context, client *gapic.RegistryClient, name string, w io.Writer) error {
+	stream, err := client.ExportProject(ctx, &rpcpb.ExportProjectRequest{Name: name})
+	if err != nil {
+		return err
+	}
+
+	for {
+		body, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(body.GetData()); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportProject creates a project from an archive that is read from r,
+// and waits for the import to finish.
+func ImportProject(ctx context.Context, client *gapic.RegistryClient, r io.Reader) (*rpcpb.Project, error) {
+	// The stream is cancelled if the archive can't be read, so that it isn't imported partially.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.ImportProject(streamCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// Chunks aren't reused, since messages may still be in use after they're sent.
+		buf := make([]byte, archiveChunkSize)
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if n > 0 {
+			// Failed streams return io.EOF, and their status is returned by CloseAndRecv.
+			if err := stream.Send(&rpcpb.ImportProjectRequest{Data: buf[:n]}); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if n < len(buf) {
+			break
+		}
+	}
+
+	op, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+
+	project := new(rpcpb.Project)
+	if err := longrunning.InternalNewOperation(client.LROClient, op).Wait(ctx, project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
@@ -73,6 +73,27 @@ func GetVersion(ctx context.Context,
 	return version, nil
 }
 
+func GetDeployment(ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	handler DeploymentHandler) (*rpc.ApiDeployment, error) {
+	name := "projects/" + segments[1] + "/apis/" + segments[2] + "/deployments/" + segments[3]
+	if len(segments) > 5 && segments[5] != "" {
+		name += "@" + segments[5]
+	}
+	request := &rpc.GetApiDeploymentRequest{
+		Name: name,
+	}
+	deployment, err := client.GetApiDeployment(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if handler != nil {
+		handler(deployment)
+	}
+	return deployment, nil
+}
+
 func GetSpec(ctx context.Context,
 	client *gapic.RegistryClient,
 	segments []string,
@@ -16,8 +16,15 @@ package core
 
 import (
 	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxConflictRetries is the number of times that an update is attempted
+// when it conflicts with changes made by other clients.
+const maxConflictRetries = 5
+
 // Labeling represents a user-specified change to a set of labels or annotations.
 // Note that this same structure is used for both labels and annotations.
 type Labeling struct {
@@ -48,6 +55,21 @@ func (l *Labeling) Apply(m map[string]string) (map[string]string, error) {
 	return m, nil
 }
 
+// RetryOnConflict calls update until it succeeds or fails for a reason other than a conflict.
+// Updates that conflict with changes made by other clients fail with ABORTED, and reload
+// is called to get the current state of the resource before the update is retried.
+func RetryOnConflict(update, reload func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := update()
+		if status.Code(err) != codes.Aborted || attempt == maxConflictRetries {
+			return err
+		}
+		if err := reload(); err != nil {
+			return err
+		}
+	}
+}
+
 // UpdateMap updates a map containing labels or annotations to be modified.
 func UpdateMap(m map[string]string,
 	keyOverwrite bool,
@@ -157,6 +157,56 @@ func ListSpecRevisions(ctx context.Context,
 	return nil
 }
 
+func ListDeployments(ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	filterFlag string,
+	handler DeploymentHandler) error {
+	request := &rpc.ListApiDeploymentsRequest{
+		Parent: "projects/" + segments[1] + "/apis/" + segments[2],
+	}
+	filter := filterFlag
+	if len(segments) > 3 && segments[3] != "-" {
+		filter = "deployment_id == '" + segments[3] + "'"
+	}
+	if filter != "" {
+		request.Filter = filter
+	}
+	it := client.ListApiDeployments(ctx, request)
+	for {
+		deployment, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return err
+		}
+		handler(deployment)
+	}
+	return nil
+}
+
+func ListDeploymentRevisions(ctx context.Context,
+	client *gapic.RegistryClient,
+	segments []string,
+	handler DeploymentHandler) error {
+	request := &rpc.ListApiDeploymentRevisionsRequest{
+		Name: "projects/" + segments[1] +
+			"/apis/" + segments[2] +
+			"/deployments/" + segments[3],
+	}
+	it := client.ListApiDeploymentRevisions(ctx, request)
+	for {
+		deployment, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return err
+		}
+		handler(deployment)
+	}
+	return nil
+}
+
 func ListArtifacts(ctx context.Context,
 	client *gapic.RegistryClient,
 	segments []string,
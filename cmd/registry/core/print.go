@@ -59,6 +59,14 @@ func PrintSpecDetail(message *rpc.ApiSpec) {
 	PrintMessage(message)
 }
 
+func PrintDeployment(deployment *rpc.ApiDeployment) {
+	fmt.Println(deployment.Name)
+}
+
+func PrintDeploymentDetail(message *rpc.ApiDeployment) {
+	PrintMessage(message)
+}
+
 func PrintSpecContents(message *rpc.ApiSpec) {
 	contents := message.GetContents()
 	if strings.Contains(message.GetMimeType(), "+gzip") {
@@ -67,6 +75,27 @@ func PrintSpecContents(message *rpc.ApiSpec) {
 	os.Stdout.Write(contents)
 }
 
+// PrintSpecDiff prints the changes between two spec revisions, one per line.
+// Added, removed and modified elements are prefixed with "+", "-" and "~".
+func PrintSpecDiff(diff *rpc.DiffApiSpecRevisionsResponse) {
+	if diff.GetFormat() == "text" {
+		fmt.Print(diff.GetUnifiedDiff())
+		return
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", diff.GetBaseRevisionName(), diff.GetRevisionName())
+	for _, c := range diff.GetChanges() {
+		switch c.GetKind() {
+		case rpc.ApiSpecChange_ADDED:
+			fmt.Printf("+ %s %s\n", c.GetElement(), c.GetLocation())
+		case rpc.ApiSpecChange_REMOVED:
+			fmt.Printf("- %s %s\n", c.GetElement(), c.GetLocation())
+		default:
+			fmt.Printf("~ %s %s: %s %q -> %q\n", c.GetElement(), c.GetLocation(), c.GetAttribute(), c.GetOldValue(), c.GetNewValue())
+		}
+	}
+}
+
 func PrintArtifact(artifact *rpc.Artifact) {
 	fmt.Println(artifact.Name)
 }
@@ -95,6 +124,8 @@ func PrintArtifactContents(artifact *rpc.Artifact) {
 		unmarshalAndPrint(artifact.GetContents(), &rpc.Index{})
 	case "google.cloud.apigee.registry.applications.v1alpha1.References":
 		unmarshalAndPrint(artifact.GetContents(), &rpc.References{})
+	case "google.cloud.apigee.registry.applications.v1alpha1.BreakingChanges":
+		unmarshalAndPrint(artifact.GetContents(), &rpc.BreakingChanges{})
 	case "gnostic.openapiv2.Document":
 		unmarshalAndPrint(artifact.GetContents(), &openapiv2.Document{})
 	case "gnostic.openapiv3.Document":
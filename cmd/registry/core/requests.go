@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRequestAttempts is the number of times that a request with an ID is attempted
+// when it fails with a transient error.
+const maxRequestAttempts = 4
+
+// requestRetryDelay is the delay before the first retry of a request. It doubles with each retry.
+const requestRetryDelay = 500 * time.Millisecond
+
+// NewRequestID returns a unique ID for a request. The server returns the original
+// response to retries of completed requests, so requests with IDs can be retried safely.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// RetryRequest calls request until it succeeds or fails for a reason other than a transient error.
+// Requests must have IDs so that requests completed before a failure aren't repeated when retried.
+func RetryRequest(ctx context.Context, request func() error) error {
+	delay := requestRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := request()
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		default:
+			return err
+		}
+		if attempt == maxRequestAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
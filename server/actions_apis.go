@@ -32,8 +32,7 @@ func (s *RegistryServer) CreateApi(ctx context.Context, req *rpc.CreateApiReques
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetApi() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api %+v: body must be provided", req.GetApi())
@@ -44,13 +43,21 @@ func (s *RegistryServer) CreateApi(ctx context.Context, req *rpc.CreateApiReques
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	replayed := new(rpc.Api)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
 	// Creation should only succeed when the parent exists.
 	if _, err := db.GetProject(ctx, parent); err != nil {
 		return nil, err
 	}
 
+	// Names of deleted APIs can't be reused until the APIs are purged.
 	name := parent.Api(req.GetApiId())
-	if _, err := db.GetApi(ctx, name); err == nil {
+	if _, err := db.GetApiIncludingDeleted(ctx, name); err == nil {
 		return nil, status.Errorf(codes.AlreadyExists, "API %q already exists", name)
 	} else if !isNotFound(err) {
 		return nil, err
@@ -65,16 +72,19 @@ func (s *RegistryServer) CreateApi(ctx context.Context, req *rpc.CreateApiReques
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err := db.SaveApi(ctx, api); err != nil {
+	var message *rpc.Api
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveApi(ctx, api); err != nil {
+			return err
+		}
+		if message, err = api.Message(); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
 		return nil, err
 	}
 
-	message, err := api.Message()
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	s.notify(ctx, rpc.Notification_CREATED, name.String())
 	return message, nil
 }
 
@@ -84,8 +94,7 @@ func (s *RegistryServer) DeleteApi(ctx context.Context, req *rpc.DeleteApiReques
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseApi(req.GetName())
 	if err != nil {
@@ -97,29 +106,64 @@ func (s *RegistryServer) DeleteApi(ctx context.Context, req *rpc.DeleteApiReques
 		return nil, err
 	}
 
-	if err := db.DeleteApi(ctx, name); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if err := checkApiEtag(ctx, db, name, req.GetEtag()); err != nil {
+			return err
+		}
+		deleteTime, purgeTime := s.deletionTimes()
+		return db.SoftDeleteApi(ctx, name, deleteTime, purgeTime)
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
 	return &empty.Empty{}, nil
 }
 
+// UndeleteApi handles the corresponding API request.
+func (s *RegistryServer) UndeleteApi(ctx context.Context, req *rpc.UndeleteApiRequest) (*rpc.Api, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseApi(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var message *rpc.Api
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		api, err := db.UndeleteApi(ctx, name)
+		if err != nil {
+			return err
+		}
+		if message, err = api.Message(); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
 // GetApi handles the corresponding API request.
 func (s *RegistryServer) GetApi(ctx context.Context, req *rpc.GetApiRequest) (*rpc.Api, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseApi(req.GetName())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	api, err := db.GetApi(ctx, name)
+	// Deleted APIs are returned until they are purged.
+	api, err := db.GetApiIncludingDeleted(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -138,8 +182,7 @@ func (s *RegistryServer) ListApis(ctx context.Context, req *rpc.ListApisRequest)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -155,9 +198,11 @@ func (s *RegistryServer) ListApis(ctx context.Context, req *rpc.ListApisRequest)
 	}
 
 	listing, err := db.ListApis(ctx, parent, dao.PageOptions{
-		Size:   req.GetPageSize(),
-		Filter: req.GetFilter(),
-		Token:  req.GetPageToken(),
+		Size:        req.GetPageSize(),
+		Filter:      req.GetFilter(),
+		Token:       req.GetPageToken(),
+		OrderBy:     req.GetOrderBy(),
+		ShowDeleted: req.GetShowDeleted(),
 	})
 	if err != nil {
 		return nil, err
@@ -184,8 +229,7 @@ func (s *RegistryServer) UpdateApi(ctx context.Context, req *rpc.UpdateApiReques
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetApi() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api %v: body must be provided", req.GetApi())
@@ -198,24 +242,69 @@ func (s *RegistryServer) UpdateApi(ctx context.Context, req *rpc.UpdateApiReques
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	api, err := db.GetApi(ctx, name)
-	if err != nil {
+	replayed := new(rpc.Api)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
+	} else if ok {
+		return replayed, nil
 	}
 
-	if err := api.Update(req.GetApi(), models.ExpandMask(req.GetApi(), req.GetUpdateMask())); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	// The api is read and saved in one transaction, so concurrent updates can't overwrite each other.
+	var message *rpc.Api
+	if err := s.commitChange(ctx, db, func(db dao.DAO) (*models.Change, error) {
+		api, created, err := db.UpsertApi(ctx, name, req.GetAllowMissing(), func(api *models.Api) (*models.Api, error) {
+			if api == nil {
+				return newApi(ctx, db, name, req.GetApi())
+			}
+
+			current, err := api.Message()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			} else if err := checkEtag(name.String(), req.GetApi().GetEtag(), current.GetEtag()); err != nil {
+				return nil, err
+			}
+
+			if err := api.Update(req.GetApi(), models.ExpandMask(req.GetApi(), req.GetUpdateMask())); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return api, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if message, err = api.Message(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		} else if err := s.recordRequest(ctx, db, req, message); err != nil {
+			return nil, err
+		}
+
+		if created {
+			return models.NewChange(rpc.Notification_CREATED, name.String()), nil
+		}
+		return models.NewChange(rpc.Notification_UPDATED, name.String()), nil
+	}); err != nil {
+		return nil, err
 	}
 
-	if err := db.SaveApi(ctx, api); err != nil {
+	return message, nil
+}
+
+// newApi returns a new api with the specified name after checking that it can be created.
+func newApi(ctx context.Context, db dao.DAO, name names.Api, body *rpc.Api) (*models.Api, error) {
+	if err := name.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Creation should only succeed when the parent exists.
+	if _, err := db.GetProject(ctx, name.Project()); err != nil {
 		return nil, err
 	}
 
-	message, err := api.Message()
+	api, err := models.NewApi(name, body)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, name.String())
-	return message, nil
+	return api, nil
 }
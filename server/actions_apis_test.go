@@ -17,6 +17,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/apigee/registry/rpc"
@@ -115,7 +116,7 @@ func TestCreateApi(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, created, opts) {
@@ -355,7 +356,7 @@ func TestGetApi(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, got, opts) {
@@ -527,7 +528,7 @@ func TestListApis(t *testing.T) {
 			opts := cmp.Options{
 				protocmp.Transform(),
 				protocmp.IgnoreFields(new(rpc.ListApisResponse), "next_page_token"),
-				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time", "etag"),
 				protocmp.SortRepeated(func(a, b *rpc.Api) bool {
 					return a.GetName() < b.GetName()
 				}),
@@ -581,6 +582,30 @@ func TestListApisResponseCodes(t *testing.T) {
 			},
 			want: codes.InvalidArgument,
 		},
+		{
+			desc: "unknown order_by field",
+			req: &rpc.ListApisRequest{
+				Parent:  "projects/my-project",
+				OrderBy: "popularity desc",
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid order_by direction",
+			req: &rpc.ListApisRequest{
+				Parent:  "projects/my-project",
+				OrderBy: "display_name descending",
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "unsupported order_by field",
+			req: &rpc.ListApisRequest{
+				Parent:  "projects/my-project",
+				OrderBy: "labels",
+			},
+			want: codes.InvalidArgument,
+		},
 	}
 
 	for _, test := range tests {
@@ -692,7 +717,7 @@ func TestListApisSequence(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time"),
+		protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time", "etag"),
 		cmpopts.SortSlices(func(a, b *rpc.Api) bool {
 			return a.GetName() < b.GetName()
 		}),
@@ -703,6 +728,153 @@ func TestListApisSequence(t *testing.T) {
 	}
 }
 
+func TestListApisOrdering(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server,
+		&rpc.Api{Name: "projects/my-project/apis/a", DisplayName: "Beta"},
+		&rpc.Api{Name: "projects/my-project/apis/b", DisplayName: "Alpha"},
+		&rpc.Api{Name: "projects/my-project/apis/c", DisplayName: "Beta"},
+		&rpc.Api{Name: "projects/my-project/apis/d", DisplayName: "Gamma"},
+	)
+
+	// Make "b" the most recently updated API.
+	if _, err := server.UpdateApi(ctx, &rpc.UpdateApiRequest{
+		Api: &rpc.Api{Name: "projects/my-project/apis/b", Description: "updated"},
+	}); err != nil {
+		t.Fatalf("Setup: UpdateApi() returned error: %s", err)
+	}
+
+	tests := []struct {
+		orderBy string
+		want    []string
+	}{
+		{
+			orderBy: "",
+			want:    []string{"a", "b", "c", "d"},
+		},
+		{
+			orderBy: "name desc",
+			want:    []string{"d", "c", "b", "a"},
+		},
+		{
+			orderBy: "display_name",
+			want:    []string{"b", "a", "c", "d"},
+		},
+		{
+			orderBy: "display_name desc, api_id desc",
+			want:    []string{"d", "c", "a", "b"},
+		},
+		{
+			orderBy: " display_name desc ,api_id",
+			want:    []string{"d", "a", "c", "b"},
+		},
+		{
+			orderBy: "update_time desc",
+			want:    []string{"b", "d", "c", "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.orderBy, func(t *testing.T) {
+			req := &rpc.ListApisRequest{
+				Parent:   "projects/my-project",
+				PageSize: 1,
+				OrderBy:  test.orderBy,
+			}
+
+			got := make([]string, 0)
+			for {
+				resp, err := server.ListApis(ctx, req)
+				if err != nil {
+					t.Fatalf("ListApis(%+v) returned error: %s", req, err)
+				}
+				for _, api := range resp.GetApis() {
+					got = append(got, strings.TrimPrefix(api.GetName(), "projects/my-project/apis/"))
+				}
+				if resp.GetNextPageToken() == "" {
+					break
+				}
+				req.PageToken = resp.GetNextPageToken()
+			}
+
+			if !cmp.Equal(test.want, got) {
+				t.Errorf("List sequence returned unexpected diff (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+
+	t.Run("order changed between pages", func(t *testing.T) {
+		req := &rpc.ListApisRequest{
+			Parent:   "projects/my-project",
+			PageSize: 1,
+			OrderBy:  "display_name",
+		}
+		resp, err := server.ListApis(ctx, req)
+		if err != nil {
+			t.Fatalf("ListApis(%+v) returned error: %s", req, err)
+		}
+
+		req.PageToken = resp.GetNextPageToken()
+		req.OrderBy = "display_name desc"
+		if _, err := server.ListApis(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListApis(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+		}
+	})
+}
+
+// Page tokens identify the last listed resource, so resources created between
+// requests must not shift later pages or cause resources to be listed twice.
+func TestListApisSequenceWithInsertions(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server,
+		&rpc.Api{Name: "projects/my-project/apis/api2"},
+		&rpc.Api{Name: "projects/my-project/apis/api4"},
+		&rpc.Api{Name: "projects/my-project/apis/api6"},
+	)
+
+	req := &rpc.ListApisRequest{
+		Parent:   "projects/my-project",
+		PageSize: 1,
+	}
+
+	listed := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		got, err := server.ListApis(ctx, req)
+		if err != nil {
+			t.Fatalf("ListApis(%+v) returned error: %s", req, err)
+		}
+		for _, api := range got.GetApis() {
+			listed = append(listed, api.GetName())
+		}
+		if got.GetNextPageToken() == "" {
+			break
+		}
+
+		// Create an API that sorts before the listed ones and one that sorts after.
+		seedApis(ctx, t, server,
+			&rpc.Api{Name: fmt.Sprintf("projects/my-project/apis/api%d", i)},
+			&rpc.Api{Name: fmt.Sprintf("projects/my-project/apis/api%d5", 5+i)},
+		)
+		req.PageToken = got.GetNextPageToken()
+	}
+
+	want := []string{
+		"projects/my-project/apis/api2",
+		"projects/my-project/apis/api4",
+		"projects/my-project/apis/api55",
+		"projects/my-project/apis/api6",
+		"projects/my-project/apis/api65",
+		"projects/my-project/apis/api75",
+		"projects/my-project/apis/api85",
+		"projects/my-project/apis/api95",
+	}
+	if !cmp.Equal(want, listed) {
+		t.Errorf("List sequence returned unexpected diff (-want +got):\n%s", cmp.Diff(want, listed))
+	}
+}
+
 // This test prevents the list sequence from ending before a known filter match is listed.
 // For simplicity, it does not guarantee the resource is returned on a later page.
 func TestListApisLargeCollectionFiltering(t *testing.T) {
@@ -836,7 +1008,7 @@ func TestUpdateApi(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Api), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, updated, opts) {
@@ -919,6 +1091,71 @@ func TestUpdateApiResponseCodes(t *testing.T) {
 	}
 }
 
+func TestUpdateApiAllowMissing(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	req := &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
+			Name:        "projects/my-project/apis/my-api",
+			DisplayName: "My Api",
+		},
+		AllowMissing: true,
+	}
+	if _, err := server.UpdateApi(ctx, req); err != nil {
+		t.Fatalf("UpdateApi(%+v) returned error: %s", req, err)
+	}
+
+	got, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: req.Api.GetName()})
+	if err != nil {
+		t.Fatalf("GetApi(%q) returned error: %s", req.Api.GetName(), err)
+	} else if got.GetDisplayName() != "My Api" {
+		t.Errorf("GetApi(%q) returned display_name %q, want %q", req.Api.GetName(), got.GetDisplayName(), "My Api")
+	}
+
+	// Updates of missing apis should still require the parent to exist.
+	req.Api.Name = "projects/other-project/apis/my-api"
+	if _, err := server.UpdateApi(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("UpdateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestUpdateApiAllowMissingConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	// Each request either creates the api or updates it, so none should fail.
+	const count = 8
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			_, err := server.UpdateApi(ctx, &rpc.UpdateApiRequest{
+				Api: &rpc.Api{
+					Name:        "projects/my-project/apis/my-api",
+					DisplayName: fmt.Sprintf("api %d", i),
+				},
+				AllowMissing: true,
+			})
+			errs <- err
+		}(i)
+	}
+
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("UpdateApi() returned error: %s", err)
+		}
+	}
+
+	apis, err := server.ListApis(ctx, &rpc.ListApisRequest{Parent: "projects/my-project"})
+	if err != nil {
+		t.Fatalf("ListApis() returned error: %s", err)
+	} else if got := len(apis.GetApis()); got != 1 {
+		t.Errorf("ListApis() returned %d apis, want 1", got)
+	}
+}
+
 func TestDeleteApi(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -951,8 +1188,11 @@ func TestDeleteApi(t *testing.T) {
 					Name: test.req.GetName(),
 				}
 
-				if _, err := server.GetApi(ctx, req); status.Code(err) != codes.NotFound {
-					t.Fatalf("GetApi(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), codes.NotFound, err)
+				// Deleted resources are returned until they are purged.
+				if got, err := server.GetApi(ctx, req); err != nil {
+					t.Fatalf("GetApi(%+v) returned error: %s", req, err)
+				} else if got.GetDeleteTime() == nil || got.GetPurgeTime() == nil {
+					t.Errorf("GetApi(%+v) returned delete_time %v and purge_time %v, want both set", req, got.GetDeleteTime(), got.GetPurgeTime())
 				}
 			})
 		})
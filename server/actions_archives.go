@@ -0,0 +1,310 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/archive"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// archiveChunkSize is the largest amount of archive data that is streamed in a single message.
+	archiveChunkSize = 1 << 20
+	// importBatchSize is the largest number of archive entries that are imported in a single transaction.
+	importBatchSize = 100
+	// importBatchBytes is the amount of archive data after which a batch of entries is imported.
+	importBatchBytes = 8 << 20
+)
+
+// ExportProject handles the corresponding API request.
+func (s *RegistryServer) ExportProject(req *rpc.ExportProjectRequest, stream rpc.Registry_ExportProjectServer) error {
+	ctx := stream.Context()
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseProject(req.GetName())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	out := bufio.NewWriterSize(&archiveSender{stream: stream}, archiveChunkSize)
+	w, err := archive.NewWriter(out, archive.Manifest{
+		Project:    name.String(),
+		CreateTime: time.Now().Round(time.Microsecond),
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err := db.ExportProject(ctx, name, w); err != nil {
+		return err
+	} else if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// archiveSender streams the data written to it in ExportProject responses.
+type archiveSender struct {
+	stream rpc.Registry_ExportProjectServer
+	sent   bool
+}
+
+func (a *archiveSender) Write(p []byte) (int, error) {
+	body := &httpbody.HttpBody{Data: p}
+	if !a.sent {
+		body.ContentType = "application/gzip"
+		a.sent = true
+	}
+	if err := a.stream.Send(body); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ImportProject handles the corresponding API request.
+func (s *RegistryServer) ImportProject(stream rpc.Registry_ImportProjectServer) error {
+	ctx := stream.Context()
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	file, err := receiveArchive(stream)
+	if err != nil {
+		return err
+	}
+
+	// The archive is removed by the operation, or here if the operation isn't started.
+	started := false
+	defer func() {
+		if !started {
+			removeArchive(file)
+		}
+	}()
+
+	r, err := archive.NewReader(file)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	name, err := names.ParseProject(r.Manifest().Project)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid manifest: %s", err)
+	}
+
+	// Imports should only succeed for projects that don't already exist.
+	if _, err := db.GetProject(ctx, name); err == nil {
+		return status.Errorf(codes.AlreadyExists, "project %q already exists", name)
+	} else if !isNotFound(err) {
+		return err
+	}
+
+	op, err := s.startOperation(ctx, db, "import", name.String(), func(ctx context.Context, db dao.DAO, progress progressFunc) (proto.Message, error) {
+		defer removeArchive(file)
+		return s.importProject(ctx, db, name, file, progress)
+	})
+	if err != nil {
+		return err
+	}
+	started = true
+
+	return stream.SendAndClose(op)
+}
+
+// receiveArchive saves the archive data of an ImportProject stream in a temporary file.
+func receiveArchive(stream rpc.Registry_ImportProjectServer) (*os.File, error) {
+	file, err := ioutil.TempFile("", "registry-import-*.tar.gz")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to save archive: %s", err)
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			removeArchive(file)
+			return nil, err
+		}
+
+		if _, err := file.Write(req.GetData()); err != nil {
+			removeArchive(file)
+			return nil, status.Errorf(codes.Internal, "failed to save archive: %s", err)
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		removeArchive(file)
+		return nil, status.Errorf(codes.Internal, "failed to read archive: %s", err)
+	}
+	return file, nil
+}
+
+// removeArchive closes and removes an archive that was saved by receiveArchive.
+func removeArchive(file *os.File) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		log.Printf("Failed to remove archive %s: %s", file.Name(), err)
+	}
+}
+
+// importProject creates a project from an archive. The project is created first, and
+// its other entities are imported in batches so that progress can be reported and the
+// import can be cancelled between them. The project is removed if the import fails.
+func (s *RegistryServer) importProject(ctx context.Context, db dao.DAO, name names.Project, file *os.File, progress progressFunc) (proto.Message, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read archive: %s", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read archive: %s", err)
+	}
+
+	counter := &countingReader{r: file}
+	r, err := archive.NewReader(counter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	entry, err := r.Next()
+	if err == io.EOF || (err == nil && entry.Kind != storage.ProjectEntityName) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid archive: %s must be its first entity", name)
+	} else if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		if _, err := db.GetProject(ctx, name); err == nil {
+			return status.Errorf(codes.AlreadyExists, "project %q already exists", name)
+		} else if !isNotFound(err) {
+			return err
+		}
+		return db.ImportEntity(ctx, name, entry)
+	}); err != nil {
+		return nil, err
+	}
+
+	report := func(count int) error {
+		percent := int32(100 * counter.n / (info.Size() + 1))
+		return progress(percent, fmt.Sprintf("Imported %d entries", count))
+	}
+	if err := importEntries(ctx, db, name, r, report); err != nil {
+		// Imports fail when they're cancelled, so the project is removed with a context that isn't.
+		if err := s.deleteProject(context.Background(), db, name, func(int32, string) error { return nil }); err != nil {
+			log.Printf("Failed to remove %s after its import failed: %s", name, err)
+		}
+		return nil, err
+	}
+
+	project, err := db.GetProject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return project.Message(), nil
+}
+
+// importEntries imports the remaining entries of an archive in batches, and calls report
+// with the number of entries that have been imported before each batch.
+func importEntries(ctx context.Context, db dao.DAO, name names.Project, r *archive.Reader, report func(count int) error) error {
+	count := 0
+	batch := make([]*archive.Entry, 0, importBatchSize)
+	batchBytes := 0
+	flush := func() error {
+		if err := report(count); err != nil {
+			return err
+		}
+		if err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+			for _, e := range batch {
+				if err := db.ImportEntity(ctx, name, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		count += len(batch)
+		batch, batchBytes = batch[:0], 0
+		return nil
+	}
+
+	// Archives include the contents of blobs before the first blob that refers to them.
+	hashes := map[string]bool{"": true}
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		switch entry.Kind {
+		case archive.ContentsKind:
+			hashes[entry.Key] = true
+		case models.BlobEntityName:
+			blob := new(models.Blob)
+			if err := entry.Decode(blob); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			} else if !hashes[blob.Hash] {
+				return status.Errorf(codes.InvalidArgument, "invalid archive: contents of blob %q are missing", entry.Key)
+			}
+		}
+
+		batch = append(batch, entry)
+		batchBytes += len(entry.Data)
+		if len(batch) == importBatchSize || batchBytes >= importBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// countingReader counts the bytes that are read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
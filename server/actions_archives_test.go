@@ -0,0 +1,268 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/archive"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// exportStream is an in-process implementation of rpc.Registry_ExportProjectServer.
+type exportStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	data bytes.Buffer
+}
+
+func (s *exportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *exportStream) Send(body *httpbody.HttpBody) error {
+	s.data.Write(body.GetData())
+	return nil
+}
+
+// importStream is an in-process implementation of rpc.Registry_ImportProjectServer.
+type importStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks [][]byte
+	op     *longrunning.Operation
+}
+
+func (s *importStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *importStream) Recv() (*rpc.ImportProjectRequest, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	req := &rpc.ImportProjectRequest{Data: s.chunks[0]}
+	s.chunks = s.chunks[1:]
+	return req, nil
+}
+
+func (s *importStream) SendAndClose(op *longrunning.Operation) error {
+	s.op = op
+	return nil
+}
+
+func exportProject(ctx context.Context, t *testing.T, s *RegistryServer, name string) []byte {
+	t.Helper()
+
+	stream := &exportStream{ctx: ctx}
+	req := &rpc.ExportProjectRequest{Name: name}
+	if err := s.ExportProject(req, stream); err != nil {
+		t.Fatalf("ExportProject(%+v) returned error: %s", req, err)
+	}
+	return stream.data.Bytes()
+}
+
+// importProject streams an archive in small chunks and returns the import operation.
+func importProject(ctx context.Context, s *RegistryServer, data []byte) (*longrunning.Operation, error) {
+	stream := &importStream{ctx: ctx}
+	for len(data) > 1000 {
+		stream.chunks = append(stream.chunks, data[:1000])
+		data = data[1000:]
+	}
+	stream.chunks = append(stream.chunks, data)
+
+	err := s.ImportProject(stream)
+	return stream.op, err
+}
+
+// archiveEntries returns the manifest and entries of an archive, sorted by kind and key.
+func archiveEntries(t *testing.T, data []byte) (archive.Manifest, []archive.Entry) {
+	t.Helper()
+
+	r, err := archive.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader() returned error: %s", err)
+	}
+
+	entries := make([]archive.Entry, 0)
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() returned error: %s", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return r.Manifest(), entries
+}
+
+func TestExportImportProject(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedArtifacts(ctx, t, server,
+		&rpc.Artifact{Name: "projects/my-project/artifacts/a", MimeType: "text/plain", Contents: []byte("project")},
+		&rpc.Artifact{Name: "projects/my-project/apis/a/versions/v/specs/s/artifacts/a", Contents: []byte("spec")},
+	)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{Name: "projects/my-project/apis/a/versions/v/specs/s", Contents: specContents})
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{Name: "projects/my-project/apis/deleted/versions/v/specs/s", Contents: specContents})
+	seedDeployments(ctx, t, server, &rpc.ApiDeployment{Name: "projects/my-project/apis/a/deployments/d", EndpointUri: "https://example.com"})
+
+	original, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/a/versions/v/specs/s"})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+	}
+	tag := &rpc.TagApiSpecRevisionRequest{Name: original.GetName() + "@" + original.GetRevisionId(), Tag: "original"}
+	if _, err := server.TagApiSpecRevision(ctx, tag); err != nil {
+		t.Fatalf("Setup: TagApiSpecRevision() returned error: %s", err)
+	}
+	updateSpec := &rpc.UpdateApiSpecRequest{ApiSpec: &rpc.ApiSpec{Name: "projects/my-project/apis/a/versions/v/specs/s", Contents: []byte("updated")}}
+	if _, err := server.UpdateApiSpec(ctx, updateSpec); err != nil {
+		t.Fatalf("Setup: UpdateApiSpec(%+v) returned error: %s", updateSpec, err)
+	}
+	replace := &rpc.ReplaceArtifactRequest{Artifact: &rpc.Artifact{Name: "projects/my-project/artifacts/a", MimeType: "text/plain", Contents: []byte("replaced")}}
+	if _, err := server.ReplaceArtifact(ctx, replace); err != nil {
+		t.Fatalf("Setup: ReplaceArtifact(%+v) returned error: %s", replace, err)
+	}
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/deleted"}); err != nil {
+		t.Fatalf("Setup: DeleteApi() returned error: %s", err)
+	}
+
+	project, err := server.GetProject(ctx, &rpc.GetProjectRequest{Name: "projects/my-project"})
+	if err != nil {
+		t.Fatalf("Setup: GetProject() returned error: %s", err)
+	}
+
+	exported := exportProject(ctx, t, server, "projects/my-project")
+	op, err := server.DeleteProject(ctx, &rpc.DeleteProjectRequest{Name: "projects/my-project"})
+	if err != nil {
+		t.Fatalf("Setup: DeleteProject() returned error: %s", err)
+	} else if op = waitOperation(ctx, t, server, op); op.GetError() != nil {
+		t.Fatalf("Setup: DeleteProject() operation failed: %v", op.GetError())
+	}
+
+	op, err = importProject(ctx, server, exported)
+	if err != nil {
+		t.Fatalf("ImportProject() returned error: %s", err)
+	} else if op = waitOperation(ctx, t, server, op); op.GetError() != nil {
+		t.Fatalf("ImportProject() operation failed: %v", op.GetError())
+	}
+
+	imported := new(rpc.Project)
+	if err := op.GetResponse().UnmarshalTo(imported); err != nil {
+		t.Fatalf("ImportProject() operation returned unexpected response %v: %s", op.GetResponse(), err)
+	} else if diff := cmp.Diff(project, imported, protocmp.Transform()); diff != "" {
+		t.Errorf("ImportProject() returned unexpected diff: (-want +got):\n%s", diff)
+	}
+
+	// Exporting the imported project should produce the same entities and contents.
+	wantManifest, want := archiveEntries(t, exported)
+	gotManifest, got := archiveEntries(t, exportProject(ctx, t, server, "projects/my-project"))
+	if gotManifest.Project != wantManifest.Project {
+		t.Errorf("ExportProject() returned archive of %q, want %q", gotManifest.Project, wantManifest.Project)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExportProject() returned unexpected diff after import: (-want +got):\n%s", diff)
+	}
+
+	contents, err := server.GetApiSpecContents(ctx, &rpc.GetApiSpecContentsRequest{Name: "projects/my-project/apis/a/versions/v/specs/s@original/contents"})
+	if err != nil {
+		t.Fatalf("GetApiSpecContents() returned error: %s", err)
+	} else if !bytes.Equal(contents.GetData(), specContents) {
+		t.Errorf("GetApiSpecContents() returned %q, want %q", contents.GetData(), specContents)
+	}
+
+	spec, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/a/versions/v/specs/s@original"})
+	if err != nil {
+		t.Fatalf("GetApiSpec() returned error: %s", err)
+	} else if spec.GetRevisionId() != original.GetRevisionId() {
+		t.Errorf("GetApiSpec() returned revision %q, want %q", spec.GetRevisionId(), original.GetRevisionId())
+	}
+
+	if api, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/deleted"}); err != nil {
+		t.Fatalf("GetApi() returned error: %s", err)
+	} else if api.GetDeleteTime() == nil {
+		t.Errorf("GetApi() returned %v, want a deleted api", api)
+	}
+}
+
+func TestExportProjectNotFound(t *testing.T) {
+	server := defaultTestServer(t)
+	req := &rpc.ExportProjectRequest{Name: "projects/missing"}
+	if err := server.ExportProject(req, &exportStream{ctx: context.Background()}); status.Code(err) != codes.NotFound {
+		t.Errorf("ExportProject(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestImportProjectErrors(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	if _, err := importProject(ctx, server, []byte("not an archive")); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ImportProject() returned status code %q, want %q: %v", status.Code(err), codes.InvalidArgument, err)
+	}
+
+	exported := exportProject(ctx, t, server, "projects/my-project")
+	if _, err := importProject(ctx, server, exported); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("ImportProject() returned status code %q, want %q: %v", status.Code(err), codes.AlreadyExists, err)
+	}
+
+	// Archives can't include entities of other projects.
+	var buf bytes.Buffer
+	w, err := archive.NewWriter(&buf, archive.Manifest{Project: "projects/other", CreateTime: time.Now()})
+	if err != nil {
+		t.Fatalf("Setup: NewWriter() returned error: %s", err)
+	}
+	w.WriteEntity(storage.ProjectEntityName, "projects/other", &models.Project{ProjectID: "other"})
+	w.WriteEntity(storage.ApiEntityName, "projects/my-project/apis/a", &models.Api{ProjectID: "my-project", ApiID: "a"})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Setup: Close() returned error: %s", err)
+	}
+
+	op, err := importProject(ctx, server, buf.Bytes())
+	if err != nil {
+		t.Fatalf("ImportProject() returned error: %s", err)
+	}
+	if op = waitOperation(ctx, t, server, op); codes.Code(op.GetError().GetCode()) != codes.InvalidArgument {
+		t.Errorf("ImportProject() operation failed with %v, want status code %q", op.GetError(), codes.InvalidArgument)
+	}
+
+	// Failed imports don't leave partial projects behind.
+	if _, err := server.GetProject(ctx, &rpc.GetProjectRequest{Name: "projects/other"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProject() returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+}
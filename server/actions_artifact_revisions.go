@@ -0,0 +1,266 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListArtifactRevisions handles the corresponding API request.
+func (s *RegistryServer) ListArtifactRevisions(ctx context.Context, req *rpc.ListArtifactRevisionsRequest) (*rpc.ListArtifactRevisionsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
+	} else if req.GetPageSize() > 1000 {
+		req.PageSize = 1000
+	} else if req.GetPageSize() == 0 {
+		req.PageSize = 50
+	}
+
+	parent, err := names.ParseArtifact(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, err := db.GetArtifact(ctx, parent); err != nil {
+		return nil, err
+	}
+
+	listing, err := db.ListArtifactRevisions(ctx, parent, dao.PageOptions{
+		Size:    req.GetPageSize(),
+		Token:   req.GetPageToken(),
+		OrderBy: req.GetOrderBy(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &rpc.ListArtifactRevisionsResponse{
+		Artifacts:     make([]*rpc.Artifact, len(listing.Revisions)),
+		NextPageToken: listing.Token,
+	}
+
+	for i, revision := range listing.Revisions {
+		response.Artifacts[i] = revision.Message(revision.RevisionName())
+	}
+
+	return response, nil
+}
+
+// DeleteArtifactRevision handles the corresponding API request.
+func (s *RegistryServer) DeleteArtifactRevision(ctx context.Context, req *rpc.DeleteArtifactRevisionRequest) (*empty.Empty, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseArtifactRevision(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	revision, err := db.GetArtifactRevision(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the retrieved artifact revision name, which has a non-tag revision ID.
+	// This is necessary to ensure the actual revision is deleted.
+	name, err = names.ParseArtifactRevision(revision.RevisionName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	artifact, err := db.GetArtifact(ctx, name.Artifact())
+	if err != nil {
+		return nil, err
+	}
+
+	// If the current revision is deleted, the most recent remaining revision becomes current.
+	// Deleting the only revision of an artifact deletes the artifact.
+	var previous *models.ArtifactRevision
+	var blob *models.Blob
+	if artifact.RevisionID == revision.RevisionID {
+		listing, err := db.ListArtifactRevisions(ctx, name.Artifact(), dao.PageOptions{Size: 2})
+		if err != nil {
+			return nil, err
+		}
+		for i := range listing.Revisions {
+			if listing.Revisions[i].RevisionID != revision.RevisionID {
+				previous = &listing.Revisions[i]
+				break
+			}
+		}
+		if previous != nil {
+			blob, err = db.GetArtifactRevisionContents(ctx, name.Artifact().Revision(previous.RevisionID))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tags, err := db.ListArtifactRevisionTags(ctx, name.Artifact())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if artifact.RevisionID == revision.RevisionID && previous == nil {
+			return db.DeleteArtifact(ctx, name.Artifact())
+		}
+
+		// Tags of the revision are deleted with it so they don't refer to a missing revision.
+		for i := range tags {
+			if tags[i].RevisionID == revision.RevisionID {
+				if err := db.DeleteArtifactRevisionTag(ctx, &tags[i]); err != nil {
+					return err
+				}
+			}
+		}
+		if err := db.DeleteArtifactRevision(ctx, name); err != nil {
+			return err
+		}
+
+		if previous == nil {
+			return nil
+		}
+		current := previous.Artifact()
+		if err := db.SaveArtifact(ctx, current); err != nil {
+			return err
+		}
+		return db.SaveArtifactContents(ctx, current, blob.Contents)
+	}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// TagArtifactRevision handles the corresponding API request.
+func (s *RegistryServer) TagArtifactRevision(ctx context.Context, req *rpc.TagArtifactRevisionRequest) (*rpc.Artifact, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetTag() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tag %q, must not be empty", req.GetTag())
+	} else if len(req.GetTag()) > 40 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tag %q, must be 40 characters or less", req.GetTag())
+	}
+
+	// Parse the requested artifact revision name, which may include a tag name.
+	name, err := names.ParseArtifactRevision(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	replayed := new(rpc.Artifact)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
+	revision, err := db.GetArtifactRevision(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the retrieved artifact revision name, which has a non-tag revision ID.
+	// This is necessary to ensure the new tag is associated with a revision ID, not another tag.
+	name, err = names.ParseArtifactRevision(revision.RevisionName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tag := models.NewArtifactRevisionTag(name, req.GetTag())
+	if _, err := names.ParseArtifactRevision(tag.String()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tag %q: %s", req.GetTag(), err)
+	}
+
+	if err := s.commit(ctx, db, rpc.Notification_UPDATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveArtifactRevisionTag(ctx, tag); err != nil {
+			return err
+		}
+		return s.recordRequest(ctx, db, req, revision.Message(tag.String()))
+	}); err != nil {
+		return nil, err
+	}
+
+	return revision.Message(tag.String()), nil
+}
+
+// RollbackArtifact handles the corresponding API request.
+func (s *RegistryServer) RollbackArtifact(ctx context.Context, req *rpc.RollbackArtifactRequest) (*rpc.Artifact, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetRevisionId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid revision ID %q, must not be empty", req.GetRevisionId())
+	}
+
+	parent, err := names.ParseArtifact(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Get the target artifact revision to use as a base for the new rollback revision.
+	name := parent.Revision(req.GetRevisionId())
+	target, err := db.GetArtifactRevision(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := db.GetArtifactRevisionContents(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save a new current revision based on the target revision,
+	// with a new copy of the target revision blob.
+	rollback := target.Rollback()
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, rollback.RevisionName(), func(db dao.DAO) error {
+		if err := db.SaveArtifact(ctx, rollback); err != nil {
+			return err
+		}
+		if err := db.SaveArtifactContents(ctx, rollback, blob.Contents); err != nil {
+			return err
+		}
+		return s.pruneArtifactRevisions(ctx, db, parent)
+	}); err != nil {
+		return nil, err
+	}
+
+	return rollback.Message(), nil
+}
@@ -0,0 +1,383 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/retention"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testArtifactName = "projects/my-project/apis/my-api/artifacts/lint"
+
+// seedArtifactRevisions creates an artifact with a number of revisions and
+// returns the revisions from oldest to newest.
+func seedArtifactRevisions(ctx context.Context, t *testing.T, s *RegistryServer, count int) []*rpc.Artifact {
+	t.Helper()
+
+	seedArtifacts(ctx, t, s, &rpc.Artifact{
+		Name:     testArtifactName,
+		MimeType: "application/json",
+		Contents: []byte(`{"problems": 0}`),
+	})
+
+	first, err := s.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: testArtifactName})
+	if err != nil {
+		t.Fatalf("Setup: GetArtifact(%q) returned error: %s", testArtifactName, err)
+	}
+
+	revisions := []*rpc.Artifact{first}
+	for i := 1; i < count; i++ {
+		req := &rpc.ReplaceArtifactRequest{
+			Artifact: &rpc.Artifact{
+				Name:     testArtifactName,
+				MimeType: "application/json",
+				Contents: []byte(fmt.Sprintf(`{"problems": %d}`, i)),
+			},
+		}
+		replaced, err := s.ReplaceArtifact(ctx, req)
+		if err != nil {
+			t.Fatalf("Setup: ReplaceArtifact(%+v) returned error: %s", req, err)
+		}
+		revisions = append(revisions, replaced)
+	}
+
+	return revisions
+}
+
+func listArtifactRevisionIDs(ctx context.Context, t *testing.T, s *RegistryServer) []string {
+	t.Helper()
+
+	req := &rpc.ListArtifactRevisionsRequest{Name: testArtifactName}
+	got, err := s.ListArtifactRevisions(ctx, req)
+	if err != nil {
+		t.Fatalf("ListArtifactRevisions(%+v) returned error: %s", req, err)
+	}
+
+	ids := make([]string, 0, len(got.GetArtifacts()))
+	for _, a := range got.GetArtifacts() {
+		ids = append(ids, a.GetRevisionId())
+	}
+	return ids
+}
+
+func TestReplaceArtifactRevisions(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedArtifactRevisions(ctx, t, server, 3)
+
+	if revisions[1].GetRevisionId() == revisions[0].GetRevisionId() || revisions[2].GetRevisionId() == revisions[1].GetRevisionId() {
+		t.Fatalf("ReplaceArtifact reused revision IDs when contents changed: %v", revisions)
+	}
+	if !revisions[2].GetCreateTime().AsTime().Equal(revisions[0].GetCreateTime().AsTime()) {
+		t.Errorf("ReplaceArtifact changed create_time from %v to %v", revisions[0].GetCreateTime(), revisions[2].GetCreateTime())
+	}
+
+	want := []string{revisions[2].GetRevisionId(), revisions[1].GetRevisionId(), revisions[0].GetRevisionId()}
+	if got := listArtifactRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListArtifactRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Replacing an artifact without changing its contents doesn't create a revision.
+	req := &rpc.ReplaceArtifactRequest{
+		Artifact: &rpc.Artifact{
+			Name:     testArtifactName,
+			MimeType: "application/yaml",
+			Contents: []byte(`{"problems": 2}`),
+		},
+	}
+	replaced, err := server.ReplaceArtifact(ctx, req)
+	if err != nil {
+		t.Fatalf("ReplaceArtifact(%+v) returned error: %s", req, err)
+	} else if replaced.GetRevisionId() != revisions[2].GetRevisionId() {
+		t.Errorf("ReplaceArtifact(%+v) returned revision_id %q, want %q", req, replaced.GetRevisionId(), revisions[2].GetRevisionId())
+	}
+	if got := listArtifactRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListArtifactRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	t.Run("GetArtifact", func(t *testing.T) {
+		name := fmt.Sprintf("%s@%s", testArtifactName, revisions[0].GetRevisionId())
+		got, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: name})
+		if err != nil {
+			t.Fatalf("GetArtifact(%q) returned error: %s", name, err)
+		}
+
+		want := &rpc.Artifact{
+			Name:               name,
+			MimeType:           revisions[0].GetMimeType(),
+			SizeBytes:          revisions[0].GetSizeBytes(),
+			Hash:               revisions[0].GetHash(),
+			RevisionId:         revisions[0].GetRevisionId(),
+			CreateTime:         revisions[0].GetCreateTime(),
+			UpdateTime:         revisions[0].GetUpdateTime(),
+			RevisionCreateTime: revisions[0].GetRevisionCreateTime(),
+			Etag:               revisions[0].GetEtag(),
+		}
+		if !cmp.Equal(want, got, protocmp.Transform()) {
+			t.Errorf("GetArtifact(%q) returned unexpected diff (-want +got):\n%s", name, cmp.Diff(want, got, protocmp.Transform()))
+		}
+	})
+
+	t.Run("GetArtifactContents", func(t *testing.T) {
+		name := fmt.Sprintf("%s@%s/contents", testArtifactName, revisions[1].GetRevisionId())
+		got, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: name})
+		if err != nil {
+			t.Fatalf("GetArtifactContents(%q) returned error: %s", name, err)
+		}
+		if want := `{"problems": 1}`; string(got.GetData()) != want {
+			t.Errorf("GetArtifactContents(%q) returned %q, want %q", name, got.GetData(), want)
+		}
+	})
+}
+
+func TestTagArtifactRevision(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedArtifactRevisions(ctx, t, server, 2)
+
+	req := &rpc.TagArtifactRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", testArtifactName, revisions[0].GetRevisionId()),
+		Tag:  "baseline",
+	}
+	tagged, err := server.TagArtifactRevision(ctx, req)
+	if err != nil {
+		t.Fatalf("TagArtifactRevision(%+v) returned error: %s", req, err)
+	}
+	if want := testArtifactName + "@baseline"; tagged.GetName() != want {
+		t.Errorf("TagArtifactRevision(%+v) returned name %q, want %q", req, tagged.GetName(), want)
+	}
+
+	name := testArtifactName + "@baseline/contents"
+	got, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: name})
+	if err != nil {
+		t.Fatalf("GetArtifactContents(%q) returned error: %s", name, err)
+	}
+	if want := `{"problems": 0}`; string(got.GetData()) != want {
+		t.Errorf("GetArtifactContents(%q) returned %q, want %q", name, got.GetData(), want)
+	}
+
+	for _, tag := range []string{"", "Invalid_Tag"} {
+		req := &rpc.TagArtifactRevisionRequest{Name: req.GetName(), Tag: tag}
+		if _, err := server.TagArtifactRevision(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("TagArtifactRevision(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+		}
+	}
+}
+
+func TestRollbackArtifact(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedArtifactRevisions(ctx, t, server, 2)
+
+	req := &rpc.RollbackArtifactRequest{
+		Name:       testArtifactName,
+		RevisionId: revisions[0].GetRevisionId(),
+	}
+	rollback, err := server.RollbackArtifact(ctx, req)
+	if err != nil {
+		t.Fatalf("RollbackArtifact(%+v) returned error: %s", req, err)
+	}
+
+	want := &rpc.Artifact{
+		Name:       testArtifactName,
+		MimeType:   revisions[0].GetMimeType(),
+		SizeBytes:  revisions[0].GetSizeBytes(),
+		Hash:       revisions[0].GetHash(),
+		CreateTime: revisions[0].GetCreateTime(),
+	}
+	opts := cmp.Options{
+		protocmp.Transform(),
+		protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "update_time", "revision_create_time", "etag"),
+	}
+	if !cmp.Equal(want, rollback, opts) {
+		t.Errorf("RollbackArtifact(%+v) returned unexpected diff (-want +got):\n%s", req, cmp.Diff(want, rollback, opts))
+	}
+
+	// Rollback should create a new revision, i.e. it should not reuse an existing revision ID.
+	for _, r := range revisions {
+		if rollback.GetRevisionId() == r.GetRevisionId() {
+			t.Fatalf("RollbackArtifact(%+v) returned existing revision_id %q, expected new revision ID", req, r.GetRevisionId())
+		}
+	}
+
+	contents, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: testArtifactName + "/contents"})
+	if err != nil {
+		t.Fatalf("GetArtifactContents(%q) returned error: %s", testArtifactName, err)
+	} else if want := `{"problems": 0}`; string(contents.GetData()) != want {
+		t.Errorf("GetArtifactContents(%q) returned %q, want %q", testArtifactName, contents.GetData(), want)
+	}
+
+	req = &rpc.RollbackArtifactRequest{Name: testArtifactName, RevisionId: "missing"}
+	if _, err := server.RollbackArtifact(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("RollbackArtifact(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestDeleteArtifactRevision(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedArtifactRevisions(ctx, t, server, 3)
+
+	// Deleting the current revision makes the previous revision current.
+	req := &rpc.DeleteArtifactRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", testArtifactName, revisions[2].GetRevisionId()),
+	}
+	if _, err := server.DeleteArtifactRevision(ctx, req); err != nil {
+		t.Fatalf("DeleteArtifactRevision(%+v) returned error: %s", req, err)
+	}
+
+	got, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: testArtifactName})
+	if err != nil {
+		t.Fatalf("GetArtifact(%q) returned error: %s", testArtifactName, err)
+	} else if got.GetRevisionId() != revisions[1].GetRevisionId() {
+		t.Errorf("GetArtifact(%q) returned revision_id %q, want %q", testArtifactName, got.GetRevisionId(), revisions[1].GetRevisionId())
+	}
+
+	contents, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: testArtifactName + "/contents"})
+	if err != nil {
+		t.Fatalf("GetArtifactContents(%q) returned error: %s", testArtifactName, err)
+	} else if want := `{"problems": 1}`; string(contents.GetData()) != want {
+		t.Errorf("GetArtifactContents(%q) returned %q, want %q", testArtifactName, contents.GetData(), want)
+	}
+
+	// Deleting an earlier revision leaves the current revision in place.
+	req = &rpc.DeleteArtifactRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", testArtifactName, revisions[0].GetRevisionId()),
+	}
+	if _, err := server.DeleteArtifactRevision(ctx, req); err != nil {
+		t.Fatalf("DeleteArtifactRevision(%+v) returned error: %s", req, err)
+	}
+	if _, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: req.GetName()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetArtifact(%q) returned status code %q, want %q: %v", req.GetName(), status.Code(err), codes.NotFound, err)
+	}
+	if want, got := []string{revisions[1].GetRevisionId()}, listArtifactRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListArtifactRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Deleting the only revision deletes the artifact.
+	req = &rpc.DeleteArtifactRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", testArtifactName, revisions[1].GetRevisionId()),
+	}
+	if _, err := server.DeleteArtifactRevision(ctx, req); err != nil {
+		t.Fatalf("DeleteArtifactRevision(%+v) returned error: %s", req, err)
+	}
+	if _, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: testArtifactName}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetArtifact(%q) returned status code %q, want %q: %v", testArtifactName, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestArtifactRevisionRetention(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	server.retention = retention.Config{ArtifactRevisions: 2}
+	revisions := seedArtifactRevisions(ctx, t, server, 2)
+
+	// Tagged revisions are kept even when they are older than the revisions to keep.
+	tagReq := &rpc.TagArtifactRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", testArtifactName, revisions[0].GetRevisionId()),
+		Tag:  "baseline",
+	}
+	if _, err := server.TagArtifactRevision(ctx, tagReq); err != nil {
+		t.Fatalf("Setup: TagArtifactRevision(%+v) returned error: %s", tagReq, err)
+	}
+
+	for i := 2; i < 4; i++ {
+		req := &rpc.ReplaceArtifactRequest{
+			Artifact: &rpc.Artifact{
+				Name:     testArtifactName,
+				Contents: []byte(fmt.Sprintf(`{"problems": %d}`, i)),
+			},
+		}
+		replaced, err := server.ReplaceArtifact(ctx, req)
+		if err != nil {
+			t.Fatalf("ReplaceArtifact(%+v) returned error: %s", req, err)
+		}
+		revisions = append(revisions, replaced)
+	}
+
+	want := []string{revisions[3].GetRevisionId(), revisions[2].GetRevisionId(), revisions[0].GetRevisionId()}
+	if got := listArtifactRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListArtifactRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestArtifactRevisionsResponseCodes(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedArtifactRevisions(ctx, t, server, 1)
+
+	tests := []struct {
+		desc string
+		call func() error
+		want codes.Code
+	}{
+		{
+			desc: "list revisions of missing artifact",
+			call: func() error {
+				_, err := server.ListArtifactRevisions(ctx, &rpc.ListArtifactRevisionsRequest{Name: "projects/my-project/artifacts/missing"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			desc: "list revisions with negative page size",
+			call: func() error {
+				_, err := server.ListArtifactRevisions(ctx, &rpc.ListArtifactRevisionsRequest{Name: testArtifactName, PageSize: -1})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "get missing revision",
+			call: func() error {
+				_, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: testArtifactName + "@missing"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			desc: "delete revision without revision ID",
+			call: func() error {
+				_, err := server.DeleteArtifactRevision(ctx, &rpc.DeleteArtifactRevisionRequest{Name: testArtifactName})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "rollback without revision ID",
+			call: func() error {
+				_, err := server.RollbackArtifact(ctx, &rpc.RollbackArtifactRequest{Name: testArtifactName})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := test.call(); status.Code(err) != test.want {
+				t.Errorf("returned status code %q, want %q: %v", status.Code(err), test.want, err)
+			}
+		})
+	}
+}
@@ -53,8 +53,7 @@ func (s *RegistryServer) CreateArtifact(ctx context.Context, req *rpc.CreateArti
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetArtifact() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid artifact %+v: body must be provided", req.GetArtifact())
@@ -65,6 +64,13 @@ func (s *RegistryServer) CreateArtifact(ctx context.Context, req *rpc.CreateArti
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	replayed := new(rpc.Artifact)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
 	name := parent.Artifact(req.GetArtifactId())
 	if _, err := db.GetArtifact(ctx, name); err == nil {
 		return nil, status.Errorf(codes.AlreadyExists, "artifact %q already exists", name)
@@ -72,40 +78,23 @@ func (s *RegistryServer) CreateArtifact(ctx context.Context, req *rpc.CreateArti
 		return nil, err
 	}
 
-	if err := name.Validate(); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+	artifact, err := newArtifact(ctx, db, parent, name, req.GetArtifact())
+	if err != nil {
+		return nil, err
 	}
 
-	// Creation should only succeed when the parent exists.
-	switch parent := parent.(type) {
-	case names.Project:
-		if _, err := db.GetProject(ctx, parent); err != nil {
-			return nil, err
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveArtifact(ctx, artifact); err != nil {
+			return err
 		}
-	case names.Api:
-		if _, err := db.GetApi(ctx, parent); err != nil {
-			return nil, err
-		}
-	case names.Version:
-		if _, err := db.GetVersion(ctx, parent); err != nil {
-			return nil, err
-		}
-	case names.Spec:
-		if _, err := db.GetSpec(ctx, parent); err != nil {
-			return nil, err
+		if err := db.SaveArtifactContents(ctx, artifact, req.Artifact.GetContents()); err != nil {
+			return err
 		}
-	}
-
-	artifact := models.NewArtifact(name, req.GetArtifact())
-	if err := db.SaveArtifact(ctx, artifact); err != nil {
-		return nil, err
-	}
-
-	if err := db.SaveArtifactContents(ctx, artifact, req.Artifact.GetContents()); err != nil {
+		return s.recordRequest(ctx, db, req, artifact.Message())
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_CREATED, name.String())
 	return artifact.Message(), nil
 }
 
@@ -115,8 +104,7 @@ func (s *RegistryServer) DeleteArtifact(ctx context.Context, req *rpc.DeleteArti
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseArtifact(req.GetName())
 	if err != nil {
@@ -128,27 +116,36 @@ func (s *RegistryServer) DeleteArtifact(ctx context.Context, req *rpc.DeleteArti
 		return nil, err
 	}
 
-	if err := db.DeleteArtifact(ctx, name); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if err := checkArtifactEtag(ctx, db, name, req.GetEtag()); err != nil {
+			return err
+		}
+		return db.DeleteArtifact(ctx, name)
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
 	return &empty.Empty{}, nil
 }
 
 // GetArtifact handles the corresponding API request.
 func (s *RegistryServer) GetArtifact(ctx context.Context, req *rpc.GetArtifactRequest) (*rpc.Artifact, error) {
-	client, err := s.getStorageClient(ctx)
-	if err != nil {
-		return nil, status.Error(codes.Unavailable, err.Error())
+	// Artifact names match the beginning of revision names, so revisions are parsed first.
+	if name, err := names.ParseArtifactRevision(req.GetName()); err == nil {
+		return s.getArtifactRevision(ctx, name)
+	} else if name, err := names.ParseArtifact(req.GetName()); err == nil {
+		return s.getArtifact(ctx, name)
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
 
-	name, err := names.ParseArtifact(req.GetName())
+	return nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q, must be an artifact or revision", req.GetName())
+}
+
+func (s *RegistryServer) getArtifact(ctx context.Context, name names.Artifact) (*rpc.Artifact, error) {
+	client, err := s.getStorageClient(ctx)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, status.Error(codes.Unavailable, err.Error())
 	}
+	db := dao.NewDAO(client, s.blobs)
 
 	artifact, err := db.GetArtifact(ctx, name)
 	if err != nil {
@@ -158,32 +155,56 @@ func (s *RegistryServer) GetArtifact(ctx context.Context, req *rpc.GetArtifactRe
 	return artifact.Message(), nil
 }
 
-// GetArtifactContents handles the corresponding API request.
-func (s *RegistryServer) GetArtifactContents(ctx context.Context, req *rpc.GetArtifactContentsRequest) (*httpbody.HttpBody, error) {
+func (s *RegistryServer) getArtifactRevision(ctx context.Context, name names.ArtifactRevision) (*rpc.Artifact, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
-	name, err := names.ParseArtifact(strings.TrimSuffix(req.GetName(), "/contents"))
+	revision, err := db.GetArtifactRevision(ctx, name)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, err
 	}
 
-	artifact, err := db.GetArtifact(ctx, name)
+	return revision.Message(name.String()), nil
+}
+
+// GetArtifactContents handles the corresponding API request.
+func (s *RegistryServer) GetArtifactContents(ctx context.Context, req *rpc.GetArtifactContentsRequest) (*httpbody.HttpBody, error) {
+	client, err := s.getStorageClient(ctx)
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.Unavailable, err.Error())
 	}
+	db := dao.NewDAO(client, s.blobs)
 
-	blob, err := db.GetArtifactContents(ctx, name)
-	if err != nil {
-		return nil, err
+	var artifactName = strings.TrimSuffix(req.GetName(), "/contents")
+	var mimeType string
+	var blob *models.Blob
+	if name, err := names.ParseArtifactRevision(artifactName); err == nil {
+		revision, err := db.GetArtifactRevision(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		mimeType = revision.MimeType
+		if blob, err = db.GetArtifactRevisionContents(ctx, name); err != nil {
+			return nil, err
+		}
+	} else if name, err := names.ParseArtifact(artifactName); err == nil {
+		artifact, err := db.GetArtifact(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		mimeType = artifact.MimeType
+		if blob, err = db.GetArtifactContents(ctx, name); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q, must be an artifact or revision", artifactName)
 	}
 
 	return &httpbody.HttpBody{
-		ContentType: artifact.MimeType,
+		ContentType: mimeType,
 		Data:        blob.Contents,
 	}, nil
 }
@@ -194,8 +215,7 @@ func (s *RegistryServer) ListArtifacts(ctx context.Context, req *rpc.ListArtifac
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -214,27 +234,31 @@ func (s *RegistryServer) ListArtifacts(ctx context.Context, req *rpc.ListArtifac
 	switch parent := parent.(type) {
 	case names.Project:
 		listing, err = db.ListProjectArtifacts(ctx, parent, dao.PageOptions{
-			Size:   req.GetPageSize(),
-			Filter: req.GetFilter(),
-			Token:  req.GetPageToken(),
+			Size:    req.GetPageSize(),
+			Filter:  req.GetFilter(),
+			Token:   req.GetPageToken(),
+			OrderBy: req.GetOrderBy(),
 		})
 	case names.Api:
 		listing, err = db.ListApiArtifacts(ctx, parent, dao.PageOptions{
-			Size:   req.GetPageSize(),
-			Filter: req.GetFilter(),
-			Token:  req.GetPageToken(),
+			Size:    req.GetPageSize(),
+			Filter:  req.GetFilter(),
+			Token:   req.GetPageToken(),
+			OrderBy: req.GetOrderBy(),
 		})
 	case names.Version:
 		listing, err = db.ListVersionArtifacts(ctx, parent, dao.PageOptions{
-			Size:   req.GetPageSize(),
-			Filter: req.GetFilter(),
-			Token:  req.GetPageToken(),
+			Size:    req.GetPageSize(),
+			Filter:  req.GetFilter(),
+			Token:   req.GetPageToken(),
+			OrderBy: req.GetOrderBy(),
 		})
 	case names.Spec:
 		listing, err = db.ListSpecArtifacts(ctx, parent, dao.PageOptions{
-			Size:   req.GetPageSize(),
-			Filter: req.GetFilter(),
-			Token:  req.GetPageToken(),
+			Size:    req.GetPageSize(),
+			Filter:  req.GetFilter(),
+			Token:   req.GetPageToken(),
+			OrderBy: req.GetOrderBy(),
 		})
 	}
 	if err != nil {
@@ -259,28 +283,74 @@ func (s *RegistryServer) ReplaceArtifact(ctx context.Context, req *rpc.ReplaceAr
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseArtifact(req.Artifact.GetName())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	// Replacement should only succeed on artifacts that currently exist.
-	if _, err := db.GetArtifact(ctx, name); err != nil {
+	replayed := new(rpc.Artifact)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
+	} else if ok {
+		return replayed, nil
 	}
 
-	artifact := models.NewArtifact(name, req.GetArtifact())
-	if err := db.SaveArtifact(ctx, artifact); err != nil {
+	// Replacement should only succeed on artifacts that currently exist.
+	existing, err := db.GetArtifact(ctx, name)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := db.SaveArtifactContents(ctx, artifact, req.Artifact.GetContents()); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	// Changed contents are saved as a new revision, and older revisions
+	// that aren't kept by the artifact retention policy are deleted.
+	artifact := existing.Replace(req.GetArtifact())
+	if err := s.commit(ctx, db, rpc.Notification_UPDATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveArtifact(ctx, artifact); err != nil {
+			return err
+		}
+		if err := db.SaveArtifactContents(ctx, artifact, req.Artifact.GetContents()); err != nil {
+			return err
+		}
+		if artifact.RevisionID != existing.RevisionID {
+			if err := s.pruneArtifactRevisions(ctx, db, name); err != nil {
+				return err
+			}
+		}
+		return s.recordRequest(ctx, db, req, artifact.Message())
+	}); err != nil {
+		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, name.String())
 	return artifact.Message(), nil
 }
+
+// newArtifact returns a new artifact with the specified name after checking that it can be created.
+func newArtifact(ctx context.Context, db dao.DAO, parent artifactParent, name names.Artifact, body *rpc.Artifact) (*models.Artifact, error) {
+	if err := name.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Creation should only succeed when the parent exists.
+	switch parent := parent.(type) {
+	case names.Project:
+		if _, err := db.GetProject(ctx, parent); err != nil {
+			return nil, err
+		}
+	case names.Api:
+		if _, err := db.GetApi(ctx, parent); err != nil {
+			return nil, err
+		}
+	case names.Version:
+		if _, err := db.GetVersion(ctx, parent); err != nil {
+			return nil, err
+		}
+	case names.Spec:
+		if _, err := db.GetSpec(ctx, parent); err != nil {
+			return nil, err
+		}
+	}
+
+	return models.NewArtifact(name, body), nil
+}
@@ -121,7 +121,7 @@ func TestCreateArtifact(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Artifact), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "create_time", "update_time", "revision_create_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, created, opts) {
@@ -348,7 +348,7 @@ func TestGetArtifact(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Artifact), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "create_time", "update_time", "revision_create_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, got, opts) {
@@ -602,7 +602,7 @@ func TestListArtifacts(t *testing.T) {
 			opts := cmp.Options{
 				protocmp.Transform(),
 				protocmp.IgnoreFields(new(rpc.ListArtifactsResponse), "next_page_token"),
-				protocmp.IgnoreFields(new(rpc.Artifact), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "create_time", "update_time", "revision_create_time", "etag"),
 				protocmp.SortRepeated(func(a, b *rpc.Artifact) bool {
 					return a.GetName() < b.GetName()
 				}),
@@ -781,7 +781,7 @@ func TestListArtifactsSequence(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.Artifact), "create_time", "update_time"),
+		protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "create_time", "update_time", "revision_create_time", "etag"),
 		cmpopts.SortSlices(func(a, b *rpc.Artifact) bool {
 			return a.GetName() < b.GetName()
 		}),
@@ -866,7 +866,7 @@ func TestReplaceArtifact(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Artifact), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Artifact), "revision_id", "create_time", "update_time", "revision_create_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, updated, opts) {
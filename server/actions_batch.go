@@ -0,0 +1,306 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchSize is the maximum number of resources in a batch request.
+const maxBatchSize = 1000
+
+func validateBatchSize(size int) error {
+	if size == 0 {
+		return status.Error(codes.InvalidArgument, "invalid batch: at least one resource must be provided")
+	} else if size > maxBatchSize {
+		return status.Errorf(codes.InvalidArgument, "invalid batch of %d resources: must not exceed %d", size, maxBatchSize)
+	}
+	return nil
+}
+
+// BatchGetApiSpecs handles the corresponding API request.
+func (s *RegistryServer) BatchGetApiSpecs(ctx context.Context, req *rpc.BatchGetApiSpecsRequest) (*rpc.BatchGetApiSpecsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	parent, err := names.ParseVersion(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validateBatchSize(len(req.GetNames())); err != nil {
+		return nil, err
+	}
+
+	response := &rpc.BatchGetApiSpecsResponse{
+		ApiSpecs: make([]*rpc.ApiSpec, len(req.GetNames())),
+	}
+
+	for i, n := range req.GetNames() {
+		var spec *models.Spec
+		if name, err := names.ParseSpec(n); err == nil {
+			if name.Version() != parent {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be a child of %q", n, parent)
+			}
+			spec, err = db.GetSpecIncludingDeleted(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+		} else if name, err := names.ParseSpecRevision(n); err == nil {
+			if name.Spec().Version() != parent {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be a child of %q", n, parent)
+			}
+			spec, err = db.GetSpecRevisionIncludingDeleted(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be a spec or spec revision", n)
+		}
+
+		response.ApiSpecs[i], err = spec.BasicMessage(n)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+// BatchCreateApiSpecs handles the corresponding API request.
+func (s *RegistryServer) BatchCreateApiSpecs(ctx context.Context, req *rpc.BatchCreateApiSpecsRequest) (*rpc.BatchCreateApiSpecsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	parent, err := names.ParseVersion(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validateBatchSize(len(req.GetRequests())); err != nil {
+		return nil, err
+	}
+
+	for _, r := range req.GetRequests() {
+		if r.GetParent() != "" && r.GetParent() != req.GetParent() {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parent %q: must match %q", r.GetParent(), req.GetParent())
+		} else if r.GetApiSpec() == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid api_spec %+v: body must be provided", r.GetApiSpec())
+		}
+	}
+
+	// Specs are created in one transaction, so either all of them are created or none are.
+	specs := make([]*models.Spec, len(req.GetRequests()))
+	if err := s.commitChanges(ctx, db, func(db dao.DAO) ([]*models.Change, error) {
+		changes := make([]*models.Change, len(req.GetRequests()))
+		for i, r := range req.GetRequests() {
+			name := parent.Spec(r.GetApiSpecId())
+			if _, err := db.GetSpecIncludingDeleted(ctx, name); err == nil {
+				return nil, status.Errorf(codes.AlreadyExists, "API spec %q already exists", name)
+			} else if !isNotFound(err) {
+				return nil, err
+			}
+
+			spec, err := newSpec(ctx, db, name, r.GetApiSpec())
+			if err != nil {
+				return nil, err
+			}
+
+			if err := db.SaveSpecRevision(ctx, spec); err != nil {
+				return nil, err
+			}
+			if err := db.SaveSpecRevisionContents(ctx, spec, r.ApiSpec.GetContents()); err != nil {
+				return nil, err
+			}
+
+			specs[i] = spec
+			changes[i] = models.NewChange(rpc.Notification_CREATED, spec.RevisionName())
+		}
+		return changes, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	response := &rpc.BatchCreateApiSpecsResponse{
+		ApiSpecs: make([]*rpc.ApiSpec, len(specs)),
+	}
+
+	for i, spec := range specs {
+		response.ApiSpecs[i], err = spec.BasicMessage(spec.Name())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+// BatchUpdateArtifacts handles the corresponding API request.
+func (s *RegistryServer) BatchUpdateArtifacts(ctx context.Context, req *rpc.BatchUpdateArtifactsRequest) (*rpc.BatchUpdateArtifactsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	parent, err := parseArtifactParent(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validateBatchSize(len(req.GetRequests())); err != nil {
+		return nil, err
+	}
+
+	artifactNames := make([]names.Artifact, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		name, err := names.ParseArtifact(r.GetArtifact().GetName())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		} else if parent.Artifact(name.ArtifactID()).String() != name.String() {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be a child of %q", name, req.GetParent())
+		}
+		artifactNames[i] = name
+	}
+
+	// Artifacts are replaced in one transaction, so either all of them are changed or none are.
+	artifacts := make([]*models.Artifact, len(req.GetRequests()))
+	if err := s.commitChanges(ctx, db, func(db dao.DAO) ([]*models.Change, error) {
+		changes := make([]*models.Change, len(req.GetRequests()))
+		for i, r := range req.GetRequests() {
+			name := artifactNames[i]
+			artifact, change, err := s.replaceArtifact(ctx, db, parent, name, r.GetArtifact(), req.GetAllowMissing())
+			if err != nil {
+				return nil, err
+			}
+			artifacts[i] = artifact
+			changes[i] = models.NewChange(change, name.String())
+		}
+		return changes, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	response := &rpc.BatchUpdateArtifactsResponse{
+		Artifacts: make([]*rpc.Artifact, len(artifacts)),
+	}
+
+	for i, artifact := range artifacts {
+		response.Artifacts[i] = artifact.Message()
+	}
+
+	return response, nil
+}
+
+// replaceArtifact saves the contents of an artifact, which is created if it doesn't exist and allowMissing is true.
+// The artifact is read for update, so its etag can't change before it is saved.
+func (s *RegistryServer) replaceArtifact(ctx context.Context, db dao.DAO, parent artifactParent, name names.Artifact, body *rpc.Artifact, allowMissing bool) (*models.Artifact, rpc.Notification_Change, error) {
+	locked := db.ForUpdate()
+	existing, err := locked.GetArtifact(ctx, name)
+	if allowMissing && isNotFound(err) {
+		artifact, err := newArtifact(ctx, db, parent, name, body)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := db.SaveArtifact(ctx, artifact); err != nil {
+			return nil, 0, err
+		}
+		return artifact, rpc.Notification_CREATED, db.SaveArtifactContents(ctx, artifact, body.GetContents())
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	if err := checkEtag(name.String(), body.GetEtag(), existing.Message().GetEtag()); err != nil {
+		return nil, 0, err
+	}
+
+	artifact := existing.Replace(body)
+	if err := db.SaveArtifact(ctx, artifact); err != nil {
+		return nil, 0, err
+	}
+	if err := db.SaveArtifactContents(ctx, artifact, body.GetContents()); err != nil {
+		return nil, 0, err
+	}
+	if artifact.RevisionID != existing.RevisionID {
+		if err := s.pruneArtifactRevisions(ctx, db, name); err != nil {
+			return nil, 0, err
+		}
+	}
+	return artifact, rpc.Notification_UPDATED, nil
+}
+
+// BatchDeleteApis handles the corresponding API request.
+func (s *RegistryServer) BatchDeleteApis(ctx context.Context, req *rpc.BatchDeleteApisRequest) (*empty.Empty, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	parent, err := names.ParseProject(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validateBatchSize(len(req.GetNames())); err != nil {
+		return nil, err
+	}
+
+	apiNames := make([]names.Api, len(req.GetNames()))
+	for i, n := range req.GetNames() {
+		name, err := names.ParseApi(n)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		} else if name.Project() != parent {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be a child of %q", n, parent)
+		}
+		apiNames[i] = name
+	}
+
+	// APIs are deleted in one transaction, so either all of them are deleted or none are.
+	deleteTime, purgeTime := s.deletionTimes()
+	if err := s.commitChanges(ctx, db, func(db dao.DAO) ([]*models.Change, error) {
+		changes := make([]*models.Change, len(apiNames))
+		for i, name := range apiNames {
+			// Deletion should only succeed on APIs that currently exist.
+			if _, err := db.GetApi(ctx, name); err != nil {
+				return nil, err
+			}
+			if err := db.SoftDeleteApi(ctx, name, deleteTime, purgeTime); err != nil {
+				return nil, err
+			}
+			changes[i] = models.NewChange(rpc.Notification_DELETED, name.String())
+		}
+		return changes, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &empty.Empty{}, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBatchGetApiSpecs(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server,
+		&rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/first"},
+		&rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/second"},
+		&rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v2/specs/other"},
+	)
+
+	req := &rpc.BatchGetApiSpecsRequest{
+		Parent: "projects/my-project/apis/my-api/versions/v1",
+		Names: []string{
+			"projects/my-project/apis/my-api/versions/v1/specs/second",
+			"projects/my-project/apis/my-api/versions/v1/specs/first",
+		},
+	}
+	got, err := server.BatchGetApiSpecs(ctx, req)
+	if err != nil {
+		t.Fatalf("BatchGetApiSpecs(%+v) returned error: %s", req, err)
+	} else if len(got.GetApiSpecs()) != len(req.GetNames()) {
+		t.Fatalf("BatchGetApiSpecs(%+v) returned %d specs, want %d", req, len(got.GetApiSpecs()), len(req.GetNames()))
+	}
+	for i, spec := range got.GetApiSpecs() {
+		if spec.GetName() != req.GetNames()[i] {
+			t.Errorf("BatchGetApiSpecs(%+v) returned spec %q at position %d, want %q", req, spec.GetName(), i, req.GetNames()[i])
+		}
+	}
+
+	tests := []struct {
+		desc  string
+		names []string
+		want  codes.Code
+	}{
+		{
+			desc:  "missing spec",
+			names: []string{"projects/my-project/apis/my-api/versions/v1/specs/first", "projects/my-project/apis/my-api/versions/v1/specs/missing"},
+			want:  codes.NotFound,
+		},
+		{
+			desc:  "spec in another version",
+			names: []string{"projects/my-project/apis/my-api/versions/v2/specs/other"},
+			want:  codes.InvalidArgument,
+		},
+		{
+			desc:  "no names",
+			names: []string{},
+			want:  codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := &rpc.BatchGetApiSpecsRequest{
+				Parent: "projects/my-project/apis/my-api/versions/v1",
+				Names:  test.names,
+			}
+			if _, err := server.BatchGetApiSpecs(ctx, req); status.Code(err) != test.want {
+				t.Errorf("BatchGetApiSpecs(%+v) returned status code %q, want %q: %v", req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestBatchCreateApiSpecs(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedVersions(ctx, t, server, &rpc.ApiVersion{Name: "projects/my-project/apis/my-api/versions/v1"})
+
+	req := &rpc.BatchCreateApiSpecsRequest{
+		Parent: "projects/my-project/apis/my-api/versions/v1",
+		Requests: []*rpc.CreateApiSpecRequest{
+			{ApiSpecId: "first", ApiSpec: &rpc.ApiSpec{Contents: []byte("first")}},
+			{ApiSpecId: "second", ApiSpec: &rpc.ApiSpec{Contents: []byte("second")}},
+		},
+	}
+	created, err := server.BatchCreateApiSpecs(ctx, req)
+	if err != nil {
+		t.Fatalf("BatchCreateApiSpecs(%+v) returned error: %s", req, err)
+	} else if len(created.GetApiSpecs()) != 2 {
+		t.Fatalf("BatchCreateApiSpecs(%+v) returned %d specs, want 2", req, len(created.GetApiSpecs()))
+	}
+
+	for _, spec := range created.GetApiSpecs() {
+		if _, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: spec.GetName()}); err != nil {
+			t.Errorf("GetApiSpec(%q) returned error: %s", spec.GetName(), err)
+		}
+	}
+
+	// A batch that includes an existing spec should fail without creating any of the others.
+	req = &rpc.BatchCreateApiSpecsRequest{
+		Parent: "projects/my-project/apis/my-api/versions/v1",
+		Requests: []*rpc.CreateApiSpecRequest{
+			{ApiSpecId: "third", ApiSpec: &rpc.ApiSpec{}},
+			{ApiSpecId: "first", ApiSpec: &rpc.ApiSpec{}},
+		},
+	}
+	if _, err := server.BatchCreateApiSpecs(ctx, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("BatchCreateApiSpecs(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.AlreadyExists, err)
+	}
+
+	name := "projects/my-project/apis/my-api/versions/v1/specs/third"
+	if _, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: name}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiSpec(%q) returned status code %q, want %q: %v", name, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestBatchCreateApiSpecsInvalid(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedVersions(ctx, t, server, &rpc.ApiVersion{Name: "projects/my-project/apis/my-api/versions/v1"})
+
+	tooMany := make([]*rpc.CreateApiSpecRequest, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = &rpc.CreateApiSpecRequest{ApiSpecId: fmt.Sprintf("spec-%d", i), ApiSpec: &rpc.ApiSpec{}}
+	}
+
+	tests := []struct {
+		desc     string
+		requests []*rpc.CreateApiSpecRequest
+	}{
+		{
+			desc: "mismatched parent",
+			requests: []*rpc.CreateApiSpecRequest{
+				{Parent: "projects/my-project/apis/my-api/versions/v2", ApiSpecId: "my-spec", ApiSpec: &rpc.ApiSpec{}},
+			},
+		},
+		{
+			desc: "missing body",
+			requests: []*rpc.CreateApiSpecRequest{
+				{ApiSpecId: "my-spec"},
+			},
+		},
+		{
+			desc: "invalid identifier",
+			requests: []*rpc.CreateApiSpecRequest{
+				{ApiSpecId: "valid", ApiSpec: &rpc.ApiSpec{}},
+				{ApiSpecId: "Invalid!", ApiSpec: &rpc.ApiSpec{}},
+			},
+		},
+		{
+			desc:     "too many requests",
+			requests: tooMany,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := &rpc.BatchCreateApiSpecsRequest{
+				Parent:   "projects/my-project/apis/my-api/versions/v1",
+				Requests: test.requests,
+			}
+			if _, err := server.BatchCreateApiSpecs(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("BatchCreateApiSpecs(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+			}
+
+			name := "projects/my-project/apis/my-api/versions/v1/specs/valid"
+			if _, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: name}); status.Code(err) != codes.NotFound {
+				t.Errorf("GetApiSpec(%q) returned status code %q, want %q: %v", name, status.Code(err), codes.NotFound, err)
+			}
+		})
+	}
+}
+
+func TestBatchUpdateArtifacts(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedArtifacts(ctx, t, server, &rpc.Artifact{
+		Name:     "projects/my-project/artifacts/existing",
+		MimeType: "text/plain",
+		Contents: []byte("original"),
+	})
+
+	req := &rpc.BatchUpdateArtifactsRequest{
+		Parent: "projects/my-project",
+		Requests: []*rpc.ReplaceArtifactRequest{
+			{Artifact: &rpc.Artifact{Name: "projects/my-project/artifacts/existing", MimeType: "text/plain", Contents: []byte("updated")}},
+			{Artifact: &rpc.Artifact{Name: "projects/my-project/artifacts/missing", MimeType: "text/plain", Contents: []byte("created")}},
+		},
+	}
+
+	// Without allow_missing, the missing artifact should fail the whole batch.
+	if _, err := server.BatchUpdateArtifacts(ctx, req); status.Code(err) != codes.NotFound {
+		t.Fatalf("BatchUpdateArtifacts(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+	contents, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: "projects/my-project/artifacts/existing"})
+	if err != nil {
+		t.Fatalf("GetArtifactContents() returned error: %s", err)
+	} else if string(contents.GetData()) != "original" {
+		t.Errorf("GetArtifactContents() returned %q, want %q", contents.GetData(), "original")
+	}
+
+	req.AllowMissing = true
+	got, err := server.BatchUpdateArtifacts(ctx, req)
+	if err != nil {
+		t.Fatalf("BatchUpdateArtifacts(%+v) returned error: %s", req, err)
+	} else if len(got.GetArtifacts()) != 2 {
+		t.Fatalf("BatchUpdateArtifacts(%+v) returned %d artifacts, want 2", req, len(got.GetArtifacts()))
+	}
+
+	for _, r := range req.GetRequests() {
+		contents, err := server.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: r.GetArtifact().GetName()})
+		if err != nil {
+			t.Fatalf("GetArtifactContents(%q) returned error: %s", r.GetArtifact().GetName(), err)
+		} else if string(contents.GetData()) != string(r.GetArtifact().GetContents()) {
+			t.Errorf("GetArtifactContents(%q) returned %q, want %q", r.GetArtifact().GetName(), contents.GetData(), r.GetArtifact().GetContents())
+		}
+	}
+
+	// Artifacts must be children of the parent.
+	req = &rpc.BatchUpdateArtifactsRequest{
+		Parent: "projects/my-project/apis/my-api",
+		Requests: []*rpc.ReplaceArtifactRequest{
+			{Artifact: &rpc.Artifact{Name: "projects/my-project/artifacts/existing"}},
+		},
+	}
+	if _, err := server.BatchUpdateArtifacts(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchUpdateArtifacts(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+	}
+}
+
+func TestBatchDeleteApis(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server,
+		&rpc.Api{Name: "projects/my-project/apis/first"},
+		&rpc.Api{Name: "projects/my-project/apis/second"},
+	)
+
+	// A batch that includes a missing api should fail without deleting any of the others.
+	req := &rpc.BatchDeleteApisRequest{
+		Parent: "projects/my-project",
+		Names: []string{
+			"projects/my-project/apis/first",
+			"projects/my-project/apis/missing",
+		},
+	}
+	if _, err := server.BatchDeleteApis(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("BatchDeleteApis(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+	if _, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/first"}); err != nil {
+		t.Errorf("GetApi() returned error: %s", err)
+	}
+
+	req = &rpc.BatchDeleteApisRequest{
+		Parent: "projects/my-project",
+		Names: []string{
+			"projects/other-project/apis/first",
+		},
+	}
+	if _, err := server.BatchDeleteApis(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchDeleteApis(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+	}
+
+	req = &rpc.BatchDeleteApisRequest{
+		Parent: "projects/my-project",
+		Names: []string{
+			"projects/my-project/apis/first",
+			"projects/my-project/apis/second",
+		},
+	}
+	if _, err := server.BatchDeleteApis(ctx, req); err != nil {
+		t.Fatalf("BatchDeleteApis(%+v) returned error: %s", req, err)
+	}
+
+	for _, name := range req.GetNames() {
+		if got, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: name}); err != nil {
+			t.Errorf("GetApi(%q) returned error: %s", name, err)
+		} else if got.GetDeleteTime() == nil {
+			t.Errorf("GetApi(%q) returned no delete_time, want the API to be deleted", name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListChanges handles the corresponding API request.
+func (s *RegistryServer) ListChanges(ctx context.Context, req *rpc.ListChangesRequest) (*rpc.ListChangesResponse, error) {
+	pattern, err := parseWatchPattern(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
+	} else if req.GetPageSize() > 1000 {
+		req.PageSize = 1000
+	} else if req.GetPageSize() == 0 {
+		req.PageSize = 50
+	}
+
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	listing, err := db.ListChanges(ctx, dao.PageOptions{
+		Size:  req.GetPageSize(),
+		Token: req.GetSinceToken(),
+	}, func(c *models.Change) bool {
+		return pattern.Matches(c.Resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &rpc.ListChangesResponse{
+		Changes:   make([]*rpc.Notification, len(listing.Changes)),
+		NextToken: listing.Token,
+	}
+
+	for i := range listing.Changes {
+		response.Changes[i] = listing.Changes[i].Message()
+	}
+
+	return response, nil
+}
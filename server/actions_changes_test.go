@@ -0,0 +1,345 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/notify"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type change struct {
+	change   rpc.Notification_Change
+	resource string
+}
+
+func changesOf(notifications []*rpc.Notification) []change {
+	changes := make([]change, len(notifications))
+	for i, n := range notifications {
+		changes[i] = change{n.GetChange(), n.GetResource()}
+	}
+	return changes
+}
+
+func equalChanges(a, b []change) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListChanges(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+
+	// An empty log returns a token that can be used to resume later.
+	empty, err := server.ListChanges(ctx, &rpc.ListChangesRequest{Parent: "projects/-"})
+	if err != nil {
+		t.Fatalf("ListChanges() returned error: %s", err)
+	} else if len(empty.GetChanges()) > 0 || empty.GetNextToken() == "" {
+		t.Fatalf("ListChanges() returned %d changes with token %q, want no changes and a token", len(empty.GetChanges()), empty.GetNextToken())
+	}
+
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+	for i := 1; i <= 3; i++ {
+		if _, err := server.CreateApi(ctx, &rpc.CreateApiRequest{
+			Parent: "projects/my-project",
+			ApiId:  fmt.Sprintf("a%d", i),
+			Api:    &rpc.Api{},
+		}); err != nil {
+			t.Fatalf("Setup: CreateApi() returned error: %s", err)
+		}
+	}
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/a1"}); err != nil {
+		t.Fatalf("Setup: DeleteApi() returned error: %s", err)
+	}
+
+	tests := []struct {
+		desc  string
+		req   *rpc.ListChangesRequest
+		token string
+		want  []change
+	}{
+		{
+			desc: "all changes",
+			req:  &rpc.ListChangesRequest{Parent: "projects/-"},
+			want: []change{
+				{rpc.Notification_CREATED, "projects/my-project"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a1"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a2"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a3"},
+				{rpc.Notification_DELETED, "projects/my-project/apis/a1"},
+			},
+		},
+		{
+			desc: "matching pattern",
+			req:  &rpc.ListChangesRequest{Parent: "projects/my-project/apis/a1"},
+			want: []change{
+				{rpc.Notification_CREATED, "projects/my-project/apis/a1"},
+				{rpc.Notification_DELETED, "projects/my-project/apis/a1"},
+			},
+		},
+		{
+			desc:  "since token",
+			req:   &rpc.ListChangesRequest{Parent: "projects/-"},
+			token: empty.GetNextToken(),
+			want: []change{
+				{rpc.Notification_CREATED, "projects/my-project"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a1"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a2"},
+				{rpc.Notification_CREATED, "projects/my-project/apis/a3"},
+				{rpc.Notification_DELETED, "projects/my-project/apis/a1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			test.req.SinceToken = test.token
+			got, err := server.ListChanges(ctx, test.req)
+			if err != nil {
+				t.Fatalf("ListChanges(%+v) returned error: %s", test.req, err)
+			}
+
+			if !equalChanges(changesOf(got.GetChanges()), test.want) {
+				t.Errorf("ListChanges(%+v) returned %v, want %v", test.req, changesOf(got.GetChanges()), test.want)
+			}
+		})
+	}
+
+	t.Run("paging", func(t *testing.T) {
+		var got []change
+		req := &rpc.ListChangesRequest{Parent: "projects/my-project/apis/-", PageSize: 1}
+		for i := 0; i < 5; i++ {
+			resp, err := server.ListChanges(ctx, req)
+			if err != nil {
+				t.Fatalf("ListChanges(%+v) returned error: %s", req, err)
+			}
+			got = append(got, changesOf(resp.GetChanges())...)
+			req.SinceToken = resp.GetNextToken()
+		}
+
+		want := []change{
+			{rpc.Notification_CREATED, "projects/my-project/apis/a1"},
+			{rpc.Notification_CREATED, "projects/my-project/apis/a2"},
+			{rpc.Notification_CREATED, "projects/my-project/apis/a3"},
+			{rpc.Notification_DELETED, "projects/my-project/apis/a1"},
+		}
+		if !equalChanges(got, want) {
+			t.Errorf("ListChanges() pages returned %v, want %v", got, want)
+		}
+	})
+}
+
+func TestListChangesInvalidRequests(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+
+	tests := []*rpc.ListChangesRequest{
+		{Parent: "projects"},
+		{Parent: "projects/-", PageSize: -1},
+		{Parent: "projects/-", SinceToken: "this token is not valid"},
+	}
+
+	for _, req := range tests {
+		if _, err := server.ListChanges(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListChanges(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+		}
+	}
+}
+
+func TestDrainOutbox(t *testing.T) {
+	ctx := context.Background()
+	notifier := notify.NewMemory()
+	server := New(Config{
+		Database: "sqlite3",
+		DBConfig: fmt.Sprintf("%s/registry.db", t.TempDir()),
+		Notifier: notifier,
+	})
+	t.Cleanup(server.Close)
+	sub := notifier.Subscribe(10, nil)
+
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+	if _, err := server.CreateApi(ctx, &rpc.CreateApiRequest{
+		Parent: "projects/my-project",
+		ApiId:  "my-api",
+		Api:    &rpc.Api{},
+	}); err != nil {
+		t.Fatalf("Setup: CreateApi() returned error: %s", err)
+	}
+
+	// Changes are only sent to the notifier when the outbox is drained.
+	if len(sub.C) > 0 {
+		t.Fatalf("Notifier received %d notifications before the outbox was drained", len(sub.C))
+	}
+
+	if err := server.drainOutbox(ctx); err != nil {
+		t.Fatalf("drainOutbox() returned error: %s", err)
+	}
+
+	// Dispatched changes are not sent again.
+	if err := server.drainOutbox(ctx); err != nil {
+		t.Fatalf("drainOutbox() returned error: %s", err)
+	}
+
+	got := make([]*rpc.Notification, 0)
+	for len(sub.C) > 0 {
+		got = append(got, <-sub.C)
+	}
+
+	want := []change{
+		{rpc.Notification_CREATED, "projects/my-project"},
+		{rpc.Notification_CREATED, "projects/my-project/apis/my-api"},
+	}
+	if !equalChanges(changesOf(got), want) {
+		t.Errorf("Notifier received %v, want %v", changesOf(got), want)
+	}
+}
+
+// failingNotifier rejects notifications until it is told to accept them.
+type failingNotifier struct {
+	*notify.MemoryNotifier
+	fail bool
+}
+
+func (n *failingNotifier) Notify(ctx context.Context, notification *rpc.Notification) error {
+	if n.fail {
+		return errors.New("destination is unavailable")
+	}
+	return n.MemoryNotifier.Notify(ctx, notification)
+}
+
+func TestDrainOutboxFailure(t *testing.T) {
+	ctx := context.Background()
+	notifier := &failingNotifier{MemoryNotifier: notify.NewMemory(), fail: true}
+	server := New(Config{
+		Database: "sqlite3",
+		DBConfig: fmt.Sprintf("%s/registry.db", t.TempDir()),
+		Notifier: notifier,
+	})
+	t.Cleanup(server.Close)
+	sub := notifier.Subscribe(10, nil)
+
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+	if err := server.drainOutbox(ctx); err == nil {
+		t.Fatalf("drainOutbox() succeeded, want error from the notifier")
+	}
+
+	// Changes that weren't delivered stay in the outbox.
+	client, err := server.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("getStorageClient() returned error: %s", err)
+	}
+	db := dao.NewDAO(client, server.blobs)
+	undispatched, err := db.ListUndispatchedChanges(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListUndispatchedChanges() returned error: %s", err)
+	} else if len(undispatched) != 1 {
+		t.Fatalf("ListUndispatchedChanges() returned %d changes, want 1", len(undispatched))
+	}
+
+	notifier.fail = false
+	if err := server.drainOutbox(ctx); err != nil {
+		t.Fatalf("drainOutbox() returned error: %s", err)
+	}
+
+	got := make([]*rpc.Notification, 0)
+	for len(sub.C) > 0 {
+		got = append(got, <-sub.C)
+	}
+	want := []change{{rpc.Notification_CREATED, "projects/my-project"}}
+	if !equalChanges(changesOf(got), want) {
+		t.Errorf("Notifier received %v, want %v", changesOf(got), want)
+	}
+}
+
+func TestListChangesWaitsForGaps(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	start, err := server.ListChanges(ctx, &rpc.ListChangesRequest{Parent: "projects/-"})
+	if err != nil {
+		t.Fatalf("Setup: ListChanges() returned error: %s", err)
+	}
+
+	client, err := server.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("Setup: getStorageClient() returned error: %s", err)
+	}
+	db := dao.NewDAO(client, server.blobs)
+	changes, err := db.ListChanges(ctx, dao.PageOptions{Size: 10}, func(*models.Change) bool { return true })
+	if err != nil {
+		t.Fatalf("Setup: ListChanges() returned error: %s", err)
+	}
+	last := changes.Changes[len(changes.Changes)-1].ID
+
+	// Changes are saved with explicit IDs to commit them out of order.
+	save := func(id int64, resource string, changeTime time.Time) {
+		t.Helper()
+		c := models.NewChange(rpc.Notification_CREATED, resource)
+		c.ID, c.ChangeTime, c.Dispatched = id, changeTime, true
+		if err := db.SaveChange(ctx, c); err != nil {
+			t.Fatalf("Setup: SaveChange(%d) returned error: %s", id, err)
+		}
+	}
+	list := func(token string) *rpc.ListChangesResponse {
+		t.Helper()
+		resp, err := server.ListChanges(ctx, &rpc.ListChangesRequest{Parent: "projects/-", SinceToken: token})
+		if err != nil {
+			t.Fatalf("ListChanges() returned error: %s", err)
+		}
+		return resp
+	}
+
+	// A recent change after a gap isn't listed until the gap is filled.
+	save(last+2, "projects/my-project/apis/later", time.Now())
+	resp := list(start.GetNextToken())
+	if got := changesOf(resp.GetChanges()); len(got) != 0 {
+		t.Errorf("ListChanges() returned %v after a recent gap, want no changes", got)
+	}
+
+	save(last+1, "projects/my-project/apis/earlier", time.Now())
+	resp = list(resp.GetNextToken())
+	want := []change{
+		{rpc.Notification_CREATED, "projects/my-project/apis/earlier"},
+		{rpc.Notification_CREATED, "projects/my-project/apis/later"},
+	}
+	if got := changesOf(resp.GetChanges()); !equalChanges(got, want) {
+		t.Errorf("ListChanges() returned %v after the gap was filled, want %v", got, want)
+	}
+
+	// Old gaps are left by transactions that were rolled back and are skipped.
+	save(last+4, "projects/my-project/apis/old", time.Now().Add(-time.Hour))
+	resp = list(resp.GetNextToken())
+	want = []change{{rpc.Notification_CREATED, "projects/my-project/apis/old"}}
+	if got := changesOf(resp.GetChanges()); !equalChanges(got, want) {
+		t.Errorf("ListChanges() returned %v after an old gap, want %v", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testDeploymentName = "projects/my-project/apis/my-api/deployments/prod"
+
+// seedDeploymentRevisions creates a deployment with count revisions and returns them from oldest to newest.
+func seedDeploymentRevisions(ctx context.Context, t *testing.T, s *RegistryServer, count int) []*rpc.ApiDeployment {
+	t.Helper()
+
+	seedDeployments(ctx, t, s, &rpc.ApiDeployment{
+		Name:        testDeploymentName,
+		EndpointUri: "https://0.api.example.com",
+	})
+
+	first, err := s.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: testDeploymentName})
+	if err != nil {
+		t.Fatalf("Setup: GetApiDeployment(%q) returned error: %s", testDeploymentName, err)
+	}
+
+	revisions := []*rpc.ApiDeployment{first}
+	for i := 1; i < count; i++ {
+		req := &rpc.UpdateApiDeploymentRequest{
+			ApiDeployment: &rpc.ApiDeployment{
+				Name:        testDeploymentName,
+				EndpointUri: fmt.Sprintf("https://%d.api.example.com", i),
+			},
+		}
+		updated, err := s.UpdateApiDeployment(ctx, req)
+		if err != nil {
+			t.Fatalf("Setup: UpdateApiDeployment(%+v) returned error: %s", req, err)
+		}
+		revisions = append(revisions, updated)
+	}
+
+	return revisions
+}
+
+func listDeploymentRevisionIDs(ctx context.Context, t *testing.T, s *RegistryServer) []string {
+	t.Helper()
+
+	req := &rpc.ListApiDeploymentRevisionsRequest{Name: testDeploymentName}
+	got, err := s.ListApiDeploymentRevisions(ctx, req)
+	if err != nil {
+		t.Fatalf("ListApiDeploymentRevisions(%+v) returned error: %s", req, err)
+	}
+
+	ids := make([]string, len(got.GetApiDeployments()))
+	for i, revision := range got.GetApiDeployments() {
+		ids[i] = revision.GetRevisionId()
+	}
+	return ids
+}
+
+func TestListApiDeploymentRevisions(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedDeploymentRevisions(ctx, t, server, 3)
+
+	want := []string{revisions[2].GetRevisionId(), revisions[1].GetRevisionId(), revisions[0].GetRevisionId()}
+	if got := listDeploymentRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListApiDeploymentRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	req := &rpc.ListApiDeploymentRevisionsRequest{Name: testDeploymentName, PageSize: 2}
+	got, err := server.ListApiDeploymentRevisions(ctx, req)
+	if err != nil {
+		t.Fatalf("ListApiDeploymentRevisions(%+v) returned error: %s", req, err)
+	}
+	if len(got.GetApiDeployments()) != 2 || got.GetNextPageToken() == "" {
+		t.Errorf("ListApiDeploymentRevisions(%+v) returned %d revisions and token %q, want 2 revisions and a token", req, len(got.GetApiDeployments()), got.GetNextPageToken())
+	}
+	for _, revision := range got.GetApiDeployments() {
+		if want := testDeploymentName + "@" + revision.GetRevisionId(); revision.GetName() != want {
+			t.Errorf("ListApiDeploymentRevisions(%+v) returned name %q, want %q", req, revision.GetName(), want)
+		}
+	}
+
+	req.PageToken = got.GetNextPageToken()
+	got, err = server.ListApiDeploymentRevisions(ctx, req)
+	if err != nil {
+		t.Fatalf("ListApiDeploymentRevisions(%+v) returned error: %s", req, err)
+	}
+	if len(got.GetApiDeployments()) != 1 || got.GetApiDeployments()[0].GetRevisionId() != revisions[0].GetRevisionId() {
+		t.Errorf("ListApiDeploymentRevisions(%+v) returned %v, want only the first revision", req, got.GetApiDeployments())
+	}
+}
+
+func TestTagApiDeploymentRevision(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedDeploymentRevisions(ctx, t, server, 2)
+
+	req := &rpc.TagApiDeploymentRevisionRequest{
+		Name: testDeploymentName + "@" + revisions[0].GetRevisionId(),
+		Tag:  "stable",
+	}
+	tagged, err := server.TagApiDeploymentRevision(ctx, req)
+	if err != nil {
+		t.Fatalf("TagApiDeploymentRevision(%+v) returned error: %s", req, err)
+	}
+	if want := testDeploymentName + "@stable"; tagged.GetName() != want {
+		t.Errorf("TagApiDeploymentRevision(%+v) returned name %q, want %q", req, tagged.GetName(), want)
+	}
+
+	got, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: testDeploymentName + "@stable"})
+	if err != nil {
+		t.Fatalf("GetApiDeployment(%q) returned error: %s", testDeploymentName+"@stable", err)
+	}
+	opts := cmp.Options{
+		protocmp.Transform(),
+		protocmp.IgnoreFields(new(rpc.ApiDeployment), "name"),
+	}
+	if !cmp.Equal(revisions[0], got, opts) {
+		t.Errorf("GetApiDeployment(%q) returned unexpected diff (-want +got):\n%s", testDeploymentName+"@stable", cmp.Diff(revisions[0], got, opts))
+	}
+
+	// Tags can be applied by referring to other tags.
+	req = &rpc.TagApiDeploymentRevisionRequest{
+		Name: testDeploymentName + "@stable",
+		Tag:  "previous",
+	}
+	if _, err := server.TagApiDeploymentRevision(ctx, req); err != nil {
+		t.Fatalf("TagApiDeploymentRevision(%+v) returned error: %s", req, err)
+	}
+	if got, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: testDeploymentName + "@previous"}); err != nil {
+		t.Fatalf("GetApiDeployment(%q) returned error: %s", testDeploymentName+"@previous", err)
+	} else if got.GetRevisionId() != revisions[0].GetRevisionId() {
+		t.Errorf("GetApiDeployment(%q) returned revision %q, want %q", testDeploymentName+"@previous", got.GetRevisionId(), revisions[0].GetRevisionId())
+	}
+}
+
+func TestTagApiDeploymentRevisionResponseCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *rpc.TagApiDeploymentRevisionRequest
+		want codes.Code
+	}{
+		{
+			desc: "revision not found",
+			req:  &rpc.TagApiDeploymentRevisionRequest{Name: testDeploymentName + "@doesnt-exist", Tag: "stable"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "missing revision",
+			req:  &rpc.TagApiDeploymentRevisionRequest{Name: testDeploymentName, Tag: "stable"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "empty tag",
+			req:  &rpc.TagApiDeploymentRevisionRequest{Name: testDeploymentName + "@current"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid tag",
+			req:  &rpc.TagApiDeploymentRevisionRequest{Name: testDeploymentName + "@current", Tag: "Not Valid"},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			revisions := seedDeploymentRevisions(ctx, t, server, 1)
+			if _, err := server.TagApiDeploymentRevision(ctx, &rpc.TagApiDeploymentRevisionRequest{
+				Name: testDeploymentName + "@" + revisions[0].GetRevisionId(),
+				Tag:  "current",
+			}); err != nil {
+				t.Fatalf("Setup: TagApiDeploymentRevision returned error: %s", err)
+			}
+
+			if _, err := server.TagApiDeploymentRevision(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("TagApiDeploymentRevision(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestRollbackApiDeployment(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedDeploymentRevisions(ctx, t, server, 2)
+
+	req := &rpc.RollbackApiDeploymentRequest{
+		Name:       testDeploymentName,
+		RevisionId: revisions[0].GetRevisionId(),
+	}
+	rollback, err := server.RollbackApiDeployment(ctx, req)
+	if err != nil {
+		t.Fatalf("RollbackApiDeployment(%+v) returned error: %s", req, err)
+	}
+	if id := rollback.GetRevisionId(); id == revisions[0].GetRevisionId() || id == revisions[1].GetRevisionId() {
+		t.Errorf("RollbackApiDeployment(%+v) returned existing revision ID %q, want a new one", req, id)
+	}
+
+	got, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: testDeploymentName})
+	if err != nil {
+		t.Fatalf("GetApiDeployment(%q) returned error: %s", testDeploymentName, err)
+	}
+	if got.GetRevisionId() != rollback.GetRevisionId() {
+		t.Errorf("GetApiDeployment(%q) returned revision %q, want rollback revision %q", testDeploymentName, got.GetRevisionId(), rollback.GetRevisionId())
+	}
+	if got.GetEndpointUri() != revisions[0].GetEndpointUri() {
+		t.Errorf("GetApiDeployment(%q) returned endpoint %q, want %q", testDeploymentName, got.GetEndpointUri(), revisions[0].GetEndpointUri())
+	}
+
+	want := []string{rollback.GetRevisionId(), revisions[1].GetRevisionId(), revisions[0].GetRevisionId()}
+	if got := listDeploymentRevisionIDs(ctx, t, server); !cmp.Equal(want, got) {
+		t.Errorf("ListApiDeploymentRevisions returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	req = &rpc.RollbackApiDeploymentRequest{Name: testDeploymentName, RevisionId: "doesnt-exist"}
+	if _, err := server.RollbackApiDeployment(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("RollbackApiDeployment(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+
+	req = &rpc.RollbackApiDeploymentRequest{Name: testDeploymentName}
+	if _, err := server.RollbackApiDeployment(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RollbackApiDeployment(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+	}
+}
+
+func TestDeleteApiDeploymentRevision(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	revisions := seedDeploymentRevisions(ctx, t, server, 2)
+
+	req := &rpc.DeleteApiDeploymentRevisionRequest{
+		Name: testDeploymentName + "@" + revisions[1].GetRevisionId(),
+	}
+	if _, err := server.DeleteApiDeploymentRevision(ctx, req); err != nil {
+		t.Fatalf("DeleteApiDeploymentRevision(%+v) returned error: %s", req, err)
+	}
+
+	// The previous revision becomes the current revision.
+	got, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: testDeploymentName})
+	if err != nil {
+		t.Fatalf("GetApiDeployment(%q) returned error: %s", testDeploymentName, err)
+	}
+	if got.GetRevisionId() != revisions[0].GetRevisionId() {
+		t.Errorf("GetApiDeployment(%q) returned revision %q, want %q", testDeploymentName, got.GetRevisionId(), revisions[0].GetRevisionId())
+	}
+
+	if _, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: req.GetName()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiDeployment(%q) returned status code %q, want %q: %v", req.GetName(), status.Code(err), codes.NotFound, err)
+	}
+
+	if _, err := server.DeleteApiDeploymentRevision(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteApiDeploymentRevision(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+
+	req = &rpc.DeleteApiDeploymentRevisionRequest{Name: testDeploymentName}
+	if _, err := server.DeleteApiDeploymentRevision(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DeleteApiDeploymentRevision(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+	}
+}
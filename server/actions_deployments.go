@@ -0,0 +1,277 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateApiDeployment handles the corresponding API request.
+func (s *RegistryServer) CreateApiDeployment(ctx context.Context, req *rpc.CreateApiDeploymentRequest) (*rpc.ApiDeployment, error) {
+	parent, err := names.ParseApi(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.GetApiDeployment() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid api_deployment %+v: body must be provided", req.GetApiDeployment())
+	}
+
+	return s.createDeployment(ctx, parent.Deployment(req.GetApiDeploymentId()), req.GetApiDeployment(), req)
+}
+
+// createDeployment creates a deployment for req, which is a create request or an update request that allows missing deployments.
+func (s *RegistryServer) createDeployment(ctx context.Context, name names.Deployment, body *rpc.ApiDeployment, req idempotentRequest) (*rpc.ApiDeployment, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	replayed := new(rpc.ApiDeployment)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
+	if _, err := db.GetDeployment(ctx, name); err == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "API deployment %q already exists", name)
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	if err := name.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Creation should only succeed when the parent exists.
+	if _, err := db.GetApi(ctx, name.Api()); err != nil {
+		return nil, err
+	}
+
+	deployment, err := models.NewDeployment(name, body)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var message *rpc.ApiDeployment
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, deployment.RevisionName(), func(db dao.DAO) error {
+		if err := db.SaveDeploymentRevision(ctx, deployment); err != nil {
+			return err
+		}
+		if message, err = deployment.Message(name.String()); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// DeleteApiDeployment handles the corresponding API request.
+func (s *RegistryServer) DeleteApiDeployment(ctx context.Context, req *rpc.DeleteApiDeploymentRequest) (*empty.Empty, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseDeployment(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Deletion should only succeed on API deployments that currently exist.
+	if _, err := db.GetDeployment(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if err := checkDeploymentEtag(ctx, db, name, req.GetEtag()); err != nil {
+			return err
+		}
+		return db.DeleteDeployment(ctx, name)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// GetApiDeployment handles the corresponding API request.
+func (s *RegistryServer) GetApiDeployment(ctx context.Context, req *rpc.GetApiDeploymentRequest) (*rpc.ApiDeployment, error) {
+	if name, err := names.ParseDeployment(req.GetName()); err == nil {
+		return s.getApiDeployment(ctx, name)
+	} else if name, err := names.ParseDeploymentRevision(req.GetName()); err == nil {
+		return s.getApiDeploymentRevision(ctx, name)
+	}
+
+	return nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q, must be an API deployment or revision", req.GetName())
+}
+
+func (s *RegistryServer) getApiDeployment(ctx context.Context, name names.Deployment) (*rpc.ApiDeployment, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	deployment, err := db.GetDeployment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := deployment.Message(name.String())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return message, nil
+}
+
+func (s *RegistryServer) getApiDeploymentRevision(ctx context.Context, name names.DeploymentRevision) (*rpc.ApiDeployment, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	revision, err := db.GetDeploymentRevision(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := revision.Message(name.String())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return message, nil
+}
+
+// ListApiDeployments handles the corresponding API request.
+func (s *RegistryServer) ListApiDeployments(ctx context.Context, req *rpc.ListApiDeploymentsRequest) (*rpc.ListApiDeploymentsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
+	} else if req.GetPageSize() > 1000 {
+		req.PageSize = 1000
+	} else if req.GetPageSize() == 0 {
+		req.PageSize = 50
+	}
+
+	parent, err := names.ParseApi(req.GetParent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	listing, err := db.ListDeployments(ctx, parent, dao.PageOptions{
+		Size:    req.GetPageSize(),
+		Filter:  req.GetFilter(),
+		Token:   req.GetPageToken(),
+		OrderBy: req.GetOrderBy(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &rpc.ListApiDeploymentsResponse{
+		ApiDeployments: make([]*rpc.ApiDeployment, len(listing.Deployments)),
+		NextPageToken:  listing.Token,
+	}
+
+	for i, deployment := range listing.Deployments {
+		response.ApiDeployments[i], err = deployment.Message(deployment.Name())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+// UpdateApiDeployment handles the corresponding API request.
+func (s *RegistryServer) UpdateApiDeployment(ctx context.Context, req *rpc.UpdateApiDeploymentRequest) (*rpc.ApiDeployment, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetApiDeployment() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid api_deployment %+v: body must be provided", req.GetApiDeployment())
+	} else if err := models.ValidateMask(req.GetApiDeployment(), req.GetUpdateMask()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid update_mask %v: %s", req.GetUpdateMask(), err)
+	}
+
+	name, err := names.ParseDeployment(req.ApiDeployment.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	replayed := new(rpc.ApiDeployment)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
+	deployment, err := db.GetDeployment(ctx, name)
+	if req.GetAllowMissing() && isNotFound(err) {
+		return s.createDeployment(ctx, name, req.GetApiDeployment(), req)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Apply the update to the deployment - possibly changing the revision ID.
+	if err := deployment.Update(req.GetApiDeployment(), models.ExpandMask(req.GetApiDeployment(), req.GetUpdateMask())); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Save the updated/current deployment. This creates a new revision or updates the previous one.
+	var message *rpc.ApiDeployment
+	if err := s.commit(ctx, db, rpc.Notification_UPDATED, deployment.RevisionName(), func(db dao.DAO) error {
+		if err := checkDeploymentEtag(ctx, db, name, req.GetApiDeployment().GetEtag()); err != nil {
+			return err
+		}
+		if err := db.SaveDeploymentRevision(ctx, deployment); err != nil {
+			return err
+		}
+		if message, err = deployment.Message(name.String()); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
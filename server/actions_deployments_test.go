@@ -0,0 +1,728 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"context"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/names"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func seedDeployments(ctx context.Context, t *testing.T, s *RegistryServer, deployments ...*rpc.ApiDeployment) {
+	t.Helper()
+
+	for _, deployment := range deployments {
+		name, err := names.ParseDeployment(deployment.Name)
+		if err != nil {
+			t.Fatalf("Setup/Seeding: ParseDeployment(%q) returned error: %s", deployment.Name, err)
+		}
+
+		parent := name.Api()
+		seedApis(ctx, t, s, &rpc.Api{
+			Name: parent.String(),
+		})
+
+		req := &rpc.CreateApiDeploymentRequest{
+			Parent:          parent.String(),
+			ApiDeploymentId: name.DeploymentID,
+			ApiDeployment:   deployment,
+		}
+
+		switch _, err := s.CreateApiDeployment(ctx, req); status.Code(err) {
+		case codes.OK, codes.AlreadyExists:
+			// Deployment is now ready for use in test.
+		default:
+			t.Fatalf("Setup/Seeding: CreateApiDeployment(%+v) returned error: %s", req, err)
+		}
+	}
+}
+
+func TestCreateApiDeployment(t *testing.T) {
+	tests := []struct {
+		desc string
+		seed *rpc.Api
+		req  *rpc.CreateApiDeploymentRequest
+		want *rpc.ApiDeployment
+	}{
+		{
+			desc: "fully populated resource",
+			seed: &rpc.Api{
+				Name: "projects/my-project/apis/my-api",
+			},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/my-api",
+				ApiDeploymentId: "prod",
+				ApiDeployment: &rpc.ApiDeployment{
+					DisplayName:     "Production",
+					Description:     "My Deployment",
+					ApiSpecRevision: "projects/my-project/apis/my-api/versions/v1/specs/openapi.yaml@1234abcd",
+					EndpointUri:     "https://api.example.com",
+					Environment:     "production",
+					Gateway:         "envoy",
+					AccessGuidance:  "Request an API key.",
+					Labels:          map[string]string{"tier": "gold"},
+					Annotations:     map[string]string{"owner": "platform"},
+				},
+			},
+			want: &rpc.ApiDeployment{
+				Name:            "projects/my-project/apis/my-api/deployments/prod",
+				DisplayName:     "Production",
+				Description:     "My Deployment",
+				ApiSpecRevision: "projects/my-project/apis/my-api/versions/v1/specs/openapi.yaml@1234abcd",
+				EndpointUri:     "https://api.example.com",
+				Environment:     "production",
+				Gateway:         "envoy",
+				AccessGuidance:  "Request an API key.",
+				Labels:          map[string]string{"tier": "gold"},
+				Annotations:     map[string]string{"owner": "platform"},
+			},
+		},
+		{
+			desc: "empty resource",
+			seed: &rpc.Api{
+				Name: "projects/my-project/apis/my-api",
+			},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/my-api",
+				ApiDeploymentId: "prod",
+				ApiDeployment:   &rpc.ApiDeployment{},
+			},
+			want: &rpc.ApiDeployment{
+				Name: "projects/my-project/apis/my-api/deployments/prod",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedApis(ctx, t, server, test.seed)
+
+			created, err := server.CreateApiDeployment(ctx, test.req)
+			if err != nil {
+				t.Fatalf("CreateApiDeployment(%+v) returned error: %s", test.req, err)
+			}
+
+			opts := cmp.Options{
+				protocmp.Transform(),
+				protocmp.IgnoreFields(new(rpc.ApiDeployment), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
+			}
+
+			if !cmp.Equal(test.want, created, opts) {
+				t.Errorf("CreateApiDeployment(%+v) returned unexpected diff (-want +got):\n%s", test.req, cmp.Diff(test.want, created, opts))
+			}
+
+			if created.RevisionId == "" {
+				t.Errorf("CreateApiDeployment(%+v) returned unexpected empty revision_id", test.req)
+			}
+
+			t.Run("GetApiDeployment", func(t *testing.T) {
+				req := &rpc.GetApiDeploymentRequest{
+					Name: created.GetName(),
+				}
+
+				got, err := server.GetApiDeployment(ctx, req)
+				if err != nil {
+					t.Fatalf("GetApiDeployment(%+v) returned error: %s", req, err)
+				}
+
+				opts := protocmp.Transform()
+				if !cmp.Equal(created, got, opts) {
+					t.Errorf("GetApiDeployment(%+v) returned unexpected diff (-want +got):\n%s", req, cmp.Diff(created, got, opts))
+				}
+			})
+		})
+	}
+}
+
+func TestCreateApiDeploymentResponseCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		seed *rpc.Api
+		req  *rpc.CreateApiDeploymentRequest
+		want codes.Code
+	}{
+		{
+			desc: "parent not found",
+			seed: &rpc.Api{Name: "projects/my-project/apis/my-api"},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/other-api",
+				ApiDeploymentId: "valid-id",
+				ApiDeployment:   &rpc.ApiDeployment{},
+			},
+			want: codes.NotFound,
+		},
+		{
+			desc: "missing resource body",
+			seed: &rpc.Api{Name: "projects/my-project/apis/my-api"},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/my-api",
+				ApiDeploymentId: "valid-id",
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid parent",
+			seed: &rpc.Api{Name: "projects/my-project/apis/my-api"},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/my-api/versions/v1",
+				ApiDeploymentId: "valid-id",
+				ApiDeployment:   &rpc.ApiDeployment{},
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid identifier",
+			seed: &rpc.Api{Name: "projects/my-project/apis/my-api"},
+			req: &rpc.CreateApiDeploymentRequest{
+				Parent:          "projects/my-project/apis/my-api",
+				ApiDeploymentId: "-invalid",
+				ApiDeployment:   &rpc.ApiDeployment{},
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedApis(ctx, t, server, test.seed)
+
+			if _, err := server.CreateApiDeployment(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("CreateApiDeployment(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestCreateApiDeploymentDuplicates(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+		Name: "projects/my-project/apis/my-api/deployments/prod",
+	})
+
+	req := &rpc.CreateApiDeploymentRequest{
+		Parent:          "projects/my-project/apis/my-api",
+		ApiDeploymentId: "PROD",
+		ApiDeployment:   &rpc.ApiDeployment{},
+	}
+	if _, err := server.CreateApiDeployment(ctx, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateApiDeployment(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.AlreadyExists, err)
+	}
+}
+
+func TestGetApiDeploymentResponseCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *rpc.GetApiDeploymentRequest
+		want codes.Code
+	}{
+		{
+			desc: "resource not found",
+			req:  &rpc.GetApiDeploymentRequest{Name: "projects/my-project/apis/my-api/deployments/doesnt-exist"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "revision not found",
+			req:  &rpc.GetApiDeploymentRequest{Name: "projects/my-project/apis/my-api/deployments/prod@doesnt-exist"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "invalid name",
+			req:  &rpc.GetApiDeploymentRequest{Name: "projects/my-project/apis/my-api/versions/v1"},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+				Name: "projects/my-project/apis/my-api/deployments/prod",
+			})
+
+			if _, err := server.GetApiDeployment(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("GetApiDeployment(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestListApiDeployments(t *testing.T) {
+	seed := []*rpc.ApiDeployment{
+		{
+			Name:        "projects/my-project/apis/my-api/deployments/prod",
+			Environment: "production",
+			Gateway:     "envoy",
+			Labels:      map[string]string{"tier": "gold"},
+		},
+		{
+			Name:        "projects/my-project/apis/my-api/deployments/staging",
+			Environment: "staging",
+			Gateway:     "envoy",
+		},
+		{
+			Name:        "projects/my-project/apis/other-api/deployments/prod",
+			Environment: "production",
+			Gateway:     "apigee",
+		},
+		{
+			Name:        "projects/other-project/apis/my-api/deployments/prod",
+			Environment: "production",
+			Gateway:     "envoy",
+		},
+	}
+
+	tests := []struct {
+		desc string
+		req  *rpc.ListApiDeploymentsRequest
+		want []string
+	}{
+		{
+			desc: "single api",
+			req:  &rpc.ListApiDeploymentsRequest{Parent: "projects/my-project/apis/my-api"},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/prod",
+				"projects/my-project/apis/my-api/deployments/staging",
+			},
+		},
+		{
+			desc: "across apis",
+			req:  &rpc.ListApiDeploymentsRequest{Parent: "projects/my-project/apis/-"},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/prod",
+				"projects/my-project/apis/my-api/deployments/staging",
+				"projects/my-project/apis/other-api/deployments/prod",
+			},
+		},
+		{
+			desc: "across projects",
+			req:  &rpc.ListApiDeploymentsRequest{Parent: "projects/-/apis/-"},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/prod",
+				"projects/my-project/apis/my-api/deployments/staging",
+				"projects/my-project/apis/other-api/deployments/prod",
+				"projects/other-project/apis/my-api/deployments/prod",
+			},
+		},
+		{
+			desc: "environment filter",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent: "projects/-/apis/-",
+				Filter: "environment == 'staging'",
+			},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/staging",
+			},
+		},
+		{
+			desc: "gateway filter",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent: "projects/my-project/apis/-",
+				Filter: "gateway == 'apigee'",
+			},
+			want: []string{
+				"projects/my-project/apis/other-api/deployments/prod",
+			},
+		},
+		{
+			desc: "labels filter",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent: "projects/-/apis/-",
+				Filter: "has(labels.tier) && labels.tier == 'gold'",
+			},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/prod",
+			},
+		},
+		{
+			desc: "ordered by deployment ID",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent:  "projects/my-project/apis/my-api",
+				OrderBy: "deployment_id desc",
+			},
+			want: []string{
+				"projects/my-project/apis/my-api/deployments/staging",
+				"projects/my-project/apis/my-api/deployments/prod",
+			},
+		},
+	}
+
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedDeployments(ctx, t, server, seed...)
+
+	// Create a second revision of one deployment, which should only be listed once.
+	if _, err := server.UpdateApiDeployment(ctx, &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: &rpc.ApiDeployment{
+			Name:        "projects/my-project/apis/my-api/deployments/prod",
+			EndpointUri: "https://api.example.com",
+		},
+	}); err != nil {
+		t.Fatalf("Setup: UpdateApiDeployment returned error: %s", err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := server.ListApiDeployments(ctx, test.req)
+			if err != nil {
+				t.Fatalf("ListApiDeployments(%+v) returned error: %s", test.req, err)
+			}
+
+			names := make([]string, len(got.GetApiDeployments()))
+			for i, deployment := range got.GetApiDeployments() {
+				names[i] = deployment.GetName()
+			}
+
+			if !cmp.Equal(test.want, names) {
+				t.Errorf("ListApiDeployments(%+v) returned unexpected diff (-want +got):\n%s", test.req, cmp.Diff(test.want, names))
+			}
+		})
+	}
+}
+
+func TestListApiDeploymentsResponseCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *rpc.ListApiDeploymentsRequest
+		want codes.Code
+	}{
+		{
+			desc: "parent api not found",
+			req:  &rpc.ListApiDeploymentsRequest{Parent: "projects/my-project/apis/other-api"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "negative page size",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent:   "projects/my-project/apis/my-api",
+				PageSize: -1,
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid filter",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent: "projects/my-project/apis/my-api",
+				Filter: "this filter is not valid",
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid order_by",
+			req: &rpc.ListApiDeploymentsRequest{
+				Parent:  "projects/my-project/apis/my-api",
+				OrderBy: "unknown_field",
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+				Name: "projects/my-project/apis/my-api/deployments/prod",
+			})
+
+			if _, err := server.ListApiDeployments(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("ListApiDeployments(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestUpdateApiDeployment(t *testing.T) {
+	tests := []struct {
+		desc        string
+		req         *rpc.UpdateApiDeploymentRequest
+		want        *rpc.ApiDeployment
+		newRevision bool
+	}{
+		{
+			desc: "implicit nil mask on metadata",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name:        "projects/my-project/apis/my-api/deployments/prod",
+					Description: "My Updated Deployment",
+				},
+			},
+			want: &rpc.ApiDeployment{
+				Name:        "projects/my-project/apis/my-api/deployments/prod",
+				DisplayName: "Production",
+				Description: "My Updated Deployment",
+				EndpointUri: "https://api.example.com",
+				Environment: "production",
+			},
+		},
+		{
+			desc: "field specific mask on endpoint",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name:        "projects/my-project/apis/my-api/deployments/prod",
+					Description: "Ignored",
+					EndpointUri: "https://v2.api.example.com",
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"endpoint_uri"}},
+			},
+			want: &rpc.ApiDeployment{
+				Name:        "projects/my-project/apis/my-api/deployments/prod",
+				DisplayName: "Production",
+				Description: "My Deployment",
+				EndpointUri: "https://v2.api.example.com",
+				Environment: "production",
+			},
+			newRevision: true,
+		},
+		{
+			desc: "unchanged endpoint",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name:        "projects/my-project/apis/my-api/deployments/prod",
+					EndpointUri: "https://api.example.com",
+				},
+			},
+			want: &rpc.ApiDeployment{
+				Name:        "projects/my-project/apis/my-api/deployments/prod",
+				DisplayName: "Production",
+				Description: "My Deployment",
+				EndpointUri: "https://api.example.com",
+				Environment: "production",
+			},
+		},
+		{
+			desc: "full replacement wildcard mask",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name:        "projects/my-project/apis/my-api/deployments/prod",
+					Environment: "production",
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"*"}},
+			},
+			want: &rpc.ApiDeployment{
+				Name:        "projects/my-project/apis/my-api/deployments/prod",
+				Environment: "production",
+			},
+			newRevision: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+				Name:        "projects/my-project/apis/my-api/deployments/prod",
+				DisplayName: "Production",
+				Description: "My Deployment",
+				EndpointUri: "https://api.example.com",
+				Environment: "production",
+			})
+
+			original, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: test.req.ApiDeployment.GetName()})
+			if err != nil {
+				t.Fatalf("Setup: GetApiDeployment returned error: %s", err)
+			}
+
+			updated, err := server.UpdateApiDeployment(ctx, test.req)
+			if err != nil {
+				t.Fatalf("UpdateApiDeployment(%+v) returned error: %s", test.req, err)
+			}
+
+			opts := cmp.Options{
+				protocmp.Transform(),
+				protocmp.IgnoreFields(new(rpc.ApiDeployment), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
+			}
+
+			if !cmp.Equal(test.want, updated, opts) {
+				t.Errorf("UpdateApiDeployment(%+v) returned unexpected diff (-want +got):\n%s", test.req, cmp.Diff(test.want, updated, opts))
+			}
+
+			if got := updated.GetRevisionId() != original.GetRevisionId(); got != test.newRevision {
+				t.Errorf("UpdateApiDeployment(%+v) created new revision: %t, want %t", test.req, got, test.newRevision)
+			}
+
+			t.Run("GetApiDeployment", func(t *testing.T) {
+				req := &rpc.GetApiDeploymentRequest{
+					Name: updated.GetName(),
+				}
+
+				got, err := server.GetApiDeployment(ctx, req)
+				if err != nil {
+					t.Fatalf("GetApiDeployment(%+v) returned error: %s", req, err)
+				}
+
+				opts := protocmp.Transform()
+				if !cmp.Equal(updated, got, opts) {
+					t.Errorf("GetApiDeployment(%+v) returned unexpected diff (-want +got):\n%s", req, cmp.Diff(updated, got, opts))
+				}
+			})
+		})
+	}
+}
+
+func TestUpdateApiDeploymentResponseCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *rpc.UpdateApiDeploymentRequest
+		want codes.Code
+	}{
+		{
+			desc: "resource not found",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name: "projects/my-project/apis/my-api/deployments/doesnt-exist",
+				},
+			},
+			want: codes.NotFound,
+		},
+		{
+			desc: "missing resource body",
+			req:  &rpc.UpdateApiDeploymentRequest{},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "nonexistent field in mask",
+			req: &rpc.UpdateApiDeploymentRequest{
+				ApiDeployment: &rpc.ApiDeployment{
+					Name: "projects/my-project/apis/my-api/deployments/prod",
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"this field does not exist"}},
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			server := defaultTestServer(t)
+			seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+				Name: "projects/my-project/apis/my-api/deployments/prod",
+			})
+
+			if _, err := server.UpdateApiDeployment(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("UpdateApiDeployment(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestUpdateApiDeploymentAllowMissing(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/my-api"})
+
+	req := &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: &rpc.ApiDeployment{
+			Name:        "projects/my-project/apis/my-api/deployments/prod",
+			Environment: "production",
+		},
+		AllowMissing: true,
+	}
+	if _, err := server.UpdateApiDeployment(ctx, req); err != nil {
+		t.Fatalf("UpdateApiDeployment(%+v) returned error: %s", req, err)
+	}
+
+	got, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: req.ApiDeployment.GetName()})
+	if err != nil {
+		t.Fatalf("GetApiDeployment(%q) returned error: %s", req.ApiDeployment.GetName(), err)
+	}
+	if got.GetEnvironment() != "production" {
+		t.Errorf("GetApiDeployment(%q) returned environment %q, want %q", req.ApiDeployment.GetName(), got.GetEnvironment(), "production")
+	}
+}
+
+func TestDeleteApiDeployment(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+		Name:        "projects/my-project/apis/my-api/deployments/prod",
+		EndpointUri: "https://api.example.com",
+	})
+
+	// Create a second revision and tag it, so deletion has to remove both.
+	updated, err := server.UpdateApiDeployment(ctx, &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: &rpc.ApiDeployment{
+			Name:        "projects/my-project/apis/my-api/deployments/prod",
+			EndpointUri: "https://v2.api.example.com",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Setup: UpdateApiDeployment returned error: %s", err)
+	}
+	if _, err := server.TagApiDeploymentRevision(ctx, &rpc.TagApiDeploymentRevisionRequest{
+		Name: "projects/my-project/apis/my-api/deployments/prod@" + updated.GetRevisionId(),
+		Tag:  "current",
+	}); err != nil {
+		t.Fatalf("Setup: TagApiDeploymentRevision returned error: %s", err)
+	}
+
+	req := &rpc.DeleteApiDeploymentRequest{
+		Name: "projects/my-project/apis/my-api/deployments/prod",
+	}
+	if _, err := server.DeleteApiDeployment(ctx, req); err != nil {
+		t.Fatalf("DeleteApiDeployment(%+v) returned error: %s", req, err)
+	}
+
+	for _, name := range []string{
+		"projects/my-project/apis/my-api/deployments/prod",
+		"projects/my-project/apis/my-api/deployments/prod@current",
+		"projects/my-project/apis/my-api/deployments/prod@" + updated.GetRevisionId(),
+	} {
+		if _, err := server.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: name}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetApiDeployment(%q) returned status code %q, want %q: %v", name, status.Code(err), codes.NotFound, err)
+		}
+	}
+
+	if _, err := server.DeleteApiDeployment(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteApiDeployment(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestDeleteApiRemovesDeployments(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedDeployments(ctx, t, server, &rpc.ApiDeployment{
+		Name: "projects/my-project/apis/my-api/deployments/prod",
+	})
+
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/my-api"}); err != nil {
+		t.Fatalf("DeleteApi returned error: %s", err)
+	}
+	if err := server.purge(ctx, time.Now().Add(server.purgeDelay()+time.Minute)); err != nil {
+		t.Fatalf("purge returned error: %s", err)
+	}
+
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/my-api"})
+	req := &rpc.GetApiDeploymentRequest{Name: "projects/my-project/apis/my-api/deployments/prod"}
+	if _, err := server.GetApiDeployment(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiDeployment(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
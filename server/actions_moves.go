@@ -0,0 +1,160 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MoveApi handles the corresponding API request.
+func (s *RegistryServer) MoveApi(ctx context.Context, req *rpc.MoveApiRequest) (*rpc.Api, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	from, to, err := parseApiDestination(req.GetName(), req.GetDestination())
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := new(rpc.Api)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
+	// The api is removed from its old name and created with its new name in one transaction.
+	var message *rpc.Api
+	if err := s.commitChanges(ctx, db, func(db dao.DAO) ([]*models.Change, error) {
+		if err := checkApiDestination(ctx, db, from, to); err != nil {
+			return nil, err
+		} else if err := checkApiEtag(ctx, db, from, req.GetEtag()); err != nil {
+			return nil, err
+		}
+
+		api, err := db.MoveApi(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if message, err = api.Message(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		} else if err := s.recordRequest(ctx, db, req, message); err != nil {
+			return nil, err
+		}
+
+		return []*models.Change{
+			models.NewChange(rpc.Notification_DELETED, from.String()),
+			models.NewChange(rpc.Notification_CREATED, to.String()),
+		}, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// CopyApi handles the corresponding API request.
+func (s *RegistryServer) CopyApi(ctx context.Context, req *rpc.CopyApiRequest) (*rpc.Api, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	from, to, err := parseApiDestination(req.GetName(), req.GetDestination())
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := new(rpc.Api)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
+	var message *rpc.Api
+	if err := s.commitChange(ctx, db, func(db dao.DAO) (*models.Change, error) {
+		if err := checkApiDestination(ctx, db, from, to); err != nil {
+			return nil, err
+		}
+
+		api, err := db.CopyApi(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if message, err = api.Message(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		} else if err := s.recordRequest(ctx, db, req, message); err != nil {
+			return nil, err
+		}
+
+		return models.NewChange(rpc.Notification_CREATED, to.String()), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// parseApiDestination parses the names of an api and its destination for a move or copy.
+func parseApiDestination(name, destination string) (names.Api, names.Api, error) {
+	from, err := names.ParseApi(name)
+	if err != nil {
+		return names.Api{}, names.Api{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	to, err := names.ParseApi(destination)
+	if err != nil {
+		return names.Api{}, names.Api{}, status.Errorf(codes.InvalidArgument, "invalid destination %q: %s", destination, err)
+	} else if err := to.Validate(); err != nil {
+		return names.Api{}, names.Api{}, status.Errorf(codes.InvalidArgument, "invalid destination %q: %s", destination, err)
+	} else if to.String() == from.String() {
+		return names.Api{}, names.Api{}, status.Errorf(codes.InvalidArgument, "invalid destination %q: must differ from the api's name", destination)
+	}
+
+	return from, to, nil
+}
+
+// checkApiDestination checks that an api exists and can be moved or copied to the destination.
+func checkApiDestination(ctx context.Context, db dao.DAO, from, to names.Api) error {
+	// Deleted APIs can't be moved or copied, because they are purged with their old names.
+	if _, err := db.GetApi(ctx, from); err != nil {
+		return err
+	}
+
+	if _, err := db.GetProject(ctx, to.Project()); err != nil {
+		return err
+	}
+
+	// Names of deleted APIs can't be reused until they are purged.
+	if _, err := db.GetApiIncludingDeleted(ctx, to); err == nil {
+		return status.Errorf(codes.AlreadyExists, "API %q already exists", to)
+	}
+
+	return nil
+}
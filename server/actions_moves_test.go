@@ -0,0 +1,290 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// seedMovableApi creates an api with a tagged spec revision, a deployment of the spec
+// and a lint artifact that refers to the spec, an unrelated api and the spec's parent.
+func seedMovableApi(ctx context.Context, t *testing.T, s *RegistryServer) *rpc.ApiSpec {
+	t.Helper()
+
+	seedProjects(ctx, t, s, &rpc.Project{Name: "projects/other"})
+	seedSpecs(ctx, t, s, &rpc.ApiSpec{Name: "projects/my-project/apis/a/versions/v/specs/s", Contents: specContents})
+
+	spec, err := s.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/a/versions/v/specs/s"})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+	}
+	tag := &rpc.TagApiSpecRevisionRequest{Name: spec.GetName() + "@" + spec.GetRevisionId(), Tag: "original"}
+	if _, err := s.TagApiSpecRevision(ctx, tag); err != nil {
+		t.Fatalf("Setup: TagApiSpecRevision() returned error: %s", err)
+	}
+
+	seedDeployments(ctx, t, s, &rpc.ApiDeployment{
+		Name:            "projects/my-project/apis/a/deployments/d",
+		ApiSpecRevision: spec.GetName() + "@" + spec.GetRevisionId(),
+	})
+
+	lint, err := proto.Marshal(&rpc.Lint{
+		Name: spec.GetName(),
+		Files: []*rpc.LintFile{
+			{FilePath: "projects/my-project/apis/a/versions/v/specs/s/openapi.yaml"},
+			{FilePath: "projects/my-project/apis/a-b/versions/v/specs/s/openapi.yaml"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Setup: Marshal() returned error: %s", err)
+	}
+	seedArtifacts(ctx, t, s, &rpc.Artifact{
+		Name:     spec.GetName() + "/artifacts/lint",
+		MimeType: "application/octet-stream;type=google.cloud.apigee.registry.applications.v1alpha1.Lint",
+		Contents: lint,
+	})
+
+	return spec
+}
+
+// checkMovedApi checks that an api seeded by seedMovableApi was copied to projects/other/apis/b.
+func checkMovedApi(ctx context.Context, t *testing.T, s *RegistryServer, spec *rpc.ApiSpec) {
+	t.Helper()
+
+	const moved = "projects/other/apis/b/versions/v/specs/s"
+	got, err := s.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: moved + "@original"})
+	if err != nil {
+		t.Fatalf("GetApiSpec() returned error: %s", err)
+	} else if got.GetRevisionId() != spec.GetRevisionId() {
+		t.Errorf("GetApiSpec() returned revision %q, want %q", got.GetRevisionId(), spec.GetRevisionId())
+	}
+
+	contents, err := s.GetApiSpecContents(ctx, &rpc.GetApiSpecContentsRequest{Name: moved + "/contents"})
+	if err != nil {
+		t.Fatalf("GetApiSpecContents() returned error: %s", err)
+	} else if !bytes.Equal(contents.GetData(), specContents) {
+		t.Errorf("GetApiSpecContents() returned %q, want %q", contents.GetData(), specContents)
+	}
+
+	deployment, err := s.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: "projects/other/apis/b/deployments/d"})
+	if err != nil {
+		t.Fatalf("GetApiDeployment() returned error: %s", err)
+	} else if want := moved + "@" + spec.GetRevisionId(); deployment.GetApiSpecRevision() != want {
+		t.Errorf("GetApiDeployment() returned api_spec_revision %q, want %q", deployment.GetApiSpecRevision(), want)
+	}
+
+	// Names in artifacts are changed, but names of other apis with the same prefix aren't.
+	artifact, err := s.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: moved + "/artifacts/lint/contents"})
+	if err != nil {
+		t.Fatalf("GetArtifactContents() returned error: %s", err)
+	}
+	lint := new(rpc.Lint)
+	if err := proto.Unmarshal(artifact.GetData(), lint); err != nil {
+		t.Fatalf("Unmarshal() returned error: %s", err)
+	}
+	want := &rpc.Lint{
+		Name: moved,
+		Files: []*rpc.LintFile{
+			{FilePath: moved + "/openapi.yaml"},
+			{FilePath: "projects/my-project/apis/a-b/versions/v/specs/s/openapi.yaml"},
+		},
+	}
+	if diff := cmp.Diff(want, lint, protocmp.Transform()); diff != "" {
+		t.Errorf("GetArtifactContents() returned unexpected diff: (-want +got):\n%s", diff)
+	}
+}
+
+// checkNoApiEntities checks that no entities of an api or its children remain in storage.
+func checkNoApiEntities(ctx context.Context, t *testing.T, s *RegistryServer, projectID, apiID string) {
+	t.Helper()
+
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("getStorageClient() returned error: %s", err)
+	}
+	kinds := map[string]interface{}{
+		storage.ApiEntityName:                   new(models.Api),
+		storage.VersionEntityName:               new(models.Version),
+		storage.SpecEntityName:                  new(models.Spec),
+		storage.SpecRevisionTagEntityName:       new(models.SpecRevisionTag),
+		storage.DeploymentEntityName:            new(models.Deployment),
+		storage.DeploymentRevisionTagEntityName: new(models.DeploymentRevisionTag),
+		storage.ArtifactEntityName:              new(models.Artifact),
+		storage.ArtifactRevisionEntityName:      new(models.ArtifactRevision),
+		storage.ArtifactRevisionTagEntityName:   new(models.ArtifactRevisionTag),
+		models.BlobEntityName:                   new(models.Blob),
+	}
+	for kind, v := range kinds {
+		q := client.NewQuery(kind)
+		q = q.Require("ProjectID", projectID)
+		q = q.Require("ApiID", apiID)
+		if k, err := client.Run(ctx, q).Next(v); err == nil {
+			t.Errorf("%s %s remains after the api was moved", kind, k)
+		} else if err != iterator.Done {
+			t.Fatalf("Run(%s) returned error: %s", kind, err)
+		}
+	}
+}
+
+func TestMoveApi(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	spec := seedMovableApi(ctx, t, server)
+
+	api, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/a"})
+	if err != nil {
+		t.Fatalf("Setup: GetApi() returned error: %s", err)
+	}
+
+	req := &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/other/apis/b", Etag: api.GetEtag()}
+	moved, err := server.MoveApi(ctx, req)
+	if err != nil {
+		t.Fatalf("MoveApi(%+v) returned error: %s", req, err)
+	} else if moved.GetName() != "projects/other/apis/b" {
+		t.Errorf("MoveApi(%+v) returned api %q, want %q", req, moved.GetName(), "projects/other/apis/b")
+	} else if !moved.GetCreateTime().AsTime().Equal(api.GetCreateTime().AsTime()) {
+		t.Errorf("MoveApi(%+v) returned create_time %v, want %v", req, moved.GetCreateTime(), api.GetCreateTime())
+	}
+
+	checkMovedApi(ctx, t, server, spec)
+
+	// Moved apis aren't soft deleted, so their old names can be reused immediately.
+	if _, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/a"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApi() returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+	if _, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: spec.GetName()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiSpec() returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+	checkNoApiEntities(ctx, t, server, "my-project", "a")
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/a"})
+}
+
+func TestCopyApi(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	spec := seedMovableApi(ctx, t, server)
+
+	req := &rpc.CopyApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/other/apis/b"}
+	if _, err := server.CopyApi(ctx, req); err != nil {
+		t.Fatalf("CopyApi(%+v) returned error: %s", req, err)
+	}
+
+	checkMovedApi(ctx, t, server, spec)
+
+	// Copies share unchanged contents, which are kept when the original is deleted.
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/a"}); err != nil {
+		t.Fatalf("DeleteApi() returned error: %s", err)
+	}
+	got, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/other/apis/b/versions/v/specs/s"})
+	if err != nil {
+		t.Fatalf("GetApiSpec() returned error: %s", err)
+	} else if got.GetHash() != spec.GetHash() {
+		t.Errorf("GetApiSpec() returned hash %q, want %q", got.GetHash(), spec.GetHash())
+	}
+}
+
+func TestMoveApiErrors(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server,
+		&rpc.Api{Name: "projects/my-project/apis/a"},
+		&rpc.Api{Name: "projects/my-project/apis/b"},
+		&rpc.Api{Name: "projects/my-project/apis/deleted"},
+	)
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/deleted"}); err != nil {
+		t.Fatalf("Setup: DeleteApi() returned error: %s", err)
+	}
+
+	tests := []struct {
+		desc string
+		req  *rpc.MoveApiRequest
+		want codes.Code
+	}{
+		{
+			desc: "same name",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/my-project/apis/a"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "invalid destination",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/my-project/apis/a/versions/v"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "missing api",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/missing", Destination: "projects/my-project/apis/c"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "deleted api",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/deleted", Destination: "projects/my-project/apis/c"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "missing project",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/missing/apis/a"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "existing destination",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/my-project/apis/b"},
+			want: codes.AlreadyExists,
+		},
+		{
+			desc: "deleted destination",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/my-project/apis/deleted"},
+			want: codes.AlreadyExists,
+		},
+		{
+			desc: "stale etag",
+			req:  &rpc.MoveApiRequest{Name: "projects/my-project/apis/a", Destination: "projects/my-project/apis/c", Etag: "stale"},
+			want: codes.Aborted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := server.MoveApi(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("MoveApi(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+
+			copyReq := &rpc.CopyApiRequest{Name: test.req.GetName(), Destination: test.req.GetDestination()}
+			if test.req.GetEtag() != "" {
+				// Copies don't change the original, so they don't check etags.
+				return
+			}
+			if _, err := server.CopyApi(ctx, copyReq); status.Code(err) != test.want {
+				t.Errorf("CopyApi(%+v) returned status code %q, want %q: %v", copyReq, status.Code(err), test.want, err)
+			}
+		})
+	}
+
+	// Failed moves don't change the api.
+	if _, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/a"}); err != nil {
+		t.Errorf("GetApi() returned error: %s", err)
+	}
+}
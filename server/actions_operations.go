@@ -0,0 +1,241 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultWaitTimeout is how long WaitOperation waits if no timeout is specified.
+const defaultWaitTimeout = time.Minute
+
+// maxWaitPollInterval is the longest time between polls of an operation that is being waited for.
+const maxWaitPollInterval = time.Second
+
+// validateOperationName returns an error if a name can't be the name of an operation.
+func validateOperationName(name string) error {
+	if id := strings.TrimPrefix(name, "operations/"); id == name || id == "" || strings.Contains(id, "/") {
+		return status.Errorf(codes.InvalidArgument, "invalid operation name %q: must have the form operations/{id}", name)
+	}
+	return nil
+}
+
+// getOperation returns the stored state of an operation, failing it first if it was abandoned.
+func (s *RegistryServer) getOperation(ctx context.Context, db dao.DAO, name string) (*models.Operation, error) {
+	op, err := db.GetOperation(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkAbandoned(ctx, db, op)
+}
+
+// ListOperations handles the corresponding API request.
+func (s *RegistryServer) ListOperations(ctx context.Context, req *longrunning.ListOperationsRequest) (*longrunning.ListOperationsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
+	} else if req.GetPageSize() > 1000 {
+		req.PageSize = 1000
+	} else if req.GetPageSize() == 0 {
+		req.PageSize = 50
+	}
+
+	if req.GetName() != "" && req.GetName() != "operations" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid name %q: must be %q", req.GetName(), "operations")
+	}
+
+	listing, err := db.ListOperations(ctx, dao.PageOptions{
+		Size:   req.GetPageSize(),
+		Filter: req.GetFilter(),
+		Token:  req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &longrunning.ListOperationsResponse{
+		Operations:    make([]*longrunning.Operation, len(listing.Operations)),
+		NextPageToken: listing.Token,
+	}
+
+	for i := range listing.Operations {
+		op, err := s.checkAbandoned(ctx, db, &listing.Operations[i])
+		if err != nil {
+			return nil, err
+		}
+		response.Operations[i], err = op.Message()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+// GetOperation handles the corresponding API request.
+func (s *RegistryServer) GetOperation(ctx context.Context, req *longrunning.GetOperationRequest) (*longrunning.Operation, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if err := validateOperationName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	op, err := s.getOperation(ctx, db, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := op.Message()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return message, nil
+}
+
+// DeleteOperation handles the corresponding API request.
+func (s *RegistryServer) DeleteOperation(ctx context.Context, req *longrunning.DeleteOperationRequest) (*empty.Empty, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if err := validateOperationName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	// Running operations would record their results again after they were deleted.
+	if err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+		op, err := s.getOperation(ctx, db, req.GetName())
+		if err != nil {
+			return err
+		} else if !op.Done {
+			return status.Errorf(codes.FailedPrecondition, "operation %q is still running and must be cancelled or finish first", req.GetName())
+		}
+		return db.DeleteOperation(ctx, req.GetName())
+	}); err != nil {
+		return nil, err
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// CancelOperation handles the corresponding API request.
+func (s *RegistryServer) CancelOperation(ctx context.Context, req *longrunning.CancelOperationRequest) (*empty.Empty, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if err := validateOperationName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	// Operations running in other servers stop when they next record progress.
+	if err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+		op, err := s.getOperation(ctx, db, req.GetName())
+		if err != nil {
+			return err
+		} else if op.Done {
+			return nil
+		}
+		op.CancelRequested = true
+		return db.SaveOperation(ctx, op)
+	}); err != nil {
+		return nil, err
+	}
+
+	if run, ok := s.runningOperation(req.GetName()); ok {
+		run.stop(status.Error(codes.Canceled, "operation was cancelled"))
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// WaitOperation handles the corresponding API request.
+func (s *RegistryServer) WaitOperation(ctx context.Context, req *longrunning.WaitOperationRequest) (*longrunning.Operation, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	if err := validateOperationName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultWaitTimeout
+	if req.GetTimeout() != nil {
+		if err := req.GetTimeout().CheckValid(); err != nil || req.GetTimeout().AsDuration() < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid timeout %v: must be a positive duration", req.GetTimeout())
+		}
+		timeout = req.GetTimeout().AsDuration()
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	// Polls start frequently so that short operations are returned quickly.
+	interval := 10 * time.Millisecond
+	for {
+		op, err := s.getOperation(ctx, db, req.GetName())
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Done {
+			message, err := op.Message()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return message, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-deadline.C:
+			message, err := op.Message()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return message, nil
+		case <-time.After(interval):
+		}
+
+		if interval *= 2; interval > maxWaitPollInterval {
+			interval = maxWaitPollInterval
+		}
+	}
+}
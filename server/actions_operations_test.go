@@ -0,0 +1,210 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// waitOperation waits for an operation to finish and returns its final state.
+func waitOperation(ctx context.Context, t *testing.T, s *RegistryServer, op *longrunning.Operation) *longrunning.Operation {
+	t.Helper()
+
+	req := &longrunning.WaitOperationRequest{Name: op.GetName(), Timeout: durationpb.New(10 * time.Second)}
+	got, err := s.WaitOperation(ctx, req)
+	if err != nil {
+		t.Fatalf("WaitOperation(%+v) returned error: %s", req, err)
+	} else if !got.GetDone() {
+		t.Fatalf("WaitOperation(%+v) returned an unfinished operation", req)
+	}
+	return got
+}
+
+// operationMetadata returns the metadata of an operation.
+func operationMetadata(t *testing.T, op *longrunning.Operation) *rpc.OperationMetadata {
+	t.Helper()
+
+	metadata := new(rpc.OperationMetadata)
+	if err := op.GetMetadata().UnmarshalTo(metadata); err != nil {
+		t.Fatalf("Failed to unmarshal metadata of operation %q: %s", op.GetName(), err)
+	}
+	return metadata
+}
+
+// startBlockedOperation starts an operation that runs until it is stopped.
+func startBlockedOperation(ctx context.Context, t *testing.T, s *RegistryServer) *longrunning.Operation {
+	t.Helper()
+
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("Setup: getStorageClient() returned error: %s", err)
+	}
+
+	op, err := s.startOperation(ctx, dao.NewDAO(client, s.blobs), "wait", "projects/my-project", func(ctx context.Context, db dao.DAO, progress progressFunc) (proto.Message, error) {
+		for {
+			if err := progress(0, "Waiting"); err != nil {
+				return nil, err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Setup: startOperation() returned error: %s", err)
+	}
+	return op
+}
+
+func TestDeleteProjectOperation(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server,
+		&rpc.ApiSpec{Name: "projects/my-project/apis/a/versions/v1/specs/s"},
+		&rpc.ApiSpec{Name: "projects/my-project/apis/b/versions/v1/specs/s"},
+	)
+	seedArtifacts(ctx, t, server, &rpc.Artifact{Name: "projects/my-project/artifacts/my-artifact"})
+
+	req := &rpc.DeleteProjectRequest{Name: "projects/my-project"}
+	op, err := server.DeleteProject(ctx, req)
+	if err != nil {
+		t.Fatalf("DeleteProject(%+v) returned error: %s", req, err)
+	}
+
+	op = waitOperation(ctx, t, server, op)
+	if op.GetError() != nil {
+		t.Fatalf("DeleteProject(%+v) operation failed: %v", req, op.GetError())
+	} else if err := op.GetResponse().UnmarshalTo(new(emptypb.Empty)); err != nil {
+		t.Errorf("DeleteProject(%+v) operation returned unexpected response %v: %s", req, op.GetResponse(), err)
+	}
+
+	metadata := operationMetadata(t, op)
+	if metadata.GetVerb() != "delete" || metadata.GetTarget() != req.GetName() {
+		t.Errorf("DeleteProject(%+v) returned operation for %s %s, want delete %s", req, metadata.GetVerb(), metadata.GetTarget(), req.GetName())
+	}
+	if metadata.GetProgressPercent() != 100 || metadata.GetEndTime() == nil {
+		t.Errorf("DeleteProject(%+v) returned operation with progress %d%% and end_time %v, want 100%% and an end time", req, metadata.GetProgressPercent(), metadata.GetEndTime())
+	}
+
+	for _, name := range []string{"projects/my-project/apis/a", "projects/my-project/apis/b"} {
+		if _, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: name}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetApi(%q) returned status code %q, want %q: %v", name, status.Code(err), codes.NotFound, err)
+		}
+	}
+	if _, err := server.GetProject(ctx, &rpc.GetProjectRequest{Name: req.GetName()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProject(%q) returned status code %q, want %q: %v", req.GetName(), status.Code(err), codes.NotFound, err)
+	}
+
+	listing, err := server.ListOperations(ctx, &longrunning.ListOperationsRequest{Name: "operations", Filter: "verb == 'delete'"})
+	if err != nil {
+		t.Fatalf("ListOperations() returned error: %s", err)
+	} else if len(listing.GetOperations()) != 1 || listing.GetOperations()[0].GetName() != op.GetName() {
+		t.Errorf("ListOperations() returned %v, want only %q", listing.GetOperations(), op.GetName())
+	}
+
+	if _, err := server.DeleteOperation(ctx, &longrunning.DeleteOperationRequest{Name: op.GetName()}); err != nil {
+		t.Fatalf("DeleteOperation(%q) returned error: %s", op.GetName(), err)
+	}
+	if _, err := server.GetOperation(ctx, &longrunning.GetOperationRequest{Name: op.GetName()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOperation(%q) returned status code %q, want %q: %v", op.GetName(), status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestCancelOperation(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	op := startBlockedOperation(ctx, t, server)
+
+	waitReq := &longrunning.WaitOperationRequest{Name: op.GetName(), Timeout: durationpb.New(10 * time.Millisecond)}
+	if got, err := server.WaitOperation(ctx, waitReq); err != nil {
+		t.Fatalf("WaitOperation(%+v) returned error: %s", waitReq, err)
+	} else if got.GetDone() {
+		t.Errorf("WaitOperation(%+v) returned a finished operation, want it to time out", waitReq)
+	}
+
+	if _, err := server.DeleteOperation(ctx, &longrunning.DeleteOperationRequest{Name: op.GetName()}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteOperation(%q) returned status code %q, want %q: %v", op.GetName(), status.Code(err), codes.FailedPrecondition, err)
+	}
+
+	if _, err := server.CancelOperation(ctx, &longrunning.CancelOperationRequest{Name: op.GetName()}); err != nil {
+		t.Fatalf("CancelOperation(%q) returned error: %s", op.GetName(), err)
+	}
+
+	op = waitOperation(ctx, t, server, op)
+	if code := codes.Code(op.GetError().GetCode()); code != codes.Canceled {
+		t.Errorf("Cancelled operation failed with status code %q, want %q", code, codes.Canceled)
+	}
+	if !operationMetadata(t, op).GetCancelRequested() {
+		t.Errorf("Cancelled operation has cancel_requested false, want true")
+	}
+
+	// Finished operations can't be cancelled, but cancelling them isn't an error.
+	if _, err := server.CancelOperation(ctx, &longrunning.CancelOperationRequest{Name: op.GetName()}); err != nil {
+		t.Errorf("CancelOperation(%q) returned error: %s", op.GetName(), err)
+	}
+}
+
+func TestAbandonedOperation(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	client, err := server.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("Setup: getStorageClient() returned error: %s", err)
+	}
+
+	// Operations that stop recording progress were abandoned by their servers.
+	op := models.NewOperation("delete", "projects/my-project")
+	op.UpdateTime = time.Now().Add(-2 * operationTimeout)
+	db := dao.NewDAO(client, server.blobs)
+	if err := db.SaveOperation(ctx, op); err != nil {
+		t.Fatalf("Setup: SaveOperation() returned error: %s", err)
+	}
+
+	got, err := server.GetOperation(ctx, &longrunning.GetOperationRequest{Name: op.Name()})
+	if err != nil {
+		t.Fatalf("GetOperation(%q) returned error: %s", op.Name(), err)
+	} else if !got.GetDone() || codes.Code(got.GetError().GetCode()) != codes.Aborted {
+		t.Errorf("GetOperation(%q) returned %v, want an operation that failed with %q", op.Name(), got, codes.Aborted)
+	}
+}
+
+func TestOperationResponseCodes(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+
+	for _, name := range []string{"", "operations", "projects/my-project/operations/x", "operations/x/y"} {
+		if _, err := server.GetOperation(ctx, &longrunning.GetOperationRequest{Name: name}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetOperation(%q) returned status code %q, want %q: %v", name, status.Code(err), codes.InvalidArgument, err)
+		}
+	}
+
+	if _, err := server.GetOperation(ctx, &longrunning.GetOperationRequest{Name: "operations/missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOperation() returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+
+	if _, err := server.ListOperations(ctx, &longrunning.ListOperationsRequest{Name: "projects/my-project"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListOperations() returned status code %q, want %q: %v", status.Code(err), codes.InvalidArgument, err)
+	}
+}
@@ -16,14 +16,17 @@ package server
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/apigee/registry/rpc"
 	"github.com/apigee/registry/server/dao"
 	"github.com/apigee/registry/server/models"
 	"github.com/apigee/registry/server/names"
 	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/genproto/googleapis/longrunning"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // CreateProject handles the corresponding API request.
@@ -32,13 +35,19 @@ func (s *RegistryServer) CreateProject(ctx context.Context, req *rpc.CreateProje
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetProject() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid project %+v: body must be provided", req.GetProject())
 	}
 
+	replayed := new(rpc.Project)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
 	name := names.Project{ProjectID: req.GetProjectId()}
 	if _, err := db.GetProject(ctx, name); err == nil {
 		return nil, status.Errorf(codes.AlreadyExists, "project %q already exists", name)
@@ -51,22 +60,25 @@ func (s *RegistryServer) CreateProject(ctx context.Context, req *rpc.CreateProje
 	}
 
 	project := models.NewProject(name, req.GetProject())
-	if err := db.SaveProject(ctx, project); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveProject(ctx, project); err != nil {
+			return err
+		}
+		return s.recordRequest(ctx, db, req, project.Message())
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_CREATED, name.String())
 	return project.Message(), nil
 }
 
 // DeleteProject handles the corresponding API request.
-func (s *RegistryServer) DeleteProject(ctx context.Context, req *rpc.DeleteProjectRequest) (*empty.Empty, error) {
+func (s *RegistryServer) DeleteProject(ctx context.Context, req *rpc.DeleteProjectRequest) (*longrunning.Operation, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseProject(req.GetName())
 	if err != nil {
@@ -76,14 +88,59 @@ func (s *RegistryServer) DeleteProject(ctx context.Context, req *rpc.DeleteProje
 	// Deletion should only succeed on projects that currently exist.
 	if _, err := db.GetProject(ctx, name); err != nil {
 		return nil, err
-	}
-
-	if err := db.DeleteProject(ctx, name); err != nil {
+	} else if err := checkProjectEtag(ctx, db, name, req.GetEtag()); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
-	return &empty.Empty{}, nil
+	return s.startOperation(ctx, db, "delete", name.String(), func(ctx context.Context, db dao.DAO, progress progressFunc) (proto.Message, error) {
+		if err := s.deleteProject(ctx, db, name, progress); err != nil {
+			return nil, err
+		}
+		return &empty.Empty{}, nil
+	})
+}
+
+// deleteProject deletes the APIs of a project one at a time, so that progress can be
+// reported and the deletion can be cancelled between them. The project is deleted
+// with its remaining children at the end.
+func (s *RegistryServer) deleteProject(ctx context.Context, db dao.DAO, name names.Project, progress progressFunc) error {
+	apis := make([]names.Api, 0)
+	opts := dao.PageOptions{Size: 1000, ShowDeleted: true}
+	for {
+		listing, err := db.ListApis(ctx, name, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, api := range listing.Apis {
+			apis = append(apis, name.Api(api.ApiID))
+		}
+
+		if listing.Token == "" {
+			break
+		}
+		opts.Token = listing.Token
+	}
+
+	// The project itself is the last step.
+	steps := len(apis) + 1
+	for i, api := range apis {
+		if err := progress(int32(100*i/steps), fmt.Sprintf("Deleting %s", api)); err != nil {
+			return err
+		}
+		if err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+			return db.DeleteApi(ctx, api)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := progress(int32(100*len(apis)/steps), fmt.Sprintf("Deleting %s", name)); err != nil {
+		return err
+	}
+	return s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		return db.DeleteProject(ctx, name)
+	})
 }
 
 // GetProject handles the corresponding API request.
@@ -92,8 +149,7 @@ func (s *RegistryServer) GetProject(ctx context.Context, req *rpc.GetProjectRequ
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseProject(req.GetName())
 	if err != nil {
@@ -114,8 +170,7 @@ func (s *RegistryServer) ListProjects(ctx context.Context, req *rpc.ListProjects
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -152,8 +207,7 @@ func (s *RegistryServer) UpdateProject(ctx context.Context, req *rpc.UpdateProje
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetProject() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid project %+v: body must be provided", req.GetProject())
@@ -166,16 +220,32 @@ func (s *RegistryServer) UpdateProject(ctx context.Context, req *rpc.UpdateProje
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	project, err := db.GetProject(ctx, name)
-	if err != nil {
+	replayed := new(rpc.Project)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
-	}
-
-	project.Update(req.GetProject(), models.ExpandMask(req.GetProject(), req.GetUpdateMask()))
-	if err := db.SaveProject(ctx, project); err != nil {
+	} else if ok {
+		return replayed, nil
+	}
+
+	// The project is read for update and saved in one transaction, so concurrent updates can't overwrite each other.
+	var project *models.Project
+	if err := s.commit(ctx, db, rpc.Notification_UPDATED, name.String(), func(db dao.DAO) error {
+		locked := db.ForUpdate()
+		var err error
+		if project, err = locked.GetProject(ctx, name); err != nil {
+			return err
+		} else if err := checkEtag(name.String(), req.GetProject().GetEtag(), project.Message().GetEtag()); err != nil {
+			return err
+		}
+
+		project.Update(req.GetProject(), models.ExpandMask(req.GetProject(), req.GetUpdateMask()))
+		if err := db.SaveProject(ctx, project); err != nil {
+			return err
+		}
+		return s.recordRequest(ctx, db, req, project.Message())
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, name.String())
 	return project.Message(), nil
 }
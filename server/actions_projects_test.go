@@ -87,7 +87,7 @@ func TestCreateProject(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, created, opts) {
@@ -281,7 +281,7 @@ func TestGetProject(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, got, opts) {
@@ -427,7 +427,7 @@ func TestListProjects(t *testing.T) {
 			opts := cmp.Options{
 				protocmp.Transform(),
 				protocmp.IgnoreFields(new(rpc.ListProjectsResponse), "next_page_token"),
-				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time", "etag"),
 				test.extraOpts,
 			}
 
@@ -579,7 +579,7 @@ func TestListProjectsSequence(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time"),
+		protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time", "etag"),
 		cmpopts.SortSlices(func(a, b *rpc.Project) bool {
 			return a.GetName() < b.GetName()
 		}),
@@ -722,7 +722,7 @@ func TestUpdateProject(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.Project), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, updated, opts) {
@@ -828,9 +828,13 @@ func TestDeleteProject(t *testing.T) {
 			server := defaultTestServer(t)
 			seedProjects(ctx, t, server, test.seed)
 
-			if _, err := server.DeleteProject(ctx, test.req); err != nil {
+			op, err := server.DeleteProject(ctx, test.req)
+			if err != nil {
 				t.Fatalf("DeleteProject(%+v) returned error: %s", test.req, err)
 			}
+			if op = waitOperation(ctx, t, server, op); op.GetError() != nil {
+				t.Fatalf("DeleteProject(%+v) operation failed: %v", test.req, op.GetError())
+			}
 
 			t.Run("GetProject", func(t *testing.T) {
 				req := &rpc.GetProjectRequest{
@@ -16,9 +16,11 @@ package server
 
 import (
 	"context"
+	"strings"
 
 	"github.com/apigee/registry/rpc"
 	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/diff"
 	"github.com/apigee/registry/server/models"
 	"github.com/apigee/registry/server/names"
 	"github.com/golang/protobuf/ptypes/empty"
@@ -32,8 +34,7 @@ func (s *RegistryServer) ListApiSpecRevisions(ctx context.Context, req *rpc.List
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -49,8 +50,9 @@ func (s *RegistryServer) ListApiSpecRevisions(ctx context.Context, req *rpc.List
 	}
 
 	listing, err := db.ListSpecRevisions(ctx, parent, dao.PageOptions{
-		Size:  req.GetPageSize(),
-		Token: req.GetPageToken(),
+		Size:    req.GetPageSize(),
+		Token:   req.GetPageToken(),
+		OrderBy: req.GetOrderBy(),
 	})
 	if err != nil {
 		return nil, err
@@ -77,8 +79,7 @@ func (s *RegistryServer) DeleteApiSpecRevision(ctx context.Context, req *rpc.Del
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseSpecRevision(req.GetName())
 	if err != nil {
@@ -97,22 +98,154 @@ func (s *RegistryServer) DeleteApiSpecRevision(ctx context.Context, req *rpc.Del
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err := db.DeleteSpecRevision(ctx, name); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		return db.DeleteSpecRevision(ctx, name)
+	}); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
 	return &empty.Empty{}, nil
 }
 
+// DiffApiSpecRevisions handles the corresponding API request.
+func (s *RegistryServer) DiffApiSpecRevisions(ctx context.Context, req *rpc.DiffApiSpecRevisionsRequest) (*rpc.DiffApiSpecRevisionsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	var revision *models.Spec
+	if name, err := names.ParseSpec(req.GetName()); err == nil {
+		if revision, err = db.GetSpec(ctx, name); err != nil {
+			return nil, err
+		}
+	} else if name, err := names.ParseSpecRevision(req.GetName()); err == nil {
+		if revision, err = db.GetSpecRevision(ctx, name); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q, must be an API spec or revision", req.GetName())
+	}
+
+	name, err := names.ParseSpec(revision.Name())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var base *models.Spec
+	if id := req.GetBaseRevisionId(); id != "" {
+		if base, err = db.GetSpecRevision(ctx, name.Revision(id)); err != nil {
+			return nil, err
+		}
+	} else if base, err = previousSpecRevision(ctx, db, name, revision.RevisionID); err != nil {
+		return nil, err
+	}
+
+	before, err := specDescription(ctx, db, name, base)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := specDescription(ctx, db, name, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := diff.Diff(before, after)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to compare revisions: %s", err)
+	}
+
+	return response, nil
+}
+
+// previousSpecRevision returns the revision of a spec that was created immediately before the identified revision.
+func previousSpecRevision(ctx context.Context, db dao.DAO, name names.Spec, revisionID string) (*models.Spec, error) {
+	opts := dao.PageOptions{Size: 1000}
+	found := false
+	for {
+		listing, err := db.ListSpecRevisions(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// Revisions are listed from newest to oldest.
+		for i := range listing.Specs {
+			if found {
+				return &listing.Specs[i], nil
+			}
+			found = listing.Specs[i].RevisionID == revisionID
+		}
+
+		if listing.Token == "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "spec revision %q has no earlier revision", name.Revision(revisionID))
+		}
+		opts.Token = listing.Token
+	}
+}
+
+// specDescription returns the uncompressed contents of a spec revision for comparison.
+func specDescription(ctx context.Context, db dao.DAO, name names.Spec, spec *models.Spec) (diff.Description, error) {
+	blob, err := db.GetSpecRevisionContents(ctx, name.Revision(spec.RevisionID))
+	if err != nil {
+		return diff.Description{}, err
+	}
+
+	d := diff.Description{
+		Name:     spec.RevisionName(),
+		MimeType: spec.MimeType,
+		Contents: blob.Contents,
+	}
+	if strings.Contains(d.MimeType, "+gzip") {
+		if d.Contents, err = GUnzippedBytes(d.Contents); err != nil {
+			return diff.Description{}, status.Errorf(codes.FailedPrecondition, "failed to unzip contents with gzip MIME type: %s", err)
+		}
+		d.MimeType = strings.Replace(d.MimeType, "+gzip", "", 1)
+	}
+
+	return d, nil
+}
+
+// PruneApiSpecRevisions handles the corresponding API request.
+func (s *RegistryServer) PruneApiSpecRevisions(ctx context.Context, req *rpc.PruneApiSpecRevisionsRequest) (*rpc.PruneApiSpecRevisionsResponse, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseSpec(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, err := db.GetSpec(ctx, name); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.retentionPolicy(ctx, db, name.Api())
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.pruneSpecRevisions(ctx, db, name, policy, req.GetValidateOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	return &rpc.PruneApiSpecRevisionsResponse{
+		RevisionNames: revisions,
+	}, nil
+}
+
 // TagApiSpecRevision handles the corresponding API request.
 func (s *RegistryServer) TagApiSpecRevision(ctx context.Context, req *rpc.TagApiSpecRevisionRequest) (*rpc.ApiSpec, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetTag() == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid tag %q, must not be empty", req.GetTag())
@@ -126,6 +259,13 @@ func (s *RegistryServer) TagApiSpecRevision(ctx context.Context, req *rpc.TagApi
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	replayed := new(rpc.ApiSpec)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
 	revision, err := db.GetSpecRevision(ctx, name)
 	if err != nil {
 		return nil, err
@@ -139,16 +279,20 @@ func (s *RegistryServer) TagApiSpecRevision(ctx context.Context, req *rpc.TagApi
 	}
 
 	tag := models.NewSpecRevisionTag(name, req.GetTag())
-	if err := db.SaveSpecRevisionTag(ctx, tag); err != nil {
-		return nil, err
-	}
-
 	message, err := revision.BasicMessage(tag.String())
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, name.String())
+	if err := s.commit(ctx, db, rpc.Notification_UPDATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveSpecRevisionTag(ctx, tag); err != nil {
+			return err
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
+		return nil, err
+	}
+
 	return message, nil
 }
 
@@ -158,8 +302,7 @@ func (s *RegistryServer) RollbackApiSpec(ctx context.Context, req *rpc.RollbackA
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetRevisionId() == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid revision ID %q, must not be empty", req.GetRevisionId())
@@ -177,20 +320,21 @@ func (s *RegistryServer) RollbackApiSpec(ctx context.Context, req *rpc.RollbackA
 		return nil, err
 	}
 
-	// Save a new rollback revision based on the target revision.
-	rollback := target.NewRevision()
-	if err := db.SaveSpecRevision(ctx, rollback); err != nil {
-		return nil, err
-	}
-
 	blob, err := db.GetSpecRevisionContents(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	// Save a new copy of the target revision blob for the rollback revision.
+	// Save a new rollback revision based on the target revision,
+	// with a new copy of the target revision blob.
+	rollback := target.NewRevision()
 	blob.RevisionID = name.RevisionID
-	if err := db.SaveSpecRevisionContents(ctx, rollback, blob.Contents); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, rollback.RevisionName(), func(db dao.DAO) error {
+		if err := db.SaveSpecRevision(ctx, rollback); err != nil {
+			return err
+		}
+		return db.SaveSpecRevisionContents(ctx, rollback, blob.Contents)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -199,6 +343,5 @@ func (s *RegistryServer) RollbackApiSpec(ctx context.Context, req *rpc.RollbackA
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_CREATED, rollback.RevisionName())
 	return message, nil
 }
@@ -15,11 +15,19 @@
 package server
 
 import (
+	"time"
+
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/blobs"
+	"github.com/apigee/registry/server/models"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -207,11 +215,12 @@ func TestRollbackApiSpec(t *testing.T) {
 		CreateTime:         firstRevision.GetCreateTime(),
 		RevisionCreateTime: firstRevision.GetRevisionCreateTime(),
 		RevisionUpdateTime: firstRevision.GetRevisionUpdateTime(),
+		Etag:               firstRevision.GetEtag(),
 	}
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "revision_create_time", "revision_update_time"),
+		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "revision_create_time", "revision_update_time", "etag"),
 	}
 
 	if !cmp.Equal(want, rollback, opts) {
@@ -303,6 +312,7 @@ func TestListApiSpecRevisions(t *testing.T) {
 		RevisionCreateTime: firstRevision.GetRevisionCreateTime(),
 		RevisionUpdateTime: firstRevision.GetRevisionUpdateTime(),
 		RevisionId:         firstRevision.GetRevisionId(),
+		Etag:               firstRevision.GetEtag(),
 	}
 
 	updateReq := &rpc.UpdateApiSpecRequest{
@@ -326,6 +336,7 @@ func TestListApiSpecRevisions(t *testing.T) {
 		CreateTime:         secondRevision.GetCreateTime(),
 		RevisionCreateTime: secondRevision.GetRevisionCreateTime(),
 		RevisionUpdateTime: secondRevision.GetRevisionUpdateTime(),
+		Etag:               secondRevision.GetEtag(),
 		RevisionId:         secondRevision.GetRevisionId(),
 	}
 
@@ -415,7 +426,7 @@ func TestUpdateApiSpecRevisions(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 	}
 
 	t.Run("modify revision without content changes", func(t *testing.T) {
@@ -480,3 +491,292 @@ func TestUpdateApiSpecRevisions(t *testing.T) {
 		}
 	})
 }
+
+func TestSpecRevisionContentsAreShared(t *testing.T) {
+	servers := map[string]func(t *testing.T) (*RegistryServer, func(hash string) bool){
+		"database": func(t *testing.T) (*RegistryServer, func(string) bool) {
+			server := defaultTestServer(t)
+			return server, func(hash string) bool {
+				client, err := server.getStorageClient(context.Background())
+				if err != nil {
+					t.Fatalf("getStorageClient() returned error: %s", err)
+				}
+				err = client.Get(context.Background(), client.NewKey(models.BlobContentsEntityName, hash), new(models.BlobContents))
+				if err != nil && !client.IsNotFound(err) {
+					t.Fatalf("Get(%s) returned error: %s", hash, err)
+				}
+				return err == nil
+			}
+		},
+		"filesystem": func(t *testing.T) (*RegistryServer, func(string) bool) {
+			dir := t.TempDir()
+			server := New(Config{
+				Database: "sqlite3",
+				DBConfig: fmt.Sprintf("%s/registry.db", t.TempDir()),
+				Blobs:    blobs.Config{Type: blobs.Filesystem, Path: dir},
+			})
+			t.Cleanup(server.Close)
+			return server, func(hash string) bool {
+				_, err := os.Stat(filepath.Join(dir, hash[:2], hash))
+				return err == nil
+			}
+		},
+	}
+
+	for name, create := range servers {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			server, stored := create(t)
+
+			shared, updated := specContents, []byte(`{"openapi": "3.0.0", "info": {"title": "My API", "version": "v2"}, "paths": {}}`)
+			seedSpecs(ctx, t, server, &rpc.ApiSpec{
+				Name:     "projects/my-project/apis/a/versions/v1/specs/s",
+				Contents: shared,
+			}, &rpc.ApiSpec{
+				Name:     "projects/my-project/apis/b/versions/v1/specs/s",
+				Contents: shared,
+			})
+
+			first, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/a/versions/v1/specs/s"})
+			if err != nil {
+				t.Fatalf("Setup: GetApiSpec returned error: %s", err)
+			}
+			updateReq := &rpc.UpdateApiSpecRequest{
+				ApiSpec: &rpc.ApiSpec{
+					Name:     "projects/my-project/apis/a/versions/v1/specs/s",
+					Contents: updated,
+				},
+			}
+			if _, err := server.UpdateApiSpec(ctx, updateReq); err != nil {
+				t.Fatalf("Setup: UpdateApiSpec(%+v) returned error: %s", updateReq, err)
+			}
+
+			// The shared contents are still referred to by the other spec.
+			deleteRevisionReq := &rpc.DeleteApiSpecRevisionRequest{
+				Name: fmt.Sprintf("projects/my-project/apis/a/versions/v1/specs/s@%s", first.GetRevisionId()),
+			}
+			if _, err := server.DeleteApiSpecRevision(ctx, deleteRevisionReq); err != nil {
+				t.Fatalf("DeleteApiSpecRevision(%+v) returned error: %s", deleteRevisionReq, err)
+			}
+			if !stored(sha256hash(shared)) {
+				t.Errorf("Shared contents were deleted while a spec refers to them")
+			}
+
+			getReq := &rpc.GetApiSpecContentsRequest{Name: "projects/my-project/apis/b/versions/v1/specs/s/contents"}
+			if got, err := server.GetApiSpecContents(ctx, getReq); err != nil {
+				t.Fatalf("GetApiSpecContents(%+v) returned error: %s", getReq, err)
+			} else if !bytes.Equal(got.GetData(), shared) {
+				t.Errorf("GetApiSpecContents(%+v) returned %q, want %q", getReq, got.GetData(), shared)
+			}
+
+			deleteReq := &rpc.DeleteApiSpecRequest{Name: "projects/my-project/apis/b/versions/v1/specs/s"}
+			if _, err := server.DeleteApiSpec(ctx, deleteReq); err != nil {
+				t.Fatalf("DeleteApiSpec(%+v) returned error: %s", deleteReq, err)
+			}
+			if !stored(sha256hash(shared)) {
+				t.Errorf("Shared contents were deleted before the spec was purged")
+			}
+			if err := server.purge(ctx, time.Now().Add(server.purgeDelay()+time.Minute)); err != nil {
+				t.Fatalf("purge returned error: %s", err)
+			}
+			if stored(sha256hash(shared)) {
+				t.Errorf("Shared contents were not deleted with the last spec that refers to them")
+			}
+			if !stored(sha256hash(updated)) {
+				t.Errorf("Contents of the remaining spec were deleted")
+			}
+		})
+	}
+}
+
+func TestSharedContentsConcurrently(t *testing.T) {
+	servers := map[string]func(t *testing.T) *RegistryServer{
+		"database": defaultTestServer,
+		"filesystem": func(t *testing.T) *RegistryServer {
+			server := defaultTestServer(t)
+			store, err := blobs.NewFilesystem(t.TempDir())
+			if err != nil {
+				t.Fatalf("Setup: NewFilesystem() returned error: %s", err)
+			}
+			server.blobs = store
+			return server
+		},
+	}
+
+	for name, create := range servers {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			server := create(t)
+			seedVersions(ctx, t, server, &rpc.ApiVersion{Name: "projects/my-project/apis/my-api/versions/v1"})
+
+			// Specs with the same contents are created while the only revision that shares them is deleted.
+			const rounds, count = 4, 4
+			for round := 0; round < rounds; round++ {
+				name := fmt.Sprintf("projects/my-project/apis/my-api/versions/v1/specs/deleted-%d", round)
+				seedSpecs(ctx, t, server, &rpc.ApiSpec{Name: name, Contents: specContents})
+				first, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: name})
+				if err != nil {
+					t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+				}
+				update := &rpc.UpdateApiSpecRequest{ApiSpec: &rpc.ApiSpec{Name: name, Contents: []byte(fmt.Sprintf("updated %d", round))}}
+				if _, err := server.UpdateApiSpec(ctx, update); err != nil {
+					t.Fatalf("Setup: UpdateApiSpec(%+v) returned error: %s", update, err)
+				}
+
+				errs := make(chan error, count+1)
+				go func() {
+					_, err := server.DeleteApiSpecRevision(ctx, &rpc.DeleteApiSpecRevisionRequest{Name: name + "@" + first.GetRevisionId()})
+					errs <- err
+				}()
+				for i := 0; i < count; i++ {
+					go func(i int) {
+						_, err := server.CreateApiSpec(ctx, &rpc.CreateApiSpecRequest{
+							Parent:    "projects/my-project/apis/my-api/versions/v1",
+							ApiSpecId: fmt.Sprintf("created-%d-%d", round, i),
+							ApiSpec:   &rpc.ApiSpec{Contents: specContents},
+						})
+						errs <- err
+					}(i)
+				}
+				for i := 0; i < count+1; i++ {
+					if err := <-errs; err != nil {
+						t.Fatalf("Concurrent request returned error: %s", err)
+					}
+				}
+
+				for i := 0; i < count; i++ {
+					req := &rpc.GetApiSpecContentsRequest{
+						Name: fmt.Sprintf("projects/my-project/apis/my-api/versions/v1/specs/created-%d-%d/contents", round, i),
+					}
+					if got, err := server.GetApiSpecContents(ctx, req); err != nil {
+						t.Fatalf("GetApiSpecContents(%+v) returned error: %s", req, err)
+					} else if !bytes.Equal(got.GetData(), specContents) {
+						t.Errorf("GetApiSpecContents(%+v) returned %q, want %q", req, got.GetData(), specContents)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDiffApiSpecRevisions(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+
+	const spec = "projects/my-project/apis/my-api/versions/v1/specs/openapi.json"
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name:     spec,
+		MimeType: "application/x.openapi;version=3",
+		Contents: specContents,
+	})
+	first, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: spec})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec(%q) returned error: %s", spec, err)
+	}
+
+	// The updated revision is compressed to check that revisions are compared uncompressed.
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	if _, err := zw.Write([]byte(`{"openapi": "3.0.0", "info": {"title": "My API", "version": "v2"}, "paths": {"/pets": {"get": {"responses": {"200": {"description": "ok"}}}}}}`)); err != nil {
+		t.Fatalf("Setup: failed to compress contents: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Setup: failed to compress contents: %s", err)
+	}
+	updateReq := &rpc.UpdateApiSpecRequest{
+		ApiSpec: &rpc.ApiSpec{
+			Name:     spec,
+			MimeType: "application/x.openapi+gzip;version=3",
+			Contents: gzipped.Bytes(),
+		},
+	}
+	second, err := server.UpdateApiSpec(ctx, updateReq)
+	if err != nil {
+		t.Fatalf("Setup: UpdateApiSpec(%+v) returned error: %s", updateReq, err)
+	}
+
+	want := &rpc.DiffApiSpecRevisionsResponse{
+		BaseRevisionName: fmt.Sprintf("%s@%s", spec, first.GetRevisionId()),
+		RevisionName:     fmt.Sprintf("%s@%s", spec, second.GetRevisionId()),
+		Format:           "openapi",
+		Changes: []*rpc.ApiSpecChange{
+			{Kind: rpc.ApiSpecChange_ADDED, Element: "path", Location: "paths./pets", Attributes: map[string]string{}},
+		},
+	}
+
+	tests := []struct {
+		desc string
+		req  *rpc.DiffApiSpecRevisionsRequest
+	}{
+		{
+			desc: "current revision with previous revision",
+			req:  &rpc.DiffApiSpecRevisionsRequest{Name: spec},
+		},
+		{
+			desc: "specific revisions",
+			req: &rpc.DiffApiSpecRevisionsRequest{
+				Name:           fmt.Sprintf("%s@%s", spec, second.GetRevisionId()),
+				BaseRevisionId: first.GetRevisionId(),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := server.DiffApiSpecRevisions(ctx, test.req)
+			if err != nil {
+				t.Fatalf("DiffApiSpecRevisions(%+v) returned error: %s", test.req, err)
+			}
+			if !cmp.Equal(want, got, protocmp.Transform()) {
+				t.Errorf("DiffApiSpecRevisions(%+v) returned unexpected diff (-want +got):\n%s", test.req, cmp.Diff(want, got, protocmp.Transform()))
+			}
+		})
+	}
+}
+
+func TestDiffApiSpecRevisionsResponseCodes(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name:     "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+		Contents: specContents,
+	})
+
+	tests := []struct {
+		desc string
+		req  *rpc.DiffApiSpecRevisionsRequest
+		want codes.Code
+	}{
+		{
+			desc: "invalid name",
+			req:  &rpc.DiffApiSpecRevisionsRequest{Name: "projects/my-project/apis/my-api"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "missing spec",
+			req:  &rpc.DiffApiSpecRevisionsRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/missing"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "missing base revision",
+			req: &rpc.DiffApiSpecRevisionsRequest{
+				Name:           "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+				BaseRevisionId: "missing",
+			},
+			want: codes.NotFound,
+		},
+		{
+			desc: "only revision",
+			req:  &rpc.DiffApiSpecRevisionsRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"},
+			want: codes.FailedPrecondition,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := server.DiffApiSpecRevisions(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("DiffApiSpecRevisions(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
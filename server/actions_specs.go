@@ -29,7 +29,6 @@ import (
 	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // CreateApiSpec handles the corresponding API request.
@@ -43,51 +42,52 @@ func (s *RegistryServer) CreateApiSpec(ctx context.Context, req *rpc.CreateApiSp
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api_spec %+v: body must be provided", req.GetApiSpec())
 	}
 
-	return s.createSpec(ctx, parent.Spec(req.GetApiSpecId()), req.GetApiSpec())
+	return s.createSpec(ctx, parent.Spec(req.GetApiSpecId()), req)
 }
 
-func (s *RegistryServer) createSpec(ctx context.Context, name names.Spec, body *rpc.ApiSpec) (*rpc.ApiSpec, error) {
+func (s *RegistryServer) createSpec(ctx context.Context, name names.Spec, req *rpc.CreateApiSpecRequest) (*rpc.ApiSpec, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
-	if _, err := db.GetSpec(ctx, name); err == nil {
-		return nil, status.Errorf(codes.AlreadyExists, "API spec %q already exists", name)
-	} else if !isNotFound(err) {
+	replayed := new(rpc.ApiSpec)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
+	} else if ok {
+		return replayed, nil
 	}
 
-	if err := name.Validate(); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
-	}
-
-	// Creation should only succeed when the parent exists.
-	if _, err := db.GetVersion(ctx, name.Version()); err != nil {
+	body := req.GetApiSpec()
+	// Names of deleted specs can't be reused until the specs are purged.
+	if _, err := db.GetSpecIncludingDeleted(ctx, name); err == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "API spec %q already exists", name)
+	} else if !isNotFound(err) {
 		return nil, err
 	}
 
-	spec, err := models.NewSpec(name, body)
+	spec, err := newSpec(ctx, db, name, body)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
-	}
-
-	if err := db.SaveSpecRevision(ctx, spec); err != nil {
 		return nil, err
 	}
 
-	if err := db.SaveSpecRevisionContents(ctx, spec, body.GetContents()); err != nil {
+	var message *rpc.ApiSpec
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, spec.RevisionName(), func(db dao.DAO) error {
+		if err := db.SaveSpecRevision(ctx, spec); err != nil {
+			return err
+		}
+		if err := db.SaveSpecRevisionContents(ctx, spec, body.GetContents()); err != nil {
+			return err
+		}
+		if message, err = spec.BasicMessage(name.String()); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
 		return nil, err
 	}
 
-	message, err := spec.BasicMessage(name.String())
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	s.notify(ctx, rpc.Notification_CREATED, spec.RevisionName())
 	return message, nil
 }
 
@@ -97,8 +97,7 @@ func (s *RegistryServer) DeleteApiSpec(ctx context.Context, req *rpc.DeleteApiSp
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseSpec(req.GetName())
 	if err != nil {
@@ -110,14 +109,49 @@ func (s *RegistryServer) DeleteApiSpec(ctx context.Context, req *rpc.DeleteApiSp
 		return nil, err
 	}
 
-	if err := db.DeleteSpec(ctx, name); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if err := checkSpecEtag(ctx, db, name, req.GetEtag()); err != nil {
+			return err
+		}
+		deleteTime, purgeTime := s.deletionTimes()
+		return db.SoftDeleteSpec(ctx, name, deleteTime, purgeTime)
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
 	return &empty.Empty{}, nil
 }
 
+// UndeleteApiSpec handles the corresponding API request.
+func (s *RegistryServer) UndeleteApiSpec(ctx context.Context, req *rpc.UndeleteApiSpecRequest) (*rpc.ApiSpec, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseSpec(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var message *rpc.ApiSpec
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		spec, err := db.UndeleteSpec(ctx, name)
+		if err != nil {
+			return err
+		}
+		if message, err = spec.BasicMessage(name.String()); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
 // GetApiSpec handles the corresponding API request.
 func (s *RegistryServer) GetApiSpec(ctx context.Context, req *rpc.GetApiSpecRequest) (*rpc.ApiSpec, error) {
 	if name, err := names.ParseSpec(req.GetName()); err == nil {
@@ -134,10 +168,10 @@ func (s *RegistryServer) getApiSpec(ctx context.Context, name names.Spec) (*rpc.
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
-	spec, err := db.GetSpec(ctx, name)
+	// Deleted specs are returned until they are purged.
+	spec, err := db.GetSpecIncludingDeleted(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -155,10 +189,9 @@ func (s *RegistryServer) getApiSpecRevision(ctx context.Context, name names.Spec
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
-	revision, err := db.GetSpecRevision(ctx, name)
+	revision, err := db.GetSpecRevisionIncludingDeleted(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -187,8 +220,7 @@ func (s *RegistryServer) GetApiSpecContents(ctx context.Context, req *rpc.GetApi
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if !strings.HasSuffix(req.GetName(), "/contents") {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q, must include /contents suffix", req.GetName())
@@ -236,8 +268,7 @@ func (s *RegistryServer) ListApiSpecs(ctx context.Context, req *rpc.ListApiSpecs
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -253,9 +284,11 @@ func (s *RegistryServer) ListApiSpecs(ctx context.Context, req *rpc.ListApiSpecs
 	}
 
 	listing, err := db.ListSpecs(ctx, parent, dao.PageOptions{
-		Size:   req.GetPageSize(),
-		Filter: req.GetFilter(),
-		Token:  req.GetPageToken(),
+		Size:        req.GetPageSize(),
+		Filter:      req.GetFilter(),
+		Token:       req.GetPageToken(),
+		OrderBy:     req.GetOrderBy(),
+		ShowDeleted: req.GetShowDeleted(),
 	})
 	if err != nil {
 		return nil, err
@@ -282,8 +315,7 @@ func (s *RegistryServer) UpdateApiSpec(ctx context.Context, req *rpc.UpdateApiSp
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetApiSpec() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api_spec %+v: body must be provided", req.GetApiSpec())
@@ -296,37 +328,70 @@ func (s *RegistryServer) UpdateApiSpec(ctx context.Context, req *rpc.UpdateApiSp
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	spec, err := db.GetSpec(ctx, name)
-	if req.GetAllowMissing() && isNotFound(err) {
-		return s.createSpec(ctx, name, req.GetApiSpec())
-	} else if err != nil {
+	replayed := new(rpc.ApiSpec)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
-	}
+	} else if ok {
+		return replayed, nil
+	}
+
+	// The spec is read and saved in one transaction, so concurrent updates can't overwrite each other.
+	// Updates create a new revision when the contents change, and otherwise update the current revision.
+	var message *rpc.ApiSpec
+	if err := s.commitChange(ctx, db, func(db dao.DAO) (*models.Change, error) {
+		spec, created, err := db.UpsertSpec(ctx, name, req.GetAllowMissing(), req.ApiSpec.GetContents(), func(spec *models.Spec) (*models.Spec, error) {
+			if spec == nil {
+				return newSpec(ctx, db, name, req.GetApiSpec())
+			}
+
+			current, err := spec.BasicMessage(name.String())
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			} else if err := checkEtag(name.String(), req.GetApiSpec().GetEtag(), current.GetEtag()); err != nil {
+				return nil, err
+			}
+
+			if err := spec.Update(req.GetApiSpec(), models.ExpandMask(req.GetApiSpec(), req.GetUpdateMask())); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return spec, nil
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	// Apply the update to the spec - possibly changing the revision ID.
-	if err := spec.Update(req.GetApiSpec(), models.ExpandMask(req.GetApiSpec(), req.GetUpdateMask())); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
+		if message, err = spec.BasicMessage(name.String()); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		} else if err := s.recordRequest(ctx, db, req, message); err != nil {
+			return nil, err
+		}
 
-	// Save the updated/current spec. This creates a new revision or updates the previous one.
-	if err := db.SaveSpecRevision(ctx, spec); err != nil {
+		if created {
+			return models.NewChange(rpc.Notification_CREATED, spec.RevisionName()), nil
+		}
+		return models.NewChange(rpc.Notification_UPDATED, spec.RevisionName()), nil
+	}); err != nil {
 		return nil, err
 	}
 
-	// If the spec contents were updated, save a new blob.
-	implicitUpdate := req.GetUpdateMask() == nil && len(req.ApiSpec.GetContents()) > 0
-	explicitUpdate := len(fieldmaskpb.Intersect(req.GetUpdateMask(), &fieldmaskpb.FieldMask{Paths: []string{"contents"}}).GetPaths()) > 0
-	if implicitUpdate || explicitUpdate {
-		if err := db.SaveSpecRevisionContents(ctx, spec, req.ApiSpec.GetContents()); err != nil {
-			return nil, err
-		}
+	return message, nil
+}
+
+// newSpec returns a new spec with the specified name after checking that it can be created.
+func newSpec(ctx context.Context, db dao.DAO, name names.Spec, body *rpc.ApiSpec) (*models.Spec, error) {
+	if err := name.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	message, err := spec.BasicMessage(name.String())
+	// Creation should only succeed when the parent exists.
+	if _, err := db.GetVersion(ctx, name.Version()); err != nil {
+		return nil, err
+	}
+
+	spec, err := models.NewSpec(name, body)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, spec.RevisionName())
-	return message, nil
+	return spec, nil
 }
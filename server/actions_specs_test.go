@@ -15,12 +15,16 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/blobs"
 	"github.com/apigee/registry/server/names"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -125,7 +129,7 @@ func TestCreateApiSpec(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, created, opts) {
@@ -385,7 +389,7 @@ func TestGetApiSpec(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, got, opts) {
@@ -684,6 +688,62 @@ func TestListApiSpecs(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "mime type contains filtering",
+			seed: []*rpc.ApiSpec{
+				{
+					Name:     "projects/my-project/apis/my-api/versions/v1/specs/spec1",
+					MimeType: "application/x.openapi;version=3",
+				},
+				{
+					Name:     "projects/my-project/apis/my-api/versions/v1/specs/spec2",
+					MimeType: "application/x.OPENAPI;version=2",
+				},
+				{Name: "projects/my-project/apis/my-api/versions/v1/specs/spec3"},
+			},
+			req: &rpc.ListApiSpecsRequest{
+				Parent: "projects/my-project/apis/-/versions/-",
+				Filter: "mime_type.contains('openapi')",
+			},
+			want: &rpc.ListApiSpecsResponse{
+				ApiSpecs: []*rpc.ApiSpec{
+					{
+						Name:     "projects/my-project/apis/my-api/versions/v1/specs/spec1",
+						MimeType: "application/x.openapi;version=3",
+					},
+				},
+			},
+		},
+		{
+			desc: "label filtering",
+			seed: []*rpc.ApiSpec{
+				{
+					Name:   "projects/my-project/apis/my-api/versions/v1/specs/spec1",
+					Labels: map[string]string{"env": "prod"},
+				},
+				{
+					Name:   "projects/my-project/apis/my-api/versions/v1/specs/spec2",
+					Labels: map[string]string{"env": "dev"},
+				},
+				{Name: "projects/my-project/apis/my-api/versions/v1/specs/spec3"},
+			},
+			req: &rpc.ListApiSpecsRequest{
+				Parent: "projects/my-project/apis/my-api/versions/v1",
+				Filter: "labels.env == 'prod' || (has(labels.env) && spec_id.startsWith('spec2'))",
+			},
+			want: &rpc.ListApiSpecsResponse{
+				ApiSpecs: []*rpc.ApiSpec{
+					{
+						Name:   "projects/my-project/apis/my-api/versions/v1/specs/spec1",
+						Labels: map[string]string{"env": "prod"},
+					},
+					{
+						Name:   "projects/my-project/apis/my-api/versions/v1/specs/spec2",
+						Labels: map[string]string{"env": "dev"},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -700,7 +760,7 @@ func TestListApiSpecs(t *testing.T) {
 			opts := cmp.Options{
 				protocmp.Transform(),
 				protocmp.IgnoreFields(new(rpc.ListApiSpecsResponse), "next_page_token"),
-				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 				protocmp.SortRepeated(func(a, b *rpc.ApiSpec) bool {
 					return a.GetName() < b.GetName()
 				}),
@@ -879,7 +939,7 @@ func TestListApiSpecsSequence(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+		protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 		cmpopts.SortSlices(func(a, b *rpc.ApiSpec) bool {
 			return a.GetName() < b.GetName()
 		}),
@@ -1059,7 +1119,7 @@ func TestUpdateApiSpec(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiSpec), "revision_id", "create_time", "revision_create_time", "revision_update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, updated, opts) {
@@ -1152,6 +1212,43 @@ func TestUpdateApiSpecResponseCodes(t *testing.T) {
 	}
 }
 
+func TestUpdateApiSpecAllowMissingConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedVersions(ctx, t, server, &rpc.ApiVersion{Name: "projects/my-project/apis/my-api/versions/v1"})
+
+	// Each request either creates the spec or updates it, so none should fail.
+	const count = 8
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			_, err := server.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
+				ApiSpec: &rpc.ApiSpec{
+					Name:     "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+					Contents: []byte(fmt.Sprintf("contents %d", i)),
+				},
+				AllowMissing: true,
+			})
+			errs <- err
+		}(i)
+	}
+
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("UpdateApiSpec() returned error: %s", err)
+		}
+	}
+
+	revisions, err := server.ListApiSpecRevisions(ctx, &rpc.ListApiSpecRevisionsRequest{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+	})
+	if err != nil {
+		t.Fatalf("ListApiSpecRevisions() returned error: %s", err)
+	} else if got := len(revisions.GetApiSpecs()); got != count {
+		t.Errorf("ListApiSpecRevisions() returned %d revisions, want %d", got, count)
+	}
+}
+
 func TestDeleteApiSpec(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -1184,8 +1281,11 @@ func TestDeleteApiSpec(t *testing.T) {
 					Name: test.req.GetName(),
 				}
 
-				if _, err := server.GetApiSpec(ctx, req); status.Code(err) != codes.NotFound {
-					t.Fatalf("GetApiSpec(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), codes.NotFound, err)
+				// Deleted resources are returned until they are purged.
+				if got, err := server.GetApiSpec(ctx, req); err != nil {
+					t.Fatalf("GetApiSpec(%+v) returned error: %s", req, err)
+				} else if got.GetDeleteTime() == nil || got.GetPurgeTime() == nil {
+					t.Errorf("GetApiSpec(%+v) returned delete_time %v and purge_time %v, want both set", req, got.GetDeleteTime(), got.GetPurgeTime())
 				}
 			})
 		})
@@ -1225,3 +1325,57 @@ func TestDeleteApiSpecResponseCodes(t *testing.T) {
 		})
 	}
 }
+
+func TestApiSpecContentsInBlobStore(t *testing.T) {
+	ctx := context.Background()
+	dbConfig := fmt.Sprintf("%s/registry.db", t.TempDir())
+	blobDir := t.TempDir()
+
+	// Contents saved without a blob store are kept in the database.
+	server := New(Config{Database: "sqlite3", DBConfig: dbConfig})
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name:     "projects/my-project/apis/my-api/versions/v1/specs/inline",
+		Contents: specContents,
+	})
+	server.Close()
+
+	server = New(Config{
+		Database: "sqlite3",
+		DBConfig: dbConfig,
+		Blobs:    blobs.Config{Type: blobs.Filesystem, Path: blobDir},
+	})
+	t.Cleanup(server.Close)
+	stored := []byte(`{"openapi": "3.0.0", "info": {"title": "Stored API", "version": "v1"}, "paths": {}}`)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name:     "projects/my-project/apis/my-api/versions/v1/specs/stored",
+		Contents: stored,
+	})
+
+	for name, want := range map[string][]byte{"inline": specContents, "stored": stored} {
+		req := &rpc.GetApiSpecContentsRequest{
+			Name: "projects/my-project/apis/my-api/versions/v1/specs/" + name + "/contents",
+		}
+		got, err := server.GetApiSpecContents(ctx, req)
+		if err != nil {
+			t.Fatalf("GetApiSpecContents(%+v) returned error: %s", req, err)
+		}
+		if !bytes.Equal(got.GetData(), want) {
+			t.Errorf("GetApiSpecContents(%+v) returned %q, want %q", req, got.GetData(), want)
+		}
+	}
+
+	hash := sha256hash(stored)
+	if _, err := os.Stat(filepath.Join(blobDir, hash[:2], hash)); err != nil {
+		t.Errorf("Contents were not saved in the blob store: %s", err)
+	}
+
+	// Contents in the blob store can't be read without it.
+	server = New(Config{Database: "sqlite3", DBConfig: dbConfig})
+	t.Cleanup(server.Close)
+	req := &rpc.GetApiSpecContentsRequest{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/stored/contents",
+	}
+	if _, err := server.GetApiSpecContents(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetApiSpecContents(%+v) without blob store returned status code %q, want %q: %v", req, status.Code(err), codes.FailedPrecondition, err)
+	}
+}
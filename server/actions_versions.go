@@ -32,8 +32,7 @@ func (s *RegistryServer) CreateApiVersion(ctx context.Context, req *rpc.CreateAp
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetApiVersion() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api_version %+v: body must be provided", req.GetApiVersion())
@@ -44,13 +43,21 @@ func (s *RegistryServer) CreateApiVersion(ctx context.Context, req *rpc.CreateAp
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	replayed := new(rpc.ApiVersion)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
+		return nil, err
+	} else if ok {
+		return replayed, nil
+	}
+
 	// Creation should only succeed when the parent exists.
 	if _, err := db.GetApi(ctx, parent); err != nil {
 		return nil, err
 	}
 
 	name := parent.Version(req.GetApiVersionId())
-	if _, err := db.GetVersion(ctx, name); err == nil {
+	// Names of deleted versions can't be reused until the versions are purged.
+	if _, err := db.GetVersionIncludingDeleted(ctx, name); err == nil {
 		return nil, status.Errorf(codes.AlreadyExists, "API version %q already exists", name)
 	} else if !isNotFound(err) {
 		return nil, err
@@ -65,16 +72,19 @@ func (s *RegistryServer) CreateApiVersion(ctx context.Context, req *rpc.CreateAp
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err := db.SaveVersion(ctx, version); err != nil {
+	var message *rpc.ApiVersion
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		if err := db.SaveVersion(ctx, version); err != nil {
+			return err
+		}
+		if message, err = version.Message(); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return s.recordRequest(ctx, db, req, message)
+	}); err != nil {
 		return nil, err
 	}
 
-	message, err := version.Message()
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	s.notify(ctx, rpc.Notification_CREATED, name.String())
 	return message, nil
 }
 
@@ -84,8 +94,7 @@ func (s *RegistryServer) DeleteApiVersion(ctx context.Context, req *rpc.DeleteAp
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseVersion(req.GetName())
 	if err != nil {
@@ -97,29 +106,64 @@ func (s *RegistryServer) DeleteApiVersion(ctx context.Context, req *rpc.DeleteAp
 		return nil, err
 	}
 
-	if err := db.DeleteVersion(ctx, name); err != nil {
+	if err := s.commit(ctx, db, rpc.Notification_DELETED, name.String(), func(db dao.DAO) error {
+		if err := checkVersionEtag(ctx, db, name, req.GetEtag()); err != nil {
+			return err
+		}
+		deleteTime, purgeTime := s.deletionTimes()
+		return db.SoftDeleteVersion(ctx, name, deleteTime, purgeTime)
+	}); err != nil {
 		return nil, err
 	}
 
-	s.notify(ctx, rpc.Notification_DELETED, name.String())
 	return &empty.Empty{}, nil
 }
 
+// UndeleteApiVersion handles the corresponding API request.
+func (s *RegistryServer) UndeleteApiVersion(ctx context.Context, req *rpc.UndeleteApiVersionRequest) (*rpc.ApiVersion, error) {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	name, err := names.ParseVersion(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var message *rpc.ApiVersion
+	if err := s.commit(ctx, db, rpc.Notification_CREATED, name.String(), func(db dao.DAO) error {
+		version, err := db.UndeleteVersion(ctx, name)
+		if err != nil {
+			return err
+		}
+		if message, err = version.Message(); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
 // GetApiVersion handles the corresponding API request.
 func (s *RegistryServer) GetApiVersion(ctx context.Context, req *rpc.GetApiVersionRequest) (*rpc.ApiVersion, error) {
 	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	name, err := names.ParseVersion(req.GetName())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	version, err := db.GetVersion(ctx, name)
+	// Deleted versions are returned until they are purged.
+	version, err := db.GetVersionIncludingDeleted(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -138,8 +182,7 @@ func (s *RegistryServer) ListApiVersions(ctx context.Context, req *rpc.ListApiVe
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetPageSize() < 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d: must not be negative", req.GetPageSize())
@@ -155,9 +198,11 @@ func (s *RegistryServer) ListApiVersions(ctx context.Context, req *rpc.ListApiVe
 	}
 
 	listing, err := db.ListVersions(ctx, parent, dao.PageOptions{
-		Size:   req.GetPageSize(),
-		Filter: req.GetFilter(),
-		Token:  req.GetPageToken(),
+		Size:        req.GetPageSize(),
+		Filter:      req.GetFilter(),
+		Token:       req.GetPageToken(),
+		OrderBy:     req.GetOrderBy(),
+		ShowDeleted: req.GetShowDeleted(),
 	})
 	if err != nil {
 		return nil, err
@@ -184,8 +229,7 @@ func (s *RegistryServer) UpdateApiVersion(ctx context.Context, req *rpc.UpdateAp
 	if err != nil {
 		return nil, status.Error(codes.Unavailable, err.Error())
 	}
-	defer s.releaseStorageClient(client)
-	db := dao.NewDAO(client)
+	db := dao.NewDAO(client, s.blobs)
 
 	if req.GetApiVersion() == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid api_version %+v: body must be provided", req.GetApiVersion())
@@ -198,24 +242,69 @@ func (s *RegistryServer) UpdateApiVersion(ctx context.Context, req *rpc.UpdateAp
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	version, err := db.GetVersion(ctx, name)
-	if err != nil {
+	replayed := new(rpc.ApiVersion)
+	if ok, err := s.replayRequest(ctx, db, req, replayed); err != nil {
 		return nil, err
+	} else if ok {
+		return replayed, nil
 	}
 
-	if err := version.Update(req.GetApiVersion(), models.ExpandMask(req.GetApiVersion(), req.GetUpdateMask())); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	// The version is read and saved in one transaction, so concurrent updates can't overwrite each other.
+	var message *rpc.ApiVersion
+	if err := s.commitChange(ctx, db, func(db dao.DAO) (*models.Change, error) {
+		version, created, err := db.UpsertVersion(ctx, name, req.GetAllowMissing(), func(version *models.Version) (*models.Version, error) {
+			if version == nil {
+				return newVersion(ctx, db, name, req.GetApiVersion())
+			}
+
+			current, err := version.Message()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			} else if err := checkEtag(name.String(), req.GetApiVersion().GetEtag(), current.GetEtag()); err != nil {
+				return nil, err
+			}
+
+			if err := version.Update(req.GetApiVersion(), models.ExpandMask(req.GetApiVersion(), req.GetUpdateMask())); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return version, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if message, err = version.Message(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		} else if err := s.recordRequest(ctx, db, req, message); err != nil {
+			return nil, err
+		}
+
+		if created {
+			return models.NewChange(rpc.Notification_CREATED, name.String()), nil
+		}
+		return models.NewChange(rpc.Notification_UPDATED, name.String()), nil
+	}); err != nil {
+		return nil, err
 	}
 
-	if err := db.SaveVersion(ctx, version); err != nil {
+	return message, nil
+}
+
+// newVersion returns a new version with the specified name after checking that it can be created.
+func newVersion(ctx context.Context, db dao.DAO, name names.Version, body *rpc.ApiVersion) (*models.Version, error) {
+	if err := name.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Creation should only succeed when the parent exists.
+	if _, err := db.GetApi(ctx, name.Api()); err != nil {
 		return nil, err
 	}
 
-	message, err := version.Message()
+	version, err := models.NewVersion(name, body)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	s.notify(ctx, rpc.Notification_UPDATED, name.String())
-	return message, nil
+	return version, nil
 }
@@ -113,7 +113,7 @@ func TestCreateApiVersion(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, created, opts) {
@@ -351,7 +351,7 @@ func TestGetApiVersion(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, got, opts) {
@@ -553,7 +553,7 @@ func TestListApiVersions(t *testing.T) {
 			opts := cmp.Options{
 				protocmp.Transform(),
 				protocmp.IgnoreFields(new(rpc.ListApiVersionsResponse), "next_page_token"),
-				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time", "etag"),
 				protocmp.SortRepeated(func(a, b *rpc.ApiVersion) bool {
 					return a.GetName() < b.GetName()
 				}),
@@ -725,7 +725,7 @@ func TestListApiVersionsSequence(t *testing.T) {
 
 	opts := cmp.Options{
 		protocmp.Transform(),
-		protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time"),
+		protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time", "etag"),
 		cmpopts.SortSlices(func(a, b *rpc.ApiVersion) bool {
 			return a.GetName() < b.GetName()
 		}),
@@ -869,7 +869,7 @@ func TestUpdateApiVersion(t *testing.T) {
 
 			opts := cmp.Options{
 				protocmp.Transform(),
-				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time"),
+				protocmp.IgnoreFields(new(rpc.ApiVersion), "create_time", "update_time", "etag"),
 			}
 
 			if !cmp.Equal(test.want, updated, opts) {
@@ -952,6 +952,71 @@ func TestUpdateApiVersionResponseCodes(t *testing.T) {
 	}
 }
 
+func TestUpdateApiVersionAllowMissing(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/my-api"})
+
+	req := &rpc.UpdateApiVersionRequest{
+		ApiVersion: &rpc.ApiVersion{
+			Name:  "projects/my-project/apis/my-api/versions/v1",
+			State: "production",
+		},
+		AllowMissing: true,
+	}
+	if _, err := server.UpdateApiVersion(ctx, req); err != nil {
+		t.Fatalf("UpdateApiVersion(%+v) returned error: %s", req, err)
+	}
+
+	got, err := server.GetApiVersion(ctx, &rpc.GetApiVersionRequest{Name: req.ApiVersion.GetName()})
+	if err != nil {
+		t.Fatalf("GetApiVersion(%q) returned error: %s", req.ApiVersion.GetName(), err)
+	} else if got.GetState() != "production" {
+		t.Errorf("GetApiVersion(%q) returned state %q, want %q", req.ApiVersion.GetName(), got.GetState(), "production")
+	}
+
+	// Updates of missing versions should still require the parent to exist.
+	req.ApiVersion.Name = "projects/my-project/apis/other-api/versions/v1"
+	if _, err := server.UpdateApiVersion(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("UpdateApiVersion(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestUpdateApiVersionAllowMissingConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/my-api"})
+
+	// Each request either creates the version or updates it, so none should fail.
+	const count = 8
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			_, err := server.UpdateApiVersion(ctx, &rpc.UpdateApiVersionRequest{
+				ApiVersion: &rpc.ApiVersion{
+					Name:        "projects/my-project/apis/my-api/versions/v1",
+					DisplayName: fmt.Sprintf("version %d", i),
+				},
+				AllowMissing: true,
+			})
+			errs <- err
+		}(i)
+	}
+
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("UpdateApiVersion() returned error: %s", err)
+		}
+	}
+
+	versions, err := server.ListApiVersions(ctx, &rpc.ListApiVersionsRequest{Parent: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("ListApiVersions() returned error: %s", err)
+	} else if got := len(versions.GetApiVersions()); got != 1 {
+		t.Errorf("ListApiVersions() returned %d versions, want 1", got)
+	}
+}
+
 func TestDeleteApiVersion(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -984,8 +1049,11 @@ func TestDeleteApiVersion(t *testing.T) {
 					Name: test.req.GetName(),
 				}
 
-				if _, err := server.GetApiVersion(ctx, req); status.Code(err) != codes.NotFound {
-					t.Fatalf("GetApiVersion(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), codes.NotFound, err)
+				// Deleted resources are returned until they are purged.
+				if got, err := server.GetApiVersion(ctx, req); err != nil {
+					t.Fatalf("GetApiVersion(%+v) returned error: %s", req, err)
+				} else if got.GetDeleteTime() == nil || got.GetPurgeTime() == nil {
+					t.Errorf("GetApiVersion(%+v) returned delete_time %v and purge_time %v, want both set", req, got.GetDeleteTime(), got.GetPurgeTime())
 				}
 			})
 		})
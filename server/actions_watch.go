@@ -0,0 +1,51 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchResources handles the corresponding API request.
+func (s *RegistryServer) WatchResources(req *rpc.WatchResourcesRequest, stream rpc.Registry_WatchResourcesServer) error {
+	pattern, err := parseWatchPattern(req.GetParent())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := s.watchers.Subscribe(watcherBufferSize, func(n *rpc.Notification) bool {
+		return pattern.Matches(n.GetResource())
+	})
+	defer s.watchers.Unsubscribe(sub)
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case n, ok := <-sub.C:
+			if !ok && sub.Overflowed() {
+				return status.Errorf(codes.ResourceExhausted, "watcher for %q fell too far behind and was disconnected", req.GetParent())
+			} else if !ok {
+				return nil
+			}
+			if err := stream.Send(n); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchStream is an in-process implementation of rpc.Registry_WatchResourcesServer.
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *rpc.Notification
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(n *rpc.Notification) error {
+	s.sent <- n
+	return nil
+}
+
+func TestWatchPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		resource string
+		want     bool
+	}{
+		{"projects/p", "projects/p", true},
+		{"projects/p", "projects/p/apis/a", true},
+		{"projects/p", "projects/q", false},
+		{"projects/-", "projects/q/apis/a", true},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/p/apis/a/versions/v/specs/s", true},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/p/apis/a/versions/v/specs/s@12345678", true},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/p/apis/a/versions/v/specs/s/artifacts/x", true},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/p/apis/a/versions/v", false},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/p/apis/a/versions/v/artifacts/x", false},
+		{"projects/p/apis/-/versions/-/specs/-", "projects/q/apis/a/versions/v/specs/s", false},
+		{"projects/p/apis/A", "projects/p/apis/a", true},
+		{"projects/p/apis/-/deployments/-", "projects/p/apis/a/deployments/d", true},
+		{"projects/p/apis/-/deployments/-", "projects/p/apis/a/deployments/d@12345678", true},
+		{"projects/p/apis/-/deployments/d", "projects/p/apis/a/deployments/e", false},
+		{"projects/p/apis/-/deployments/-", "projects/p/apis/a/versions/v", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.resource, func(t *testing.T) {
+			p, err := parseWatchPattern(test.pattern)
+			if err != nil {
+				t.Fatalf("parseWatchPattern(%q) returned error: %s", test.pattern, err)
+			}
+
+			if got := p.Matches(test.resource); got != test.want {
+				t.Errorf("Matches(%q) returned %t, want %t", test.resource, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWatchResourcesInvalidPattern(t *testing.T) {
+	tests := []string{
+		"",
+		"projects",
+		"apis/a",
+		"projects/p/versions/v",
+		"projects/p/apis/a/specs/s",
+		"projects/p/apis/",
+		"projects/p/apis/a/deployments/d/artifacts/x",
+	}
+
+	for _, pattern := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			server := defaultTestServer(t)
+			stream := &watchStream{ctx: context.Background(), sent: make(chan *rpc.Notification, 1)}
+			req := &rpc.WatchResourcesRequest{Parent: pattern}
+			if err := server.WatchResources(req, stream); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("WatchResources(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+			}
+		})
+	}
+}
+
+func TestWatchResources(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream := &watchStream{ctx: watchCtx, sent: make(chan *rpc.Notification, 10)}
+	req := &rpc.WatchResourcesRequest{Parent: "projects/my-project/apis/-"}
+
+	done := make(chan error)
+	go func() {
+		done <- server.WatchResources(req, stream)
+	}()
+
+	// Wait for the watcher to be registered before making changes.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if server.watchers.Subscribers() > 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("WatchResources(%+v) did not register a watcher", req)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Changes to the project itself are not selected by the pattern.
+	if _, err := server.UpdateProject(ctx, &rpc.UpdateProjectRequest{
+		Project: &rpc.Project{Name: "projects/my-project", DisplayName: "Updated"},
+	}); err != nil {
+		t.Fatalf("Setup: UpdateProject() returned error: %s", err)
+	}
+
+	if _, err := server.CreateApi(ctx, &rpc.CreateApiRequest{
+		Parent: "projects/my-project",
+		ApiId:  "my-api",
+		Api:    &rpc.Api{},
+	}); err != nil {
+		t.Fatalf("Setup: CreateApi() returned error: %s", err)
+	}
+
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{
+		Name: "projects/my-project/apis/my-api",
+	}); err != nil {
+		t.Fatalf("Setup: DeleteApi() returned error: %s", err)
+	}
+
+	want := []struct {
+		change   rpc.Notification_Change
+		resource string
+	}{
+		{rpc.Notification_CREATED, "projects/my-project/apis/my-api"},
+		{rpc.Notification_DELETED, "projects/my-project/apis/my-api"},
+	}
+
+	for _, w := range want {
+		select {
+		case got := <-stream.sent:
+			if got.GetChange() != w.change || got.GetResource() != w.resource {
+				t.Errorf("WatchResources(%+v) sent %s %q, want %s %q", req, got.GetChange(), got.GetResource(), w.change, w.resource)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("WatchResources(%+v) timed out waiting for %s %q", req, w.change, w.resource)
+		}
+	}
+
+	cancel()
+	if err := <-done; status.Code(err) != codes.Canceled {
+		t.Errorf("WatchResources(%+v) returned status code %q after cancellation, want %q", req, status.Code(err), codes.Canceled)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive reads and writes project archives. Archives are gzipped tar
+// files that begin with a manifest, which is followed by the storage entities
+// of a project and the contents of its blobs. Entities are JSON-encoded at
+// paths that are formed from their kind and storage key, and contents are
+// stored once for each hash, before the first blob that refers to them.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	// Format identifies project archives in their manifests.
+	Format = "registry-project-archive"
+	// Version is the version of the archive format that is written by this package.
+	Version = 1
+	// ContentsKind is the kind of the entries that hold blob contents.
+	ContentsKind = "contents"
+
+	manifestPath = "manifest.json"
+	entitySuffix = ".json"
+)
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Project    string    `json:"project"`     // The name of the archived project.
+	CreateTime time.Time `json:"create_time"` // The time that the archive was created.
+}
+
+// Entry is an entity or the contents of a blob that was read from an archive.
+type Entry struct {
+	Kind string // The kind of the entity, or ContentsKind for contents.
+	Key  string // The storage key of the entity, or the hash of the contents.
+	Data []byte // The encoded entity, or the contents.
+}
+
+// Decode decodes the entity of an entry.
+func (e *Entry) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("invalid %s %q: %s", e.Kind, e.Key, err)
+	}
+	return nil
+}
+
+// Writer writes an archive.
+type Writer struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+// NewWriter returns a writer that writes an archive with a manifest to w.
+// Format and Version are set in the manifest when they are empty.
+func NewWriter(w io.Writer, m Manifest) (*Writer, error) {
+	if m.Format == "" {
+		m.Format = Format
+	}
+	if m.Version == 0 {
+		m.Version = Version
+	}
+
+	gz := gzip.NewWriter(w)
+	aw := &Writer{gz: gz, tw: tar.NewWriter(gz), modTime: m.CreateTime}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.write(manifestPath, data); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// WriteEntity writes an entity with its kind and storage key.
+func (w *Writer) WriteEntity(kind, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %s", kind, key, err)
+	}
+	return w.write(kind+"/"+key+entitySuffix, data)
+}
+
+// WriteContents writes the contents of blobs with a hash.
+func (w *Writer) WriteContents(hash string, contents []byte) error {
+	return w.write(ContentsKind+"/"+hash, contents)
+}
+
+func (w *Writer) write(path string, data []byte) error {
+	header := &tar.Header{
+		Name:    path,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: w.modTime,
+	}
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(data)
+	return err
+}
+
+// Close finishes the archive. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// Reader reads an archive.
+type Reader struct {
+	tr       *tar.Reader
+	manifest Manifest
+}
+
+// NewReader returns a reader for the archive in r. It reads and checks the manifest.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err)
+	}
+
+	ar := &Reader{tr: tar.NewReader(gz)}
+	header, err := ar.tr.Next()
+	if err == io.EOF || (err == nil && header.Name != manifestPath) {
+		return nil, fmt.Errorf("invalid archive: %s must be its first file", manifestPath)
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err)
+	}
+
+	data, err := ioutil.ReadAll(ar.tr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err)
+	} else if err := json.Unmarshal(data, &ar.manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+
+	if ar.manifest.Format != Format {
+		return nil, fmt.Errorf("invalid manifest: unknown format %q", ar.manifest.Format)
+	} else if ar.manifest.Version < 1 || ar.manifest.Version > Version {
+		return nil, fmt.Errorf("invalid manifest: unsupported version %d", ar.manifest.Version)
+	}
+
+	return ar, nil
+}
+
+// Manifest returns the manifest of the archive.
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Next returns the next entry in the archive. It returns io.EOF when there are no more entries.
+func (r *Reader) Next() (*Entry, error) {
+	header, err := r.tr.Next()
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err)
+	}
+
+	i := strings.Index(header.Name, "/")
+	if header.Typeflag != tar.TypeReg || i < 1 {
+		return nil, fmt.Errorf("invalid archive: unexpected file %q", header.Name)
+	}
+
+	entry := &Entry{Kind: header.Name[:i], Key: header.Name[i+1:]}
+	if entry.Kind != ContentsKind {
+		if !strings.HasSuffix(entry.Key, entitySuffix) {
+			return nil, fmt.Errorf("invalid archive: unexpected file %q", header.Name)
+		}
+		entry.Key = strings.TrimSuffix(entry.Key, entitySuffix)
+	}
+
+	if entry.Data, err = ioutil.ReadAll(r.tr); err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err)
+	}
+	return entry, nil
+}
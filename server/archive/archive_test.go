@@ -0,0 +1,137 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type entity struct {
+	Key  string
+	Name string
+}
+
+func TestRoundTrip(t *testing.T) {
+	manifest := Manifest{
+		Project:    "projects/my-project",
+		CreateTime: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, manifest)
+	if err != nil {
+		t.Fatalf("NewWriter() returned error: %s", err)
+	}
+	if err := w.WriteEntity("Api", "projects/my-project/apis/a", &entity{Key: "projects/my-project/apis/a", Name: "a"}); err != nil {
+		t.Fatalf("WriteEntity() returned error: %s", err)
+	}
+	if err := w.WriteContents("abc", []byte("contents")); err != nil {
+		t.Fatalf("WriteContents() returned error: %s", err)
+	}
+	if err := w.WriteEntity("Spec", "projects/my-project/apis/a/versions/v/specs/s.json@1234", &entity{Name: "s.json"}); err != nil {
+		t.Fatalf("WriteEntity() returned error: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() returned error: %s", err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader() returned error: %s", err)
+	}
+
+	manifest.Format, manifest.Version = Format, Version
+	if diff := cmp.Diff(manifest, r.Manifest()); diff != "" {
+		t.Errorf("Manifest() returned unexpected diff: (-want +got):\n%s", diff)
+	}
+
+	want := []Entry{
+		{Kind: "Api", Key: "projects/my-project/apis/a", Data: []byte(`{"Key":"projects/my-project/apis/a","Name":"a"}`)},
+		{Kind: ContentsKind, Key: "abc", Data: []byte("contents")},
+		{Kind: "Spec", Key: "projects/my-project/apis/a/versions/v/specs/s.json@1234", Data: []byte(`{"Key":"","Name":"s.json"}`)},
+	}
+	got := make([]Entry, 0)
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() returned error: %s", err)
+		}
+		got = append(got, *entry)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Next() returned unexpected diff: (-want +got):\n%s", diff)
+	}
+
+	decoded := new(entity)
+	if err := got[0].Decode(decoded); err != nil {
+		t.Fatalf("Decode() returned error: %s", err)
+	} else if decoded.Name != "a" {
+		t.Errorf("Decode() returned %+v, want name %q", decoded, "a")
+	}
+}
+
+func TestInvalidArchives(t *testing.T) {
+	archive := func(files map[string]string, order ...string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for _, name := range order {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+			tw.Write([]byte(files[name]))
+		}
+		tw.Close()
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		desc string
+		data []byte
+	}{
+		{
+			desc: "not gzipped",
+			data: []byte("manifest"),
+		},
+		{
+			desc: "missing manifest",
+			data: archive(map[string]string{"Api/projects/p/apis/a.json": "{}"}, "Api/projects/p/apis/a.json"),
+		},
+		{
+			desc: "unknown format",
+			data: archive(map[string]string{manifestPath: `{"format":"zip","version":1}`}, manifestPath),
+		},
+		{
+			desc: "newer version",
+			data: archive(map[string]string{manifestPath: `{"format":"registry-project-archive","version":2}`}, manifestPath),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(test.data)); err == nil {
+				t.Errorf("NewReader() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobs
+
+// This is the protocol used by the Registry server to store the contents of specs and artifacts.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Store keeps blob contents outside of the registry database.
+// Contents are addressed by their SHA-256 hash, so a stored value never changes.
+type Store interface {
+	// Put stores contents with the specified hash. Storing contents that already exist succeeds.
+	Put(ctx context.Context, hash string, contents []byte) error
+	// Get returns the contents with the specified hash or ErrNotFound if they don't exist.
+	Get(ctx context.Context, hash string) ([]byte, error)
+	// Delete removes the contents with the specified hash. Deleting missing contents succeeds.
+	Delete(ctx context.Context, hash string) error
+}
+
+// ErrNotFound is returned when a store does not contain the requested contents.
+var ErrNotFound = errors.New("blob not found")
+
+const (
+	// Database keeps contents in the registry database with the rest of each blob.
+	Database = "database"
+	// Filesystem keeps contents in files below a local directory.
+	Filesystem = "filesystem"
+	// S3 keeps contents in a bucket of an S3-compatible object store.
+	S3 = "s3"
+)
+
+// Config configures a blob store.
+type Config struct {
+	// Type selects the store implementation: database, filesystem, or s3.
+	// If unspecified, the database is used.
+	Type string `yaml:"type"`
+	// Path is the directory that contains files of the filesystem store.
+	Path string `yaml:"path"`
+	// Endpoint is the URL of the S3-compatible service, e.g. https://s3.us-east-1.amazonaws.com.
+	Endpoint string `yaml:"endpoint"`
+	// Region is the region used to sign S3 requests. If unspecified, us-east-1 is used.
+	Region string `yaml:"region"`
+	// Bucket is the S3 bucket that contains blobs.
+	Bucket string `yaml:"bucket"`
+	// Prefix is prepended to the keys of S3 objects.
+	Prefix string `yaml:"prefix"`
+	// AccessKey and SecretKey are the credentials used to sign S3 requests.
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+// Validate returns an error if the configuration can't be used to create a store.
+func (c Config) Validate() error {
+	switch c.Type {
+	case "", Database:
+	case Filesystem:
+		if c.Path == "" {
+			return fmt.Errorf("invalid path %q: filesystem blobs require a directory", c.Path)
+		}
+	case S3:
+		if c.Endpoint == "" {
+			return fmt.Errorf("invalid endpoint %q: s3 blobs require an endpoint URL", c.Endpoint)
+		} else if c.Bucket == "" {
+			return fmt.Errorf("invalid bucket %q: s3 blobs require a bucket", c.Bucket)
+		} else if c.AccessKey == "" || c.SecretKey == "" {
+			return fmt.Errorf("invalid credentials: s3 blobs require an access_key and secret_key")
+		}
+	default:
+		return fmt.Errorf("invalid type %q: must be one of [%s, %s, %s]", c.Type, Database, Filesystem, S3)
+	}
+	return nil
+}
+
+// New creates a blob store from a configuration.
+// It returns a nil store for the database type, since contents are then kept with the rest of each blob.
+func New(c Config) (Store, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case "", Database:
+		return nil, nil
+	case Filesystem:
+		return NewFilesystem(c.Path)
+	case S3:
+		return NewS3(c.Endpoint, c.Region, c.Bucket, c.Prefix, c.AccessKey, c.SecretKey)
+	default:
+		return nil, fmt.Errorf("unsupported blob store type %q", c.Type)
+	}
+}
+
+var hashPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// checkHash returns an error if a hash is not a hex-encoded SHA-256 hash.
+// Hashes are used in file paths and object keys, so other values are rejected.
+func checkHash(hash string) error {
+	if !hashPattern.MatchString(hash) {
+		return fmt.Errorf("invalid blob hash %q", hash)
+	}
+	return nil
+}
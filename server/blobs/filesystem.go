@@ -0,0 +1,99 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemStore keeps blob contents in files below a local directory.
+// Files are grouped in subdirectories named by the first two characters of their hashes.
+type FilesystemStore struct {
+	root string
+}
+
+// NewFilesystem creates a store that keeps contents below the root directory, creating it if needed.
+func NewFilesystem(root string) (*FilesystemStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %s", err)
+	}
+	return &FilesystemStore{root: root}, nil
+}
+
+func (s *FilesystemStore) path(hash string) string {
+	return filepath.Join(s.root, hash[:2], hash)
+}
+
+// Put stores contents with the specified hash.
+// Contents are written to a temporary file that is renamed, so readers never see partial files.
+func (s *FilesystemStore) Put(ctx context.Context, hash string, contents []byte) error {
+	if err := checkHash(hash); err != nil {
+		return err
+	}
+
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, hash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Get returns the contents with the specified hash.
+func (s *FilesystemStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	if err := checkHash(hash); err != nil {
+		return nil, err
+	}
+
+	contents, err := ioutil.ReadFile(s.path(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return contents, err
+}
+
+// Delete removes the contents with the specified hash.
+func (s *FilesystemStore) Delete(ctx context.Context, hash string) error {
+	if err := checkHash(hash); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.path(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	s3Timeout       = 60 * time.Second
+)
+
+// S3Store keeps blob contents as objects in a bucket of an S3-compatible service, such as MinIO.
+// Objects are addressed with path-style URLs and requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	// now returns the time used to sign requests.
+	now func() time.Time
+}
+
+// NewS3 creates a store that keeps contents in the bucket at the endpoint.
+// Object keys are the hashes of their contents, preceded by prefix.
+func NewS3(endpoint, region, bucket, prefix, accessKey, secretKey string) (*S3Store, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q: must be an http or https URL", endpoint)
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	return &S3Store{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		prefix:    prefix,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: s3Timeout},
+		now:       time.Now,
+	}, nil
+}
+
+// Put stores contents with the specified hash.
+func (s *S3Store) Put(ctx context.Context, hash string, contents []byte) error {
+	if err := checkHash(hash); err != nil {
+		return err
+	}
+
+	_, err := s.do(ctx, http.MethodPut, hash, contents)
+	return err
+}
+
+// Get returns the contents with the specified hash.
+func (s *S3Store) Get(ctx context.Context, hash string) ([]byte, error) {
+	if err := checkHash(hash); err != nil {
+		return nil, err
+	}
+
+	return s.do(ctx, http.MethodGet, hash, nil)
+}
+
+// Delete removes the contents with the specified hash.
+func (s *S3Store) Delete(ctx context.Context, hash string) error {
+	if err := checkHash(hash); err != nil {
+		return err
+	}
+
+	_, err := s.do(ctx, http.MethodDelete, hash, nil)
+	return err
+}
+
+// do sends a signed request for the object with the specified hash and returns the response body.
+func (s *S3Store) do(ctx context.Context, method, hash string, body []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + s.prefix + hash
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("s3 %s %s returned %s: %s", method, u.Path, resp.Status, bytes.TrimSpace(contents))
+	}
+	return contents, nil
+}
+
+// sign adds AWS Signature Version 4 authentication to a request.
+// All of the request's headers are signed, along with its host and the payload hash.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	signed := make([]string, 0, len(headers))
+	for name := range headers {
+		signed = append(signed, name)
+	}
+	sort.Strings(signed)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, true),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// uriEncode encodes a string as required for canonical requests,
+// where every byte other than unreserved characters is percent-encoded.
+// Slashes are kept when encoding paths.
+func uriEncode(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery returns query parameters sorted by name and encoded as required for canonical requests.
+func canonicalQuery(values url.Values) string {
+	params := make([]string, 0, len(values))
+	for name, vs := range values {
+		for _, v := range vs {
+			params = append(params, uriEncode(name, false)+"="+uriEncode(v, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -34,15 +34,15 @@ type ApiList struct {
 
 var apiFields = []filtering.Field{
 	{Name: "name", Type: filtering.String},
-	{Name: "project_id", Type: filtering.String},
-	{Name: "api_id", Type: filtering.String},
-	{Name: "display_name", Type: filtering.String},
-	{Name: "description", Type: filtering.String},
-	{Name: "create_time", Type: filtering.Timestamp},
-	{Name: "update_time", Type: filtering.Timestamp},
-	{Name: "availability", Type: filtering.String},
-	{Name: "recommended_version", Type: filtering.String},
-	{Name: "labels", Type: filtering.StringMap},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "api_id", Type: filtering.String, StorageName: "ApiID"},
+	{Name: "display_name", Type: filtering.String, StorageName: "DisplayName"},
+	{Name: "description", Type: filtering.String, StorageName: "Description"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "update_time", Type: filtering.Timestamp, StorageName: "UpdateTime"},
+	{Name: "availability", Type: filtering.String, StorageName: "Availability"},
+	{Name: "recommended_version", Type: filtering.String, StorageName: "RecommendedVersion"},
+	{Name: "labels", Type: filtering.StringMap, StorageName: "Labels"},
 }
 
 func (d *DAO) ListApis(ctx context.Context, parent names.Project, opts PageOptions) (ApiList, error) {
@@ -59,7 +59,18 @@ func (d *DAO) ListApis(ctx context.Context, parent names.Project, opts PageOptio
 		token.Filter = opts.Filter
 	}
 
-	q = q.ApplyOffset(token.Offset)
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return ApiList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, apiFields)
+	if err != nil {
+		return ApiList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
 
 	if parent.ProjectID != "-" {
 		q = q.Require("ProjectID", parent.ProjectID)
@@ -67,11 +78,15 @@ func (d *DAO) ListApis(ctx context.Context, parent names.Project, opts PageOptio
 			return ApiList{}, err
 		}
 	}
+	if !opts.ShowDeleted {
+		q = q.Require("Deleted", false)
+	}
 
 	filter, err := filtering.NewFilter(opts.Filter, apiFields)
 	if err != nil {
 		return ApiList{}, err
 	}
+	filter = applyFilter(q, filter, opts.Size)
 
 	it := d.Run(ctx, q)
 	response := ApiList{
@@ -89,14 +104,14 @@ func (d *DAO) ListApis(ctx context.Context, parent names.Project, opts PageOptio
 		if err != nil {
 			return response, err
 		} else if !match {
-			token.Offset++
+			token.LastKey, token.LastValues = api.Key, orderValues(api, orderings)
 			continue
 		} else if len(response.Apis) == int(opts.Size) {
 			break
 		}
 
 		response.Apis = append(response.Apis, *api)
-		token.Offset++
+		token.LastKey, token.LastValues = api.Key, orderValues(api, orderings)
 	}
 	if err != nil && err != iterator.Done {
 		return response, status.Error(codes.Internal, err.Error())
@@ -132,10 +147,23 @@ func apiMap(api models.Api) (map[string]interface{}, error) {
 	}, nil
 }
 
+// GetApi returns the named api. Deleted apis aren't found.
 func (d *DAO) GetApi(ctx context.Context, name names.Api) (*models.Api, error) {
+	api, err := d.GetApiIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if api.Deleted {
+		return nil, status.Errorf(codes.NotFound, "api %q was deleted", name)
+	}
+
+	return api, nil
+}
+
+// GetApiIncludingDeleted returns the named api, which may be deleted.
+func (d *DAO) GetApiIncludingDeleted(ctx context.Context, name names.Api) (*models.Api, error) {
 	api := new(models.Api)
 	k := d.NewKey(storage.ApiEntityName, name.String())
-	if err := d.Get(ctx, k, api); d.IsNotFound(err) {
+	if err := d.get(ctx, k, api); d.IsNotFound(err) {
 		return nil, status.Errorf(codes.NotFound, "api %q not found in database", name)
 	} else if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -153,6 +181,41 @@ func (d *DAO) SaveApi(ctx context.Context, api *models.Api) error {
 	return nil
 }
 
+// UpsertApi saves the api returned by update, which is called with the current
+// state of the named api. If the api doesn't exist and allowMissing is true,
+// update is called with nil to create it. The api is locked while it is read and
+// saved in a single transaction, so concurrent upserts of the same api are applied
+// one at a time. When allowMissing is true, its project is locked first, so that
+// concurrent upserts can't all find the api missing and create it.
+// UpsertApi reports whether the api was created.
+func (d *DAO) UpsertApi(ctx context.Context, name names.Api, allowMissing bool, update func(*models.Api) (*models.Api, error)) (api *models.Api, created bool, err error) {
+	err = d.Transaction(ctx, func(ctx context.Context, db DAO) error {
+		db = db.ForUpdate()
+		if allowMissing {
+			if err := db.lockParent(ctx, db.NewKey(storage.ProjectEntityName, name.Project().String()), new(models.Project)); err != nil {
+				return err
+			}
+		}
+		api, err = db.GetApi(ctx, name)
+		if allowMissing && isNotFound(err) {
+			if _, err := db.GetApiIncludingDeleted(ctx, name); err == nil {
+				return status.Errorf(codes.AlreadyExists, "api %q was deleted and must be undeleted to be updated", name)
+			}
+		}
+		if allowMissing && status.Code(err) == codes.NotFound {
+			created = true
+		} else if err != nil {
+			return err
+		}
+
+		if api, err = update(api); err != nil {
+			return err
+		}
+		return db.SaveApi(ctx, api)
+	})
+	return api, created, err
+}
+
 func (d *DAO) DeleteApi(ctx context.Context, name names.Api) error {
 	if err := d.DeleteChildrenOfApi(ctx, name); err != nil {
 		return status.Error(codes.Internal, err.Error())
@@ -0,0 +1,165 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/apigee/registry/server/archive"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// archiveKinds are the kinds of the entities that are saved in project archives
+// after their project. Parents are listed before their children, so entities
+// can be imported in the order that they were exported.
+var archiveKinds = []string{
+	storage.ApiEntityName,
+	storage.VersionEntityName,
+	storage.SpecEntityName,
+	storage.SpecRevisionTagEntityName,
+	storage.DeploymentEntityName,
+	storage.DeploymentRevisionTagEntityName,
+	storage.ArtifactEntityName,
+	storage.ArtifactRevisionEntityName,
+	storage.ArtifactRevisionTagEntityName,
+	models.BlobEntityName,
+}
+
+// newArchiveEntity returns a new entity of a kind that is saved in project archives.
+func newArchiveEntity(kind string) (interface{}, error) {
+	switch kind {
+	case storage.ProjectEntityName:
+		return new(models.Project), nil
+	case storage.ApiEntityName:
+		return new(models.Api), nil
+	case storage.VersionEntityName:
+		return new(models.Version), nil
+	case storage.SpecEntityName:
+		return new(models.Spec), nil
+	case storage.SpecRevisionTagEntityName:
+		return new(models.SpecRevisionTag), nil
+	case storage.DeploymentEntityName:
+		return new(models.Deployment), nil
+	case storage.DeploymentRevisionTagEntityName:
+		return new(models.DeploymentRevisionTag), nil
+	case storage.ArtifactEntityName:
+		return new(models.Artifact), nil
+	case storage.ArtifactRevisionEntityName:
+		return new(models.ArtifactRevision), nil
+	case storage.ArtifactRevisionTagEntityName:
+		return new(models.ArtifactRevisionTag), nil
+	case models.BlobEntityName:
+		return new(models.Blob), nil
+	default:
+		return nil, fmt.Errorf("unknown entity kind %q", kind)
+	}
+}
+
+// stringField returns the value of a string field of an entity.
+func stringField(v interface{}, field string) string {
+	return reflect.ValueOf(v).Elem().FieldByName(field).String()
+}
+
+// ExportProject writes a project and all of its entities to an archive, including
+// revisions, revision tags and deleted resources that haven't been purged. The
+// contents of blobs are written once for each hash, before the first blob that
+// refers to them. Errors from the archive writer are returned unchanged.
+func (d *DAO) ExportProject(ctx context.Context, name names.Project, w *archive.Writer) error {
+	project, err := d.GetProject(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteEntity(storage.ProjectEntityName, project.Key, project); err != nil {
+		return err
+	}
+
+	written := make(map[string]bool)
+	for _, kind := range archiveKinds {
+		v, err := newArchiveEntity(kind)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		it := d.Run(ctx, d.NewQuery(kind).Require("ProjectID", name.ProjectID))
+		for _, err = it.Next(v); err == nil; _, err = it.Next(v) {
+			if blob, ok := v.(*models.Blob); ok && blob.Hash != "" && !written[blob.Hash] {
+				if err := d.loadBlobContents(ctx, blob); err != nil {
+					return err
+				}
+				if err := w.WriteContents(blob.Hash, blob.Contents); err != nil {
+					return err
+				}
+				written[blob.Hash] = true
+				blob.Contents = nil
+			}
+
+			if err := w.WriteEntity(kind, stringField(v, "Key"), v); err != nil {
+				return err
+			}
+		}
+		if err != iterator.Done {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// ImportEntity saves an entity or blob contents that were read from an archive of
+// the named project. Entities are saved as they were exported, with their original
+// keys, revision ids and timestamps. Blobs must be imported after their contents.
+func (d *DAO) ImportEntity(ctx context.Context, name names.Project, e *archive.Entry) error {
+	if e.Kind == archive.ContentsKind {
+		if hash := fmt.Sprintf("%x", sha256.Sum256(e.Data)); hash != e.Key {
+			return status.Errorf(codes.InvalidArgument, "contents %q don't match their hash %q", e.Key, hash)
+		}
+		return d.saveContents(ctx, &models.Blob{Hash: e.Key, SizeInBytes: int32(len(e.Data)), Contents: e.Data})
+	}
+
+	v, err := newArchiveEntity(e.Kind)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	} else if err := e.Decode(v); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Entities must belong to the project, which is the only entity that isn't a child of it.
+	owned := strings.HasPrefix(e.Key, name.String()+"/")
+	if e.Kind == storage.ProjectEntityName {
+		owned = e.Key == name.String()
+	}
+	if !owned || stringField(v, "ProjectID") != name.ProjectID {
+		return status.Errorf(codes.InvalidArgument, "%s %q doesn't belong to %q", e.Kind, e.Key, name)
+	}
+
+	// Contents are saved separately and aren't kept with blobs.
+	if blob, ok := v.(*models.Blob); ok {
+		blob.Contents = nil
+	}
+
+	if _, err := d.Put(ctx, d.NewKey(e.Kind, e.Key), v); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
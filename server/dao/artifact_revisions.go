@@ -0,0 +1,211 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ArtifactRevisionList contains a page of artifact revisions.
+type ArtifactRevisionList struct {
+	Revisions []models.ArtifactRevision
+	Token     string
+}
+
+func (d *DAO) ListArtifactRevisions(ctx context.Context, parent names.Artifact, opts PageOptions) (ArtifactRevisionList, error) {
+	q := artifactQuery(d.NewQuery(storage.ArtifactRevisionEntityName), parent)
+
+	token, err := decodeToken(opts.Token)
+	if err != nil {
+		return ArtifactRevisionList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
+	}
+
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return ArtifactRevisionList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, artifactFields)
+	if err != nil {
+		return ArtifactRevisionList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	if len(orderings) == 0 {
+		// Revisions are listed from newest to oldest by default.
+		orderings = []ordering{{StorageName: "RevisionCreateTime", Descending: true}}
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
+
+	it := d.Run(ctx, q)
+	response := ArtifactRevisionList{
+		Revisions: make([]models.ArtifactRevision, 0, opts.Size),
+	}
+
+	revision := new(models.ArtifactRevision)
+	for _, err = it.Next(revision); err == nil; _, err = it.Next(revision) {
+		token.LastKey, token.LastValues = revision.Key, orderValues(revision, orderings)
+
+		response.Revisions = append(response.Revisions, *revision)
+		if len(response.Revisions) == int(opts.Size) {
+			break
+		}
+	}
+	if err != nil && err != iterator.Done {
+		return response, status.Error(codes.Internal, err.Error())
+	}
+
+	if err == nil {
+		response.Token, err = encodeToken(token)
+		if err != nil {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+func (d *DAO) SaveArtifactRevision(ctx context.Context, revision *models.ArtifactRevision) error {
+	k := d.NewKey(storage.ArtifactRevisionEntityName, revision.RevisionName())
+	if _, err := d.Put(ctx, k, revision); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) SaveArtifactRevisionContents(ctx context.Context, revision *models.ArtifactRevision, contents []byte) error {
+	blob := models.NewBlobForArtifactRevision(revision, contents)
+	k := d.NewKey(models.BlobEntityName, revision.RevisionName())
+	return d.saveBlob(ctx, k, blob)
+}
+
+func (d *DAO) GetArtifactRevision(ctx context.Context, name names.ArtifactRevision) (*models.ArtifactRevision, error) {
+	name, err := d.unwrapArtifactRevisionTag(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := name.Artifact()
+	if err := d.checkParentNotDeleted(ctx, parent.ProjectID(), parent.ApiID(), parent.VersionID(), parent.SpecID()); err != nil {
+		return nil, err
+	}
+
+	revision := new(models.ArtifactRevision)
+	k := d.NewKey(storage.ArtifactRevisionEntityName, name.String())
+	if err := d.Get(ctx, k, revision); d.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "artifact revision %q not found", name)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return revision, nil
+}
+
+func (d *DAO) GetArtifactRevisionContents(ctx context.Context, name names.ArtifactRevision) (*models.Blob, error) {
+	name, err := d.unwrapArtifactRevisionTag(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := new(models.Blob)
+	k := d.NewKey(models.BlobEntityName, name.String())
+	if err := d.Get(ctx, k, blob); d.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "artifact revision contents %q not found", name)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := d.loadBlobContents(ctx, blob); err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// DeleteArtifactRevision deletes a revision of an artifact.
+// Callers are responsible for updating the artifact if its current revision is deleted.
+func (d *DAO) DeleteArtifactRevision(ctx context.Context, name names.ArtifactRevision) error {
+	name, err := d.unwrapArtifactRevisionTag(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	k := d.NewKey(models.BlobEntityName, name.String())
+	if err := d.deleteBlob(ctx, k); err != nil {
+		return err
+	}
+
+	k = d.NewKey(storage.ArtifactRevisionEntityName, name.String())
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) SaveArtifactRevisionTag(ctx context.Context, tag *models.ArtifactRevisionTag) error {
+	k := d.NewKey(storage.ArtifactRevisionTagEntityName, tag.String())
+	if _, err := d.Put(ctx, k, tag); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) unwrapArtifactRevisionTag(ctx context.Context, name names.ArtifactRevision) (names.ArtifactRevision, error) {
+	tag := new(models.ArtifactRevisionTag)
+	if err := d.Get(ctx, d.NewKey(storage.ArtifactRevisionTagEntityName, name.String()), tag); d.IsNotFound(err) {
+		return name, nil
+	} else if err != nil {
+		return names.ArtifactRevision{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return name.Artifact().Revision(tag.RevisionID), nil
+}
+
+// ListArtifactRevisionTags returns the tags of all revisions of an artifact.
+func (d *DAO) ListArtifactRevisionTags(ctx context.Context, parent names.Artifact) ([]models.ArtifactRevisionTag, error) {
+	q := artifactQuery(d.NewQuery(storage.ArtifactRevisionTagEntityName), parent)
+
+	it := d.Run(ctx, q)
+	tags := make([]models.ArtifactRevisionTag, 0)
+	tag := new(models.ArtifactRevisionTag)
+	var err error
+	for _, err = it.Next(tag); err == nil; _, err = it.Next(tag) {
+		tags = append(tags, *tag)
+	}
+	if err != nil && err != iterator.Done {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return tags, nil
+}
+
+func (d *DAO) DeleteArtifactRevisionTag(ctx context.Context, tag *models.ArtifactRevisionTag) error {
+	k := d.NewKey(storage.ArtifactRevisionTagEntityName, tag.String())
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
@@ -34,45 +34,26 @@ type ArtifactList struct {
 
 var artifactFields = []filtering.Field{
 	{Name: "name", Type: filtering.String},
-	{Name: "project_id", Type: filtering.String},
-	{Name: "api_id", Type: filtering.String},
-	{Name: "version_id", Type: filtering.String},
-	{Name: "spec_id", Type: filtering.String},
-	{Name: "artifact_id", Type: filtering.String},
-	{Name: "create_time", Type: filtering.Timestamp},
-	{Name: "update_time", Type: filtering.Timestamp},
-	{Name: "mime_type", Type: filtering.String},
-	{Name: "size_bytes", Type: filtering.Int},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "api_id", Type: filtering.String, StorageName: "ApiID"},
+	{Name: "version_id", Type: filtering.String, StorageName: "VersionID"},
+	{Name: "spec_id", Type: filtering.String, StorageName: "SpecID"},
+	{Name: "artifact_id", Type: filtering.String, StorageName: "ArtifactID"},
+	{Name: "revision_id", Type: filtering.String, StorageName: "RevisionID"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "update_time", Type: filtering.Timestamp, StorageName: "UpdateTime"},
+	{Name: "revision_create_time", Type: filtering.Timestamp, StorageName: "RevisionCreateTime"},
+	{Name: "mime_type", Type: filtering.String, StorageName: "MimeType"},
+	{Name: "size_bytes", Type: filtering.Int, StorageName: "SizeInBytes"},
 }
 
 func (d *DAO) ListSpecArtifacts(ctx context.Context, parent names.Spec, opts PageOptions) (ArtifactList, error) {
 	q := d.NewQuery(storage.ArtifactEntityName)
 
-	token, err := decodeToken(opts.Token)
-	if err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
-	}
-
-	if err := token.ValidateFilter(opts.Filter); err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
-	} else {
-		token.Filter = opts.Filter
-	}
-
-	q = q.ApplyOffset(token.Offset)
-
-	if id := parent.ProjectID; id != "-" {
-		q = q.Require("ProjectID", id)
-	}
-	if id := parent.ApiID; id != "-" {
-		q = q.Require("ApiID", id)
-	}
-	if id := parent.VersionID; id != "-" {
-		q = q.Require("VersionID", id)
-	}
-	if id := parent.SpecID; id != "-" {
-		q = q.Require("SpecID", id)
-	}
+	q = requireID(q, "ProjectID", parent.ProjectID)
+	q = requireID(q, "ApiID", parent.ApiID)
+	q = requireID(q, "VersionID", parent.VersionID)
+	q = requireID(q, "SpecID", parent.SpecID)
 
 	if parent.ProjectID != "-" && parent.ApiID != "-" && parent.VersionID != "-" && parent.SpecID != "-" {
 		if _, err := d.GetSpec(ctx, parent); err != nil {
@@ -92,37 +73,16 @@ func (d *DAO) ListSpecArtifacts(ctx context.Context, parent names.Spec, opts Pag
 		}
 	}
 
-	return d.listArtifacts(ctx, d.Run(ctx, q), opts, func(a *models.Artifact) bool {
-		return a.ProjectID != "" && a.ApiID != "" && a.VersionID != "" && a.SpecID != ""
-	})
+	return d.listArtifacts(ctx, q, opts)
 }
 
 func (d *DAO) ListVersionArtifacts(ctx context.Context, parent names.Version, opts PageOptions) (ArtifactList, error) {
 	q := d.NewQuery(storage.ArtifactEntityName)
 	q = q.Require("SpecID", "")
 
-	token, err := decodeToken(opts.Token)
-	if err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
-	}
-
-	if err := token.ValidateFilter(opts.Filter); err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
-	} else {
-		token.Filter = opts.Filter
-	}
-
-	q = q.ApplyOffset(token.Offset)
-
-	if id := parent.ProjectID; id != "-" {
-		q = q.Require("ProjectID", id)
-	}
-	if id := parent.ApiID; id != "-" {
-		q = q.Require("ApiID", id)
-	}
-	if id := parent.VersionID; id != "-" {
-		q = q.Require("VersionID", id)
-	}
+	q = requireID(q, "ProjectID", parent.ProjectID)
+	q = requireID(q, "ApiID", parent.ApiID)
+	q = requireID(q, "VersionID", parent.VersionID)
 
 	if parent.ProjectID != "-" && parent.ApiID != "-" && parent.VersionID != "-" {
 		if _, err := d.GetVersion(ctx, parent); err != nil {
@@ -138,9 +98,7 @@ func (d *DAO) ListVersionArtifacts(ctx context.Context, parent names.Version, op
 		}
 	}
 
-	return d.listArtifacts(ctx, d.Run(ctx, q), opts, func(a *models.Artifact) bool {
-		return a.ProjectID != "" && a.ApiID != "" && a.VersionID != ""
-	})
+	return d.listArtifacts(ctx, q, opts)
 }
 
 func (d *DAO) ListApiArtifacts(ctx context.Context, parent names.Api, opts PageOptions) (ArtifactList, error) {
@@ -148,25 +106,8 @@ func (d *DAO) ListApiArtifacts(ctx context.Context, parent names.Api, opts PageO
 	q = q.Require("VersionID", "")
 	q = q.Require("SpecID", "")
 
-	token, err := decodeToken(opts.Token)
-	if err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
-	}
-
-	if err := token.ValidateFilter(opts.Filter); err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
-	} else {
-		token.Filter = opts.Filter
-	}
-
-	q = q.ApplyOffset(token.Offset)
-
-	if id := parent.ProjectID; id != "-" {
-		q = q.Require("ProjectID", id)
-	}
-	if id := parent.ApiID; id != "-" {
-		q = q.Require("ApiID", id)
-	}
+	q = requireID(q, "ProjectID", parent.ProjectID)
+	q = requireID(q, "ApiID", parent.ApiID)
 
 	if parent.ProjectID != "-" && parent.ApiID != "-" {
 		if _, err := d.GetApi(ctx, parent); err != nil {
@@ -178,9 +119,7 @@ func (d *DAO) ListApiArtifacts(ctx context.Context, parent names.Api, opts PageO
 		}
 	}
 
-	return d.listArtifacts(ctx, d.Run(ctx, q), opts, func(a *models.Artifact) bool {
-		return a.ProjectID != "" && a.ApiID != ""
-	})
+	return d.listArtifacts(ctx, q, opts)
 }
 
 func (d *DAO) ListProjectArtifacts(ctx context.Context, parent names.Project, opts PageOptions) (ArtifactList, error) {
@@ -189,6 +128,26 @@ func (d *DAO) ListProjectArtifacts(ctx context.Context, parent names.Project, op
 	q = q.Require("VersionID", "")
 	q = q.Require("SpecID", "")
 
+	q = requireID(q, "ProjectID", parent.ProjectID)
+	if parent.ProjectID != "-" {
+		if _, err := d.GetProject(ctx, parent); err != nil {
+			return ArtifactList{}, err
+		}
+	}
+
+	return d.listArtifacts(ctx, q, opts)
+}
+
+// requireID adds a requirement for an ID of the artifact parent to a query.
+// Artifacts of other parent types have empty IDs, so they are excluded when the ID is "-".
+func requireID(q storage.Query, field, id string) storage.Query {
+	if id == "-" {
+		return q.After(field, "")
+	}
+	return q.Require(field, id)
+}
+
+func (d *DAO) listArtifacts(ctx context.Context, q storage.Query, opts PageOptions) (ArtifactList, error) {
 	token, err := decodeToken(opts.Token)
 	if err != nil {
 		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
@@ -200,32 +159,25 @@ func (d *DAO) ListProjectArtifacts(ctx context.Context, parent names.Project, op
 		token.Filter = opts.Filter
 	}
 
-	q = q.ApplyOffset(token.Offset)
-
-	if id := parent.ProjectID; id != "-" {
-		q = q.Require("ProjectID", id)
-		if _, err := d.GetProject(ctx, parent); err != nil {
-			return ArtifactList{}, err
-		}
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
 	}
 
-	return d.listArtifacts(ctx, d.Run(ctx, q), opts, func(a *models.Artifact) bool {
-		return a.ProjectID != ""
-	})
-}
-
-func (d *DAO) listArtifacts(ctx context.Context, it storage.Iterator, opts PageOptions, include func(*models.Artifact) bool) (ArtifactList, error) {
-	token, err := decodeToken(opts.Token)
+	orderings, err := parseOrderBy(opts.OrderBy, artifactFields)
 	if err != nil {
-		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
-	} else {
-		token.Filter = opts.Filter
+		return ArtifactList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
 	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
 
 	filter, err := filtering.NewFilter(opts.Filter, artifactFields)
 	if err != nil {
 		return ArtifactList{}, err
 	}
+	filter = applyFilter(q, filter, opts.Size)
+	it := d.Run(ctx, q)
 
 	response := ArtifactList{
 		Artifacts: make([]models.Artifact, 0, opts.Size),
@@ -241,15 +193,15 @@ func (d *DAO) listArtifacts(ctx context.Context, it storage.Iterator, opts PageO
 		match, err := filter.Matches(artifactMap)
 		if err != nil {
 			return response, err
-		} else if !match || !include(artifact) {
-			token.Offset++
+		} else if !match {
+			token.LastKey, token.LastValues = artifact.Key, orderValues(artifact, orderings)
 			continue
 		} else if len(response.Artifacts) == int(opts.Size) {
 			break
 		}
 
 		response.Artifacts = append(response.Artifacts, *artifact)
-		token.Offset++
+		token.LastKey, token.LastValues = artifact.Key, orderValues(artifact, orderings)
 	}
 	if err != nil && err != iterator.Done {
 		return response, status.Error(codes.Internal, err.Error())
@@ -267,42 +219,50 @@ func (d *DAO) listArtifacts(ctx context.Context, it storage.Iterator, opts PageO
 
 func artifactMap(artifact models.Artifact) (map[string]interface{}, error) {
 	return map[string]interface{}{
-		"name":        artifact.Name(),
-		"project_id":  artifact.ProjectID,
-		"api_id":      artifact.ApiID,
-		"version_id":  artifact.VersionID,
-		"spec_id":     artifact.SpecID,
-		"artifact_id": artifact.ArtifactID,
-		"create_time": artifact.CreateTime,
-		"update_time": artifact.UpdateTime,
-		"mime_type":   artifact.MimeType,
-		"size_bytes":  artifact.SizeInBytes,
+		"name":                 artifact.Name(),
+		"project_id":           artifact.ProjectID,
+		"api_id":               artifact.ApiID,
+		"version_id":           artifact.VersionID,
+		"spec_id":              artifact.SpecID,
+		"artifact_id":          artifact.ArtifactID,
+		"revision_id":          artifact.RevisionID,
+		"create_time":          artifact.CreateTime,
+		"update_time":          artifact.UpdateTime,
+		"revision_create_time": artifact.RevisionCreateTime,
+		"mime_type":            artifact.MimeType,
+		"size_bytes":           artifact.SizeInBytes,
 	}, nil
 }
 
+// SaveArtifact saves an artifact and its current revision.
 func (d *DAO) SaveArtifact(ctx context.Context, artifact *models.Artifact) error {
 	k := d.NewKey(storage.ArtifactEntityName, artifact.Name())
 	if _, err := d.Put(ctx, k, artifact); err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 
-	return nil
+	return d.SaveArtifactRevision(ctx, artifact.Revision())
 }
 
+// SaveArtifactContents saves the contents of an artifact and its current revision.
 func (d *DAO) SaveArtifactContents(ctx context.Context, artifact *models.Artifact, contents []byte) error {
 	blob := models.NewBlobForArtifact(artifact, contents)
 	k := d.NewKey(models.BlobEntityName, artifact.Name())
-	if _, err := d.Put(ctx, k, blob); err != nil {
-		return status.Error(codes.Internal, err.Error())
+	if err := d.saveBlob(ctx, k, blob); err != nil {
+		return err
 	}
 
-	return nil
+	return d.SaveArtifactRevisionContents(ctx, artifact.Revision(), contents)
 }
 
 func (d *DAO) GetArtifact(ctx context.Context, name names.Artifact) (*models.Artifact, error) {
+	if err := d.checkParentNotDeleted(ctx, name.ProjectID(), name.ApiID(), name.VersionID(), name.SpecID()); err != nil {
+		return nil, err
+	}
+
 	artifact := new(models.Artifact)
 	k := d.NewKey(storage.ArtifactEntityName, name.String())
-	if err := d.Get(ctx, k, artifact); d.IsNotFound(err) {
+	if err := d.get(ctx, k, artifact); d.IsNotFound(err) {
 		return nil, status.Errorf(codes.NotFound, "artifact %q not found in database", name)
 	} else if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -320,19 +280,40 @@ func (d *DAO) GetArtifactContents(ctx context.Context, name names.Artifact) (*mo
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	if err := d.loadBlobContents(ctx, blob); err != nil {
+		return nil, err
+	}
+
 	return blob, nil
 }
 
+// DeleteArtifact deletes an artifact with all of its revisions and their tags.
 func (d *DAO) DeleteArtifact(ctx context.Context, name names.Artifact) error {
-	k := d.NewKey(models.BlobEntityName, name.String())
-	if err := d.Delete(ctx, k); err != nil {
-		return status.Error(codes.Internal, err.Error())
-	}
+	return d.deleteChildren(ctx, artifactQuery(d.NewQuery(models.BlobEntityName), name), func() error {
+		for _, kind := range []string{
+			storage.ArtifactRevisionTagEntityName,
+			storage.ArtifactRevisionEntityName,
+			models.BlobEntityName,
+		} {
+			if err := d.DeleteAllMatches(ctx, artifactQuery(d.NewQuery(kind), name)); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
 
-	k = d.NewKey(storage.ArtifactEntityName, name.String())
-	if err := d.Delete(ctx, k); err != nil {
-		return status.Error(codes.Internal, err.Error())
-	}
+		k := d.NewKey(storage.ArtifactEntityName, name.String())
+		if err := d.Delete(ctx, k); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return nil
+	})
+}
 
-	return nil
+// artifactQuery adds requirements to a query that select the entities of an artifact.
+// Artifacts of other parent types have empty IDs, so every ID is required.
+func artifactQuery(q storage.Query, name names.Artifact) storage.Query {
+	q = q.Require("ProjectID", name.ProjectID())
+	q = q.Require("ApiID", name.ApiID())
+	q = q.Require("VersionID", name.VersionID())
+	q = q.Require("SpecID", name.SpecID())
+	return q.Require("ArtifactID", name.ArtifactID())
 }
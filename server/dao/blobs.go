@@ -0,0 +1,328 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/apigee/registry/server/blobs"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Blobs refer to their contents by hash, and the contents are shared by all
+// blobs with the same hash. Contents are kept in the database as BlobContents
+// or in the blob store if one is configured, and they are deleted when the
+// last blob that refers to them is deleted or replaced. Contents in a blob
+// store also have a BlobContents row without contents, which is locked while
+// they are saved or deleted.
+
+// maxContentsAttempts is the number of times saving contents is retried when they are deleted concurrently.
+const maxContentsAttempts = 3
+
+// saveBlob saves a blob and its contents, unless contents with the same hash already exist.
+func (d *DAO) saveBlob(ctx context.Context, k storage.Key, blob *models.Blob) error {
+	previous := new(models.Blob)
+	if err := d.Get(ctx, k, previous); err != nil && !d.IsNotFound(err) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err := d.saveContents(ctx, blob); err != nil {
+		return err
+	}
+
+	saved := *blob
+	saved.Contents = nil
+	if _, err := d.Put(ctx, k, &saved); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if previous.Hash != blob.Hash {
+		return d.releaseContents(ctx, previous.Hash)
+	}
+	return nil
+}
+
+// saveContents saves the contents of a blob if they aren't already saved.
+func (d *DAO) saveContents(ctx context.Context, blob *models.Blob) error {
+	if blob.Hash == "" {
+		return nil
+	}
+
+	// Contents are locked until the blob that refers to them is committed, so that
+	// they can't be deleted by a concurrent transaction that releases them.
+	contents := models.NewBlobContents(blob)
+	if d.blobs != nil {
+		contents.Contents = nil
+	}
+	if err := d.lockContents(ctx, contents); err != nil {
+		return err
+	}
+
+	// Stored contents are put even if their row exists, because putting contents
+	// that already exist succeeds and replaces any that were lost.
+	if d.blobs != nil {
+		if err := d.blobs.Put(ctx, blob.Hash, blob.Contents); err != nil {
+			return status.Errorf(codes.Unavailable, "failed to store blob contents: %s", err)
+		}
+	}
+	return nil
+}
+
+// lockContents locks the row of contents until the current transaction ends, creating it if it doesn't exist.
+func (d *DAO) lockContents(ctx context.Context, contents *models.BlobContents) error {
+	k := d.NewKey(models.BlobContentsEntityName, contents.Key)
+	for attempt := 1; ; attempt++ {
+		if err := d.GetForUpdate(ctx, k, new(models.BlobContents)); err == nil {
+			return nil
+		} else if !d.IsNotFound(err) {
+			return status.Error(codes.Internal, err.Error())
+		} else if attempt > maxContentsAttempts {
+			return status.Errorf(codes.Aborted, "contents %s were deleted while they were saved", contents.Key)
+		}
+
+		// Contents that are created concurrently are saved once, and contents that are
+		// deleted after they are created here are created again.
+		if _, err := d.Create(ctx, k, contents); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+// loadBlobContents reads the contents of a blob.
+// Contents that were saved in the database remain there if a blob store is configured later.
+func (d *DAO) loadBlobContents(ctx context.Context, blob *models.Blob) error {
+	if len(blob.Contents) > 0 || blob.Hash == "" {
+		return nil
+	}
+
+	// Rows of contents that are kept in a blob store have no contents.
+	contents := new(models.BlobContents)
+	if err := d.Get(ctx, d.NewKey(models.BlobContentsEntityName, blob.Hash), contents); err == nil && len(contents.Contents) > 0 {
+		blob.Contents = contents.Contents
+		return nil
+	} else if err != nil && !d.IsNotFound(err) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if d.blobs == nil {
+		if blob.SizeInBytes == 0 {
+			return nil
+		}
+		return status.Errorf(codes.FailedPrecondition, "contents of blob %q are kept in a blob store that is not configured", blob.Key)
+	}
+
+	stored, err := d.blobs.Get(ctx, blob.Hash)
+	if err == blobs.ErrNotFound && blob.SizeInBytes == 0 {
+		return nil
+	} else if err == blobs.ErrNotFound {
+		return status.Errorf(codes.DataLoss, "contents of blob %q are missing from the blob store", blob.Key)
+	} else if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to read blob contents: %s", err)
+	}
+
+	blob.Contents = stored
+	return nil
+}
+
+// deleteBlob deletes a blob and releases its contents.
+func (d *DAO) deleteBlob(ctx context.Context, k storage.Key) error {
+	blob := new(models.Blob)
+	if err := d.Get(ctx, k, blob); d.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return d.releaseContents(ctx, blob.Hash)
+}
+
+// releaseContents deletes contents that are no longer referred to by any blob.
+// Contents in a blob store are deleted after the current transaction is committed,
+// so that they remain available if it is rolled back.
+func (d *DAO) releaseContents(ctx context.Context, hashes ...string) error {
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+
+		// Contents are locked before their references are checked, so that blobs that
+		// refer to them can't be saved until they are deleted.
+		k := d.NewKey(models.BlobContentsEntityName, hash)
+		err := d.GetForUpdate(ctx, k, new(models.BlobContents))
+		if err != nil && !d.IsNotFound(err) {
+			return status.Error(codes.Internal, err.Error())
+		}
+		missing := err != nil
+
+		if referenced, err := d.contentsReferenced(ctx, hash); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		} else if referenced {
+			continue
+		}
+
+		if d.blobs == nil {
+			if err := d.Delete(ctx, k); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			continue
+		}
+
+		// The row of stored contents is kept until the contents are deleted, so that they are
+		// deleted under its lock. Contents that were stored without a row are given one.
+		if missing {
+			if err := d.lockContents(ctx, &models.BlobContents{Key: hash, CreateTime: time.Now().Round(time.Microsecond)}); err != nil {
+				return err
+			}
+		}
+		if d.released != nil {
+			*d.released = append(*d.released, hash)
+		} else {
+			d.deleteStoredContents(ctx, hash)
+		}
+	}
+	return nil
+}
+
+// deleteStoredContents deletes released contents from the blob store along with their rows.
+// Each of them is deleted in a transaction that locks its row and checks that no blob that
+// refers to them has been saved since they were released, so contents that are saved again
+// concurrently are kept. Failures are logged because the blobs have already been deleted,
+// and any remaining contents are only wasted space.
+func (d *DAO) deleteStoredContents(ctx context.Context, hashes ...string) {
+	for _, hash := range hashes {
+		if err := d.Transaction(ctx, func(ctx context.Context, db DAO) error {
+			k := db.NewKey(models.BlobContentsEntityName, hash)
+			if err := db.GetForUpdate(ctx, k, new(models.BlobContents)); db.IsNotFound(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if referenced, err := db.contentsReferenced(ctx, hash); err != nil || referenced {
+				return err
+			}
+
+			if err := db.blobs.Delete(ctx, hash); err != nil {
+				return err
+			}
+			return db.Delete(ctx, k)
+		}); err != nil {
+			log.Printf("Failed to delete blob contents %s: %s", hash, err)
+		}
+	}
+}
+
+// contentsReferenced returns true if any blob refers to the contents with a hash.
+// The blobs are locked so that blobs committed by other transactions are found.
+func (d *DAO) contentsReferenced(ctx context.Context, hash string) (bool, error) {
+	q := d.NewQuery(models.BlobEntityName)
+	q = q.Require("Hash", hash)
+	q = q.ApplyLimit(1)
+	q = q.ForUpdate()
+	it := d.Run(ctx, q)
+	if _, err := it.Next(new(models.Blob)); err == iterator.Done {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// blobHashes returns the distinct hashes of the blobs that match a query.
+func (d *DAO) blobHashes(ctx context.Context, q storage.Query) ([]string, error) {
+	seen := make(map[string]bool)
+	hashes := make([]string, 0)
+	it := d.Run(ctx, q)
+	blob := new(models.Blob)
+	var err error
+	for _, err = it.Next(blob); err == nil; _, err = it.Next(blob) {
+		if !seen[blob.Hash] {
+			seen[blob.Hash] = true
+			hashes = append(hashes, blob.Hash)
+		}
+	}
+	if err != iterator.Done {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// The following methods delete the children of resources with the storage client
+// and release the contents of the blobs that are deleted.
+
+// DeleteChildrenOfProject deletes all the children of a project.
+func (d *DAO) DeleteChildrenOfProject(ctx context.Context, project names.Project) error {
+	q := d.NewQuery(models.BlobEntityName)
+	q = q.Require("ProjectID", project.ProjectID)
+	return d.deleteChildren(ctx, q, func() error {
+		return d.Client.DeleteChildrenOfProject(ctx, project)
+	})
+}
+
+// DeleteChildrenOfApi deletes all the children of an api.
+func (d *DAO) DeleteChildrenOfApi(ctx context.Context, api names.Api) error {
+	q := d.NewQuery(models.BlobEntityName)
+	q = q.Require("ProjectID", api.ProjectID)
+	q = q.Require("ApiID", api.ApiID)
+	return d.deleteChildren(ctx, q, func() error {
+		return d.Client.DeleteChildrenOfApi(ctx, api)
+	})
+}
+
+// DeleteChildrenOfVersion deletes all the children of a version.
+func (d *DAO) DeleteChildrenOfVersion(ctx context.Context, version names.Version) error {
+	q := d.NewQuery(models.BlobEntityName)
+	q = q.Require("ProjectID", version.ProjectID)
+	q = q.Require("ApiID", version.ApiID)
+	q = q.Require("VersionID", version.VersionID)
+	return d.deleteChildren(ctx, q, func() error {
+		return d.Client.DeleteChildrenOfVersion(ctx, version)
+	})
+}
+
+// DeleteChildrenOfSpec deletes all the children of a spec.
+func (d *DAO) DeleteChildrenOfSpec(ctx context.Context, spec names.Spec) error {
+	q := d.NewQuery(models.BlobEntityName)
+	q = q.Require("ProjectID", spec.ProjectID)
+	q = q.Require("ApiID", spec.ApiID)
+	q = q.Require("VersionID", spec.VersionID)
+	q = q.Require("SpecID", spec.SpecID)
+	return d.deleteChildren(ctx, q, func() error {
+		return d.Client.DeleteChildrenOfSpec(ctx, spec)
+	})
+}
+
+// deleteChildren runs a deletion and releases the contents of the deleted blobs, which are those that match q.
+func (d *DAO) deleteChildren(ctx context.Context, q storage.Query, deletion func() error) error {
+	hashes, err := d.blobHashes(ctx, q)
+	if err != nil {
+		return err
+	}
+	if err := deletion(); err != nil {
+		return err
+	}
+	return d.releaseContents(ctx, hashes...)
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changeBatchSize is the number of changes read from storage at a time.
+const changeBatchSize = 1000
+
+// changeVisibilityDelay is how long a gap in the IDs of the change log is assumed to belong
+// to a transaction that hasn't been committed. IDs are assigned when changes are recorded, just
+// before their transactions commit, so on databases like PostgreSQL and MySQL a change can become
+// visible after changes with higher IDs. Gaps that are older than this are left by transactions
+// that were rolled back and are skipped.
+const changeVisibilityDelay = 10 * time.Second
+
+// ChangeList contains a page of changes.
+type ChangeList struct {
+	Changes []models.Change
+	// Token identifies the position in the log after the last change that was read.
+	// It is always set so that listing can be resumed when more changes are made.
+	Token string
+}
+
+// Transaction runs fn with a DAO whose modifications are committed together.
+// All modifications are rolled back if fn returns an error.
+func (d *DAO) Transaction(ctx context.Context, fn func(context.Context, DAO) error) error {
+	// Contents released in nested transactions are deleted when the outermost transaction is committed.
+	released := d.released
+	if released == nil {
+		released = new([]string)
+	}
+
+	if err := d.Client.Transaction(ctx, func(ctx context.Context, c storage.Client) error {
+		tx := NewDAO(c, d.blobs)
+		tx.released = released
+		return fn(ctx, tx)
+	}); err != nil {
+		return err
+	}
+
+	if d.released == nil && d.blobs != nil {
+		d.deleteStoredContents(ctx, *released...)
+	}
+	return nil
+}
+
+// ListChanges returns a page of changes that were made after the position identified
+// by the token in opts. Changes that aren't included are skipped but still advance the
+// returned token. The filter in opts is ignored. Listing stops before recent gaps in the
+// log, so that changes committed out of order aren't skipped when listing is resumed.
+func (d *DAO) ListChanges(ctx context.Context, opts PageOptions, include func(*models.Change) bool) (ChangeList, error) {
+	position, err := decodeChangeToken(opts.Token)
+	if err != nil {
+		return ChangeList{}, status.Errorf(codes.InvalidArgument, "invalid token %q: %s", opts.Token, err)
+	}
+
+	response := ChangeList{
+		Changes: make([]models.Change, 0, opts.Size),
+	}
+
+	gapped := false
+	for len(response.Changes) < int(opts.Size) && !gapped {
+		q := d.NewQuery(models.ChangeEntityName)
+		q = q.After("ID", position)
+		q = q.ApplyLimit(changeBatchSize)
+
+		it := d.Run(ctx, q)
+		count := 0
+		change := new(models.Change)
+		for _, err = it.Next(change); err == nil; _, err = it.Next(change) {
+			// IDs start at 1, so a change that doesn't follow the last one is after a gap.
+			if change.ID != position+1 && time.Since(change.ChangeTime) < changeVisibilityDelay {
+				gapped = true
+				break
+			}
+			count++
+			position = change.ID
+			if include(change) {
+				response.Changes = append(response.Changes, *change)
+			}
+			if len(response.Changes) == int(opts.Size) {
+				break
+			}
+		}
+		if err != nil && err != iterator.Done {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+
+		if count < changeBatchSize {
+			break
+		}
+	}
+
+	response.Token = encodeChangeToken(position)
+	return response, nil
+}
+
+// ListUndispatchedChanges returns the oldest changes that haven't been sent to a notifier.
+func (d *DAO) ListUndispatchedChanges(ctx context.Context, limit int32) ([]models.Change, error) {
+	q := d.NewQuery(models.ChangeEntityName)
+	q = q.Require("Dispatched", false)
+	q = q.ApplyLimit(limit)
+
+	changes := make([]models.Change, 0)
+	it := d.Run(ctx, q)
+	change := new(models.Change)
+	var err error
+	for _, err = it.Next(change); err == nil; _, err = it.Next(change) {
+		changes = append(changes, *change)
+	}
+	if err != iterator.Done {
+		return changes, status.Error(codes.Internal, err.Error())
+	}
+
+	return changes, nil
+}
+
+// SaveChange adds a change to the log, or updates it if it was previously saved.
+func (d *DAO) SaveChange(ctx context.Context, change *models.Change) error {
+	k := d.NewKey(models.ChangeEntityName, "")
+	if _, err := d.Put(ctx, k, change); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// encodeChangeToken converts a position in the change log into an opaque string.
+func encodeChangeToken(position int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.FormatInt(position, 10)))
+}
+
+// decodeChangeToken converts a string returned from encodeChangeToken() back into a position.
+// Empty encoding strings are decoded to the start of the log.
+func decodeChangeToken(encoded string) (int64, error) {
+	if encoded == "" {
+		return 0, nil
+	}
+
+	decoding, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode token, expected base64: %s", err)
+	}
+
+	position, err := strconv.ParseInt(string(decoding), 10, 64)
+	if err != nil || position < 0 {
+		return 0, fmt.Errorf("failed to decode token position %q", decoding)
+	}
+
+	return position, nil
+}
@@ -16,11 +16,19 @@ package dao
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/gob"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/apigee/registry/server/blobs"
 	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // PageOptions contains custom arguments for listing requests.
@@ -34,37 +42,108 @@ type PageOptions struct {
 	// If specified, listing will continue from the end of the previous page. Otherwise,
 	// the first page in a listing series will be returned.
 	Token string
+	// OrderBy is the ordering for this listing request, as described at https://google.aip.dev/132.
+	// If unspecified, resources are listed in order of their names.
+	OrderBy string
+	// ShowDeleted includes deleted resources that haven't been purged, as described at https://google.aip.dev/164.
+	// It is only used when listing apis, versions and specs.
+	ShowDeleted bool
 }
 
 type DAO struct {
 	storage.Client
+	// blobs keeps the contents of blobs. If nil, contents are kept in the database.
+	blobs blobs.Store
+	// released collects the hashes of contents to delete from the blob store when a transaction is committed.
+	// It is nil outside of transactions.
+	released *[]string
+	// forUpdate locks the rows of resources that are read, as described at ForUpdate.
+	forUpdate bool
 }
 
-func NewDAO(c storage.Client) DAO {
+func NewDAO(c storage.Client, b blobs.Store) DAO {
 	return DAO{
 		Client: c,
+		blobs:  b,
 	}
 }
 
+// ForUpdate returns a DAO whose reads of resources lock them until the current transaction ends,
+// so that they can't be changed by other transactions before they are saved. Specs and deployments
+// are locked by locking the version or api that they belong to, since updates can add revisions.
+// Reads of parents that check whether they were deleted don't lock them.
+func (d DAO) ForUpdate() DAO {
+	d.forUpdate = true
+	return d
+}
+
+// get reads an entity, locking it if the DAO was returned by ForUpdate.
+func (d *DAO) get(ctx context.Context, k storage.Key, v interface{}) error {
+	if d.forUpdate {
+		return d.GetForUpdate(ctx, k, v)
+	}
+	return d.Get(ctx, k, v)
+}
+
+// lockParent locks the parent of revisioned resources, or of resources that may be created,
+// if the DAO was returned by ForUpdate. Transactions that lock the same parent are applied
+// one at a time and then read its children with their latest state. A missing parent isn't locked.
+func (d *DAO) lockParent(ctx context.Context, k storage.Key, v interface{}) error {
+	if !d.forUpdate {
+		return nil
+	}
+	if err := d.GetForUpdate(ctx, k, v); err != nil && !d.IsNotFound(err) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// run runs a query, locking its results if the DAO was returned by ForUpdate.
+func (d *DAO) run(ctx context.Context, q storage.Query) storage.Iterator {
+	if d.forUpdate {
+		q = q.ForUpdate()
+	}
+	return d.Run(ctx, q)
+}
+
+func init() {
+	// Timestamps are encoded in tokens as the values of ordered fields.
+	gob.Register(time.Time{})
+}
+
 // token contains information to share between sequential page iterators.
 type token struct {
-	// Offset is the number of resources that should be skipped before the page begins.
-	// It should be set to the number of resources already returned.
-	Offset int32
+	// LastKey is the storage key of the last resource considered for the previous page.
+	// The next page begins with the resource that follows it. It is empty for the first page.
+	LastKey string
+	// LastValues are the values of the ordered fields of the last resource considered for the previous page.
+	LastValues []interface{}
 	// Filter is the filter string for this listing request. It should be consistent between sequential pages.
 	Filter string
+	// OrderBy is the ordering for this listing request. It should be consistent between sequential pages.
+	OrderBy string
 }
 
 // ValidateFilter returns an error if the new filter doesn't match the token's encoded filter.
 // When the token represents the first page, any filter is valid and no error will be returned.
 func (t token) ValidateFilter(newFilter string) error {
-	if t.Offset > 0 && newFilter != t.Filter {
+	if t.LastKey != "" && newFilter != t.Filter {
 		return fmt.Errorf("new filter does not match previous filter %q", t.Filter)
 	}
 
 	return nil
 }
 
+// ValidateOrder returns an error if the new ordering doesn't match the token's encoded ordering.
+// When the token represents the first page, any ordering is valid and no error will be returned.
+func (t token) ValidateOrder(newOrder string) error {
+	if t.LastKey != "" && newOrder != t.OrderBy {
+		return fmt.Errorf("new order_by does not match previous order_by %q", t.OrderBy)
+	}
+
+	return nil
+}
+
 // encodeToken converts a token struct into an opaque string that can be converted back into struct form using decodeToken().
 func encodeToken(o token) (string, error) {
 	var encoding bytes.Buffer
@@ -97,3 +176,106 @@ func decodeToken(encoded string) (token, error) {
 
 	return opts, nil
 }
+
+// StartAfter limits a query to resources after the last one considered for the previous page.
+func (t token) StartAfter(q storage.Query) storage.Query {
+	if t.LastKey == "" {
+		return q
+	}
+	return q.StartAfter(t.LastKey, t.LastValues...)
+}
+
+// ordering is a field that listed resources are sorted by.
+type ordering struct {
+	// StorageName is the name of the model field that stores the ordered values.
+	StorageName string
+	Descending  bool
+}
+
+// parseOrderBy returns the orderings of an order_by string as described at https://google.aip.dev/132.
+// Resources can be ordered by name or by fields that are stored in a comparable form.
+func parseOrderBy(orderBy string, fields []filtering.Field) ([]ordering, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, nil
+	}
+
+	storageNames := make(map[string]string, len(fields))
+	for _, f := range fields {
+		switch {
+		case f.Name == "name":
+			storageNames[f.Name] = "Key"
+		case f.StorageName != "" && f.Type != filtering.StringMap:
+			storageNames[f.Name] = f.StorageName
+		}
+	}
+
+	orderings := make([]ordering, 0)
+	seen := make(map[string]bool)
+	for _, term := range strings.Split(orderBy, ",") {
+		words := strings.Fields(term)
+		if len(words) == 0 || len(words) > 2 || (len(words) == 2 && words[1] != "desc") {
+			return nil, fmt.Errorf("invalid ordering %q, expected a field name optionally followed by \"desc\"", strings.TrimSpace(term))
+		}
+
+		name, ok := storageNames[words[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown or unsupported field %q", words[0])
+		} else if seen[words[0]] {
+			return nil, fmt.Errorf("field %q is ordered more than once", words[0])
+		}
+		seen[words[0]] = true
+
+		orderings = append(orderings, ordering{StorageName: name, Descending: len(words) == 2})
+	}
+
+	return orderings, nil
+}
+
+// applyOrder orders the results of a query. It must be applied before the query's page token.
+func applyOrder(q storage.Query, orderings []ordering) storage.Query {
+	for _, o := range orderings {
+		if o.Descending {
+			q = q.Descending(o.StorageName)
+		} else {
+			q = q.Ascending(o.StorageName)
+		}
+	}
+	return q
+}
+
+// orderValues returns the values of the ordered fields of a model.
+func orderValues(v interface{}, orderings []ordering) []interface{} {
+	if len(orderings) == 0 {
+		return nil
+	}
+
+	model := reflect.Indirect(reflect.ValueOf(v))
+	values := make([]interface{}, 0, len(orderings))
+	for _, o := range orderings {
+		values = append(values, model.FieldByName(o.StorageName).Interface())
+	}
+	return values
+}
+
+// applyFilter adds the conditions of a filter that storage can evaluate to a query.
+// It returns the filter that must still be evaluated on query results, which
+// matches everything if storage can evaluate the entire filter. In that case,
+// the query is limited to the number of results needed to fill a page of the
+// specified size and determine whether another page follows it.
+func applyFilter(q storage.Query, filter filtering.Filter, size int32) filtering.Filter {
+	complete := true
+	for _, c := range filter.Conjuncts() {
+		if c == nil || !q.ApplyFilter(c) {
+			complete = false
+		}
+	}
+
+	if !complete {
+		return filter
+	}
+
+	if size > 0 {
+		q.ApplyLimit(size + 1)
+	}
+	return filtering.Filter{}
+}
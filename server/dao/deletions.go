@@ -0,0 +1,337 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Deleted apis, versions and specs are kept until their purge time so that they can be undeleted.
+// Deleting a resource also deletes its children that aren't already deleted, and the children are
+// marked with the same delete time so that undeleting the resource only restores those children.
+
+// SoftDeleteApi marks an api and its versions and specs as deleted.
+func (d *DAO) SoftDeleteApi(ctx context.Context, name names.Api, deleteTime, purgeTime time.Time) error {
+	api, err := d.GetApi(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	api.Deleted, api.DeleteTime, api.PurgeTime = true, deleteTime, purgeTime
+	if err := d.SaveApi(ctx, api); err != nil {
+		return err
+	}
+
+	q := d.NewQuery(storage.VersionEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	if err := d.markVersions(ctx, q, func(v *models.Version) bool { return !v.Deleted }, true, deleteTime, purgeTime); err != nil {
+		return err
+	}
+
+	q = d.NewQuery(storage.SpecEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	return d.markSpecs(ctx, q, func(s *models.Spec) bool { return !s.Deleted }, true, deleteTime, purgeTime)
+}
+
+// SoftDeleteVersion marks a version and its specs as deleted.
+func (d *DAO) SoftDeleteVersion(ctx context.Context, name names.Version, deleteTime, purgeTime time.Time) error {
+	version, err := d.GetVersion(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	version.Deleted, version.DeleteTime, version.PurgeTime = true, deleteTime, purgeTime
+	if err := d.SaveVersion(ctx, version); err != nil {
+		return err
+	}
+
+	q := d.NewQuery(storage.SpecEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	q = q.Require("VersionID", name.VersionID)
+	return d.markSpecs(ctx, q, func(s *models.Spec) bool { return !s.Deleted }, true, deleteTime, purgeTime)
+}
+
+// SoftDeleteSpec marks all revisions of a spec as deleted.
+func (d *DAO) SoftDeleteSpec(ctx context.Context, name names.Spec, deleteTime, purgeTime time.Time) error {
+	if _, err := d.GetSpec(ctx, name); err != nil {
+		return err
+	}
+
+	return d.markSpecs(ctx, specRevisionsQuery(d, name), func(s *models.Spec) bool { return !s.Deleted }, true, deleteTime, purgeTime)
+}
+
+// UndeleteApi restores a deleted api and the children that were deleted with it.
+func (d *DAO) UndeleteApi(ctx context.Context, name names.Api) (*models.Api, error) {
+	api, err := d.GetApiIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if !api.Deleted {
+		return nil, status.Errorf(codes.FailedPrecondition, "api %q is not deleted", name)
+	}
+
+	deletedWith := func(t time.Time) bool { return t.Equal(api.DeleteTime) }
+
+	q := d.NewQuery(storage.VersionEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	q = q.Require("Deleted", true)
+	if err := d.markVersions(ctx, q, func(v *models.Version) bool { return deletedWith(v.DeleteTime) }, false, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	q = d.NewQuery(storage.SpecEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	q = q.Require("Deleted", true)
+	if err := d.markSpecs(ctx, q, func(s *models.Spec) bool { return deletedWith(s.DeleteTime) }, false, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	api.Deleted, api.DeleteTime, api.PurgeTime = false, time.Time{}, time.Time{}
+	if err := d.SaveApi(ctx, api); err != nil {
+		return nil, err
+	}
+
+	return api, nil
+}
+
+// UndeleteVersion restores a deleted version and the specs that were deleted with it.
+// Versions of deleted apis can't be restored without their api.
+func (d *DAO) UndeleteVersion(ctx context.Context, name names.Version) (*models.Version, error) {
+	version, err := d.GetVersionIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if !version.Deleted {
+		return nil, status.Errorf(codes.FailedPrecondition, "api version %q is not deleted", name)
+	}
+
+	if _, err := d.GetApi(ctx, name.Api()); isNotFound(err) {
+		return nil, status.Errorf(codes.FailedPrecondition, "api %q must be undeleted first", name.Api())
+	} else if err != nil {
+		return nil, err
+	}
+
+	q := d.NewQuery(storage.SpecEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	q = q.Require("VersionID", name.VersionID)
+	q = q.Require("Deleted", true)
+	if err := d.markSpecs(ctx, q, func(s *models.Spec) bool { return s.DeleteTime.Equal(version.DeleteTime) }, false, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	version.Deleted, version.DeleteTime, version.PurgeTime = false, time.Time{}, time.Time{}
+	if err := d.SaveVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// UndeleteSpec restores all revisions of a deleted spec.
+// Specs of deleted versions can't be restored without their version.
+func (d *DAO) UndeleteSpec(ctx context.Context, name names.Spec) (*models.Spec, error) {
+	spec, err := d.GetSpecIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if !spec.Deleted {
+		return nil, status.Errorf(codes.FailedPrecondition, "api spec %q is not deleted", name)
+	}
+
+	if _, err := d.GetVersion(ctx, name.Version()); isNotFound(err) {
+		return nil, status.Errorf(codes.FailedPrecondition, "api version %q must be undeleted first", name.Version())
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := d.markSpecs(ctx, specRevisionsQuery(d, name), func(s *models.Spec) bool { return s.Deleted }, false, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return d.GetSpec(ctx, name)
+}
+
+// PurgeDeleted permanently deletes the apis, versions and specs whose purge time is before now.
+// It returns the names of the purged resources.
+func (d *DAO) PurgeDeleted(ctx context.Context, now time.Time) ([]string, error) {
+	purged := make([]string, 0)
+
+	// Children are purged with their parents, so parents are purged first.
+	apis := make([]models.Api, 0)
+	q := d.NewQuery(storage.ApiEntityName).Require("Deleted", true)
+	it := d.Run(ctx, q)
+	api := new(models.Api)
+	var err error
+	for _, err = it.Next(api); err == nil; _, err = it.Next(api) {
+		if api.PurgeTime.Before(now) {
+			apis = append(apis, *api)
+		}
+	}
+	if err != iterator.Done {
+		return purged, status.Error(codes.Internal, err.Error())
+	}
+	for _, api := range apis {
+		name, err := names.ParseApi(api.Name())
+		if err != nil {
+			return purged, status.Error(codes.Internal, err.Error())
+		}
+		if err := d.DeleteApi(ctx, name); err != nil {
+			return purged, err
+		}
+		purged = append(purged, api.Name())
+	}
+
+	versions := make([]models.Version, 0)
+	q = d.NewQuery(storage.VersionEntityName).Require("Deleted", true)
+	it = d.Run(ctx, q)
+	version := new(models.Version)
+	for _, err = it.Next(version); err == nil; _, err = it.Next(version) {
+		if version.PurgeTime.Before(now) {
+			versions = append(versions, *version)
+		}
+	}
+	if err != iterator.Done {
+		return purged, status.Error(codes.Internal, err.Error())
+	}
+	for _, version := range versions {
+		name, err := names.ParseVersion(version.Name())
+		if err != nil {
+			return purged, status.Error(codes.Internal, err.Error())
+		}
+		if err := d.DeleteVersion(ctx, name); err != nil {
+			return purged, err
+		}
+		purged = append(purged, version.Name())
+	}
+
+	// All revisions of a spec are deleted together, so each spec is purged once.
+	specs := make(map[string]bool)
+	q = d.NewQuery(storage.SpecEntityName).Require("Deleted", true)
+	it = d.Run(ctx, q)
+	spec := new(models.Spec)
+	for _, err = it.Next(spec); err == nil; _, err = it.Next(spec) {
+		if spec.PurgeTime.Before(now) {
+			specs[spec.Name()] = true
+		}
+	}
+	if err != iterator.Done {
+		return purged, status.Error(codes.Internal, err.Error())
+	}
+	for spec := range specs {
+		name, err := names.ParseSpec(spec)
+		if err != nil {
+			return purged, status.Error(codes.Internal, err.Error())
+		}
+		if err := d.DeleteSpec(ctx, name); err != nil {
+			return purged, err
+		}
+		purged = append(purged, spec)
+	}
+
+	return purged, nil
+}
+
+// checkParentNotDeleted returns NotFound if the api, version or spec that contains an artifact or deployment
+// was deleted. Deleting a resource also deletes its versions and specs, so only the innermost one is checked.
+func (d *DAO) checkParentNotDeleted(ctx context.Context, projectID, apiID, versionID, specID string) error {
+	// Parents are only checked, so they aren't locked when their children are read for update.
+	parents := *d
+	parents.forUpdate = false
+	var err error
+	switch {
+	case specID != "":
+		_, err = parents.GetSpec(ctx, names.Spec{ProjectID: projectID, ApiID: apiID, VersionID: versionID, SpecID: specID})
+	case versionID != "":
+		_, err = parents.GetVersion(ctx, names.Version{ProjectID: projectID, ApiID: apiID, VersionID: versionID})
+	case apiID != "":
+		_, err = parents.GetApi(ctx, names.Api{ProjectID: projectID, ApiID: apiID})
+	}
+	return err
+}
+
+// specRevisionsQuery returns a query for all revisions of a spec.
+func specRevisionsQuery(d *DAO, name names.Spec) storage.Query {
+	q := d.NewQuery(storage.SpecEntityName)
+	q = q.Require("ProjectID", name.ProjectID)
+	q = q.Require("ApiID", name.ApiID)
+	q = q.Require("VersionID", name.VersionID)
+	q = q.Require("SpecID", name.SpecID)
+	return q
+}
+
+// markVersions sets the deletion state of the versions that match q and are selected by include.
+// Matches are read before any are saved, so saving them doesn't disturb the query.
+func (d *DAO) markVersions(ctx context.Context, q storage.Query, include func(*models.Version) bool, deleted bool, deleteTime, purgeTime time.Time) error {
+	versions := make([]models.Version, 0)
+	it := d.Run(ctx, q)
+	version := new(models.Version)
+	var err error
+	for _, err = it.Next(version); err == nil; _, err = it.Next(version) {
+		if include(version) {
+			versions = append(versions, *version)
+		}
+	}
+	if err != iterator.Done {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for i := range versions {
+		versions[i].Deleted, versions[i].DeleteTime, versions[i].PurgeTime = deleted, deleteTime, purgeTime
+		if err := d.SaveVersion(ctx, &versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markSpecs sets the deletion state of the spec revisions that match q and are selected by include.
+// Matches are read before any are saved, so saving them doesn't disturb the query.
+func (d *DAO) markSpecs(ctx context.Context, q storage.Query, include func(*models.Spec) bool, deleted bool, deleteTime, purgeTime time.Time) error {
+	specs := make([]models.Spec, 0)
+	it := d.Run(ctx, q)
+	spec := new(models.Spec)
+	var err error
+	for _, err = it.Next(spec); err == nil; _, err = it.Next(spec) {
+		if include(spec) {
+			specs = append(specs, *spec)
+		}
+	}
+	if err != iterator.Done {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for i := range specs {
+		specs[i].Deleted, specs[i].DeleteTime, specs[i].PurgeTime = deleted, deleteTime, purgeTime
+		if err := d.SaveSpecRevision(ctx, &specs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
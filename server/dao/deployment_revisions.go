@@ -0,0 +1,151 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (d *DAO) ListDeploymentRevisions(ctx context.Context, parent names.Deployment, opts PageOptions) (DeploymentList, error) {
+	q := d.NewQuery(storage.DeploymentEntityName)
+	q = q.Require("ProjectID", parent.ProjectID)
+	q = q.Require("ApiID", parent.ApiID)
+	q = q.Require("DeploymentID", parent.DeploymentID)
+
+	token, err := decodeToken(opts.Token)
+	if err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
+	}
+
+	if err := token.ValidateFilter(opts.Filter); err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
+	}
+
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, deploymentFields)
+	if err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	if len(orderings) == 0 {
+		// Revisions are listed from newest to oldest by default.
+		orderings = []ordering{{StorageName: "RevisionCreateTime", Descending: true}}
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
+
+	it := d.Run(ctx, q)
+	response := DeploymentList{
+		Deployments: make([]models.Deployment, 0, opts.Size),
+	}
+
+	revision := new(models.Deployment)
+	for _, err = it.Next(revision); err == nil; _, err = it.Next(revision) {
+		token.LastKey, token.LastValues = revision.Key, orderValues(revision, orderings)
+
+		response.Deployments = append(response.Deployments, *revision)
+		if len(response.Deployments) == int(opts.Size) {
+			break
+		}
+	}
+	if err != nil && err != iterator.Done {
+		return response, status.Error(codes.Internal, err.Error())
+	}
+
+	if err == nil {
+		response.Token, err = encodeToken(token)
+		if err != nil {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+func (d *DAO) SaveDeploymentRevision(ctx context.Context, revision *models.Deployment) error {
+	k := d.NewKey(storage.DeploymentEntityName, revision.RevisionName())
+	if _, err := d.Put(ctx, k, revision); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) GetDeploymentRevision(ctx context.Context, name names.DeploymentRevision) (*models.Deployment, error) {
+	name, err := d.unwrapDeploymentRevisionTag(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := name.Deployment().Api()
+	if err := d.checkParentNotDeleted(ctx, parent.ProjectID, parent.ApiID, "", ""); err != nil {
+		return nil, err
+	}
+
+	deployment := new(models.Deployment)
+	k := d.NewKey(storage.DeploymentEntityName, name.String())
+	if err := d.Get(ctx, k, deployment); d.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "deployment revision %q not found", name)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return deployment, nil
+}
+
+func (d *DAO) DeleteDeploymentRevision(ctx context.Context, name names.DeploymentRevision) error {
+	name, err := d.unwrapDeploymentRevisionTag(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	k := d.NewKey(storage.DeploymentEntityName, name.String())
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) SaveDeploymentRevisionTag(ctx context.Context, tag *models.DeploymentRevisionTag) error {
+	k := d.NewKey(storage.DeploymentRevisionTagEntityName, tag.String())
+	if _, err := d.Put(ctx, k, tag); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) unwrapDeploymentRevisionTag(ctx context.Context, name names.DeploymentRevision) (names.DeploymentRevision, error) {
+	tag := new(models.DeploymentRevisionTag)
+	if err := d.Get(ctx, d.NewKey(storage.DeploymentRevisionTagEntityName, name.String()), tag); d.IsNotFound(err) {
+		return name, nil
+	} else if err != nil {
+		return names.DeploymentRevision{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return name.Deployment().Revision(tag.RevisionID), nil
+}
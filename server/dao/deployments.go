@@ -0,0 +1,205 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeploymentList contains a page of deployment resources.
+type DeploymentList struct {
+	Deployments []models.Deployment
+	Token       string
+}
+
+var deploymentFields = []filtering.Field{
+	{Name: "name", Type: filtering.String},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "api_id", Type: filtering.String, StorageName: "ApiID"},
+	{Name: "deployment_id", Type: filtering.String, StorageName: "DeploymentID"},
+	{Name: "display_name", Type: filtering.String, StorageName: "DisplayName"},
+	{Name: "description", Type: filtering.String, StorageName: "Description"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "revision_create_time", Type: filtering.Timestamp, StorageName: "RevisionCreateTime"},
+	{Name: "revision_update_time", Type: filtering.Timestamp, StorageName: "RevisionUpdateTime"},
+	{Name: "api_spec_revision", Type: filtering.String, StorageName: "ApiSpecRevision"},
+	{Name: "endpoint_uri", Type: filtering.String, StorageName: "EndpointURI"},
+	{Name: "environment", Type: filtering.String, StorageName: "Environment"},
+	{Name: "gateway", Type: filtering.String, StorageName: "Gateway"},
+	{Name: "access_guidance", Type: filtering.String, StorageName: "AccessGuidance"},
+	{Name: "labels", Type: filtering.StringMap, StorageName: "Labels"},
+}
+
+func (d *DAO) ListDeployments(ctx context.Context, parent names.Api, opts PageOptions) (DeploymentList, error) {
+	token, err := decodeToken(opts.Token)
+	if err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
+	}
+
+	if err := token.ValidateFilter(opts.Filter); err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
+	} else {
+		token.Filter = opts.Filter
+	}
+
+	if parent.ProjectID != "-" && parent.ApiID != "-" {
+		if _, err := d.GetApi(ctx, parent); err != nil {
+			return DeploymentList{}, err
+		}
+	} else if parent.ProjectID != "-" && parent.ApiID == "-" {
+		if _, err := d.GetProject(ctx, parent.Project()); err != nil {
+			return DeploymentList{}, err
+		}
+	}
+
+	filter, err := filtering.NewFilter(opts.Filter, deploymentFields)
+	if err != nil {
+		return DeploymentList{}, err
+	}
+
+	q := d.NewQuery(storage.DeploymentEntityName)
+	if parent.ProjectID != "-" {
+		q = q.Require("ProjectID", parent.ProjectID)
+	}
+	if parent.ApiID != "-" {
+		q = q.Require("ApiID", parent.ApiID)
+	}
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, deploymentFields)
+	if err != nil {
+		return DeploymentList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
+	filter = applyFilter(q, filter, opts.Size)
+
+	it := d.GetRecentDeploymentRevisions(ctx, q)
+	response := DeploymentList{
+		Deployments: make([]models.Deployment, 0, opts.Size),
+	}
+
+	deployment := new(models.Deployment)
+	for _, err = it.Next(deployment); err == nil; _, err = it.Next(deployment) {
+		deploymentMap, err := deploymentMap(*deployment)
+		if err != nil {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+
+		match, err := filter.Matches(deploymentMap)
+		if err != nil {
+			return response, err
+		} else if !match {
+			token.LastKey, token.LastValues = deployment.Key, orderValues(deployment, orderings)
+			continue
+		} else if len(response.Deployments) == int(opts.Size) {
+			break
+		}
+
+		response.Deployments = append(response.Deployments, *deployment)
+		token.LastKey, token.LastValues = deployment.Key, orderValues(deployment, orderings)
+	}
+	if err != nil && err != iterator.Done {
+		return response, status.Error(codes.Internal, err.Error())
+	}
+
+	if err == nil {
+		response.Token, err = encodeToken(token)
+		if err != nil {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+func deploymentMap(deployment models.Deployment) (map[string]interface{}, error) {
+	labels, err := deployment.LabelsMap()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"name":                 deployment.Name(),
+		"project_id":           deployment.ProjectID,
+		"api_id":               deployment.ApiID,
+		"deployment_id":        deployment.DeploymentID,
+		"display_name":         deployment.DisplayName,
+		"description":          deployment.Description,
+		"revision_id":          deployment.RevisionID,
+		"create_time":          deployment.CreateTime,
+		"revision_create_time": deployment.RevisionCreateTime,
+		"revision_update_time": deployment.RevisionUpdateTime,
+		"api_spec_revision":    deployment.ApiSpecRevision,
+		"endpoint_uri":         deployment.EndpointURI,
+		"environment":          deployment.Environment,
+		"gateway":              deployment.Gateway,
+		"access_guidance":      deployment.AccessGuidance,
+		"labels":               labels,
+	}, nil
+}
+
+func (d *DAO) GetDeployment(ctx context.Context, name names.Deployment) (*models.Deployment, error) {
+	normal := name.Normal()
+	if err := d.checkParentNotDeleted(ctx, normal.ProjectID, normal.ApiID, "", ""); err != nil {
+		return nil, err
+	}
+
+	if err := d.lockParent(ctx, d.NewKey(storage.ApiEntityName, normal.Api().String()), new(models.Api)); err != nil {
+		return nil, err
+	}
+
+	q := d.NewQuery(storage.DeploymentEntityName)
+	q = q.Require("ProjectID", normal.ProjectID)
+	q = q.Require("ApiID", normal.ApiID)
+	q = q.Require("DeploymentID", normal.DeploymentID)
+	q = q.Descending("RevisionCreateTime")
+
+	it := d.run(ctx, q)
+	deployment := &models.Deployment{}
+	if _, err := it.Next(deployment); err == iterator.Done {
+		return nil, status.Errorf(codes.NotFound, "api deployment %q not found in database", name)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return deployment, nil
+}
+
+func (d *DAO) DeleteDeployment(ctx context.Context, name names.Deployment) error {
+	for _, kind := range []string{storage.DeploymentRevisionTagEntityName, storage.DeploymentEntityName} {
+		q := d.NewQuery(kind)
+		q = q.Require("ProjectID", name.ProjectID)
+		q = q.Require("ApiID", name.ApiID)
+		q = q.Require("DeploymentID", name.DeploymentID)
+		if err := d.DeleteAllMatches(ctx, q); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return nil
+}
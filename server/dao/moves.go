@@ -0,0 +1,300 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// apiChildKinds are the kinds of the entities that belong to an api and are copied with it.
+// Artifacts are copied before blobs, which use the hashes of their renamed contents.
+var apiChildKinds = []string{
+	storage.VersionEntityName,
+	storage.SpecEntityName,
+	storage.SpecRevisionTagEntityName,
+	storage.DeploymentEntityName,
+	storage.DeploymentRevisionTagEntityName,
+	storage.ArtifactEntityName,
+	storage.ArtifactRevisionEntityName,
+	storage.ArtifactRevisionTagEntityName,
+	models.BlobEntityName,
+}
+
+// messageMimeTypeRegexp matches the MIME types of artifacts that contain Protocol Buffer messages.
+var messageMimeTypeRegexp = regexp.MustCompile("^application/octet-stream;type=([A-Za-z0-9_.]+)$")
+
+// MoveApi moves an api and all of its children to a new name, as described for CopyApi.
+func (d *DAO) MoveApi(ctx context.Context, from, to names.Api) (*models.Api, error) {
+	api, err := d.CopyApi(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.DeleteApi(ctx, from); err != nil {
+		return nil, err
+	}
+	return api, nil
+}
+
+// CopyApi copies an api and all of its children to a new name, including revisions, revision tags
+// and deleted versions and specs that haven't been purged. Revisions keep their ids and timestamps.
+// The names of the api and its children are changed in deployments and in the contents of artifacts
+// with message types that are known to the server. Copies share the contents of their blobs,
+// unless their contents are changed. The caller must check that the new name isn't used.
+func (d *DAO) CopyApi(ctx context.Context, from, to names.Api) (*models.Api, error) {
+	api, err := d.GetApi(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	r := renamer{from: from.String(), to: to.String()}
+	api.ProjectID, api.ApiID = to.ProjectID, to.ApiID
+	api.RecommendedVersion = r.rename(api.RecommendedVersion)
+	if err := d.SaveApi(ctx, api); err != nil {
+		return nil, err
+	}
+
+	// Artifacts and their blobs are matched by key, because blobs don't have MIME types.
+	// Revisions often share contents, which are only renamed once for each MIME type.
+	renamedContents := make(map[string]*models.Blob)
+	renamedHashes := make(map[string]*models.Blob)
+	for _, kind := range apiChildKinds {
+		entities, err := d.apiChildren(ctx, kind, from)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		for _, v := range entities {
+			key := r.rename(stringField(v, "Key"))
+			reflect.ValueOf(v).Elem().FieldByName("ProjectID").SetString(to.ProjectID)
+			reflect.ValueOf(v).Elem().FieldByName("ApiID").SetString(to.ApiID)
+
+			switch e := v.(type) {
+			case *models.Deployment:
+				e.ApiSpecRevision = r.rename(e.ApiSpecRevision)
+			case *models.Artifact:
+				if renamed, err := d.renameContents(ctx, r, e.MimeType, e.Hash, renamedHashes); err != nil {
+					return nil, err
+				} else if renamed != nil {
+					e.Hash, e.SizeInBytes = renamed.Hash, renamed.SizeInBytes
+					renamedContents[key] = renamed
+				}
+			case *models.ArtifactRevision:
+				if renamed, err := d.renameContents(ctx, r, e.MimeType, e.Hash, renamedHashes); err != nil {
+					return nil, err
+				} else if renamed != nil {
+					e.Hash, e.SizeInBytes = renamed.Hash, renamed.SizeInBytes
+					renamedContents[key] = renamed
+				}
+			case *models.Blob:
+				if renamed, ok := renamedContents[key]; ok {
+					e.Hash, e.SizeInBytes = renamed.Hash, renamed.SizeInBytes
+				}
+			}
+
+			if _, err := d.Put(ctx, d.NewKey(kind, key), v); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+	}
+
+	return api, nil
+}
+
+// apiChildren returns all the entities of a kind that belong to an api.
+// They are read before any are saved, so that saved entities aren't read while iterating.
+func (d *DAO) apiChildren(ctx context.Context, kind string, api names.Api) ([]interface{}, error) {
+	q := d.NewQuery(kind)
+	q = q.Require("ProjectID", api.ProjectID)
+	q = q.Require("ApiID", api.ApiID)
+
+	entities := make([]interface{}, 0)
+	it := d.Run(ctx, q)
+	for {
+		v, err := newArchiveEntity(kind)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := it.Next(v); err == iterator.Done {
+			return entities, nil
+		} else if err != nil {
+			return nil, err
+		}
+		entities = append(entities, v)
+	}
+}
+
+// renameContents renames resources in the contents of an artifact with a known message type and saves
+// the renamed contents. It returns a blob with the hash and size of the renamed contents, or nil if the
+// contents aren't changed. Contents that can't be parsed are copied without changes. Results are cached
+// by hash and MIME type.
+func (d *DAO) renameContents(ctx context.Context, r renamer, mimeType, hash string, cache map[string]*models.Blob) (*models.Blob, error) {
+	m := messageMimeTypeRegexp.FindStringSubmatch(mimeType)
+	if m == nil || hash == "" {
+		return nil, nil
+	}
+
+	cacheKey := hash + ";" + mimeType
+	if blob, ok := cache[cacheKey]; ok {
+		return blob, nil
+	}
+
+	blob, err := d.renameMessage(ctx, r, protoreflect.FullName(m[1]), hash)
+	if err != nil {
+		return nil, err
+	}
+	cache[cacheKey] = blob
+	return blob, nil
+}
+
+// renameMessage renames resources in contents that contain a message of the named type.
+func (d *DAO) renameMessage(ctx context.Context, r renamer, messageName protoreflect.FullName, hash string) (*models.Blob, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(messageName)
+	if err != nil {
+		return nil, nil
+	}
+
+	blob := &models.Blob{Hash: hash}
+	if err := d.loadBlobContents(ctx, blob); err != nil {
+		return nil, err
+	}
+
+	message := mt.New()
+	if err := proto.Unmarshal(blob.Contents, message.Interface()); err != nil || !r.renameFields(message) {
+		return nil, nil
+	}
+
+	contents, err := proto.MarshalOptions{Deterministic: true}.Marshal(message.Interface())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	blob = &models.Blob{
+		Hash:        fmt.Sprintf("%x", sha256.Sum256(contents)),
+		SizeInBytes: int32(len(contents)),
+		Contents:    contents,
+	}
+	if err := d.saveContents(ctx, blob); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// renamer changes the name of an api and the names of its children in strings.
+type renamer struct {
+	from string
+	to   string
+}
+
+// rename replaces the api's name where it is a complete name or a prefix of a child's name.
+func (r renamer) rename(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, r.from)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		end := i + len(r.from)
+		if (i == 0 || !isNameChar(s[i-1])) && (end == len(s) || !isNameChar(s[end])) {
+			b.WriteString(s[:i])
+			b.WriteString(r.to)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+// isNameChar returns true for the characters that can be used in resource IDs.
+func isNameChar(c byte) bool {
+	return c == '-' || c == '.' || c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// renameFields renames resources in the string fields of a message and its submessages.
+// It returns true if any fields were changed.
+func (r renamer) renameFields(m protoreflect.Message) bool {
+	// Fields are collected first, because messages shouldn't be changed while they're ranged over.
+	fields := make([]protoreflect.FieldDescriptor, 0)
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+
+	changed := false
+	for _, fd := range fields {
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				if value, ok := r.renameValue(fd, list.Get(i)); ok {
+					list.Set(i, value)
+					changed = true
+				}
+			}
+		case fd.IsMap():
+			keys := make([]protoreflect.MapKey, 0)
+			v.Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			for _, k := range keys {
+				if value, ok := r.renameValue(fd.MapValue(), v.Map().Get(k)); ok {
+					v.Map().Set(k, value)
+					changed = true
+				}
+			}
+		default:
+			if value, ok := r.renameValue(fd, v); ok {
+				m.Set(fd, value)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// renameValue renames resources in a string or message value of a field.
+// It returns the renamed value and true if the value was changed.
+func (r renamer) renameValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, bool) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if s := r.rename(v.String()); s != v.String() {
+			return protoreflect.ValueOfString(s), true
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if r.renameFields(v.Message()) {
+			return v, true
+		}
+	}
+	return v, false
+}
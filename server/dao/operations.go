@@ -0,0 +1,160 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage/filtering"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OperationList contains a page of operations.
+type OperationList struct {
+	Operations []models.Operation
+	Token      string
+}
+
+var operationFields = []filtering.Field{
+	{Name: "name", Type: filtering.String},
+	{Name: "verb", Type: filtering.String, StorageName: "Verb"},
+	{Name: "target", Type: filtering.String, StorageName: "Target"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "end_time", Type: filtering.Timestamp, StorageName: "EndTime"},
+}
+
+func (d *DAO) ListOperations(ctx context.Context, opts PageOptions) (OperationList, error) {
+	q := d.NewQuery(models.OperationEntityName)
+
+	token, err := decodeToken(opts.Token)
+	if err != nil {
+		return OperationList{}, status.Errorf(codes.InvalidArgument, "invalid page token %q: %s", opts.Token, err.Error())
+	}
+
+	if err := token.ValidateFilter(opts.Filter); err != nil {
+		return OperationList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
+	} else {
+		token.Filter = opts.Filter
+	}
+	q = token.StartAfter(q)
+
+	filter, err := filtering.NewFilter(opts.Filter, operationFields)
+	if err != nil {
+		return OperationList{}, err
+	}
+	filter = applyFilter(q, filter, opts.Size)
+
+	it := d.Run(ctx, q)
+	response := OperationList{
+		Operations: make([]models.Operation, 0, opts.Size),
+	}
+
+	op := new(models.Operation)
+	for _, err = it.Next(op); err == nil; _, err = it.Next(op) {
+		match, err := filter.Matches(operationMap(*op))
+		if err != nil {
+			return response, err
+		} else if !match {
+			token.LastKey = op.Key
+			continue
+		} else if len(response.Operations) == int(opts.Size) {
+			break
+		}
+
+		response.Operations = append(response.Operations, *op)
+		token.LastKey = op.Key
+	}
+	if err != nil && err != iterator.Done {
+		return response, status.Error(codes.Internal, err.Error())
+	}
+
+	if err == nil {
+		response.Token, err = encodeToken(token)
+		if err != nil {
+			return response, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return response, nil
+}
+
+func operationMap(op models.Operation) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        op.Name(),
+		"verb":        op.Verb,
+		"target":      op.Target,
+		"create_time": op.CreateTime,
+		"end_time":    op.EndTime,
+	}
+}
+
+func (d *DAO) GetOperation(ctx context.Context, name string) (*models.Operation, error) {
+	op := new(models.Operation)
+	k := d.NewKey(models.OperationEntityName, name)
+	if err := d.Get(ctx, k, op); d.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found in database", name)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return op, nil
+}
+
+func (d *DAO) SaveOperation(ctx context.Context, op *models.Operation) error {
+	k := d.NewKey(models.OperationEntityName, op.Name())
+	if _, err := d.Put(ctx, k, op); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (d *DAO) DeleteOperation(ctx context.Context, name string) error {
+	k := d.NewKey(models.OperationEntityName, name)
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// DeleteOperationsBefore deletes the operations that finished before a time.
+// It returns the number of operations that were deleted.
+func (d *DAO) DeleteOperationsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	expired := make([]string, 0)
+	it := d.Run(ctx, d.NewQuery(models.OperationEntityName).Require("Done", true))
+	op := new(models.Operation)
+	var err error
+	for _, err = it.Next(op); err == nil; _, err = it.Next(op) {
+		if op.EndTime.Before(cutoff) {
+			expired = append(expired, op.Key)
+		}
+	}
+	if err != iterator.Done {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+
+	for i, name := range expired {
+		if err := d.DeleteOperation(ctx, name); err != nil {
+			return i, err
+		}
+	}
+
+	return len(expired), nil
+}
@@ -34,11 +34,11 @@ type ProjectList struct {
 
 var projectFields = []filtering.Field{
 	{Name: "name", Type: filtering.String},
-	{Name: "project_id", Type: filtering.String},
-	{Name: "display_name", Type: filtering.String},
-	{Name: "description", Type: filtering.String},
-	{Name: "create_time", Type: filtering.Timestamp},
-	{Name: "update_time", Type: filtering.Timestamp},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "display_name", Type: filtering.String, StorageName: "DisplayName"},
+	{Name: "description", Type: filtering.String, StorageName: "Description"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "update_time", Type: filtering.Timestamp, StorageName: "UpdateTime"},
 }
 
 func (d *DAO) ListProjects(ctx context.Context, opts PageOptions) (ProjectList, error) {
@@ -55,12 +55,13 @@ func (d *DAO) ListProjects(ctx context.Context, opts PageOptions) (ProjectList,
 		token.Filter = opts.Filter
 	}
 
-	q = q.ApplyOffset(token.Offset)
+	q = token.StartAfter(q)
 
 	filter, err := filtering.NewFilter(opts.Filter, projectFields)
 	if err != nil {
 		return ProjectList{}, err
 	}
+	filter = applyFilter(q, filter, opts.Size)
 
 	it := d.Run(ctx, q)
 	response := ProjectList{
@@ -73,14 +74,14 @@ func (d *DAO) ListProjects(ctx context.Context, opts PageOptions) (ProjectList,
 		if err != nil {
 			return response, err
 		} else if !match {
-			token.Offset++
+			token.LastKey = project.Key
 			continue
 		} else if len(response.Projects) == int(opts.Size) {
 			break
 		}
 
 		response.Projects = append(response.Projects, *project)
-		token.Offset++
+		token.LastKey = project.Key
 	}
 	if err != nil && err != iterator.Done {
 		return response, status.Error(codes.Internal, err.Error())
@@ -110,7 +111,7 @@ func projectMap(p models.Project) map[string]interface{} {
 func (d *DAO) GetProject(ctx context.Context, name names.Project) (*models.Project, error) {
 	project := new(models.Project)
 	k := d.NewKey(storage.ProjectEntityName, name.String())
-	if err := d.Get(ctx, k, project); d.IsNotFound(err) {
+	if err := d.get(ctx, k, project); d.IsNotFound(err) {
 		return nil, status.Errorf(codes.NotFound, "project %q not found in database", name)
 	} else if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
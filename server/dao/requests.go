@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/apigee/registry/server/models"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetRequest returns the completed request with the specified ID.
+func (d *DAO) GetRequest(ctx context.Context, id string) (*models.Request, error) {
+	request := new(models.Request)
+	k := d.NewKey(models.RequestEntityName, id)
+	if err := d.get(ctx, k, request); d.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "request %q not found in database", id)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return request, nil
+}
+
+// CreateRequest records a completed request unless a request with the same ID was recorded.
+// It reports whether the request was recorded.
+func (d *DAO) CreateRequest(ctx context.Context, request *models.Request) (bool, error) {
+	k := d.NewKey(models.RequestEntityName, request.Key)
+	created, err := d.Create(ctx, k, request)
+	if err != nil {
+		return false, status.Error(codes.Internal, err.Error())
+	}
+
+	return created, nil
+}
+
+// SaveRequest records a completed request, replacing any previous request with the same ID.
+func (d *DAO) SaveRequest(ctx context.Context, request *models.Request) error {
+	k := d.NewKey(models.RequestEntityName, request.Key)
+	if _, err := d.Put(ctx, k, request); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// requestDeletionBatchSize is the maximum number of expired requests that are read at once.
+const requestDeletionBatchSize = 100
+
+// DeleteRequestsBefore deletes the requests that were completed before a time.
+// Expired requests are read and deleted in batches, so that only a batch of
+// them is held in memory at once. It returns the number of requests that were deleted.
+func (d *DAO) DeleteRequestsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	deleted := 0
+	for {
+		expired := make([]string, 0, requestDeletionBatchSize)
+		q := d.NewQuery(models.RequestEntityName)
+		q = q.Before("CreateTime", cutoff)
+		q = q.ApplyLimit(requestDeletionBatchSize)
+		it := d.Run(ctx, q)
+		request := new(models.Request)
+		var err error
+		for _, err = it.Next(request); err == nil; _, err = it.Next(request) {
+			expired = append(expired, request.Key)
+		}
+		if err != iterator.Done {
+			return deleted, status.Error(codes.Internal, err.Error())
+		}
+
+		// Deleted requests no longer match the query, so the next batch begins with the requests that remain.
+		for _, id := range expired {
+			if err := d.Delete(ctx, d.NewKey(models.RequestEntityName, id)); err != nil {
+				return deleted, status.Error(codes.Internal, err.Error())
+			}
+			deleted++
+		}
+
+		if len(expired) < requestDeletionBatchSize {
+			return deleted, nil
+		}
+	}
+}
@@ -31,7 +31,9 @@ func (d *DAO) ListSpecRevisions(ctx context.Context, parent names.Spec, opts Pag
 	q = q.Require("ApiID", parent.ApiID)
 	q = q.Require("VersionID", parent.VersionID)
 	q = q.Require("SpecID", parent.SpecID)
-	q = q.Descending("RevisionCreateTime")
+	if !opts.ShowDeleted {
+		q = q.Require("Deleted", false)
+	}
 
 	token, err := decodeToken(opts.Token)
 	if err != nil {
@@ -42,7 +44,22 @@ func (d *DAO) ListSpecRevisions(ctx context.Context, parent names.Spec, opts Pag
 		return SpecList{}, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", opts.Filter, err)
 	}
 
-	q = q.ApplyOffset(token.Offset)
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return SpecList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, specFields)
+	if err != nil {
+		return SpecList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	if len(orderings) == 0 {
+		// Revisions are listed from newest to oldest by default.
+		orderings = []ordering{{StorageName: "RevisionCreateTime", Descending: true}}
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
 
 	it := d.Run(ctx, q)
 	response := SpecList{
@@ -51,7 +68,7 @@ func (d *DAO) ListSpecRevisions(ctx context.Context, parent names.Spec, opts Pag
 
 	revision := new(models.Spec)
 	for _, err = it.Next(revision); err == nil; _, err = it.Next(revision) {
-		token.Offset++
+		token.LastKey, token.LastValues = revision.Key, orderValues(revision, orderings)
 
 		response.Specs = append(response.Specs, *revision)
 		if len(response.Specs) == int(opts.Size) {
@@ -84,14 +101,62 @@ func (d *DAO) SaveSpecRevision(ctx context.Context, revision *models.Spec) error
 func (d *DAO) SaveSpecRevisionContents(ctx context.Context, spec *models.Spec, contents []byte) error {
 	blob := models.NewBlobForSpec(spec, contents)
 	k := d.NewKey(models.BlobEntityName, spec.RevisionName())
-	if _, err := d.Put(ctx, k, blob); err != nil {
-		return status.Error(codes.Internal, err.Error())
-	}
+	return d.saveBlob(ctx, k, blob)
+}
 
-	return nil
+// UpsertSpec saves the spec returned by update, which is called with the current
+// revision of the named spec. If the spec doesn't exist and allowMissing is true,
+// update is called with nil to create it. The contents are saved with the spec
+// when it is created or when update starts a new revision. The spec's version is
+// locked before the spec is read and saved in a single transaction, so concurrent
+// upserts of the same spec are applied one at a time, including upserts that
+// find the spec missing and create it. UpsertSpec reports whether the spec was created.
+func (d *DAO) UpsertSpec(ctx context.Context, name names.Spec, allowMissing bool, contents []byte, update func(*models.Spec) (*models.Spec, error)) (spec *models.Spec, created bool, err error) {
+	err = d.Transaction(ctx, func(ctx context.Context, db DAO) error {
+		db = db.ForUpdate()
+		var revisionID string
+		spec, err = db.GetSpec(ctx, name)
+		if allowMissing && isNotFound(err) {
+			if _, err := db.GetSpecIncludingDeleted(ctx, name); err == nil {
+				return status.Errorf(codes.AlreadyExists, "api spec %q was deleted and must be undeleted to be updated", name)
+			}
+		}
+		if allowMissing && status.Code(err) == codes.NotFound {
+			created = true
+		} else if err != nil {
+			return err
+		} else {
+			revisionID = spec.RevisionID
+		}
+
+		if spec, err = update(spec); err != nil {
+			return err
+		}
+		if err := db.SaveSpecRevision(ctx, spec); err != nil {
+			return err
+		}
+		if created || spec.RevisionID != revisionID {
+			return db.SaveSpecRevisionContents(ctx, spec, contents)
+		}
+		return nil
+	})
+	return spec, created, err
 }
 
+// GetSpecRevision returns the named spec revision. Revisions of deleted specs aren't found.
 func (d *DAO) GetSpecRevision(ctx context.Context, name names.SpecRevision) (*models.Spec, error) {
+	spec, err := d.GetSpecRevisionIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if spec.Deleted {
+		return nil, status.Errorf(codes.NotFound, "spec revision %q was deleted", name)
+	}
+
+	return spec, nil
+}
+
+// GetSpecRevisionIncludingDeleted returns the named spec revision, which may belong to a deleted spec.
+func (d *DAO) GetSpecRevisionIncludingDeleted(ctx context.Context, name names.SpecRevision) (*models.Spec, error) {
 	name, err := d.unwrapSpecRevisionTag(ctx, name)
 	if err != nil {
 		return nil, err
@@ -122,6 +187,10 @@ func (d *DAO) GetSpecRevisionContents(ctx context.Context, name names.SpecRevisi
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	if err := d.loadBlobContents(ctx, blob); err != nil {
+		return nil, err
+	}
+
 	return blob, nil
 }
 
@@ -132,8 +201,8 @@ func (d *DAO) DeleteSpecRevision(ctx context.Context, name names.SpecRevision) e
 	}
 
 	k := d.NewKey(models.BlobEntityName, name.String())
-	if err := d.Delete(ctx, k); err != nil {
-		return status.Error(codes.Internal, err.Error())
+	if err := d.deleteBlob(ctx, k); err != nil {
+		return err
 	}
 
 	k = d.NewKey(storage.SpecEntityName, name.String())
@@ -163,3 +232,34 @@ func (d *DAO) unwrapSpecRevisionTag(ctx context.Context, name names.SpecRevision
 
 	return name.Spec().Revision(tag.RevisionID), nil
 }
+
+// ListSpecRevisionTags returns the tags of all revisions of a spec.
+func (d *DAO) ListSpecRevisionTags(ctx context.Context, parent names.Spec) ([]models.SpecRevisionTag, error) {
+	q := d.NewQuery(storage.SpecRevisionTagEntityName)
+	q = q.Require("ProjectID", parent.ProjectID)
+	q = q.Require("ApiID", parent.ApiID)
+	q = q.Require("VersionID", parent.VersionID)
+	q = q.Require("SpecID", parent.SpecID)
+
+	it := d.Run(ctx, q)
+	tags := make([]models.SpecRevisionTag, 0)
+	tag := new(models.SpecRevisionTag)
+	var err error
+	for _, err = it.Next(tag); err == nil; _, err = it.Next(tag) {
+		tags = append(tags, *tag)
+	}
+	if err != nil && err != iterator.Done {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return tags, nil
+}
+
+func (d *DAO) DeleteSpecRevisionTag(ctx context.Context, tag *models.SpecRevisionTag) error {
+	k := d.NewKey(storage.SpecRevisionTagEntityName, tag.String())
+	if err := d.Delete(ctx, k); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
@@ -34,19 +34,19 @@ type SpecList struct {
 
 var specFields = []filtering.Field{
 	{Name: "name", Type: filtering.String},
-	{Name: "project_id", Type: filtering.String},
-	{Name: "api_id", Type: filtering.String},
-	{Name: "version_id", Type: filtering.String},
-	{Name: "spec_id", Type: filtering.String},
-	{Name: "filename", Type: filtering.String},
-	{Name: "description", Type: filtering.String},
-	{Name: "create_time", Type: filtering.Timestamp},
-	{Name: "revision_create_time", Type: filtering.Timestamp},
-	{Name: "revision_update_time", Type: filtering.Timestamp},
-	{Name: "mime_type", Type: filtering.String},
-	{Name: "size_bytes", Type: filtering.Int},
-	{Name: "source_uri", Type: filtering.String},
-	{Name: "labels", Type: filtering.StringMap},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "api_id", Type: filtering.String, StorageName: "ApiID"},
+	{Name: "version_id", Type: filtering.String, StorageName: "VersionID"},
+	{Name: "spec_id", Type: filtering.String, StorageName: "SpecID"},
+	{Name: "filename", Type: filtering.String, StorageName: "FileName"},
+	{Name: "description", Type: filtering.String, StorageName: "Description"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "revision_create_time", Type: filtering.Timestamp, StorageName: "RevisionCreateTime"},
+	{Name: "revision_update_time", Type: filtering.Timestamp, StorageName: "RevisionUpdateTime"},
+	{Name: "mime_type", Type: filtering.String, StorageName: "MimeType"},
+	{Name: "size_bytes", Type: filtering.Int, StorageName: "SizeInBytes"},
+	{Name: "source_uri", Type: filtering.String, StorageName: "SourceURI"},
+	{Name: "labels", Type: filtering.StringMap, StorageName: "Labels"},
 }
 
 func (d *DAO) ListSpecs(ctx context.Context, parent names.Version, opts PageOptions) (SpecList, error) {
@@ -80,7 +80,34 @@ func (d *DAO) ListSpecs(ctx context.Context, parent names.Version, opts PageOpti
 		return SpecList{}, err
 	}
 
-	it := d.GetRecentSpecRevisions(ctx, token.Offset, parent.ProjectID, parent.ApiID, parent.VersionID)
+	q := d.NewQuery(storage.SpecEntityName)
+	if parent.ProjectID != "-" {
+		q = q.Require("ProjectID", parent.ProjectID)
+	}
+	if parent.ApiID != "-" {
+		q = q.Require("ApiID", parent.ApiID)
+	}
+	if parent.VersionID != "-" {
+		q = q.Require("VersionID", parent.VersionID)
+	}
+	if !opts.ShowDeleted {
+		q = q.Require("Deleted", false)
+	}
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return SpecList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, specFields)
+	if err != nil {
+		return SpecList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
+	filter = applyFilter(q, filter, opts.Size)
+
+	it := d.GetRecentSpecRevisions(ctx, q)
 	response := SpecList{
 		Specs: make([]models.Spec, 0, opts.Size),
 	}
@@ -96,14 +123,14 @@ func (d *DAO) ListSpecs(ctx context.Context, parent names.Version, opts PageOpti
 		if err != nil {
 			return response, err
 		} else if !match {
-			token.Offset++
+			token.LastKey, token.LastValues = spec.Key, orderValues(spec, orderings)
 			continue
 		} else if len(response.Specs) == int(opts.Size) {
 			break
 		}
 
 		response.Specs = append(response.Specs, *spec)
-		token.Offset++
+		token.LastKey, token.LastValues = spec.Key, orderValues(spec, orderings)
 	}
 	if err != nil && err != iterator.Done {
 		return response, status.Error(codes.Internal, err.Error())
@@ -145,8 +172,25 @@ func specMap(spec models.Spec) (map[string]interface{}, error) {
 	}, nil
 }
 
+// GetSpec returns the most recent revision of the named spec. Deleted specs aren't found.
 func (d *DAO) GetSpec(ctx context.Context, name names.Spec) (*models.Spec, error) {
+	spec, err := d.GetSpecIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if spec.Deleted {
+		return nil, status.Errorf(codes.NotFound, "api spec %q was deleted", name)
+	}
+
+	return spec, nil
+}
+
+// GetSpecIncludingDeleted returns the most recent revision of the named spec, which may be deleted.
+func (d *DAO) GetSpecIncludingDeleted(ctx context.Context, name names.Spec) (*models.Spec, error) {
 	normal := name.Normal()
+	if err := d.lockParent(ctx, d.NewKey(storage.VersionEntityName, normal.Version().String()), new(models.Version)); err != nil {
+		return nil, err
+	}
+
 	q := d.NewQuery(storage.SpecEntityName)
 	q = q.Require("ProjectID", normal.ProjectID)
 	q = q.Require("ApiID", normal.ApiID)
@@ -154,7 +198,7 @@ func (d *DAO) GetSpec(ctx context.Context, name names.Spec) (*models.Spec, error
 	q = q.Require("SpecID", normal.SpecID)
 	q = q.Descending("RevisionCreateTime")
 
-	it := d.Run(ctx, q)
+	it := d.run(ctx, q)
 	spec := &models.Spec{}
 	if _, err := it.Next(spec); err != nil {
 		return nil, status.Error(codes.NotFound, err.Error())
@@ -34,15 +34,15 @@ type VersionList struct {
 
 var versionFields = []filtering.Field{
 	{Name: "name", Type: filtering.String},
-	{Name: "project_id", Type: filtering.String},
-	{Name: "api_id", Type: filtering.String},
-	{Name: "version_id", Type: filtering.String},
-	{Name: "display_name", Type: filtering.String},
-	{Name: "description", Type: filtering.String},
-	{Name: "create_time", Type: filtering.Timestamp},
-	{Name: "update_time", Type: filtering.Timestamp},
-	{Name: "state", Type: filtering.String},
-	{Name: "labels", Type: filtering.StringMap},
+	{Name: "project_id", Type: filtering.String, StorageName: "ProjectID"},
+	{Name: "api_id", Type: filtering.String, StorageName: "ApiID"},
+	{Name: "version_id", Type: filtering.String, StorageName: "VersionID"},
+	{Name: "display_name", Type: filtering.String, StorageName: "DisplayName"},
+	{Name: "description", Type: filtering.String, StorageName: "Description"},
+	{Name: "create_time", Type: filtering.Timestamp, StorageName: "CreateTime"},
+	{Name: "update_time", Type: filtering.Timestamp, StorageName: "UpdateTime"},
+	{Name: "state", Type: filtering.String, StorageName: "State"},
+	{Name: "labels", Type: filtering.StringMap, StorageName: "Labels"},
 }
 
 func (d *DAO) ListVersions(ctx context.Context, parent names.Api, opts PageOptions) (VersionList, error) {
@@ -59,7 +59,18 @@ func (d *DAO) ListVersions(ctx context.Context, parent names.Api, opts PageOptio
 		token.Filter = opts.Filter
 	}
 
-	q = q.ApplyOffset(token.Offset)
+	if err := token.ValidateOrder(opts.OrderBy); err != nil {
+		return VersionList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	} else {
+		token.OrderBy = opts.OrderBy
+	}
+
+	orderings, err := parseOrderBy(opts.OrderBy, versionFields)
+	if err != nil {
+		return VersionList{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q: %s", opts.OrderBy, err)
+	}
+	q = applyOrder(q, orderings)
+	q = token.StartAfter(q)
 
 	if parent.ProjectID != "-" {
 		q = q.Require("ProjectID", parent.ProjectID)
@@ -76,11 +87,15 @@ func (d *DAO) ListVersions(ctx context.Context, parent names.Api, opts PageOptio
 			return VersionList{}, err
 		}
 	}
+	if !opts.ShowDeleted {
+		q = q.Require("Deleted", false)
+	}
 
 	filter, err := filtering.NewFilter(opts.Filter, versionFields)
 	if err != nil {
 		return VersionList{}, err
 	}
+	filter = applyFilter(q, filter, opts.Size)
 
 	it := d.Run(ctx, q)
 	response := VersionList{
@@ -98,14 +113,14 @@ func (d *DAO) ListVersions(ctx context.Context, parent names.Api, opts PageOptio
 		if err != nil {
 			return response, err
 		} else if !match {
-			token.Offset++
+			token.LastKey, token.LastValues = version.Key, orderValues(version, orderings)
 			continue
 		} else if len(response.Versions) == int(opts.Size) {
 			break
 		}
 
 		response.Versions = append(response.Versions, *version)
-		token.Offset++
+		token.LastKey, token.LastValues = version.Key, orderValues(version, orderings)
 	}
 	if err != nil && err != iterator.Done {
 		return response, status.Error(codes.Internal, err.Error())
@@ -140,10 +155,23 @@ func versionMap(version models.Version) (map[string]interface{}, error) {
 	}, nil
 }
 
+// GetVersion returns the named version. Deleted versions aren't found.
 func (d *DAO) GetVersion(ctx context.Context, name names.Version) (*models.Version, error) {
+	version, err := d.GetVersionIncludingDeleted(ctx, name)
+	if err != nil {
+		return nil, err
+	} else if version.Deleted {
+		return nil, status.Errorf(codes.NotFound, "api version %q was deleted", name)
+	}
+
+	return version, nil
+}
+
+// GetVersionIncludingDeleted returns the named version, which may be deleted.
+func (d *DAO) GetVersionIncludingDeleted(ctx context.Context, name names.Version) (*models.Version, error) {
 	version := new(models.Version)
 	k := d.NewKey(storage.VersionEntityName, name.String())
-	if err := d.Get(ctx, k, version); d.IsNotFound(err) {
+	if err := d.get(ctx, k, version); d.IsNotFound(err) {
 		return nil, status.Errorf(codes.NotFound, "api version %q not found in database", name)
 	} else if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -161,6 +189,41 @@ func (d *DAO) SaveVersion(ctx context.Context, version *models.Version) error {
 	return nil
 }
 
+// UpsertVersion saves the version returned by update, which is called with the current
+// state of the named version. If the version doesn't exist and allowMissing is true,
+// update is called with nil to create it. The version is locked while it is read and
+// saved in a single transaction, so concurrent upserts of the same version are applied
+// one at a time. When allowMissing is true, its api is locked first, so that concurrent
+// upserts can't all find the version missing and create it.
+// UpsertVersion reports whether the version was created.
+func (d *DAO) UpsertVersion(ctx context.Context, name names.Version, allowMissing bool, update func(*models.Version) (*models.Version, error)) (version *models.Version, created bool, err error) {
+	err = d.Transaction(ctx, func(ctx context.Context, db DAO) error {
+		db = db.ForUpdate()
+		if allowMissing {
+			if err := db.lockParent(ctx, db.NewKey(storage.ApiEntityName, name.Api().String()), new(models.Api)); err != nil {
+				return err
+			}
+		}
+		version, err = db.GetVersion(ctx, name)
+		if allowMissing && isNotFound(err) {
+			if _, err := db.GetVersionIncludingDeleted(ctx, name); err == nil {
+				return status.Errorf(codes.AlreadyExists, "api version %q was deleted and must be undeleted to be updated", name)
+			}
+		}
+		if allowMissing && status.Code(err) == codes.NotFound {
+			created = true
+		} else if err != nil {
+			return err
+		}
+
+		if version, err = update(version); err != nil {
+			return err
+		}
+		return db.SaveVersion(ctx, version)
+	})
+	return version, created, err
+}
+
 func (d *DAO) DeleteVersion(ctx context.Context, name names.Version) error {
 	if err := d.DeleteChildrenOfVersion(ctx, name); err != nil {
 		return status.Error(codes.Internal, err.Error())
@@ -0,0 +1,90 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/apigee/registry/server/dao"
+)
+
+// defaultPurgeAfter is how long deleted resources are kept if no purge delay is configured.
+const defaultPurgeAfter = 30 * 24 * time.Hour
+
+// purgeInterval is the longest time between purges of deleted resources.
+const purgeInterval = time.Hour
+
+// purgeDelay returns how long deleted resources are kept before they are purged.
+func (s *RegistryServer) purgeDelay() time.Duration {
+	if s.purgeAfter > 0 {
+		return s.purgeAfter
+	}
+	return defaultPurgeAfter
+}
+
+// deletionTimes returns the delete and purge times of a resource that is deleted now.
+func (s *RegistryServer) deletionTimes() (deleteTime, purgeTime time.Time) {
+	deleteTime = time.Now()
+	return deleteTime, deleteTime.Add(s.purgeDelay())
+}
+
+// purgeDeleted periodically purges deleted resources whose purge time has passed
+// until the context is cancelled.
+func (s *RegistryServer) purgeDeleted(ctx context.Context) {
+	interval := s.purgeDelay()
+	if interval > purgeInterval {
+		interval = purgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.purge(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to purge deleted resources: %s", err)
+		}
+	}
+}
+
+// purge permanently deletes the resources whose purge time is before now
+// and the operations that finished before the operation retention period.
+func (s *RegistryServer) purge(ctx context.Context, now time.Time) error {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	db := dao.NewDAO(client, s.blobs)
+	return db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+		purged, err := db.PurgeDeleted(ctx, now)
+		if err != nil {
+			return err
+		}
+		if len(purged) > 0 && s.loggingLevel >= loggingInfo {
+			log.Printf("Purged %d deleted resources", len(purged))
+		}
+
+		// Finished operations are kept for a fixed time.
+		_, err = db.DeleteOperationsBefore(ctx, now.Add(-operationRetention))
+		return err
+	})
+}
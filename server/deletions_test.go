@@ -0,0 +1,196 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// listApiNames returns the names of the APIs in a project.
+func listApiNames(ctx context.Context, t *testing.T, s *RegistryServer, showDeleted bool) []string {
+	t.Helper()
+
+	req := &rpc.ListApisRequest{Parent: "projects/my-project", ShowDeleted: showDeleted}
+	got, err := s.ListApis(ctx, req)
+	if err != nil {
+		t.Fatalf("ListApis(%+v) returned error: %s", req, err)
+	}
+
+	apiNames := make([]string, 0, len(got.GetApis()))
+	for _, api := range got.GetApis() {
+		apiNames = append(apiNames, api.GetName())
+	}
+	return apiNames
+}
+
+func TestSoftDeleteApi(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/other-api"})
+	seedArtifacts(ctx, t, server, &rpc.Artifact{Name: "projects/my-project/apis/my-api/versions/v1/artifacts/my-artifact"})
+
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/my-api"}); err != nil {
+		t.Fatalf("DeleteApi returned error: %s", err)
+	}
+
+	if got, want := listApiNames(ctx, t, server, false), []string{"projects/my-project/apis/other-api"}; !cmp.Equal(want, got) {
+		t.Errorf("ListApis() returned unexpected APIs (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if got, want := listApiNames(ctx, t, server, true), []string{"projects/my-project/apis/my-api", "projects/my-project/apis/other-api"}; !cmp.Equal(want, got) {
+		t.Errorf("ListApis(show_deleted) returned unexpected APIs (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Children are deleted with the API.
+	api, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("GetApi returned error: %s", err)
+	}
+	spec, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+	if err != nil {
+		t.Fatalf("GetApiSpec returned error: %s", err)
+	} else if !spec.GetDeleteTime().AsTime().Equal(api.GetDeleteTime().AsTime()) {
+		t.Errorf("GetApiSpec returned delete_time %v, want %v", spec.GetDeleteTime(), api.GetDeleteTime())
+	}
+	if _, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: "projects/my-project/apis/my-api/versions/v1/artifacts/my-artifact"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetArtifact returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+
+	// Names of deleted APIs can't be reused.
+	createReq := &rpc.CreateApiRequest{Parent: "projects/my-project", ApiId: "my-api", Api: &rpc.Api{}}
+	if _, err := server.CreateApi(ctx, createReq); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateApi(%+v) returned status code %q, want %q: %v", createReq, status.Code(err), codes.AlreadyExists, err)
+	}
+	updateReq := &rpc.UpdateApiRequest{Api: &rpc.Api{Name: "projects/my-project/apis/my-api"}, AllowMissing: true}
+	if _, err := server.UpdateApi(ctx, updateReq); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("UpdateApi(%+v) returned status code %q, want %q: %v", updateReq, status.Code(err), codes.AlreadyExists, err)
+	}
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/my-api"}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteApi returned status code %q, want %q: %v", status.Code(err), codes.NotFound, err)
+	}
+
+	undeleted, err := server.UndeleteApi(ctx, &rpc.UndeleteApiRequest{Name: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("UndeleteApi returned error: %s", err)
+	} else if undeleted.GetDeleteTime() != nil || undeleted.GetPurgeTime() != nil {
+		t.Errorf("UndeleteApi returned delete_time %v and purge_time %v, want neither", undeleted.GetDeleteTime(), undeleted.GetPurgeTime())
+	}
+
+	if spec, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"}); err != nil {
+		t.Fatalf("GetApiSpec returned error: %s", err)
+	} else if spec.GetDeleteTime() != nil {
+		t.Errorf("GetApiSpec returned delete_time %v, want the spec to be undeleted", spec.GetDeleteTime())
+	}
+	if _, err := server.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: "projects/my-project/apis/my-api/versions/v1/artifacts/my-artifact"}); err != nil {
+		t.Errorf("GetArtifact returned error: %s", err)
+	}
+
+	if _, err := server.UndeleteApi(ctx, &rpc.UndeleteApiRequest{Name: "projects/my-project/apis/my-api"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UndeleteApi returned status code %q, want %q: %v", status.Code(err), codes.FailedPrecondition, err)
+	}
+}
+
+func TestUndeleteApiKeepsDeletedChildren(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server,
+		&rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/deleted"},
+		&rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/kept"},
+	)
+
+	if _, err := server.DeleteApiSpec(ctx, &rpc.DeleteApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/deleted"}); err != nil {
+		t.Fatalf("DeleteApiSpec returned error: %s", err)
+	}
+	// Deletion times must differ for the spec to be distinguished from those deleted with the API.
+	time.Sleep(10 * time.Millisecond)
+	if _, err := server.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: "projects/my-project/apis/my-api"}); err != nil {
+		t.Fatalf("DeleteApi returned error: %s", err)
+	}
+
+	// Children can't be undeleted before their parents.
+	versionReq := &rpc.UndeleteApiVersionRequest{Name: "projects/my-project/apis/my-api/versions/v1"}
+	if _, err := server.UndeleteApiVersion(ctx, versionReq); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UndeleteApiVersion(%+v) returned status code %q, want %q: %v", versionReq, status.Code(err), codes.FailedPrecondition, err)
+	}
+
+	if _, err := server.UndeleteApi(ctx, &rpc.UndeleteApiRequest{Name: "projects/my-project/apis/my-api"}); err != nil {
+		t.Fatalf("UndeleteApi returned error: %s", err)
+	}
+
+	listReq := &rpc.ListApiSpecsRequest{Parent: "projects/my-project/apis/my-api/versions/v1"}
+	listing, err := server.ListApiSpecs(ctx, listReq)
+	if err != nil {
+		t.Fatalf("ListApiSpecs(%+v) returned error: %s", listReq, err)
+	}
+	got := make([]string, 0)
+	for _, spec := range listing.GetApiSpecs() {
+		got = append(got, spec.GetName())
+	}
+	if want := []string{"projects/my-project/apis/my-api/versions/v1/specs/kept"}; !cmp.Equal(want, got) {
+		t.Errorf("ListApiSpecs(%+v) returned unexpected specs (-want +got):\n%s", listReq, cmp.Diff(want, got))
+	}
+
+	specReq := &rpc.UndeleteApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/deleted"}
+	if _, err := server.UndeleteApiSpec(ctx, specReq); err != nil {
+		t.Errorf("UndeleteApiSpec(%+v) returned error: %s", specReq, err)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+
+	if _, err := server.DeleteApiVersion(ctx, &rpc.DeleteApiVersionRequest{Name: "projects/my-project/apis/my-api/versions/v1"}); err != nil {
+		t.Fatalf("DeleteApiVersion returned error: %s", err)
+	}
+
+	// Deleted resources are kept until their purge time.
+	if err := server.purge(ctx, time.Now()); err != nil {
+		t.Fatalf("purge returned error: %s", err)
+	}
+	getReq := &rpc.GetApiVersionRequest{Name: "projects/my-project/apis/my-api/versions/v1"}
+	if _, err := server.GetApiVersion(ctx, getReq); err != nil {
+		t.Errorf("GetApiVersion(%+v) returned error: %s", getReq, err)
+	}
+
+	if err := server.purge(ctx, time.Now().Add(server.purgeDelay()+time.Minute)); err != nil {
+		t.Fatalf("purge returned error: %s", err)
+	}
+	if _, err := server.GetApiVersion(ctx, getReq); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiVersion(%+v) returned status code %q, want %q: %v", getReq, status.Code(err), codes.NotFound, err)
+	}
+	specReq := &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"}
+	if _, err := server.GetApiSpec(ctx, specReq); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApiSpec(%+v) returned status code %q, want %q: %v", specReq, status.Code(err), codes.NotFound, err)
+	}
+
+	// Names of purged resources can be reused.
+	createReq := &rpc.CreateApiVersionRequest{
+		Parent:       "projects/my-project/apis/my-api",
+		ApiVersionId: "v1",
+		ApiVersion:   &rpc.ApiVersion{},
+	}
+	if _, err := server.CreateApiVersion(ctx, createReq); err != nil {
+		t.Errorf("CreateApiVersion(%+v) returned error: %s", createReq, err)
+	}
+}
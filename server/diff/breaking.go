@@ -0,0 +1,150 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+
+	"github.com/apigee/registry/rpc"
+)
+
+// rule classifies changes that it matches.
+type rule struct {
+	id             string
+	classification rpc.ClassifiedChange_Classification
+	matches        func(c *rpc.ApiSpecChange) bool
+}
+
+func added(elements ...string) func(c *rpc.ApiSpecChange) bool {
+	return changed(rpc.ApiSpecChange_ADDED, elements...)
+}
+
+func removed(elements ...string) func(c *rpc.ApiSpecChange) bool {
+	return changed(rpc.ApiSpecChange_REMOVED, elements...)
+}
+
+func changed(kind rpc.ApiSpecChange_Kind, elements ...string) func(c *rpc.ApiSpecChange) bool {
+	return func(c *rpc.ApiSpecChange) bool {
+		if c.GetKind() != kind {
+			return false
+		}
+		for _, e := range elements {
+			if c.GetElement() == e {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func modified(attribute string, values func(old, new string) bool) func(c *rpc.ApiSpecChange) bool {
+	return func(c *rpc.ApiSpecChange) bool {
+		return c.GetKind() == rpc.ApiSpecChange_MODIFIED && c.GetAttribute() == attribute &&
+			(values == nil || values(c.GetOldValue(), c.GetNewValue()))
+	}
+}
+
+func addedRequired(elements ...string) func(c *rpc.ApiSpecChange) bool {
+	isAdded := added(elements...)
+	return func(c *rpc.ApiSpecChange) bool {
+		return isAdded(c) && c.GetAttributes()["required"] == "true"
+	}
+}
+
+func to(value string) func(old, new string) bool {
+	return func(old, new string) bool { return new == value }
+}
+
+func involving(values ...string) func(old, new string) bool {
+	return func(old, new string) bool {
+		for _, v := range values {
+			if old == v || new == v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// rules are applied in order, and the first rule that matches a change classifies it.
+var rules = []rule{
+	{"removed-path", rpc.ClassifiedChange_BREAKING, removed("path")},
+	{"removed-operation", rpc.ClassifiedChange_BREAKING, removed("operation")},
+	{"removed-parameter", rpc.ClassifiedChange_BREAKING, removed("parameter")},
+	{"removed-request-body", rpc.ClassifiedChange_BREAKING, removed("request_body")},
+	{"removed-response", rpc.ClassifiedChange_BREAKING, removed("response", "media_type")},
+	{"removed-schema", rpc.ClassifiedChange_BREAKING, removed("schema", "message", "enum")},
+	{"removed-field", rpc.ClassifiedChange_BREAKING, removed("property", "field")},
+	{"removed-enum-value", rpc.ClassifiedChange_BREAKING, removed("enum_value")},
+	{"removed-service", rpc.ClassifiedChange_BREAKING, removed("service")},
+	{"removed-rpc", rpc.ClassifiedChange_BREAKING, removed("rpc")},
+	{"new-required-parameter", rpc.ClassifiedChange_BREAKING, addedRequired("parameter", "request_body")},
+	{"new-required-property", rpc.ClassifiedChange_BREAKING, addedRequired("property")},
+	{"added-element", rpc.ClassifiedChange_NON_BREAKING, added(
+		"path", "operation", "parameter", "request_body", "response", "media_type", "schema", "property",
+		"enum_value", "message", "field", "enum", "service", "rpc")},
+	{"changed-type", rpc.ClassifiedChange_BREAKING, modified("type", nil)},
+	{"changed-format", rpc.ClassifiedChange_BREAKING, modified("format", nil)},
+	{"changed-field-number", rpc.ClassifiedChange_BREAKING, modified("number", nil)},
+	{"changed-rpc-signature", rpc.ClassifiedChange_BREAKING, func(c *rpc.ApiSpecChange) bool {
+		return modified("request", nil)(c) || modified("response", nil)(c)
+	}},
+	{"changed-cardinality", rpc.ClassifiedChange_BREAKING, modified("label", involving("repeated", "required"))},
+	{"changed-presence", rpc.ClassifiedChange_NON_BREAKING, modified("label", nil)},
+	{"now-required", rpc.ClassifiedChange_BREAKING, modified("required", to("true"))},
+	{"now-optional", rpc.ClassifiedChange_NON_BREAKING, modified("required", to("false"))},
+	{"changed-deprecation", rpc.ClassifiedChange_NON_BREAKING, modified("deprecated", nil)},
+}
+
+// Classify classifies the changes in a structured comparison of two revisions.
+// Changes that no rule matches are classified as unknown.
+func Classify(d *rpc.DiffApiSpecRevisionsResponse) (*rpc.BreakingChanges, error) {
+	if d.GetFormat() == Text {
+		return nil, fmt.Errorf("cannot classify changes from %s to %s, which aren't in the same structured format",
+			d.GetBaseRevisionName(), d.GetRevisionName())
+	}
+
+	result := &rpc.BreakingChanges{
+		BaseRevisionName: d.GetBaseRevisionName(),
+		RevisionName:     d.GetRevisionName(),
+		Changes:          make([]*rpc.ClassifiedChange, 0, len(d.GetChanges())),
+	}
+
+	for _, c := range d.GetChanges() {
+		classified := &rpc.ClassifiedChange{
+			Classification: rpc.ClassifiedChange_UNKNOWN,
+			Change:         c,
+		}
+		for _, r := range rules {
+			if r.matches(c) {
+				classified.Classification = r.classification
+				classified.Rule = r.id
+				break
+			}
+		}
+
+		switch classified.Classification {
+		case rpc.ClassifiedChange_BREAKING:
+			result.BreakingCount++
+		case rpc.ClassifiedChange_NON_BREAKING:
+			result.NonBreakingCount++
+		default:
+			result.UnknownCount++
+		}
+		result.Changes = append(result.Changes, classified)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		change *rpc.ApiSpecChange
+		want   rpc.ClassifiedChange_Classification
+		rule   string
+	}{
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_REMOVED, Element: "field", Location: "example.Book.title"},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "removed-field",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_REMOVED, Element: "enum_value", Location: "example.Book.Format.EBOOK"},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "removed-enum-value",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "field", Location: "example.Book.title", Attribute: "type", OldValue: "string", NewValue: "int32"},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "changed-type",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_ADDED, Element: "parameter", Location: "paths./pets.get.parameters.query.limit", Attributes: map[string]string{"required": "true"}},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "new-required-parameter",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_ADDED, Element: "parameter", Location: "paths./pets.get.parameters.query.limit", Attributes: map[string]string{"required": "false"}},
+			want:   rpc.ClassifiedChange_NON_BREAKING,
+			rule:   "added-element",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "parameter", Location: "paths./pets.get.parameters.query.limit", Attribute: "required", OldValue: "false", NewValue: "true"},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "now-required",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "property", Location: "schemas.Pet.properties.name", Attribute: "required", OldValue: "true", NewValue: "false"},
+			want:   rpc.ClassifiedChange_NON_BREAKING,
+			rule:   "now-optional",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "field", Location: "example.Book.author", Attribute: "label", OldValue: "", NewValue: "repeated"},
+			want:   rpc.ClassifiedChange_BREAKING,
+			rule:   "changed-cardinality",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "field", Location: "example.Book.author", Attribute: "label", OldValue: "", NewValue: "optional"},
+			want:   rpc.ClassifiedChange_NON_BREAKING,
+			rule:   "changed-presence",
+		},
+		{
+			change: &rpc.ApiSpecChange{Kind: rpc.ApiSpecChange_MODIFIED, Element: "operation", Location: "paths./pets.get", Attribute: "operation_id", OldValue: "listPets", NewValue: "getPets"},
+			want:   rpc.ClassifiedChange_UNKNOWN,
+		},
+	}
+
+	changes := make([]*rpc.ApiSpecChange, 0, len(tests))
+	for _, test := range tests {
+		changes = append(changes, test.change)
+	}
+
+	got, err := Classify(&rpc.DiffApiSpecRevisionsResponse{Format: Proto, Changes: changes})
+	if err != nil {
+		t.Fatalf("Classify() returned error: %s", err)
+	}
+
+	for i, test := range tests {
+		c := got.GetChanges()[i]
+		if c.GetClassification() != test.want || c.GetRule() != test.rule {
+			t.Errorf("Classify() classified %v as %s by rule %q, want %s by rule %q", test.change, c.GetClassification(), c.GetRule(), test.want, test.rule)
+		}
+	}
+
+	if got.GetBreakingCount() != 6 || got.GetNonBreakingCount() != 3 || got.GetUnknownCount() != 1 {
+		t.Errorf("Classify() returned counts %d/%d/%d, want 6/3/1", got.GetBreakingCount(), got.GetNonBreakingCount(), got.GetUnknownCount())
+	}
+}
+
+func TestClassifyText(t *testing.T) {
+	if _, err := Classify(&rpc.DiffApiSpecRevisionsResponse{Format: Text, UnifiedDiff: "-a\n+b\n"}); err == nil {
+		t.Errorf("Classify() succeeded for a text comparison, want error")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares revisions of API descriptions.
+//
+// OpenAPI descriptions and Protocol Buffer descriptions are reduced to
+// elements, such as operations or fields, which are identified by their
+// location in the description and compared by their attributes.
+// Descriptions in other formats are compared line by line.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry/rpc"
+)
+
+// Formats of descriptions that can be compared.
+const (
+	OpenAPI = "openapi"
+	Proto   = "proto"
+	Text    = "text"
+)
+
+// maxContentsSize is the largest size in bytes of the contents of revisions that are compared.
+const maxContentsSize = 10 << 20
+
+// ErrTooLarge is returned when revisions are too large or too different to be compared.
+var ErrTooLarge = errors.New("revisions are too large to compare")
+
+// Description is a revision of an API description.
+type Description struct {
+	// Name identifies the revision in the comparison.
+	Name string
+	// MimeType is the uncompressed MIME type of the contents.
+	MimeType string
+	// Contents are the uncompressed contents of the description.
+	Contents []byte
+}
+
+// Format returns the format that is used to compare descriptions with a MIME type.
+func Format(mimeType string) string {
+	switch {
+	case strings.Contains(mimeType, "openapi"):
+		return OpenAPI
+	case strings.Contains(mimeType, "proto"):
+		return Proto
+	default:
+		return Text
+	}
+}
+
+// Diff compares a revision with a base revision.
+// Revisions are compared as text if they have different formats.
+// It returns ErrTooLarge if either revision is larger than maxContentsSize.
+func Diff(base, revision Description) (*rpc.DiffApiSpecRevisionsResponse, error) {
+	for _, d := range []Description{base, revision} {
+		if len(d.Contents) > maxContentsSize {
+			return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, d.Name, maxContentsSize)
+		}
+	}
+
+	response := &rpc.DiffApiSpecRevisionsResponse{
+		BaseRevisionName: base.Name,
+		RevisionName:     revision.Name,
+		Format:           Format(revision.MimeType),
+		Changes:          make([]*rpc.ApiSpecChange, 0),
+	}
+
+	if Format(base.MimeType) != response.Format {
+		response.Format = Text
+	}
+
+	var parse func(Description) (elements, error)
+	switch response.Format {
+	case OpenAPI:
+		parse = openAPIElements
+	case Proto:
+		parse = protoElements
+	default:
+		unified, err := Unified(base, revision)
+		if err != nil {
+			return nil, err
+		}
+		response.UnifiedDiff = unified
+		return response, nil
+	}
+
+	before, err := parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := parse(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Changes = compare(before, after)
+	return response, nil
+}
+
+// element is a part of a description that is compared with the same part of another revision.
+type element struct {
+	// kind is the type of the element, such as "operation" or "field".
+	kind string
+	// parent is the location of the element that contains this one, if any.
+	parent string
+	// attrs are the compared properties of the element.
+	attrs map[string]string
+}
+
+// elements are the parts of a description, keyed by location.
+type elements map[string]*element
+
+func (e elements) add(location, parent, kind string, attrs map[string]string) {
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	e[location] = &element{kind: kind, parent: parent, attrs: attrs}
+}
+
+// compare returns the changes from one set of elements to another, ordered by location.
+// Elements that were added or removed with their parents aren't reported separately.
+func compare(before, after elements) []*rpc.ApiSpecChange {
+	locations := make([]string, 0, len(before)+len(after))
+	for l := range before {
+		locations = append(locations, l)
+	}
+	for l := range after {
+		if _, ok := before[l]; !ok {
+			locations = append(locations, l)
+		}
+	}
+	sort.Strings(locations)
+
+	changes := make([]*rpc.ApiSpecChange, 0)
+	for _, l := range locations {
+		b, inBefore := before[l]
+		a, inAfter := after[l]
+		switch {
+		case !inAfter:
+			if _, ok := after[b.parent]; b.parent != "" && !ok {
+				continue
+			}
+			changes = append(changes, &rpc.ApiSpecChange{
+				Kind:       rpc.ApiSpecChange_REMOVED,
+				Element:    b.kind,
+				Location:   l,
+				Attributes: b.attrs,
+			})
+		case !inBefore:
+			if _, ok := before[a.parent]; a.parent != "" && !ok {
+				continue
+			}
+			changes = append(changes, &rpc.ApiSpecChange{
+				Kind:       rpc.ApiSpecChange_ADDED,
+				Element:    a.kind,
+				Location:   l,
+				Attributes: a.attrs,
+			})
+		default:
+			for _, attr := range attributeNames(b, a) {
+				if b.attrs[attr] != a.attrs[attr] {
+					changes = append(changes, &rpc.ApiSpecChange{
+						Kind:      rpc.ApiSpecChange_MODIFIED,
+						Element:   a.kind,
+						Location:  l,
+						Attribute: attr,
+						OldValue:  b.attrs[attr],
+						NewValue:  a.attrs[attr],
+					})
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// attributeNames returns the sorted names of the attributes of either element.
+func attributeNames(x, y *element) []string {
+	names := make([]string, 0, len(x.attrs)+len(y.attrs))
+	for n := range x.attrs {
+		names = append(names, n)
+	}
+	for n := range y.attrs {
+		if _, ok := x.attrs[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
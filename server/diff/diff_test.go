@@ -0,0 +1,431 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func zipProtos(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Setup: failed to create %s: %s", name, err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatalf("Setup: failed to write %s: %s", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Setup: failed to close archive: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffOpenAPIv2(t *testing.T) {
+	base := Description{
+		Name:     "base",
+		MimeType: "application/x.openapi;version=2",
+		Contents: []byte(`swagger: "2.0"
+info: {title: Petstore, version: "1.0.0"}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - {name: limit, in: query, type: integer, format: int32}
+        - {name: status, in: query, type: string, enum: [available, sold]}
+      responses:
+        "200": {description: ok, schema: {type: array, items: {$ref: "#/definitions/Pet"}}}
+    post:
+      operationId: createPet
+      responses:
+        "201": {description: created}
+  /pets/{id}:
+    delete:
+      parameters:
+        - {name: id, in: path, required: true, type: string}
+      responses:
+        "204": {description: deleted}
+definitions:
+  Pet:
+    type: object
+    required: [name]
+    properties:
+      name: {type: string}
+      tag: {type: string}
+`),
+	}
+	revision := Description{
+		Name:     "revision",
+		MimeType: "application/x.openapi;version=2",
+		Contents: []byte(`swagger: "2.0"
+info: {title: Petstore, version: "1.1.0"}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - {name: limit, in: query, required: true, type: integer, format: int64}
+        - {name: status, in: query, type: string, enum: [available]}
+      responses:
+        "200": {description: ok, schema: {type: array, items: {$ref: "#/definitions/Pet"}}}
+  /owners:
+    get:
+      responses:
+        "200": {description: ok}
+definitions:
+  Pet:
+    type: object
+    properties:
+      name: {type: string}
+      tag: {type: integer}
+      age: {type: integer}
+`),
+	}
+
+	want := []*rpc.ApiSpecChange{
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "path", Location: "paths./owners", Attributes: map[string]string{}},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "parameter", Location: "paths./pets.get.parameters.query.limit", Attribute: "format", OldValue: "int32", NewValue: "int64"},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "parameter", Location: "paths./pets.get.parameters.query.limit", Attribute: "required", OldValue: "false", NewValue: "true"},
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "enum_value", Location: "paths./pets.get.parameters.query.status.enum.sold", Attributes: map[string]string{}},
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "operation", Location: "paths./pets.post", Attributes: map[string]string{"operation_id": "createPet"}},
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "path", Location: "paths./pets/{id}", Attributes: map[string]string{}},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "property", Location: "schemas.Pet.properties.age", Attributes: map[string]string{"type": "integer", "required": "false"}},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "property", Location: "schemas.Pet.properties.name", Attribute: "required", OldValue: "true", NewValue: "false"},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "property", Location: "schemas.Pet.properties.tag", Attribute: "type", OldValue: "string", NewValue: "integer"},
+	}
+
+	got, err := Diff(base, revision)
+	if err != nil {
+		t.Fatalf("Diff() returned error: %s", err)
+	}
+	if got.GetFormat() != OpenAPI {
+		t.Errorf("Diff() returned format %q, want %q", got.GetFormat(), OpenAPI)
+	}
+	if !cmp.Equal(want, got.GetChanges(), protocmp.Transform()) {
+		t.Errorf("Diff() returned unexpected changes (-want +got):\n%s", cmp.Diff(want, got.GetChanges(), protocmp.Transform()))
+	}
+}
+
+func TestDiffOpenAPIv3(t *testing.T) {
+	base := Description{
+		Name:     "base",
+		MimeType: "application/x.openapi;version=3",
+		Contents: []byte(`{
+  "openapi": "3.0.0",
+  "info": {"title": "Petstore", "version": "1.0.0"},
+  "paths": {
+    "/pets": {
+      "post": {
+        "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}},
+        "responses": {"201": {"description": "created"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"status": {"type": "string", "enum": ["available", "sold"]}}},
+      "Error": {"type": "object"}
+    }
+  }
+}`),
+	}
+	revision := Description{
+		Name:     "revision",
+		MimeType: "application/x.openapi;version=3",
+		Contents: []byte(`{
+  "openapi": "3.0.0",
+  "info": {"title": "Petstore", "version": "1.0.0"},
+  "paths": {
+    "/pets": {
+      "post": {
+        "parameters": [{"name": "dryRun", "in": "query", "schema": {"type": "boolean"}}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewPet"}}}},
+        "responses": {"201": {"description": "created"}, "default": {"description": "error"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"status": {"type": "string", "enum": ["available", "sold", "pending"]}}}
+    }
+  }
+}`),
+	}
+
+	want := []*rpc.ApiSpecChange{
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "parameter", Location: "paths./pets.post.parameters.query.dryRun", Attributes: map[string]string{"required": "false", "type": "boolean"}},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "request_body", Location: "paths./pets.post.request_body", Attribute: "required", OldValue: "false", NewValue: "true"},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "media_type", Location: "paths./pets.post.request_body.content.application/json", Attribute: "type", OldValue: "#/components/schemas/Pet", NewValue: "#/components/schemas/NewPet"},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "response", Location: "paths./pets.post.responses.default", Attributes: map[string]string{}},
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "schema", Location: "schemas.Error", Attributes: map[string]string{"type": "object"}},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "enum_value", Location: "schemas.Pet.properties.status.enum.pending", Attributes: map[string]string{}},
+	}
+
+	got, err := Diff(base, revision)
+	if err != nil {
+		t.Fatalf("Diff() returned error: %s", err)
+	}
+	if !cmp.Equal(want, got.GetChanges(), protocmp.Transform()) {
+		t.Errorf("Diff() returned unexpected changes (-want +got):\n%s", cmp.Diff(want, got.GetChanges(), protocmp.Transform()))
+	}
+}
+
+func TestDiffProtos(t *testing.T) {
+	base := Description{
+		Name:     "base",
+		MimeType: "application/x.protobuf+zip",
+		Contents: zipProtos(t, map[string]string{
+			"library/v1/library.proto": `syntax = "proto3";
+package example.library.v1;
+
+service Library {
+  rpc GetBook(GetBookRequest) returns (Book);
+  rpc DeleteBook(GetBookRequest) returns (Book);
+}
+
+message Book {
+  enum Format {
+    FORMAT_UNSPECIFIED = 0;
+    HARDCOVER = 1;
+    EBOOK = 2;
+  }
+  string name = 1;
+  string author = 2;
+  Format format = 3;
+}
+
+message GetBookRequest {
+  string name = 1;
+}
+`,
+		}),
+	}
+	revision := Description{
+		Name:     "revision",
+		MimeType: "application/x.protobuf+zip",
+		Contents: zipProtos(t, map[string]string{
+			"library/v1/library.proto": `syntax = "proto3";
+package example.library.v1;
+
+service Library {
+  rpc GetBook(GetBookRequest) returns (Book);
+  rpc ListBooks(ListBooksRequest) returns (stream Book);
+}
+
+message Book {
+  enum Format {
+    FORMAT_UNSPECIFIED = 0;
+    HARDCOVER = 1;
+  }
+  string name = 1;
+  repeated string author = 2;
+  Format format = 4;
+  map<string, string> labels = 5;
+}
+
+message GetBookRequest {
+  string name = 1;
+}
+
+message ListBooksRequest {
+  string parent = 1;
+}
+`,
+		}),
+	}
+
+	want := []*rpc.ApiSpecChange{
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "enum_value", Location: "example.library.v1.Book.Format.EBOOK", Attributes: map[string]string{"number": "2"}},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "field", Location: "example.library.v1.Book.author", Attribute: "label", OldValue: "", NewValue: "repeated"},
+		{Kind: rpc.ApiSpecChange_MODIFIED, Element: "field", Location: "example.library.v1.Book.format", Attribute: "number", OldValue: "3", NewValue: "4"},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "field", Location: "example.library.v1.Book.labels", Attributes: map[string]string{"type": "map<string, string>", "number": "5"}},
+		{Kind: rpc.ApiSpecChange_REMOVED, Element: "rpc", Location: "example.library.v1.Library.DeleteBook", Attributes: map[string]string{"request": "GetBookRequest", "response": "Book"}},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "rpc", Location: "example.library.v1.Library.ListBooks", Attributes: map[string]string{"request": "ListBooksRequest", "response": "stream Book"}},
+		{Kind: rpc.ApiSpecChange_ADDED, Element: "message", Location: "example.library.v1.ListBooksRequest", Attributes: map[string]string{}},
+	}
+
+	got, err := Diff(base, revision)
+	if err != nil {
+		t.Fatalf("Diff() returned error: %s", err)
+	}
+	if got.GetFormat() != Proto {
+		t.Errorf("Diff() returned format %q, want %q", got.GetFormat(), Proto)
+	}
+	if !cmp.Equal(want, got.GetChanges(), protocmp.Transform()) {
+		t.Errorf("Diff() returned unexpected changes (-want +got):\n%s", cmp.Diff(want, got.GetChanges(), protocmp.Transform()))
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	base := Description{
+		Name:     "a@1",
+		MimeType: "text/plain",
+		Contents: []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"),
+	}
+	revision := Description{
+		Name:     "a@2",
+		MimeType: "text/plain",
+		Contents: []byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n13\n14\n15\n16\n"),
+	}
+
+	want := `--- a@1
++++ a@2
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -9,7 +9,7 @@
+ 9
+ 10
+ 11
+-12
+ 13
+ 14
+ 15
++16
+`
+
+	got, err := Diff(base, revision)
+	if err != nil {
+		t.Fatalf("Diff() returned error: %s", err)
+	}
+	if got.GetFormat() != Text {
+		t.Errorf("Diff() returned format %q, want %q", got.GetFormat(), Text)
+	}
+	if got.GetUnifiedDiff() != want {
+		t.Errorf("Diff() returned unexpected unified diff (-want +got):\n%s", cmp.Diff(want, got.GetUnifiedDiff()))
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		desc   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			desc:   "equal",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			desc:   "from empty",
+			before: "",
+			after:  "a\n",
+			want:   "--- x\n+++ y\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			desc:   "to empty",
+			before: "a\nb\n",
+			after:  "",
+			want:   "--- x\n+++ y\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			desc:   "replaced",
+			before: "a\nb\nc\n",
+			after:  "a\nx\nc\n",
+			want:   "--- x\n+++ y\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := Unified(Description{Name: "x", Contents: []byte(test.before)}, Description{Name: "y", Contents: []byte(test.after)})
+			if err != nil {
+				t.Fatalf("Unified() returned error: %s", err)
+			} else if got != test.want {
+				t.Errorf("Unified() returned unexpected diff (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestDiffDifferentFormats(t *testing.T) {
+	base := Description{Name: "base", MimeType: "application/x.protobuf+zip", Contents: []byte("not a zip")}
+	revision := Description{Name: "revision", MimeType: "application/x.openapi;version=3", Contents: []byte("openapi: 3.0.0\n")}
+
+	got, err := Diff(base, revision)
+	if err != nil {
+		t.Fatalf("Diff() returned error: %s", err)
+	}
+	if got.GetFormat() != Text || got.GetUnifiedDiff() == "" {
+		t.Errorf("Diff() returned format %q with diff %q, want a text diff", got.GetFormat(), got.GetUnifiedDiff())
+	}
+}
+
+func TestDiffInvalidContents(t *testing.T) {
+	base := Description{Name: "base", MimeType: "application/x.protobuf+zip", Contents: []byte("not a zip")}
+	if _, err := Diff(base, base); err == nil {
+		t.Errorf("Diff() succeeded for invalid contents, want error")
+	}
+}
+
+func TestUnifiedEditDistance(t *testing.T) {
+	lines := func(prefix string, count int) []byte {
+		var b strings.Builder
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(&b, "%s%d\n", prefix, i)
+		}
+		return []byte(b.String())
+	}
+
+	// Every line is replaced, so the edit distance is the total number of lines.
+	base := Description{Name: "x", Contents: lines("a", maxEditDistance/2)}
+	revision := Description{Name: "y", Contents: lines("b", maxEditDistance/2)}
+	if got, err := Unified(base, revision); err != nil {
+		t.Errorf("Unified() returned error at the maximum edit distance: %s", err)
+	} else if got == "" {
+		t.Errorf("Unified() returned an empty diff at the maximum edit distance")
+	}
+
+	revision.Contents = lines("b", maxEditDistance/2+1)
+	if _, err := Unified(base, revision); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Unified() returned %v above the maximum edit distance, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestDiffContentsSize(t *testing.T) {
+	base := Description{Name: "x", MimeType: "text/plain", Contents: bytes.Repeat([]byte("a\n"), maxContentsSize/2)}
+	revision := Description{Name: "y", MimeType: "text/plain", Contents: base.Contents}
+	if _, err := Diff(base, revision); err != nil {
+		t.Errorf("Diff() returned error at the maximum contents size: %s", err)
+	}
+
+	revision.Contents = append(revision.Contents, 'a')
+	if _, err := Diff(base, revision); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Diff() returned %v above the maximum contents size, want %v", err, ErrTooLarge)
+	}
+}
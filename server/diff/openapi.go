@@ -0,0 +1,384 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
+	openapi_v3 "github.com/googleapis/gnostic/openapiv3"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIElements returns the elements of an OpenAPI v2 or v3 description.
+// Schemas are located below "schemas" in both versions so that they can be compared across versions.
+func openAPIElements(d Description) (elements, error) {
+	var header struct {
+		Swagger string `yaml:"swagger"`
+		OpenAPI string `yaml:"openapi"`
+	}
+	if err := yaml.Unmarshal(d.Contents, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", d.Name, err)
+	}
+
+	e := make(elements)
+	switch {
+	case strings.HasPrefix(header.Swagger, "2"):
+		doc, err := openapi_v2.ParseDocument(d.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", d.Name, err)
+		}
+		addOpenAPIv2Document(e, doc)
+	case strings.HasPrefix(header.OpenAPI, "3"):
+		doc, err := openapi_v3.ParseDocument(d.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", d.Name, err)
+		}
+		addOpenAPIv3Document(e, doc)
+	default:
+		return nil, fmt.Errorf("failed to parse %s: unsupported OpenAPI version", d.Name)
+	}
+	return e, nil
+}
+
+// attributes builds an attribute map from pairs of names and values, omitting empty values.
+func attributes(pairs ...string) map[string]string {
+	attrs := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			attrs[pairs[i]] = pairs[i+1]
+		}
+	}
+	return attrs
+}
+
+func flag(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
+
+func addEnumValues(e elements, parent string, values []string) {
+	for _, v := range values {
+		e.add(parent+".enum."+v, parent, "enum_value", nil)
+	}
+}
+
+func addOpenAPIv2Document(e elements, doc *openapi_v2.Document) {
+	for _, p := range doc.GetPaths().GetPath() {
+		path := "paths." + p.GetName()
+		e.add(path, "", "path", nil)
+
+		item := p.GetValue()
+		addOpenAPIv2Parameters(e, path, item.GetParameters())
+		operations := []struct {
+			method string
+			op     *openapi_v2.Operation
+		}{
+			{"get", item.GetGet()},
+			{"put", item.GetPut()},
+			{"post", item.GetPost()},
+			{"delete", item.GetDelete()},
+			{"options", item.GetOptions()},
+			{"head", item.GetHead()},
+			{"patch", item.GetPatch()},
+		}
+		for _, o := range operations {
+			if o.op == nil {
+				continue
+			}
+
+			op := path + "." + o.method
+			e.add(op, path, "operation", attributes(
+				"operation_id", o.op.GetOperationId(),
+				"deprecated", flag(o.op.GetDeprecated()),
+			))
+			addOpenAPIv2Parameters(e, op, o.op.GetParameters())
+
+			for _, r := range o.op.GetResponses().GetResponseCode() {
+				loc := op + ".responses." + r.GetName()
+				if ref := r.GetValue().GetJsonReference(); ref != nil {
+					e.add(loc, op, "response", attributes("type", ref.GetXRef()))
+				} else {
+					schema := r.GetValue().GetResponse().GetSchema().GetSchema()
+					e.add(loc, op, "response", attributes("type", openAPIv2SchemaType(schema)))
+				}
+			}
+		}
+	}
+
+	for _, s := range doc.GetDefinitions().GetAdditionalProperties() {
+		addOpenAPIv2Schema(e, "schemas."+s.GetName(), "", "schema", s.GetValue(), false)
+	}
+}
+
+func addOpenAPIv2Parameters(e elements, parent string, parameters []*openapi_v2.ParametersItem) {
+	for _, item := range parameters {
+		if ref := item.GetJsonReference(); ref != nil {
+			e.add(parent+".parameters."+ref.GetXRef(), parent, "parameter", nil)
+			continue
+		}
+
+		if body := item.GetParameter().GetBodyParameter(); body != nil {
+			loc := parent + ".parameters." + body.GetIn() + "." + body.GetName()
+			e.add(loc, parent, "parameter", attributes(
+				"required", strconv.FormatBool(body.GetRequired()),
+				"type", openAPIv2SchemaType(body.GetSchema()),
+			))
+			continue
+		}
+
+		var (
+			in, name, typ, format string
+			required              bool
+			items                 *openapi_v2.PrimitivesItems
+			enum                  []*openapi_v2.Any
+		)
+		switch p := item.GetParameter().GetNonBodyParameter(); {
+		case p.GetHeaderParameterSubSchema() != nil:
+			s := p.GetHeaderParameterSubSchema()
+			in, name, typ, format, required, items, enum = s.In, s.Name, s.Type, s.Format, s.Required, s.Items, s.Enum
+		case p.GetFormDataParameterSubSchema() != nil:
+			s := p.GetFormDataParameterSubSchema()
+			in, name, typ, format, required, items, enum = s.In, s.Name, s.Type, s.Format, s.Required, s.Items, s.Enum
+		case p.GetQueryParameterSubSchema() != nil:
+			s := p.GetQueryParameterSubSchema()
+			in, name, typ, format, required, items, enum = s.In, s.Name, s.Type, s.Format, s.Required, s.Items, s.Enum
+		case p.GetPathParameterSubSchema() != nil:
+			s := p.GetPathParameterSubSchema()
+			in, name, typ, format, required, items, enum = s.In, s.Name, s.Type, s.Format, s.Required, s.Items, s.Enum
+		default:
+			continue
+		}
+
+		if typ == "array" && items != nil {
+			typ = "array<" + items.GetType() + ">"
+		}
+
+		loc := parent + ".parameters." + in + "." + name
+		e.add(loc, parent, "parameter", attributes(
+			"required", strconv.FormatBool(required),
+			"type", typ,
+			"format", format,
+		))
+		addEnumValues(e, loc, openAPIv2Values(enum))
+	}
+}
+
+func addOpenAPIv2Schema(e elements, loc, parent, kind string, s *openapi_v2.Schema, required bool) {
+	attrs := attributes(
+		"type", openAPIv2SchemaType(s),
+		"format", s.GetFormat(),
+		"all_of", openAPIv2SchemaTypes(s.GetAllOf()),
+	)
+	if kind == "property" {
+		attrs["required"] = strconv.FormatBool(required)
+	}
+	e.add(loc, parent, kind, attrs)
+	addEnumValues(e, loc, openAPIv2Values(s.GetEnum()))
+
+	// Properties of array items are compared as properties of the array.
+	if items := s.GetItems().GetSchema(); len(items) == 1 && items[0].GetXRef() == "" {
+		s = items[0]
+	}
+	requiredProperties := make(map[string]bool, len(s.GetRequired()))
+	for _, name := range s.GetRequired() {
+		requiredProperties[name] = true
+	}
+	for _, p := range s.GetProperties().GetAdditionalProperties() {
+		addOpenAPIv2Schema(e, loc+".properties."+p.GetName(), loc, "property", p.GetValue(), requiredProperties[p.GetName()])
+	}
+}
+
+func openAPIv2SchemaType(s *openapi_v2.Schema) string {
+	if s == nil {
+		return ""
+	} else if s.GetXRef() != "" {
+		return s.GetXRef()
+	}
+
+	t := strings.Join(s.GetType().GetValue(), "|")
+	if items := s.GetItems().GetSchema(); t == "array" && len(items) == 1 {
+		return "array<" + openAPIv2SchemaType(items[0]) + ">"
+	}
+	return t
+}
+
+func openAPIv2SchemaTypes(schemas []*openapi_v2.Schema) string {
+	types := make([]string, 0, len(schemas))
+	for _, s := range schemas {
+		types = append(types, openAPIv2SchemaType(s))
+	}
+	return strings.Join(types, ",")
+}
+
+func openAPIv2Values(values []*openapi_v2.Any) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, strings.TrimSpace(v.GetYaml()))
+	}
+	return strs
+}
+
+func addOpenAPIv3Document(e elements, doc *openapi_v3.Document) {
+	for _, p := range doc.GetPaths().GetPath() {
+		path := "paths." + p.GetName()
+		e.add(path, "", "path", nil)
+
+		item := p.GetValue()
+		addOpenAPIv3Parameters(e, path, item.GetParameters())
+		operations := []struct {
+			method string
+			op     *openapi_v3.Operation
+		}{
+			{"get", item.GetGet()},
+			{"put", item.GetPut()},
+			{"post", item.GetPost()},
+			{"delete", item.GetDelete()},
+			{"options", item.GetOptions()},
+			{"head", item.GetHead()},
+			{"patch", item.GetPatch()},
+			{"trace", item.GetTrace()},
+		}
+		for _, o := range operations {
+			if o.op == nil {
+				continue
+			}
+
+			op := path + "." + o.method
+			e.add(op, path, "operation", attributes(
+				"operation_id", o.op.GetOperationId(),
+				"deprecated", flag(o.op.GetDeprecated()),
+			))
+			addOpenAPIv3Parameters(e, op, o.op.GetParameters())
+
+			if body := o.op.GetRequestBody(); body != nil {
+				loc := op + ".request_body"
+				if ref := body.GetReference(); ref != nil {
+					e.add(loc, op, "request_body", attributes("type", ref.GetXRef()))
+				} else {
+					e.add(loc, op, "request_body", attributes("required", strconv.FormatBool(body.GetRequestBody().GetRequired())))
+					addOpenAPIv3Content(e, loc, body.GetRequestBody().GetContent())
+				}
+			}
+
+			responses := o.op.GetResponses().GetResponseOrReference()
+			if d := o.op.GetResponses().GetDefault(); d != nil {
+				responses = append(responses, &openapi_v3.NamedResponseOrReference{Name: "default", Value: d})
+			}
+			for _, r := range responses {
+				loc := op + ".responses." + r.GetName()
+				if ref := r.GetValue().GetReference(); ref != nil {
+					e.add(loc, op, "response", attributes("type", ref.GetXRef()))
+				} else {
+					e.add(loc, op, "response", nil)
+					addOpenAPIv3Content(e, loc, r.GetValue().GetResponse().GetContent())
+				}
+			}
+		}
+	}
+
+	for _, s := range doc.GetComponents().GetSchemas().GetAdditionalProperties() {
+		addOpenAPIv3Schema(e, "schemas."+s.GetName(), "", "schema", s.GetValue(), false)
+	}
+}
+
+func addOpenAPIv3Parameters(e elements, parent string, parameters []*openapi_v3.ParameterOrReference) {
+	for _, item := range parameters {
+		if ref := item.GetReference(); ref != nil {
+			e.add(parent+".parameters."+ref.GetXRef(), parent, "parameter", nil)
+			continue
+		}
+
+		p := item.GetParameter()
+		loc := parent + ".parameters." + p.GetIn() + "." + p.GetName()
+		e.add(loc, parent, "parameter", attributes(
+			"required", strconv.FormatBool(p.GetRequired()),
+			"type", openAPIv3SchemaType(p.GetSchema()),
+			"format", p.GetSchema().GetSchema().GetFormat(),
+			"deprecated", flag(p.GetDeprecated()),
+		))
+		addEnumValues(e, loc, openAPIv3Values(p.GetSchema().GetSchema().GetEnum()))
+	}
+}
+
+func addOpenAPIv3Content(e elements, parent string, content *openapi_v3.MediaTypes) {
+	for _, m := range content.GetAdditionalProperties() {
+		e.add(parent+".content."+m.GetName(), parent, "media_type", attributes(
+			"type", openAPIv3SchemaType(m.GetValue().GetSchema()),
+		))
+	}
+}
+
+func addOpenAPIv3Schema(e elements, loc, parent, kind string, sr *openapi_v3.SchemaOrReference, required bool) {
+	s := sr.GetSchema()
+	attrs := attributes(
+		"type", openAPIv3SchemaType(sr),
+		"format", s.GetFormat(),
+		"nullable", flag(s.GetNullable()),
+		"deprecated", flag(s.GetDeprecated()),
+		"all_of", openAPIv3SchemaTypes(s.GetAllOf()),
+		"one_of", openAPIv3SchemaTypes(s.GetOneOf()),
+		"any_of", openAPIv3SchemaTypes(s.GetAnyOf()),
+	)
+	if kind == "property" {
+		attrs["required"] = strconv.FormatBool(required)
+	}
+	e.add(loc, parent, kind, attrs)
+	addEnumValues(e, loc, openAPIv3Values(s.GetEnum()))
+
+	// Properties of array items are compared as properties of the array.
+	if items := s.GetItems().GetSchemaOrReference(); len(items) == 1 && items[0].GetSchema() != nil {
+		s = items[0].GetSchema()
+	}
+	requiredProperties := make(map[string]bool, len(s.GetRequired()))
+	for _, name := range s.GetRequired() {
+		requiredProperties[name] = true
+	}
+	for _, p := range s.GetProperties().GetAdditionalProperties() {
+		addOpenAPIv3Schema(e, loc+".properties."+p.GetName(), loc, "property", p.GetValue(), requiredProperties[p.GetName()])
+	}
+}
+
+func openAPIv3SchemaType(sr *openapi_v3.SchemaOrReference) string {
+	if ref := sr.GetReference(); ref != nil {
+		return ref.GetXRef()
+	}
+
+	s := sr.GetSchema()
+	if items := s.GetItems().GetSchemaOrReference(); s.GetType() == "array" && len(items) == 1 {
+		return "array<" + openAPIv3SchemaType(items[0]) + ">"
+	}
+	return s.GetType()
+}
+
+func openAPIv3SchemaTypes(schemas []*openapi_v3.SchemaOrReference) string {
+	types := make([]string, 0, len(schemas))
+	for _, s := range schemas {
+		types = append(types, openAPIv3SchemaType(s))
+	}
+	return strings.Join(types, ",")
+}
+
+func openAPIv3Values(values []*openapi_v3.Any) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, strings.TrimSpace(v.GetYaml()))
+	}
+	return strs
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	protoparser "github.com/yoheimuta/go-protoparser/v4"
+	"github.com/yoheimuta/go-protoparser/v4/parser"
+)
+
+// protoElements returns the elements of a Protocol Buffer description,
+// which is either a zip archive of .proto files or a single .proto file.
+// Elements are located by their fully-qualified names.
+func protoElements(d Description) (elements, error) {
+	e := make(elements)
+	if !strings.Contains(d.MimeType, "+zip") {
+		if err := addProtoFile(e, d.Name, bytes.NewReader(d.Contents)); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(d.Contents), int64(len(d.Contents)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unzip %s: %s", d.Name, err)
+	}
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(f.Name, ".proto") {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to unzip %s from %s: %s", f.Name, d.Name, err)
+		}
+		err = addProtoFile(e, f.Name, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func addProtoFile(e elements, filename string, r io.Reader) error {
+	p, err := protoparser.Parse(r,
+		protoparser.WithDebug(false),
+		protoparser.WithPermissive(true),
+		protoparser.WithFilename(filepath.Base(filename)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %s", filename, err)
+	}
+
+	var pkg string
+	for _, x := range p.ProtoBody {
+		if x, ok := x.(*parser.Package); ok {
+			pkg = x.Name
+		}
+	}
+
+	for _, x := range p.ProtoBody {
+		switch x := x.(type) {
+		case *parser.Message:
+			addProtoMessage(e, qualify(pkg, x.MessageName), "", x)
+		case *parser.Enum:
+			addProtoEnum(e, qualify(pkg, x.EnumName), "", x)
+		case *parser.Service:
+			addProtoService(e, qualify(pkg, x.ServiceName), x)
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func addProtoMessage(e elements, name, parent string, m *parser.Message) {
+	e.add(name, parent, "message", nil)
+	for _, x := range m.MessageBody {
+		switch x := x.(type) {
+		case *parser.Field:
+			label := ""
+			switch {
+			case x.IsRepeated:
+				label = "repeated"
+			case x.IsRequired:
+				label = "required"
+			case x.IsOptional:
+				label = "optional"
+			}
+			e.add(qualify(name, x.FieldName), name, "field", attributes(
+				"type", x.Type,
+				"number", x.FieldNumber,
+				"label", label,
+			))
+		case *parser.MapField:
+			e.add(qualify(name, x.MapName), name, "field", attributes(
+				"type", fmt.Sprintf("map<%s, %s>", x.KeyType, x.Type),
+				"number", x.FieldNumber,
+			))
+		case *parser.Oneof:
+			for _, f := range x.OneofFields {
+				e.add(qualify(name, f.FieldName), name, "field", attributes(
+					"type", f.Type,
+					"number", f.FieldNumber,
+					"oneof", x.OneofName,
+				))
+			}
+		case *parser.Message:
+			addProtoMessage(e, qualify(name, x.MessageName), name, x)
+		case *parser.Enum:
+			addProtoEnum(e, qualify(name, x.EnumName), name, x)
+		}
+	}
+}
+
+func addProtoEnum(e elements, name, parent string, m *parser.Enum) {
+	e.add(name, parent, "enum", nil)
+	for _, x := range m.EnumBody {
+		if x, ok := x.(*parser.EnumField); ok {
+			e.add(qualify(name, x.Ident), name, "enum_value", attributes("number", x.Number))
+		}
+	}
+}
+
+func addProtoService(e elements, name string, s *parser.Service) {
+	e.add(name, "", "service", nil)
+	for _, x := range s.ServiceBody {
+		if x, ok := x.(*parser.RPC); ok {
+			e.add(qualify(name, x.RPCName), name, "rpc", attributes(
+				"request", streamType(x.RPCRequest.IsStream, x.RPCRequest.MessageType),
+				"response", streamType(x.RPCResponse.IsStream, x.RPCResponse.MessageType),
+			))
+		}
+	}
+}
+
+func streamType(stream bool, messageType string) string {
+	if stream {
+		return "stream " + messageType
+	}
+	return messageType
+}
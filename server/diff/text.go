@@ -0,0 +1,183 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines around each hunk of a unified diff.
+const contextLines = 3
+
+// maxEditDistance is the largest number of removed and added lines in a unified diff.
+// Finding them keeps the paths of each round of the search, which takes memory that
+// grows with the square of their number.
+const maxEditDistance = 2000
+
+type lineOp struct {
+	kind byte // ' ' for unchanged lines, '-' for removed lines, '+' for added lines.
+	line string
+}
+
+// Unified returns a unified diff of two revisions, or an empty string if their contents are equal.
+// It returns ErrTooLarge if more than maxEditDistance lines are removed and added.
+func Unified(base, revision Description) (string, error) {
+	ops, ok := diffLines(splitLines(string(base.Contents)), splitLines(string(revision.Contents)))
+	if !ok {
+		return "", fmt.Errorf("%w: %s and %s differ by more than %d lines", ErrTooLarge, base.Name, revision.Name, maxEditDistance)
+	}
+
+	changes := make([]int, 0)
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return "", nil
+	}
+
+	// Lines of each revision that precede each operation.
+	before, after := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		before[i+1], after[i+1] = before[i], after[i]
+		if op.kind != '+' {
+			before[i+1]++
+		}
+		if op.kind != '-' {
+			after[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", base.Name, revision.Name)
+	for i := 0; i < len(changes); {
+		start := max(changes[i]-contextLines, 0)
+		end := changes[i] + 1 + contextLines
+		// Changes that are separated by less than twice the context are in the same hunk.
+		for i++; i < len(changes) && changes[i]-contextLines <= end; i++ {
+			end = changes[i] + 1 + contextLines
+		}
+		end = min(end, len(ops))
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(before[start], before[end]-before[start]),
+			hunkRange(after[start], after[end]-after[start]))
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&b, "%c%s\n", op.kind, op.line)
+		}
+	}
+	return b.String(), nil
+}
+
+func hunkRange(preceding, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", preceding)
+	case 1:
+		return fmt.Sprintf("%d", preceding+1)
+	default:
+		return fmt.Sprintf("%d,%d", preceding+1, count)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the shortest edit script from a to b using Myers' algorithm.
+// It returns false if the script would have more than maxEditDistance edits.
+func diffLines(a, b []string) ([]lineOp, bool) {
+	n, m := len(a), len(b)
+	offset := min(n+m, maxEditDistance)
+	v := make([]int, 2*offset+2)
+
+	// trace[d] holds the furthest reaching paths for diagonals -d..d before round d.
+	var trace [][]int
+	for d := 0; d <= offset; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func backtrack(a, b []string, trace [][]int) []lineOp {
+	x, y := len(a), len(b)
+	ops := make([]lineOp, 0, x+y)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, lineOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			ops = append(ops, lineOp{'+', b[y-1]})
+		} else {
+			ops = append(ops, lineOp{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, lineOp{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
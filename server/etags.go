@@ -0,0 +1,125 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/names"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Etags are checked against stored resources in the same transaction that
+// modifies them, so that changes made after a resource was read can't be overwritten.
+// Resources are read for update, which locks them until the transaction ends, so
+// concurrent transactions can't both match an etag and then save their changes.
+
+// checkEtag returns an error if an etag is provided and doesn't match the current etag of a resource.
+func checkEtag(name, etag, current string) error {
+	if etag != "" && etag != current {
+		return status.Errorf(codes.Aborted, "etag %q does not match the current etag of %s", etag, name)
+	}
+	return nil
+}
+
+func checkProjectEtag(ctx context.Context, db dao.DAO, name names.Project, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	project, err := db.GetProject(ctx, name)
+	if err != nil {
+		return err
+	}
+	return checkEtag(name.String(), etag, project.Message().GetEtag())
+}
+
+func checkApiEtag(ctx context.Context, db dao.DAO, name names.Api, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	api, err := db.GetApi(ctx, name)
+	if err != nil {
+		return err
+	}
+	message, err := api.Message()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return checkEtag(name.String(), etag, message.GetEtag())
+}
+
+func checkVersionEtag(ctx context.Context, db dao.DAO, name names.Version, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	version, err := db.GetVersion(ctx, name)
+	if err != nil {
+		return err
+	}
+	message, err := version.Message()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return checkEtag(name.String(), etag, message.GetEtag())
+}
+
+func checkSpecEtag(ctx context.Context, db dao.DAO, name names.Spec, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	spec, err := db.GetSpec(ctx, name)
+	if err != nil {
+		return err
+	}
+	message, err := spec.BasicMessage(name.String())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return checkEtag(name.String(), etag, message.GetEtag())
+}
+
+func checkDeploymentEtag(ctx context.Context, db dao.DAO, name names.Deployment, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	deployment, err := db.GetDeployment(ctx, name)
+	if err != nil {
+		return err
+	}
+	message, err := deployment.Message(name.String())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return checkEtag(name.String(), etag, message.GetEtag())
+}
+
+func checkArtifactEtag(ctx context.Context, db dao.DAO, name names.Artifact, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	db = db.ForUpdate()
+	artifact, err := db.GetArtifact(ctx, name)
+	if err != nil {
+		return err
+	}
+	return checkEtag(name.String(), etag, artifact.Message().GetEtag())
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestUpdateApiEtag(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{
+		Name: "projects/my-project/apis/my-api",
+	})
+
+	original, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("Setup: GetApi() returned error: %s", err)
+	} else if original.GetEtag() == "" {
+		t.Fatalf("Setup: GetApi() returned empty etag")
+	}
+
+	req := &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
+			Name:        original.GetName(),
+			DisplayName: "first",
+			Etag:        original.GetEtag(),
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"display_name"}},
+	}
+	updated, err := server.UpdateApi(ctx, req)
+	if err != nil {
+		t.Fatalf("UpdateApi(%+v) returned error: %s", req, err)
+	} else if updated.GetEtag() == original.GetEtag() {
+		t.Errorf("UpdateApi(%+v) returned unchanged etag %q", req, updated.GetEtag())
+	}
+
+	// The original etag is now stale, so updates that use it should fail.
+	req.Api.DisplayName = "second"
+	if _, err := server.UpdateApi(ctx, req); status.Code(err) != codes.Aborted {
+		t.Errorf("UpdateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.Aborted, err)
+	}
+
+	got, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: original.GetName()})
+	if err != nil {
+		t.Fatalf("GetApi() returned error: %s", err)
+	} else if got.GetDisplayName() != "first" {
+		t.Errorf("GetApi() returned display_name %q, want %q", got.GetDisplayName(), "first")
+	} else if got.GetEtag() != updated.GetEtag() {
+		t.Errorf("GetApi() returned etag %q, want %q", got.GetEtag(), updated.GetEtag())
+	}
+}
+
+func TestUpdateApiSpecEtag(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+	})
+
+	original, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+	}
+
+	req := &rpc.UpdateApiSpecRequest{
+		ApiSpec: &rpc.ApiSpec{
+			Name:     original.GetName(),
+			Contents: []byte("first"),
+			Etag:     original.GetEtag(),
+		},
+	}
+	if _, err := server.UpdateApiSpec(ctx, req); err != nil {
+		t.Fatalf("UpdateApiSpec(%+v) returned error: %s", req, err)
+	}
+
+	req.ApiSpec.Contents = []byte("second")
+	if _, err := server.UpdateApiSpec(ctx, req); status.Code(err) != codes.Aborted {
+		t.Errorf("UpdateApiSpec(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.Aborted, err)
+	}
+}
+
+func TestUpdateApiEtagConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{
+		Name: "projects/my-project/apis/my-api",
+	})
+
+	original, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("Setup: GetApi() returned error: %s", err)
+	}
+
+	// Every request uses the original etag, so only one of them should succeed.
+	const count = 8
+	type result struct {
+		api *rpc.Api
+		err error
+	}
+	results := make(chan result, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			api, err := server.UpdateApi(ctx, &rpc.UpdateApiRequest{
+				Api: &rpc.Api{
+					Name:   original.GetName(),
+					Labels: map[string]string{"writer": fmt.Sprint(i)},
+					Etag:   original.GetEtag(),
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+			})
+			results <- result{api: api, err: err}
+		}(i)
+	}
+
+	var updated *rpc.Api
+	for i := 0; i < count; i++ {
+		r := <-results
+		switch {
+		case r.err == nil && updated == nil:
+			updated = r.api
+		case r.err == nil:
+			t.Errorf("UpdateApi() succeeded more than once with etag %q", original.GetEtag())
+		case status.Code(r.err) != codes.Aborted:
+			t.Errorf("UpdateApi() returned status code %q, want %q: %v", status.Code(r.err), codes.Aborted, r.err)
+		}
+	}
+	if updated == nil {
+		t.Fatalf("UpdateApi() didn't succeed with etag %q", original.GetEtag())
+	}
+
+	got, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: original.GetName()})
+	if err != nil {
+		t.Fatalf("GetApi() returned error: %s", err)
+	} else if got.GetLabels()["writer"] != updated.GetLabels()["writer"] {
+		t.Errorf("GetApi() returned labels %v, want %v", got.GetLabels(), updated.GetLabels())
+	}
+}
+
+func TestUpdateApiSpecEtagConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+	})
+
+	original, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+	}
+
+	// Each request starts a new revision from the original etag, so only one of them should succeed.
+	const count = 8
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			_, err := server.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
+				ApiSpec: &rpc.ApiSpec{
+					Name:     original.GetName(),
+					Contents: []byte(fmt.Sprintf("contents %d", i)),
+					Etag:     original.GetEtag(),
+				},
+			})
+			errs <- err
+		}(i)
+	}
+
+	succeeded := 0
+	for i := 0; i < count; i++ {
+		if err := <-errs; err == nil {
+			succeeded++
+		} else if status.Code(err) != codes.Aborted {
+			t.Errorf("UpdateApiSpec() returned status code %q, want %q: %v", status.Code(err), codes.Aborted, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("UpdateApiSpec() succeeded %d times with etag %q, want 1", succeeded, original.GetEtag())
+	}
+
+	revisions, err := server.ListApiSpecRevisions(ctx, &rpc.ListApiSpecRevisionsRequest{Name: original.GetName()})
+	if err != nil {
+		t.Fatalf("ListApiSpecRevisions() returned error: %s", err)
+	} else if got := len(revisions.GetApiSpecs()); got != 2 {
+		t.Errorf("ListApiSpecRevisions() returned %d revisions, want 2", got)
+	}
+}
+
+func TestDeleteApiEtag(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{
+		Name: "projects/my-project/apis/my-api",
+	})
+
+	original, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/my-project/apis/my-api"})
+	if err != nil {
+		t.Fatalf("Setup: GetApi() returned error: %s", err)
+	}
+
+	update := &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
+			Name:        original.GetName(),
+			DisplayName: "updated",
+		},
+	}
+	updated, err := server.UpdateApi(ctx, update)
+	if err != nil {
+		t.Fatalf("Setup: UpdateApi(%+v) returned error: %s", update, err)
+	}
+
+	req := &rpc.DeleteApiRequest{
+		Name: original.GetName(),
+		Etag: original.GetEtag(),
+	}
+	if _, err := server.DeleteApi(ctx, req); status.Code(err) != codes.Aborted {
+		t.Errorf("DeleteApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.Aborted, err)
+	}
+
+	req.Etag = updated.GetEtag()
+	if _, err := server.DeleteApi(ctx, req); err != nil {
+		t.Fatalf("DeleteApi(%+v) returned error: %s", req, err)
+	}
+}
+
+func TestDeleteProjectEtag(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{
+		Name: "projects/my-project",
+	})
+
+	req := &rpc.DeleteProjectRequest{
+		Name: "projects/my-project",
+		Etag: "stale",
+	}
+	if _, err := server.DeleteProject(ctx, req); status.Code(err) != codes.Aborted {
+		t.Errorf("DeleteProject(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.Aborted, err)
+	}
+
+	if _, err := server.GetProject(ctx, &rpc.GetProjectRequest{Name: req.GetName()}); err != nil {
+		t.Errorf("GetProject() returned error: %s", err)
+	}
+}
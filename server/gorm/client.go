@@ -18,7 +18,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/GoogleCloudPlatform/cloudsql-proxy/proxy/dialers/postgres"
 	"github.com/apigee/registry/server/models"
@@ -26,28 +30,31 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 // Client represents a connection to a storage provider.
+// Clients are safe for concurrent use and share a pool of database connections.
 type Client struct {
 	db *gorm.DB
 }
 
-var mutex sync.Mutex
-var disableMutex bool
-
-func mylock() {
-	if !disableMutex {
-		mutex.Lock()
-	}
+// PoolConfig configures the pool of database connections used by a client.
+// Zero values leave the database/sql defaults in place.
+type PoolConfig struct {
+	// MaxOpenConns is the maximum number of open connections to the database.
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// MaxIdleConns is the maximum number of idle connections kept in the pool.
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime is the maximum amount of time a connection may be reused.
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// ConnMaxIdleTime is the maximum amount of time a connection may be idle.
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
 }
 
-func myunlock() {
-	if !disableMutex {
-		mutex.Unlock()
-	}
-}
+// sqliteBusyTimeout is how long SQLite connections wait for locks held by other connections, in milliseconds.
+const sqliteBusyTimeout = 10000
 
 func config() *gorm.Config {
 	return &gorm.Config{
@@ -55,11 +62,6 @@ func config() *gorm.Config {
 	}
 }
 
-var clientCount int
-var clientTotal int
-
-var openErrorCount int
-
 // Validate checks a database name and config string for validity.
 func Validate(gormDBName, gormConfig string) error {
 	switch gormDBName {
@@ -71,6 +73,8 @@ func Validate(gormDBName, gormConfig string) error {
 		break
 	case "cloudsqlpostgres":
 		break
+	case "mysql":
+		break
 	default:
 		return fmt.Errorf("unsupported database (%s)", gormDBName)
 	}
@@ -80,81 +84,110 @@ func Validate(gormDBName, gormConfig string) error {
 	return nil
 }
 
-// NewClient creates a new database session.
-func NewClient(ctx context.Context, gormDBName, gormConfig string) (*Client, error) {
-	mylock()
-	clientCount++
-	clientTotal++
+// sqliteDSN adds the connection options used for SQLite databases to a DSN.
+// Databases use write-ahead logging so that reads can proceed during writes.
+// Transactions acquire the write lock when they begin, and connections wait
+// for locks instead of failing so that concurrent writers are serialized.
+func sqliteDSN(dsn string) string {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", strconv.Itoa(sqliteBusyTimeout))
+	params.Set("_txlock", "immediate")
+
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + params.Encode()
+	}
+	return dsn + "?" + params.Encode()
+}
+
+// open opens a database with a pool of connections.
+func open(gormDBName, gormConfig string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
 	switch gormDBName {
 	case "sqlite3":
-		db, err := gorm.Open(sqlite.Open(gormConfig), config())
-		if err != nil {
-			openErrorCount++
-			log.Printf("OPEN ERROR %d %s", openErrorCount, err.Error())
-			(&Client{db: db}).close()
-			myunlock()
-			return nil, err
-		}
-		myunlock()
-		// empirically, it does not seem safe to disable the mutex for sqlite3,
-		// which might make sense since sqlite database access is in-process.
-		//disableMutex = true
-		c := (&Client{db: db}).ensure()
-		return c, nil
+		dialector = sqlite.Open(sqliteDSN(gormConfig))
 	case "postgres", "cloudsqlpostgres":
-		db, err := gorm.Open(postgres.New(postgres.Config{
+		dialector = postgres.New(postgres.Config{
 			DriverName: gormDBName,
 			DSN:        gormConfig,
-		}), config())
+		})
+	case "mysql":
+		dsn, err := mysqlDSN(gormConfig)
 		if err != nil {
-			openErrorCount++
-			log.Printf("OPEN ERROR %d %s", openErrorCount, err.Error())
-			(&Client{db: db}).close()
-			myunlock()
-			return nil, err
+			return nil, fmt.Errorf("invalid mysql dbconfig: %s", err)
 		}
-		myunlock()
-		// postgres runs in a separate process and seems to have no problems
-		// with concurrent access and modifications.
-		disableMutex = true
-		c := (&Client{db: db}).ensure()
-		return c, nil
+		dialector = newMySQLDialector(dsn)
 	default:
-		myunlock()
 		return nil, fmt.Errorf("unsupported database %s", gormDBName)
 	}
+
+	db, err := gorm.Open(dialector, config())
+	if err != nil {
+		log.Printf("Failed to open %s database: %s", gormDBName, err)
+		return nil, err
+	}
+	return db, nil
 }
 
-// Close closes a database session.
-func (c *Client) Close() {
-	mylock()
-	defer myunlock()
-	c.close()
+// NewClient creates a new database client with a pool of connections.
+// SQLite databases are migrated to the latest schema version when they are opened.
+// Other databases must be migrated explicitly, and an error is returned if
+// their schema is not at the version required by this server.
+func NewClient(ctx context.Context, gormDBName, gormConfig string) (*Client, error) {
+	m, err := NewMigrator(ctx, gormDBName, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if gormDBName == "sqlite3" {
+		_, err = m.Up(ctx, 0)
+	} else {
+		err = m.check(ctx)
+	}
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	return &Client{db: m.db}, nil
 }
 
-func (c *Client) close() {
-	clientCount--
-	sqlDB, _ := c.db.DB()
-	sqlDB.Close()
+// ConfigurePool applies a pool configuration to the client's database connections.
+func (c *Client) ConfigurePool(p PoolConfig) error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+
+	if p.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+	return nil
 }
 
-func (c *Client) ensureTable(v interface{}) {
-	mylock()
-	defer myunlock()
-	if !c.db.Migrator().HasTable(v) {
-		c.db.Migrator().CreateTable(v)
+// Close closes the client's database connections.
+func (c *Client) Close() {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		log.Printf("Failed to get database for closing: %s", err)
+		return
 	}
+	sqlDB.Close()
 }
 
-func (c *Client) ensure() *Client {
-	c.ensureTable(&models.Project{})
-	c.ensureTable(&models.Api{})
-	c.ensureTable(&models.Version{})
-	c.ensureTable(&models.Spec{})
-	c.ensureTable(&models.Blob{})
-	c.ensureTable(&models.Artifact{})
-	c.ensureTable(&models.SpecRevisionTag{})
-	return c
+// byKey returns a condition that selects the entity with a key.
+// The column name is quoted because KEY is a reserved word in MySQL.
+func byKey(name string) clause.Expression {
+	return clause.Eq{Column: clause.Column{Name: "key"}, Value: name}
 }
 
 // IsNotFound returns true if an error is due to an entity not being found.
@@ -164,16 +197,44 @@ func (c *Client) IsNotFound(err error) bool {
 
 // Get gets an entity using the storage client.
 func (c *Client) Get(ctx context.Context, k storage.Key, v interface{}) error {
-	mylock()
-	defer myunlock()
-	return c.db.Where("key = ?", k.(*Key).Name).First(v).Error
+	return c.db.Where(byKey(k.(*Key).Name)).First(v).Error
+}
+
+// GetForUpdate gets an entity and locks it until the current transaction ends.
+// SQLite doesn't support row locks, but its write transactions are already serialized.
+func (c *Client) GetForUpdate(ctx context.Context, k storage.Key, v interface{}) error {
+	return c.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where(byKey(k.(*Key).Name)).First(v).Error
+}
+
+// Create puts an entity unless an entity with the same key exists.
+func (c *Client) Create(ctx context.Context, k storage.Key, v interface{}) (bool, error) {
+	f := reflect.ValueOf(v).Elem().FieldByName("Key")
+	if !f.IsValid() {
+		return false, fmt.Errorf("unsupported entity type %T", v)
+	}
+	f.SetString(k.(*Key).Name)
+
+	created := false
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(v)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return saveLabels(tx, k.(*Key).Name, v)
+	})
+	return created, err
 }
 
 // Put puts an entity using the storage client.
 func (c *Client) Put(ctx context.Context, k storage.Key, v interface{}) (storage.Key, error) {
-	mylock()
-	defer myunlock()
 	switch r := v.(type) {
+	case *models.Change:
+		// Changes are identified by sequential IDs that are assigned when they are created.
+		if err := c.db.Save(r).Error; err != nil {
+			return nil, err
+		}
+		return c.NewKey(models.ChangeEntityName, strconv.FormatInt(r.ID, 10)), nil
 	case *models.Project:
 		r.Key = k.(*Key).Name
 	case *models.Api:
@@ -184,146 +245,226 @@ func (c *Client) Put(ctx context.Context, k storage.Key, v interface{}) (storage
 		r.Key = k.(*Key).Name
 	case *models.SpecRevisionTag:
 		r.Key = k.(*Key).Name
+	case *models.Deployment:
+		r.Key = k.(*Key).Name
+	case *models.DeploymentRevisionTag:
+		r.Key = k.(*Key).Name
 	case *models.Blob:
 		r.Key = k.(*Key).Name
+	case *models.BlobContents:
+		r.Key = k.(*Key).Name
 	case *models.Artifact:
 		r.Key = k.(*Key).Name
+	case *models.ArtifactRevision:
+		r.Key = k.(*Key).Name
+	case *models.ArtifactRevisionTag:
+		r.Key = k.(*Key).Name
+	case *models.Request:
+		r.Key = k.(*Key).Name
+	case *models.Operation:
+		r.Key = k.(*Key).Name
 	}
-	c.db.Transaction(
+	err := c.db.Transaction(
 		func(tx *gorm.DB) error {
 			// Update all fields from model: https://gorm.io/docs/update.html#Update-Selected-Fields
-			rowsAffected := tx.Model(v).Select("*").Where("key = ?", k.(*Key).Name).Updates(v).RowsAffected
+			rowsAffected := tx.Model(v).Select("*").Where(byKey(k.(*Key).Name)).Updates(v).RowsAffected
 			if rowsAffected == 0 {
 				err := tx.Create(v).Error
 				if err != nil {
 					log.Printf("CREATE ERROR %s", err.Error())
+					return err
 				}
 			}
-			return nil
+			return saveLabels(tx, k.(*Key).Name, v)
 		})
+	if err != nil {
+		return nil, err
+	}
 	return k, nil
 }
 
+// Transaction runs fn with a client whose operations are committed together.
+func (c *Client) Transaction(ctx context.Context, fn func(context.Context, storage.Client) error) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		return fn(ctx, &Client{db: tx})
+	})
+}
+
 // Delete deletes an entity using the storage client.
 func (c *Client) Delete(ctx context.Context, k storage.Key) error {
-	mylock()
-	defer myunlock()
 	var err error
 	switch k.(*Key).Kind {
 	case "Project":
-		err = c.db.Delete(&models.Project{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Project{}, byKey(k.(*Key).Name)).Error
 	case "Api":
-		err = c.db.Delete(&models.Api{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Api{}, byKey(k.(*Key).Name)).Error
 	case "Version":
-		err = c.db.Delete(&models.Version{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Version{}, byKey(k.(*Key).Name)).Error
 	case "Spec":
-		err = c.db.Delete(&models.Spec{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Spec{}, byKey(k.(*Key).Name)).Error
 	case "SpecRevisionTag":
-		err = c.db.Delete(&models.SpecRevisionTag{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.SpecRevisionTag{}, byKey(k.(*Key).Name)).Error
+	case "Deployment":
+		err = c.db.Delete(&models.Deployment{}, byKey(k.(*Key).Name)).Error
+	case "DeploymentRevisionTag":
+		err = c.db.Delete(&models.DeploymentRevisionTag{}, byKey(k.(*Key).Name)).Error
 	case "Blob":
-		err = c.db.Delete(&models.Blob{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Blob{}, byKey(k.(*Key).Name)).Error
+	case "BlobContents":
+		err = c.db.Delete(&models.BlobContents{}, byKey(k.(*Key).Name)).Error
 	case "Artifact":
-		err = c.db.Delete(&models.Artifact{}, "key = ?", k.(*Key).Name).Error
+		err = c.db.Delete(&models.Artifact{}, byKey(k.(*Key).Name)).Error
+	case "ArtifactRevision":
+		err = c.db.Delete(&models.ArtifactRevision{}, byKey(k.(*Key).Name)).Error
+	case "ArtifactRevisionTag":
+		err = c.db.Delete(&models.ArtifactRevisionTag{}, byKey(k.(*Key).Name)).Error
+	case "Request":
+		err = c.db.Delete(&models.Request{}, byKey(k.(*Key).Name)).Error
+	case "Operation":
+		err = c.db.Delete(&models.Operation{}, byKey(k.(*Key).Name)).Error
 	default:
 		return fmt.Errorf("invalid key type (fix in client.go): %s", k.(*Key).Kind)
 	}
+	if err == nil && labeledKinds[k.(*Key).Kind] {
+		err = deleteLabels(c.db, k.(*Key).Kind, k.(*Key).Name)
+	}
 	if err != nil {
 		log.Printf("ignoring error: %+v", err)
 	}
 	return nil
 }
 
+// batchSize is the number of entities that iterators read from the database at a time.
+var batchSize = 1000
+
 // Run runs a query using the storage client, returning an iterator.
+// Results are read in batches as the iterator advances.
 func (c *Client) Run(ctx context.Context, q storage.Query) storage.Iterator {
-	mylock()
-	defer myunlock()
-
-	// Filtering is currently implemented by skipping iterator elements that
-	// don't match the filter criteria, and expects to only reach the end of
-	// the iterator if there are no more resources to consider. Previously,
-	// the entire table would be read into memory. This limit should maintain
-	// that behavior until we improve our iterator implementation.
-	op := c.db.Offset(q.(*Query).Offset).Limit(100000)
-	for _, r := range q.(*Query).Requirements {
-		op = op.Where(r.Name+" = ?", r.Value)
-	}
+	query := q.(*Query)
+	return &Iterator{Client: c, query: query, fetch: func(after *Condition, limit int) (interface{}, error) {
+		op := c.db.Limit(limit).Order(query.orderClause())
+		if query.Locking {
+			op = op.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		for _, r := range query.Requirements {
+			op = op.Where(r.Clause(), r.Value)
+		}
+		for _, cond := range query.cursorConditions(after) {
+			op = op.Where(cond.SQL, cond.Args...)
+		}
 
-	if order := q.(*Query).Order; order != "" {
-		op = op.Order(order)
-	} else {
-		op = op.Order("key")
-	}
+		switch query.Kind {
+		case "Project":
+			var v []models.Project
+			return v, op.Find(&v).Error
+		case "Api":
+			var v []models.Api
+			return v, op.Find(&v).Error
+		case "Version":
+			var v []models.Version
+			return v, op.Find(&v).Error
+		case "Spec":
+			var v []models.Spec
+			return v, op.Find(&v).Error
+		case "Blob":
+			var v []models.Blob
+			return v, op.Find(&v).Error
+		case "Artifact":
+			var v []models.Artifact
+			return v, op.Find(&v).Error
+		case "ArtifactRevision":
+			var v []models.ArtifactRevision
+			return v, op.Find(&v).Error
+		case "ArtifactRevisionTag":
+			var v []models.ArtifactRevisionTag
+			return v, op.Find(&v).Error
+		case "SpecRevisionTag":
+			var v []models.SpecRevisionTag
+			return v, op.Find(&v).Error
+		case "Deployment":
+			var v []models.Deployment
+			return v, op.Find(&v).Error
+		case "DeploymentRevisionTag":
+			var v []models.DeploymentRevisionTag
+			return v, op.Find(&v).Error
+		case "Change":
+			var v []models.Change
+			return v, op.Find(&v).Error
+		case "Request":
+			var v []models.Request
+			return v, op.Find(&v).Error
+		case "Operation":
+			var v []models.Operation
+			return v, op.Find(&v).Error
+		default:
+			return nil, fmt.Errorf("unable to run query for kind %s", query.Kind)
+		}
+	}}
+}
+
+// GetRecentSpecRevisions runs a query for specs that only returns their most recent revisions.
+func (c *Client) GetRecentSpecRevisions(ctx context.Context, q storage.Query) storage.Iterator {
+	query := q.(*Query)
+	return &Iterator{Client: c, query: query, fetch: func(after *Condition, limit int) (interface{}, error) {
+		// Select all columns from `specs` table specifically.
+		// We do not want to select duplicates from the joined subquery result.
+		op := c.db.Select("specs.*").
+			Table("specs").
+			// Join missing columns that couldn't be selected in the subquery.
+			Joins(`JOIN (?) AS grp ON specs.project_id = grp.project_id AND
+				specs.api_id = grp.api_id AND
+				specs.version_id = grp.version_id AND
+				specs.spec_id = grp.spec_id AND
+				specs.revision_create_time = grp.recent_create_time`,
+				// Select spec names and only their most recent revision_create_time
+				// This query cannot select all the columns we want.
+				// See: https://stackoverflow.com/questions/7745609/sql-select-only-rows-with-max-value-on-a-column
+				c.db.Select("project_id, api_id, version_id, spec_id, MAX(revision_create_time) AS recent_create_time").
+					Table("specs").
+					Group("project_id, api_id, version_id, spec_id")).
+			Order(query.orderClause()).
+			Limit(limit)
+
+		for _, r := range query.Requirements {
+			op = op.Where("specs."+r.Clause(), r.Value)
+		}
+		for _, cond := range query.cursorConditions(after) {
+			op = op.Where(cond.SQL, cond.Args...)
+		}
 
-	switch q.(*Query).Kind {
-	case "Project":
-		var v []models.Project
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "Api":
-		var v []models.Api
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "Version":
-		var v []models.Version
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "Spec":
 		var v []models.Spec
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "Blob":
-		var v []models.Blob
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "Artifact":
-		var v []models.Artifact
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	case "SpecRevisionTag":
-		var v []models.SpecRevisionTag
-		_ = op.Find(&v).Error
-		return &Iterator{Client: c, Values: v, Index: 0}
-	default:
-		log.Printf("Unable to run query for kind %s", q.(*Query).Kind)
-		return nil
-	}
+		return v, op.Scan(&v).Error
+	}}
 }
 
-func (c *Client) GetRecentSpecRevisions(ctx context.Context, offset int32, projectID, apiID, versionID string) storage.Iterator {
-	mylock()
-	defer myunlock()
-
-	// Select all columns from `specs` table specifically.
-	// We do not want to select duplicates from the joined subquery result.
-	op := c.db.Select("specs.*").
-		Table("specs").
-		// Join missing columns that couldn't be selected in the subquery.
-		Joins(`JOIN (?) AS grp ON specs.project_id = grp.project_id AND
-			specs.api_id = grp.api_id AND
-			specs.version_id = grp.version_id AND
-			specs.spec_id = grp.spec_id AND
-			specs.revision_create_time = grp.recent_create_time`,
-			// Select spec names and only their most recent revision_create_time
-			// This query cannot select all the columns we want.
-			// See: https://stackoverflow.com/questions/7745609/sql-select-only-rows-with-max-value-on-a-column
-			c.db.Select("project_id, api_id, version_id, spec_id, MAX(revision_create_time) AS recent_create_time").
-				Table("specs").
-				Group("project_id, api_id, version_id, spec_id")).
-		Order("key").
-		Offset(int(offset)).
-		Limit(100000)
-
-	if projectID != "-" {
-		op = op.Where("specs.project_id = ?", projectID)
-	}
-	if apiID != "-" {
-		op = op.Where("specs.api_id = ?", apiID)
-	}
-	if versionID != "-" {
-		op = op.Where("specs.version_id = ?", versionID)
-	}
+// GetRecentDeploymentRevisions runs a query for deployments that only returns their most recent revisions.
+func (c *Client) GetRecentDeploymentRevisions(ctx context.Context, q storage.Query) storage.Iterator {
+	query := q.(*Query)
+	return &Iterator{Client: c, query: query, fetch: func(after *Condition, limit int) (interface{}, error) {
+		// Select all columns from `deployments` table specifically.
+		// We do not want to select duplicates from the joined subquery result.
+		op := c.db.Select("deployments.*").
+			Table("deployments").
+			// Join missing columns that couldn't be selected in the subquery.
+			Joins(`JOIN (?) AS grp ON deployments.project_id = grp.project_id AND
+				deployments.api_id = grp.api_id AND
+				deployments.deployment_id = grp.deployment_id AND
+				deployments.revision_create_time = grp.recent_create_time`,
+				// Select deployment names and only their most recent revision_create_time.
+				c.db.Select("project_id, api_id, deployment_id, MAX(revision_create_time) AS recent_create_time").
+					Table("deployments").
+					Group("project_id, api_id, deployment_id")).
+			Order(query.orderClause()).
+			Limit(limit)
+
+		for _, r := range query.Requirements {
+			op = op.Where("deployments."+r.Clause(), r.Value)
+		}
+		for _, cond := range query.cursorConditions(after) {
+			op = op.Where(cond.SQL, cond.Args...)
+		}
 
-	var v []models.Spec
-	_ = op.Scan(&v).Error
-	return &Iterator{Client: c, Values: v, Index: 0}
+		var v []models.Deployment
+		return v, op.Scan(&v).Error
+	}}
 }
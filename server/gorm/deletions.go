@@ -24,9 +24,19 @@ import (
 
 // DeleteAllMatches deletes all entities matching a query.
 func (c *Client) DeleteAllMatches(ctx context.Context, q storage.Query) error {
+	if kind := q.(*Query).Kind; labeledKinds[kind] {
+		op := c.db.Where("kind = ?", kind)
+		for _, r := range q.(*Query).Requirements {
+			op = op.Where(r.Clause(), r.Value)
+		}
+		if err := op.Delete(models.Label{}).Error; err != nil {
+			return err
+		}
+	}
+
 	op := c.db
 	for _, r := range q.(*Query).Requirements {
-		op = op.Where(r.Name+" = ?", r.Value)
+		op = op.Where(r.Clause(), r.Value)
 	}
 	switch q.(*Query).Kind {
 	case "Project":
@@ -39,10 +49,26 @@ func (c *Client) DeleteAllMatches(ctx context.Context, q storage.Query) error {
 		return op.Delete(models.Spec{}).Error
 	case "Blob":
 		return op.Delete(models.Blob{}).Error
+	case "BlobContents":
+		return op.Delete(models.BlobContents{}).Error
 	case "Artifact":
 		return op.Delete(models.Artifact{}).Error
+	case "ArtifactRevision":
+		return op.Delete(models.ArtifactRevision{}).Error
+	case "ArtifactRevisionTag":
+		return op.Delete(models.ArtifactRevisionTag{}).Error
 	case "SpecRevisionTag":
 		return op.Delete(models.SpecRevisionTag{}).Error
+	case "Deployment":
+		return op.Delete(models.Deployment{}).Error
+	case "DeploymentRevisionTag":
+		return op.Delete(models.DeploymentRevisionTag{}).Error
+	case "Change":
+		return op.Delete(models.Change{}).Error
+	case "Request":
+		return op.Delete(models.Request{}).Error
+	case "Operation":
+		return op.Delete(models.Operation{}).Error
 	}
 	return nil
 }
@@ -51,10 +77,14 @@ func (c *Client) DeleteAllMatches(ctx context.Context, q storage.Query) error {
 func (c *Client) DeleteChildrenOfProject(ctx context.Context, project names.Project) error {
 	entityNames := []string{
 		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
 		models.BlobEntityName,
 		storage.SpecEntityName,
 		storage.SpecRevisionTagEntityName,
 		storage.VersionEntityName,
+		storage.DeploymentEntityName,
+		storage.DeploymentRevisionTagEntityName,
 		storage.ApiEntityName,
 	}
 	for _, entityName := range entityNames {
@@ -72,9 +102,14 @@ func (c *Client) DeleteChildrenOfProject(ctx context.Context, project names.Proj
 func (c *Client) DeleteChildrenOfApi(ctx context.Context, api names.Api) error {
 	for _, entityName := range []string{
 		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
 		models.BlobEntityName,
 		storage.SpecEntityName,
+		storage.SpecRevisionTagEntityName,
 		storage.VersionEntityName,
+		storage.DeploymentEntityName,
+		storage.DeploymentRevisionTagEntityName,
 	} {
 		q := c.NewQuery(entityName)
 		q = q.Require("ProjectID", api.ProjectID)
@@ -91,8 +126,11 @@ func (c *Client) DeleteChildrenOfApi(ctx context.Context, api names.Api) error {
 func (c *Client) DeleteChildrenOfVersion(ctx context.Context, version names.Version) error {
 	for _, entityName := range []string{
 		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
 		models.BlobEntityName,
 		storage.SpecEntityName,
+		storage.SpecRevisionTagEntityName,
 	} {
 		q := c.NewQuery(entityName)
 		q = q.Require("ProjectID", version.ProjectID)
@@ -109,7 +147,10 @@ func (c *Client) DeleteChildrenOfVersion(ctx context.Context, version names.Vers
 func (c *Client) DeleteChildrenOfSpec(ctx context.Context, spec names.Spec) error {
 	for _, entityName := range []string{
 		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
 		models.BlobEntityName,
+		storage.SpecRevisionTagEntityName,
 	} {
 		q := c.NewQuery(entityName)
 		q = q.Require("ProjectID", spec.ProjectID)
@@ -0,0 +1,140 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage/filtering"
+)
+
+// Condition is a SQL condition that filters query results.
+type Condition struct {
+	SQL  string
+	Args []interface{}
+}
+
+var sqlOperators = map[filtering.Operator]string{
+	filtering.Equal:        "=",
+	filtering.NotEqual:     "<>",
+	filtering.Less:         "<",
+	filtering.LessEqual:    "<=",
+	filtering.Greater:      ">",
+	filtering.GreaterEqual: ">=",
+}
+
+// ApplyFilter adds a filter condition to a query if it can be translated to SQL.
+func (q *Query) ApplyFilter(c *filtering.Condition) bool {
+	sql, args, err := q.translate(c)
+	if err != nil {
+		return false
+	}
+
+	q.Conditions = append(q.Conditions, &Condition{SQL: sql, Args: args})
+	return true
+}
+
+// translate converts a filter condition into a SQL condition on the query's table.
+func (q *Query) translate(c *filtering.Condition) (string, []interface{}, error) {
+	switch c.Operator {
+	case filtering.And, filtering.Or:
+		left, leftArgs, err := q.translate(c.Operands[0])
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := q.translate(c.Operands[1])
+		if err != nil {
+			return "", nil, err
+		}
+		op := "AND"
+		if c.Operator == filtering.Or {
+			op = "OR"
+		}
+		return fmt.Sprintf("(%s %s %s)", left, op, right), append(leftArgs, rightArgs...), nil
+	case filtering.Not:
+		operand, args, err := q.translate(c.Operands[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", operand), args, nil
+	}
+
+	table := q.client.db.NamingStrategy.TableName(q.Kind)
+	if c.Type == filtering.StringMap {
+		return q.translateLabel(table, c)
+	}
+
+	// SQLite stores timestamps as text that includes the local time zone offset,
+	// so they can't be compared reliably with timestamps from filters.
+	if c.Type == filtering.Timestamp && q.client.db.Dialector.Name() == "sqlite" {
+		return "", nil, fmt.Errorf("timestamp comparisons are unsupported")
+	}
+
+	column := table + "." + q.client.db.NamingStrategy.ColumnName(table, c.Field)
+	return q.compare(column, c)
+}
+
+// translateLabel converts a condition on labels into a SQL condition that selects labeled entities.
+func (q *Query) translateLabel(table string, c *filtering.Condition) (string, []interface{}, error) {
+	switch q.Kind {
+	case "Api", "Version", "Spec":
+	default:
+		return "", nil, fmt.Errorf("labels are unsupported for %s", q.Kind)
+	}
+
+	labels := q.client.db.NamingStrategy.TableName(models.LabelEntityName)
+	sql := fmt.Sprintf("%s.key IN (SELECT entity_key FROM %s WHERE kind = ? AND label = ?", table, labels)
+	args := []interface{}{q.Kind, c.Key}
+	if c.Operator == filtering.Has {
+		return sql + ")", args, nil
+	}
+
+	value, valueArgs, err := q.compare("value", c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sql + " AND " + value + ")", append(args, valueArgs...), nil
+}
+
+// compare converts a comparison on a column into a SQL condition.
+func (q *Query) compare(column string, c *filtering.Condition) (string, []interface{}, error) {
+	switch v := c.Value.(type) {
+	case string, int64, time.Time:
+	default:
+		return "", nil, fmt.Errorf("unsupported value type %T", v)
+	}
+
+	switch c.Operator {
+	case filtering.StartsWith:
+		prefix := c.Value.(string)
+		return fmt.Sprintf("substr(%s, 1, ?) = ?", column), []interface{}{utf8.RuneCountInString(prefix), prefix}, nil
+	case filtering.Contains:
+		// LIKE is avoided because it is case insensitive in SQLite.
+		if q.client.db.Dialector.Name() == "postgres" {
+			return fmt.Sprintf("strpos(%s, ?) > 0", column), []interface{}{c.Value}, nil
+		}
+		return fmt.Sprintf("instr(%s, ?) > 0", column), []interface{}{c.Value}, nil
+	}
+
+	op, ok := sqlOperators[c.Operator]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported operator %d", c.Operator)
+	}
+	return fmt.Sprintf("%s %s ?", column, op), []interface{}{c.Value}, nil
+}
@@ -17,13 +17,20 @@ package gorm
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/apigee/registry/rpc"
 	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
 	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/protobuf/testing/protocmp"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm/schema"
 )
 
 func TestFieldClearing(t *testing.T) {
@@ -123,6 +130,33 @@ func TestCRUD(t *testing.T) {
 	}
 }
 
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewClient(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	k := c.NewKey(models.BlobContentsEntityName, "hash")
+	for i, want := range []bool{true, false} {
+		contents := &models.BlobContents{Contents: []byte(fmt.Sprintf("contents %d", i))}
+		if created, err := c.Create(ctx, k, contents); err != nil {
+			t.Fatalf("Create(%s) returned error: %s", k, err)
+		} else if created != want {
+			t.Errorf("Create(%s) returned created=%t, want %t", k, created, want)
+		}
+	}
+
+	// Entities that already exist are left unchanged.
+	got := new(models.BlobContents)
+	if err := c.GetForUpdate(ctx, k, got); err != nil {
+		t.Fatalf("GetForUpdate(%s) returned error: %s", k, err)
+	} else if string(got.Contents) != "contents 0" || got.Key != "hash" {
+		t.Errorf("GetForUpdate(%s) returned %+v, want the first contents", k, got)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	ctx := context.Background()
 
@@ -153,3 +187,526 @@ func TestLoad(t *testing.T) {
 		c.Close()
 	}
 }
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := NewClient(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	committed := c.NewKey(storage.ProjectEntityName, "projects/committed")
+	if err := c.Transaction(ctx, func(ctx context.Context, tx storage.Client) error {
+		_, err := tx.Put(ctx, committed, &models.Project{ProjectID: "committed"})
+		return err
+	}); err != nil {
+		t.Fatalf("Transaction returned error: %s", err)
+	}
+
+	if err := c.Get(ctx, committed, new(models.Project)); err != nil {
+		t.Errorf("Get(%q) returned error for committed entity: %s", committed, err)
+	}
+
+	rolledBack := c.NewKey(storage.ProjectEntityName, "projects/rolled-back")
+	if err := c.Transaction(ctx, func(ctx context.Context, tx storage.Client) error {
+		if _, err := tx.Put(ctx, rolledBack, &models.Project{ProjectID: "rolled-back"}); err != nil {
+			return err
+		}
+		return fmt.Errorf("rollback")
+	}); err == nil {
+		t.Fatalf("Transaction returned nil error, expected error from fn")
+	}
+
+	if err := c.Get(ctx, rolledBack, new(models.Project)); !c.IsNotFound(err) {
+		t.Errorf("Get(%q) returned error %v for rolled back entity, want not found", rolledBack, err)
+	}
+}
+
+func TestLabelFilters(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/testing.db"
+
+	c, err := NewClient(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	for id, labels := range map[string]map[string]string{
+		"prod": {"env": "prod"},
+		"dev":  {"env": "dev"},
+		"none": nil,
+	} {
+		api, err := models.NewApi(names.Api{ProjectID: "p", ApiID: id}, &rpc.Api{Labels: labels})
+		if err != nil {
+			t.Fatalf("Setup: NewApi(%q) returned error: %s", id, err)
+		}
+		k := c.NewKey(storage.ApiEntityName, api.Name())
+		if _, err := c.Put(ctx, k, api); err != nil {
+			t.Fatalf("Setup: Put(%q) returned error: %s", k, err)
+		}
+	}
+
+	apiIDs := func(c *Client, filter string) []string {
+		t.Helper()
+		f, err := filtering.NewFilter(filter, []filtering.Field{
+			{Name: "labels", Type: filtering.StringMap, StorageName: "Labels"},
+		})
+		if err != nil {
+			t.Fatalf("NewFilter(%q) returned error: %s", filter, err)
+		}
+
+		q := c.NewQuery(storage.ApiEntityName)
+		for _, cond := range f.Conjuncts() {
+			if !q.ApplyFilter(cond) {
+				t.Fatalf("ApplyFilter(%+v) returned false for filter %q", cond, filter)
+			}
+		}
+
+		ids := make([]string, 0)
+		it := c.Run(ctx, q)
+		api := new(models.Api)
+		for _, err := it.Next(api); err == nil; _, err = it.Next(api) {
+			ids = append(ids, api.ApiID)
+		}
+		return ids
+	}
+
+	if got, want := apiIDs(c, "labels.env == 'prod'"), []string{"prod"}; !cmp.Equal(got, want) {
+		t.Errorf("Run() returned %v, want %v", got, want)
+	}
+
+	if got, want := apiIDs(c, "!('env' in labels)"), []string{"none"}; !cmp.Equal(got, want) {
+		t.Errorf("Run() returned %v, want %v", got, want)
+	}
+
+	// Labels are removed with their entities.
+	if err := c.Delete(ctx, c.NewKey(storage.ApiEntityName, "projects/p/apis/prod")); err != nil {
+		t.Fatalf("Delete() returned error: %s", err)
+	}
+	if got, want := apiIDs(c, "has(labels.env)"), []string{"dev"}; !cmp.Equal(got, want) {
+		t.Errorf("Run() returned %v, want %v", got, want)
+	}
+
+	// Labels of existing entities are stored when the labels table is created.
+	m, err := NewMigrator(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %s", err)
+	}
+	if _, err := m.Down(ctx, 2); err != nil {
+		t.Fatalf("Setup: Down(2) returned error: %s", err)
+	}
+	m.Close()
+
+	reopened, err := NewClient(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer reopened.Close()
+
+	if got, want := apiIDs(reopened, "labels.env.startsWith('d')"), []string{"dev"}; !cmp.Equal(got, want) {
+		t.Errorf("Run() returned %v, want %v", got, want)
+	}
+}
+
+func TestKeysetIteration(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := NewClient(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	defer func(size int) { batchSize = size }(batchSize)
+	batchSize = 2
+
+	for i := 1; i <= 5; i++ {
+		p := &models.Project{ProjectID: fmt.Sprintf("p%d", i)}
+		k := c.NewKey(storage.ProjectEntityName, p.Name())
+		if _, err := c.Put(ctx, k, p); err != nil {
+			t.Fatalf("Setup: Put(%q) returned error: %s", k, err)
+		}
+	}
+
+	projectIDs := func(q storage.Query) []string {
+		t.Helper()
+		ids := make([]string, 0)
+		it := c.Run(ctx, q)
+		p := new(models.Project)
+		for _, err := it.Next(p); err == nil; _, err = it.Next(p) {
+			ids = append(ids, p.ProjectID)
+		}
+		return ids
+	}
+
+	// Iteration reads every entity across several batches.
+	want := []string{"p1", "p2", "p3", "p4", "p5"}
+	if got := projectIDs(c.NewQuery(storage.ProjectEntityName)); !cmp.Equal(got, want) {
+		t.Errorf("Run() returned %v, want %v", got, want)
+	}
+
+	// Iteration resumes after the given key.
+	want = []string{"p3", "p4", "p5"}
+	if got := projectIDs(c.NewQuery(storage.ProjectEntityName).StartAfter("projects/p2")); !cmp.Equal(got, want) {
+		t.Errorf("Run() with StartAfter returned %v, want %v", got, want)
+	}
+
+	// Entities created before the cursor position are not returned.
+	p := &models.Project{ProjectID: "p0"}
+	if _, err := c.Put(ctx, c.NewKey(storage.ProjectEntityName, p.Name()), p); err != nil {
+		t.Fatalf("Setup: Put(%q) returned error: %s", p.Name(), err)
+	}
+	if got := projectIDs(c.NewQuery(storage.ProjectEntityName).StartAfter("projects/p2")); !cmp.Equal(got, want) {
+		t.Errorf("Run() with StartAfter returned %v, want %v", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := NewClient(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	if err := c.ConfigurePool(PoolConfig{MaxOpenConns: 8}); err != nil {
+		t.Fatalf("ConfigurePool returned error: %s", err)
+	}
+
+	var mode string
+	if err := c.db.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil {
+		t.Fatalf("Failed to read journal mode: %s", err)
+	} else if mode != "wal" {
+		t.Errorf("SQLite journal mode is %q, want %q", mode, "wal")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := &models.Project{ProjectID: fmt.Sprintf("p%03d", i)}
+			errs <- c.Transaction(ctx, func(ctx context.Context, tx storage.Client) error {
+				k := tx.NewKey(storage.ProjectEntityName, p.Name())
+				if err := tx.Get(ctx, k, new(models.Project)); err == nil {
+					return fmt.Errorf("project %q already exists", p.Name())
+				}
+				_, err := tx.Put(ctx, k, p)
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Transaction returned error: %s", err)
+		}
+	}
+
+	count := 0
+	it := c.Run(ctx, c.NewQuery(storage.ProjectEntityName))
+	for _, err := it.Next(new(models.Project)); err == nil; _, err = it.Next(new(models.Project)) {
+		count++
+	}
+	if count != 100 {
+		t.Errorf("Run() returned %d projects, want 100", count)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/testing.db"
+
+	m, err := NewMigrator(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %s", err)
+	}
+	defer m.Close()
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %s", err)
+	}
+	for _, s := range status {
+		if s.Applied() {
+			t.Errorf("Migration %d is applied to a new database", s.Version)
+		}
+	}
+
+	if applied, err := m.Up(ctx, 1); err != nil {
+		t.Fatalf("Up(1) returned error: %s", err)
+	} else if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("Up(1) applied %+v, want only migration 1", applied)
+	}
+	if !m.db.Migrator().HasTable("projects") || m.db.Migrator().HasTable("changes") {
+		t.Errorf("Up(1) did not create exactly the tables of migration 1")
+	}
+
+	// Clients can't be created until the schema is at the latest version, except for SQLite.
+	if err := m.check(ctx); err == nil {
+		t.Errorf("check() returned nil error for schema version 1")
+	}
+
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up(0) returned error: %s", err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != latestVersion() {
+		t.Errorf("Version() returned (%d, %v), want (%d, nil)", v, err, latestVersion())
+	}
+	if err := m.check(ctx); err != nil {
+		t.Errorf("check() returned error for latest schema version: %s", err)
+	}
+
+	if reverted, err := m.Down(ctx, 0); err != nil {
+		t.Fatalf("Down(0) returned error: %s", err)
+	} else if len(reverted) != len(migrations) {
+		t.Errorf("Down(0) reverted %d migrations, want %d", len(reverted), len(migrations))
+	}
+	for _, table := range []string{"projects", "changes", "labels", "blob_contents", "deployments", "requests", "operations"} {
+		if m.db.Migrator().HasTable(table) {
+			t.Errorf("Down(0) did not drop table %q", table)
+		}
+	}
+
+	if _, err := m.Up(ctx, latestVersion()+1); err == nil {
+		t.Errorf("Up(%d) returned nil error for unknown version", latestVersion()+1)
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/testing.db"
+
+	// Databases created before migrations were introduced have tables but no recorded versions.
+	db, err := open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Setup: open returned error: %s", err)
+	}
+	if err := db.Migrator().CreateTable(&v1Project{}, &v1Api{}); err != nil {
+		t.Fatalf("Setup: CreateTable returned error: %s", err)
+	}
+	api := &v1Api{Key: "projects/p/apis/a", ProjectID: "p", ApiID: "a"}
+	if err := db.Create(api).Error; err != nil {
+		t.Fatalf("Setup: Create returned error: %s", err)
+	}
+	(&Client{db: db}).Close()
+
+	c, err := NewClient(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer c.Close()
+
+	got := new(models.Api)
+	if err := c.Get(ctx, c.NewKey(storage.ApiEntityName, api.Key), got); err != nil {
+		t.Errorf("Get(%q) returned error after migration: %s", api.Key, err)
+	}
+}
+
+func TestShareBlobContentsMigration(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := NewMigrator(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %s", err)
+	}
+	defer m.Close()
+
+	if _, err := m.Up(ctx, 3); err != nil {
+		t.Fatalf("Setup: Up(3) returned error: %s", err)
+	}
+
+	// Before version 4, every blob has a copy of its contents.
+	shared, unique := []byte("shared"), []byte("unique")
+	original := []v1Blob{
+		{Key: "projects/p/apis/a/versions/v/specs/s@1", Hash: "h1", SizeInBytes: 6, Contents: shared},
+		{Key: "projects/p/apis/a/versions/v/specs/s@2", Hash: "h1", SizeInBytes: 6, Contents: shared},
+		{Key: "projects/p/apis/b/versions/v/specs/s@1", Hash: "h1", SizeInBytes: 6, Contents: shared},
+		{Key: "projects/p/apis/b/versions/v/specs/t@1", Hash: "h2", SizeInBytes: 6, Contents: unique},
+		{Key: "projects/p/apis/b/versions/v/specs/u@1"},
+	}
+	if err := m.db.Create(&original).Error; err != nil {
+		t.Fatalf("Setup: Create returned error: %s", err)
+	}
+
+	if _, err := m.Up(ctx, 4); err != nil {
+		t.Fatalf("Up(4) returned error: %s", err)
+	}
+
+	var contents []v4BlobContents
+	if err := m.db.Order("key").Find(&contents).Error; err != nil {
+		t.Fatalf("Find(blob_contents) returned error: %s", err)
+	}
+	want := []v4BlobContents{
+		{Key: "h1", SizeInBytes: 6, Contents: shared},
+		{Key: "h2", SizeInBytes: 6, Contents: unique},
+	}
+	if !cmp.Equal(want, contents, cmpopts.IgnoreFields(v4BlobContents{}, "CreateTime")) {
+		t.Errorf("Up(4) moved unexpected contents (-want +got):\n%s", cmp.Diff(want, contents, cmpopts.IgnoreFields(v4BlobContents{}, "CreateTime")))
+	}
+
+	var remaining int64
+	if err := m.db.Model(&v4Blob{}).Where("contents IS NOT NULL").Count(&remaining).Error; err != nil {
+		t.Fatalf("Count(blobs) returned error: %s", err)
+	} else if remaining != 0 {
+		t.Errorf("Up(4) left contents in %d blobs, want 0", remaining)
+	}
+
+	if _, err := m.Down(ctx, 3); err != nil {
+		t.Fatalf("Down(3) returned error: %s", err)
+	}
+
+	var restored []v1Blob
+	if err := m.db.Order("key").Find(&restored).Error; err != nil {
+		t.Fatalf("Find(blobs) returned error: %s", err)
+	}
+	if !cmp.Equal(original, restored) {
+		t.Errorf("Down(3) restored unexpected blobs (-want +got):\n%s", cmp.Diff(original, restored))
+	}
+}
+
+func TestArtifactRevisionsMigration(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := NewMigrator(ctx, "sqlite3", t.TempDir()+"/testing.db")
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %s", err)
+	}
+	defer m.Close()
+
+	if _, err := m.Up(ctx, 4); err != nil {
+		t.Fatalf("Setup: Up(4) returned error: %s", err)
+	}
+
+	// Before version 5, artifacts have no revisions.
+	updated := time.Now().Round(time.Second).UTC()
+	artifact := &v1Artifact{Key: "projects/p/artifacts/a", ProjectID: "p", ArtifactID: "a", UpdateTime: updated, Hash: "h1", SizeInBytes: 8}
+	if err := m.db.Create(artifact).Error; err != nil {
+		t.Fatalf("Setup: Create returned error: %s", err)
+	}
+	if err := m.db.Create(&v4Blob{Key: artifact.Key, ProjectID: "p", ArtifactID: "a", Hash: "h1", SizeInBytes: 8}).Error; err != nil {
+		t.Fatalf("Setup: Create returned error: %s", err)
+	}
+	if err := m.db.Create(&v4BlobContents{Key: "h1", SizeInBytes: 8, Contents: []byte("contents")}).Error; err != nil {
+		t.Fatalf("Setup: Create returned error: %s", err)
+	}
+
+	if _, err := m.Up(ctx, 5); err != nil {
+		t.Fatalf("Up(5) returned error: %s", err)
+	}
+
+	var artifacts []v5Artifact
+	if err := m.db.Find(&artifacts).Error; err != nil {
+		t.Fatalf("Find(artifacts) returned error: %s", err)
+	} else if len(artifacts) != 1 || artifacts[0].RevisionID == "" {
+		t.Fatalf("Up(5) returned artifacts %+v, want one artifact with a revision ID", artifacts)
+	} else if !artifacts[0].RevisionCreateTime.Equal(updated) {
+		t.Errorf("Up(5) set revision_create_time %s, want %s", artifacts[0].RevisionCreateTime, updated)
+	}
+
+	revisionKey := artifact.Key + "@" + artifacts[0].RevisionID
+	var revisions []v5ArtifactRevision
+	if err := m.db.Find(&revisions).Error; err != nil {
+		t.Fatalf("Find(artifact_revisions) returned error: %s", err)
+	} else if len(revisions) != 1 || revisions[0].Key != revisionKey || revisions[0].Hash != "h1" {
+		t.Errorf("Up(5) created revisions %+v, want one revision %q", revisions, revisionKey)
+	}
+
+	var blobs []v4Blob
+	if err := m.db.Where(byKey(revisionKey)).Find(&blobs).Error; err != nil {
+		t.Fatalf("Find(blobs) returned error: %s", err)
+	} else if len(blobs) != 1 || blobs[0].Hash != "h1" {
+		t.Errorf("Up(5) created blobs %+v, want one blob for revision %q", blobs, revisionKey)
+	}
+
+	if _, err := m.Down(ctx, 4); err != nil {
+		t.Fatalf("Down(4) returned error: %s", err)
+	}
+	if m.db.Migrator().HasTable("artifact_revisions") || m.db.Migrator().HasColumn(&v5Artifact{}, "RevisionID") {
+		t.Errorf("Down(4) did not remove artifact revisions")
+	}
+
+	var remaining int64
+	if err := m.db.Model(&v4Blob{}).Count(&remaining).Error; err != nil {
+		t.Fatalf("Count(blobs) returned error: %s", err)
+	} else if remaining != 1 {
+		t.Errorf("Down(4) left %d blobs, want 1", remaining)
+	}
+	if err := m.db.Model(&v4BlobContents{}).Count(&remaining).Error; err != nil {
+		t.Fatalf("Count(blob_contents) returned error: %s", err)
+	} else if remaining != 1 {
+		t.Errorf("Down(4) left %d blob contents, want 1", remaining)
+	}
+}
+
+func TestMySQLSchema(t *testing.T) {
+	d := newMySQLDialector("").(mysqlDialector)
+
+	// Tables are created with the column types of this dialector.
+	if _, ok := d.Migrator(nil).(mysql.Migrator).Migrator.Dialector.(mysqlDialector); !ok {
+		t.Errorf("Migrator() does not use the registry's MySQL dialector")
+	}
+
+	dataTypes := func(model interface{}) map[string]string {
+		t.Helper()
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("schema.Parse(%T) returned error: %s", model, err)
+		}
+		types := make(map[string]string)
+		for _, f := range s.Fields {
+			types[f.Name] = d.DataTypeOf(f)
+		}
+		return types
+	}
+
+	api := dataTypes(&v1Api{})
+	want := map[string]string{
+		"Key":         "varchar(512) CHARACTER SET ascii COLLATE ascii_bin",
+		"Description": "longtext",
+		"CreateTime":  "datetime(6) NULL",
+		"Labels":      "longblob",
+	}
+	for name, dataType := range want {
+		if api[name] != dataType {
+			t.Errorf("DataTypeOf(%s) returned %q, want %q", name, api[name], dataType)
+		}
+	}
+
+	if got := dataTypes(&v1Blob{})["Contents"]; got != "longblob" {
+		t.Errorf("DataTypeOf(Contents) returned %q, want %q", got, "longblob")
+	}
+
+	label := dataTypes(&v3Label{})
+	want = map[string]string{
+		"Kind":      "varchar(255)",
+		"EntityKey": "varchar(512) CHARACTER SET ascii COLLATE ascii_bin",
+		"Label":     "varchar(255)",
+	}
+	for name, dataType := range want {
+		if label[name] != dataType {
+			t.Errorf("DataTypeOf(%s) returned %q, want %q", name, label[name], dataType)
+		}
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	got, err := mysqlDSN("registry:secret@tcp(localhost:3306)/registry")
+	if err != nil {
+		t.Fatalf("mysqlDSN returned error: %s", err)
+	}
+	if want := "registry:secret@tcp(localhost:3306)/registry?parseTime=true"; got != want {
+		t.Errorf("mysqlDSN returned %q, want %q", got, want)
+	}
+
+	if _, err := mysqlDSN("not a dsn"); err == nil {
+		t.Errorf("mysqlDSN returned nil error for invalid DSN")
+	}
+}
@@ -17,6 +17,8 @@ package gorm
 import (
 	"encoding/base64"
 	"fmt"
+	"reflect"
+	"strconv"
 
 	"github.com/apigee/registry/server/models"
 	"github.com/apigee/registry/server/storage"
@@ -29,6 +31,43 @@ type Iterator struct {
 	Values interface{}
 	Index  int
 	Cursor string
+
+	query *Query
+	// fetch reads up to limit results that follow the after condition.
+	fetch func(after *Condition, limit int) (interface{}, error)
+	// read is the number of results that have been fetched.
+	read int
+	// done is set when there are no more results to fetch.
+	done bool
+}
+
+// advance fetches the next batch of results when the current batch has been iterated through.
+func (it *Iterator) advance() error {
+	if it.done || (it.Values != nil && it.Index < reflect.ValueOf(it.Values).Len()) {
+		return nil
+	}
+
+	limit := batchSize
+	if it.query.Limit > 0 && it.query.Limit-it.read < limit {
+		limit = it.query.Limit - it.read
+	}
+
+	var after *Condition
+	if it.Values != nil {
+		values := reflect.ValueOf(it.Values)
+		after = it.query.afterValue(values.Index(values.Len() - 1))
+	}
+
+	values, err := it.fetch(after, limit)
+	if err != nil {
+		return err
+	}
+
+	it.Values, it.Index = values, 0
+	n := reflect.ValueOf(values).Len()
+	it.read += n
+	it.done = n < limit || (it.query.Limit > 0 && it.read >= it.query.Limit)
+	return nil
 }
 
 // GetCursor gets the cursor for the next page of results.
@@ -39,6 +78,10 @@ func (it *Iterator) GetCursor() (string, error) {
 
 // Next gets the next value from the iterator.
 func (it *Iterator) Next(v interface{}) (storage.Key, error) {
+	if err := it.advance(); err != nil {
+		return nil, err
+	}
+
 	switch x := v.(type) {
 	case *models.Project:
 		values := it.Values.([]models.Project)
@@ -94,6 +137,24 @@ func (it *Iterator) Next(v interface{}) (storage.Key, error) {
 			return it.Client.NewKey("Artifact", x.Key), nil
 		}
 		return nil, iterator.Done
+	case *models.ArtifactRevision:
+		values := it.Values.([]models.ArtifactRevision)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("ArtifactRevision", x.Key), nil
+		}
+		return nil, iterator.Done
+	case *models.ArtifactRevisionTag:
+		values := it.Values.([]models.ArtifactRevisionTag)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("ArtifactRevisionTag", x.Key), nil
+		}
+		return nil, iterator.Done
 	case *models.SpecRevisionTag:
 		values := it.Values.([]models.SpecRevisionTag)
 		if it.Index < len(values) {
@@ -103,6 +164,51 @@ func (it *Iterator) Next(v interface{}) (storage.Key, error) {
 			return it.Client.NewKey("SpecRevisionTag", x.Key), nil
 		}
 		return nil, iterator.Done
+	case *models.Deployment:
+		values := it.Values.([]models.Deployment)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("Deployment", x.Key), nil
+		}
+		return nil, iterator.Done
+	case *models.DeploymentRevisionTag:
+		values := it.Values.([]models.DeploymentRevisionTag)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("DeploymentRevisionTag", x.Key), nil
+		}
+		return nil, iterator.Done
+	case *models.Change:
+		values := it.Values.([]models.Change)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = strconv.FormatInt(x.ID, 10)
+			it.Index++
+			return it.Client.NewKey("Change", it.Cursor), nil
+		}
+		return nil, iterator.Done
+	case *models.Request:
+		values := it.Values.([]models.Request)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("Request", x.Key), nil
+		}
+		return nil, iterator.Done
+	case *models.Operation:
+		values := it.Values.([]models.Operation)
+		if it.Index < len(values) {
+			*x = values[it.Index]
+			it.Cursor = x.Key
+			it.Index++
+			return it.Client.NewKey("Operation", x.Key), nil
+		}
+		return nil, iterator.Done
 	default:
 		return nil, fmt.Errorf("unsupported iterator type: %t", v)
 	}
@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"github.com/apigee/registry/server/models"
+	"gorm.io/gorm"
+)
+
+// labeledKinds are the entities whose labels are also stored individually.
+var labeledKinds = map[string]bool{
+	"Api":     true,
+	"Version": true,
+	"Spec":    true,
+}
+
+// labelsFor returns the entity kind and individual labels of an entity.
+// The returned kind is empty for entities that don't have labels.
+func labelsFor(key string, v interface{}) (string, []models.Label, error) {
+	var (
+		base   models.Label
+		labels map[string]string
+		err    error
+	)
+
+	switch r := v.(type) {
+	case *models.Api:
+		base = models.Label{Kind: "Api", ProjectID: r.ProjectID, ApiID: r.ApiID}
+		labels, err = r.LabelsMap()
+	case *models.Version:
+		base = models.Label{Kind: "Version", ProjectID: r.ProjectID, ApiID: r.ApiID, VersionID: r.VersionID}
+		labels, err = r.LabelsMap()
+	case *models.Spec:
+		base = models.Label{Kind: "Spec", ProjectID: r.ProjectID, ApiID: r.ApiID, VersionID: r.VersionID, SpecID: r.SpecID}
+		labels, err = r.LabelsMap()
+	default:
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	base.EntityKey = key
+	rows := make([]models.Label, 0, len(labels))
+	for k, v := range labels {
+		label := base
+		label.Label, label.Value = k, v
+		rows = append(rows, label)
+	}
+
+	return base.Kind, rows, nil
+}
+
+// saveLabels replaces the stored labels of an entity.
+func saveLabels(tx *gorm.DB, key string, v interface{}) error {
+	kind, labels, err := labelsFor(key, v)
+	if kind == "" || err != nil {
+		return err
+	}
+
+	if err := deleteLabels(tx, kind, key); err != nil {
+		return err
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+	return tx.Create(&labels).Error
+}
+
+// deleteLabels deletes the stored labels of an entity.
+func deleteLabels(tx *gorm.DB, kind, key string) error {
+	return tx.Where("kind = ? AND entity_key = ?", kind, key).Delete(&models.Label{}).Error
+}
+
+// indexLabels stores the labels of all existing entities.
+// It is used to populate the labels table when it is created.
+func indexLabels(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var apis []models.Api
+		if err := tx.Find(&apis).Error; err != nil {
+			return err
+		}
+		for i := range apis {
+			if err := saveLabels(tx, apis[i].Key, &apis[i]); err != nil {
+				return err
+			}
+		}
+
+		var versions []models.Version
+		if err := tx.Find(&versions).Error; err != nil {
+			return err
+		}
+		for i := range versions {
+			if err := saveLabels(tx, versions[i].Key, &versions[i]); err != nil {
+				return err
+			}
+		}
+
+		var specs []models.Spec
+		if err := tx.Find(&specs).Error; err != nil {
+			return err
+		}
+		for i := range specs {
+			if err := saveLabels(tx, specs[i].Key, &specs[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,525 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migration is a numbered change to the database schema.
+// Migrations are applied in order and each one is applied in a transaction
+// that also records it in the schema_version table.
+//
+// Migrations must not create tables from the current models, which change
+// over time. Instead, they use the snapshots of the schema defined below.
+type migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error
+}
+
+// migrations lists all schema migrations in order of their versions.
+// New migrations must be appended with the next version number.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "create resource tables",
+		// Databases created before migrations were introduced may already have these tables.
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v1Project{}, &v1Api{}, &v1Version{}, &v1Spec{}, &v1Blob{}, &v1Artifact{}, &v1SpecRevisionTag{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v1Project{}, &v1Api{}, &v1Version{}, &v1Spec{}, &v1Blob{}, &v1Artifact{}, &v1SpecRevisionTag{})
+		},
+	},
+	{
+		Version:     2,
+		Description: "create changes table",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v2Change{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v2Change{})
+		},
+	},
+	{
+		Version:     3,
+		Description: "create labels table and index existing labels",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasTable(&v3Label{}) {
+				return nil
+			}
+			if err := createTables(tx, &v3Label{}); err != nil {
+				return err
+			}
+			return indexLabels(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v3Label{})
+		},
+	},
+	{
+		Version:     4,
+		Description: "share blob contents by hash",
+		Up: func(tx *gorm.DB) error {
+			if err := createTables(tx, &v4BlobContents{}); err != nil {
+				return err
+			}
+			if err := createBlobHashIndex(tx); err != nil {
+				return err
+			}
+			return shareBlobContents(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := unshareBlobContents(tx); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&v4Blob{}, "idx_blobs_hash"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&v4BlobContents{})
+		},
+	},
+	{
+		Version:     5,
+		Description: "create artifact revision tables",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"RevisionID", "RevisionCreateTime"} {
+				if tx.Migrator().HasColumn(&v5Artifact{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v5Artifact{}, column); err != nil {
+					return err
+				}
+			}
+			if err := createTables(tx, &v5ArtifactRevision{}, &v5ArtifactRevisionTag{}); err != nil {
+				return err
+			}
+			return createArtifactRevisions(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := deleteArtifactRevisionBlobs(tx); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropTable(&v5ArtifactRevision{}, &v5ArtifactRevisionTag{}); err != nil {
+				return err
+			}
+			for _, column := range []string{"RevisionID", "RevisionCreateTime"} {
+				if err := tx.Migrator().DropColumn(&v5Artifact{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     6,
+		Description: "create deployment tables",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v6Deployment{}, &v6DeploymentRevisionTag{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v6Deployment{}, &v6DeploymentRevisionTag{})
+		},
+	},
+	{
+		Version:     7,
+		Description: "create request table",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v7Request{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v7Request{})
+		},
+	},
+	{
+		Version:     8,
+		Description: "add soft deletion columns",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range softDeletedTables() {
+				for _, column := range softDeletionColumns {
+					if tx.Migrator().HasColumn(table, column) {
+						continue
+					}
+					if err := tx.Migrator().AddColumn(table, column); err != nil {
+						return err
+					}
+				}
+				// Existing resources are not deleted.
+				if err := tx.Model(table).Where("deleted IS NULL").Update("deleted", false).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// Deleted resources that haven't been purged become visible again.
+			for _, table := range softDeletedTables() {
+				for _, column := range softDeletionColumns {
+					if err := tx.Migrator().DropColumn(table, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     9,
+		Description: "create operation table",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v9Operation{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v9Operation{})
+		},
+	},
+}
+
+// softDeletionColumns are the columns of resources that can be soft-deleted.
+var softDeletionColumns = []string{"Deleted", "DeleteTime", "PurgeTime"}
+
+// softDeletedTables returns the snapshots of tables whose resources can be soft-deleted.
+func softDeletedTables() []interface{} {
+	return []interface{}{&v8Api{}, &v8Version{}, &v8Spec{}}
+}
+
+// latestVersion is the schema version that the current models require.
+func latestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// createTables creates the tables that don't already exist.
+func createTables(tx *gorm.DB, tables ...interface{}) error {
+	if tx.Dialector.Name() == "mysql" {
+		tx = tx.Set("gorm:table_options", mysqlTableOptions)
+	}
+	for _, t := range tables {
+		if tx.Migrator().HasTable(t) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaVersion records a migration that has been applied to a database.
+type SchemaVersion struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedTime time.Time
+}
+
+// TableName returns the name of the table that records applied migrations.
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// MigrationStatus describes a migration and whether it has been applied.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	// AppliedTime is the time that the migration was applied, or zero if it is pending.
+	AppliedTime time.Time
+}
+
+// Applied returns true if the migration has been applied.
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedTime.IsZero()
+}
+
+// Migrator applies schema migrations to a database.
+type Migrator struct {
+	db *gorm.DB
+}
+
+// NewMigrator opens a database for migration.
+func NewMigrator(ctx context.Context, gormDBName, gormConfig string) (*Migrator, error) {
+	db, err := open(gormDBName, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{db: db}
+	if err := m.ensureVersionTable(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Close closes the migrator's database connections.
+func (m *Migrator) Close() {
+	(&Client{db: m.db}).Close()
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	return createTables(m.db, &SchemaVersion{})
+}
+
+// Version returns the version of the most recent migration applied to the database.
+// It returns zero if no migrations have been applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var versions []SchemaVersion
+	if err := m.db.WithContext(ctx).Order("version desc").Limit(1).Find(&versions).Error; err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	return versions[0].Version, nil
+}
+
+// check returns an error if the database schema is not at the latest version.
+func (m *Migrator) check(ctx context.Context) error {
+	v, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if v != latestVersion() {
+		return fmt.Errorf("database schema is at version %d but version %d is required, run `registry-server migrate up` to update it", v, latestVersion())
+	}
+	return nil
+}
+
+// Status returns the status of every known migration in order of their versions.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var applied []SchemaVersion
+	if err := m.db.WithContext(ctx).Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	times := make(map[int]time.Time, len(applied))
+	for _, v := range applied {
+		times[v.Version] = v.AppliedTime
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		status = append(status, MigrationStatus{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedTime: times[mig.Version],
+		})
+	}
+	return status, nil
+}
+
+// Up applies pending migrations up to and including the target version.
+// If target is zero, all pending migrations are applied.
+// It returns the migrations that were applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]MigrationStatus, error) {
+	if target == 0 {
+		target = latestVersion()
+	} else if target < 0 || target > latestVersion() {
+		return nil, fmt.Errorf("unknown schema version %d, the latest version is %d", target, latestVersion())
+	}
+
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]MigrationStatus, 0)
+	for _, mig := range migrations {
+		if mig.Version <= current || mig.Version > target {
+			continue
+		}
+
+		v := SchemaVersion{Version: mig.Version, Description: mig.Description, AppliedTime: time.Now()}
+		if err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := mig.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&v).Error
+		}); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %s", mig.Version, mig.Description, err)
+		}
+		applied = append(applied, MigrationStatus{Version: v.Version, Description: v.Description, AppliedTime: v.AppliedTime})
+	}
+	return applied, nil
+}
+
+// Down reverts applied migrations until the target version is the most recent one.
+// It returns the migrations that were reverted.
+func (m *Migrator) Down(ctx context.Context, target int) ([]MigrationStatus, error) {
+	if target < 0 || target > latestVersion() {
+		return nil, fmt.Errorf("unknown schema version %d, the latest version is %d", target, latestVersion())
+	}
+
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]MigrationStatus, 0)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		mig := migrations[i]
+		if mig.Version > current || mig.Version <= target {
+			continue
+		}
+
+		if err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := mig.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaVersion{}, mig.Version).Error
+		}); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d (%s) failed: %s", mig.Version, mig.Description, err)
+		}
+		reverted = append(reverted, MigrationStatus{Version: mig.Version, Description: mig.Description})
+	}
+	return reverted, nil
+}
+
+// migrationBatchSize is the number of rows that migrations read at a time.
+const migrationBatchSize = 100
+
+// createBlobHashIndex creates the index used to find the blobs that refer to contents.
+func createBlobHashIndex(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(&v4Blob{}, "idx_blobs_hash") {
+		return nil
+	}
+	// MySQL text columns can only be indexed by a prefix, and hashes are 64 characters long.
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Exec("CREATE INDEX idx_blobs_hash ON blobs (hash(64))").Error
+	}
+	return tx.Migrator().CreateIndex(&v4Blob{}, "idx_blobs_hash")
+}
+
+// shareBlobContents moves the contents of blobs into the blob_contents table,
+// keeping a single copy of the contents that are shared by blobs with the same hash.
+func shareBlobContents(tx *gorm.DB) error {
+	for {
+		// Moved contents are cleared, so each batch starts with the remaining blobs.
+		var blobs []v4Blob
+		if err := tx.Where("hash <> '' AND contents IS NOT NULL AND length(contents) > 0").
+			Limit(migrationBatchSize).Find(&blobs).Error; err != nil {
+			return err
+		}
+		if len(blobs) == 0 {
+			return nil
+		}
+
+		keys := make([]interface{}, 0, len(blobs))
+		for _, b := range blobs {
+			contents := &v4BlobContents{Key: b.Hash, SizeInBytes: b.SizeInBytes, Contents: b.Contents, CreateTime: b.CreateTime}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(contents).Error; err != nil {
+				return err
+			}
+			keys = append(keys, b.Key)
+		}
+
+		in := clause.IN{Column: clause.Column{Name: "key"}, Values: keys}
+		if err := tx.Model(&v4Blob{}).Where(in).Update("contents", nil).Error; err != nil {
+			return err
+		}
+	}
+}
+
+// unshareBlobContents copies shared contents back into every blob that refers to them.
+func unshareBlobContents(tx *gorm.DB) error {
+	last := ""
+	for {
+		var contents []v4BlobContents
+		if err := tx.Where(clause.Gt{Column: clause.Column{Name: "key"}, Value: last}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "key"}}).
+			Limit(migrationBatchSize).Find(&contents).Error; err != nil {
+			return err
+		}
+		if len(contents) == 0 {
+			return nil
+		}
+
+		for _, c := range contents {
+			if err := tx.Model(&v4Blob{}).Where("hash = ?", c.Key).Update("contents", c.Contents).Error; err != nil {
+				return err
+			}
+			last = c.Key
+		}
+	}
+}
+
+// createArtifactRevisions creates the first revision of each artifact,
+// along with a blob for the revision that shares the artifact's contents.
+func createArtifactRevisions(tx *gorm.DB) error {
+	for {
+		// Artifacts are assigned revision IDs, so each batch starts with the remaining artifacts.
+		var artifacts []v5Artifact
+		if err := tx.Where("revision_id IS NULL OR revision_id = ''").
+			Limit(migrationBatchSize).Find(&artifacts).Error; err != nil {
+			return err
+		}
+		if len(artifacts) == 0 {
+			return nil
+		}
+
+		for _, a := range artifacts {
+			a.RevisionID = newRevisionID()
+			a.RevisionCreateTime = a.UpdateTime
+			if err := tx.Model(&v5Artifact{}).Where(byKey(a.Key)).Updates(map[string]interface{}{
+				"revision_id":          a.RevisionID,
+				"revision_create_time": a.RevisionCreateTime,
+			}).Error; err != nil {
+				return err
+			}
+
+			revision := v5ArtifactRevision(a)
+			revision.Key = a.Key + "@" + a.RevisionID
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+
+			var blobs []v4Blob
+			if err := tx.Where(byKey(a.Key)).Limit(1).Find(&blobs).Error; err != nil {
+				return err
+			}
+			for _, b := range blobs {
+				b.Key, b.RevisionID = revision.Key, a.RevisionID
+				if err := tx.Create(&b).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// deleteArtifactRevisionBlobs deletes the blobs of artifact revisions,
+// along with any contents in the database that are no longer referred to.
+func deleteArtifactRevisionBlobs(tx *gorm.DB) error {
+	key := clause.Column{Name: "key"}
+	if err := tx.Where("artifact_id <> ''").Where(clause.Like{Column: key, Value: "%@%"}).
+		Delete(&v4Blob{}).Error; err != nil {
+		return err
+	}
+	hashes := tx.Model(&v4Blob{}).Select("hash").Where("hash IS NOT NULL")
+	return tx.Where(clause.Expr{SQL: "? NOT IN (?)", Vars: []interface{}{key, hashes}}).
+		Delete(&v4BlobContents{}).Error
+}
+
+// newRevisionID generates a revision ID in the format used by the models.
+func newRevisionID() string {
+	s := uuid.New().String()
+	return s[len(s)-8:]
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// mysqlTableOptions are used when creating MySQL tables. The binary collation
+// makes string comparisons case sensitive and orders strings by their bytes,
+// as SQLite and PostgreSQL do.
+const mysqlTableOptions = "CHARACTER SET utf8mb4 COLLATE utf8mb4_bin"
+
+// mysqlDSN sets the connection options that are required by the client.
+// Timestamps are read as time values in UTC.
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
+
+// mysqlDialector adapts the MySQL dialect to the registry schema.
+type mysqlDialector struct {
+	mysql.Dialector
+}
+
+func newMySQLDialector(dsn string) gorm.Dialector {
+	return mysqlDialector{Dialector: mysql.Dialector{Config: &mysql.Config{DSN: dsn}}}
+}
+
+// Migrator returns a migrator that creates columns with the types chosen by this dialector.
+func (d mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	m := d.Dialector.Migrator(db).(mysql.Migrator)
+	m.Migrator.Dialector = d
+	return m
+}
+
+// DataTypeOf returns the MySQL column type of a field.
+func (d mysqlDialector) DataTypeOf(field *schema.Field) string {
+	switch field.DataType {
+	case schema.String:
+		// Primary keys can't be text columns, and their combined length is limited to 3072 bytes.
+		// Resource names only contain ASCII characters, so they can be stored in one byte per character.
+		if field.Name == "Key" || field.Name == "EntityKey" {
+			return "varchar(512) CHARACTER SET ascii COLLATE ascii_bin"
+		} else if field.PrimaryKey {
+			return "varchar(255)"
+		}
+	case schema.Time:
+		// Timestamps are stored with microsecond precision instead of the default milliseconds.
+		if field.Precision == 0 {
+			field.Precision = 6
+		}
+	case schema.Bytes:
+		// Serialized labels and blob contents can be larger than a varbinary column.
+		return "longblob"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
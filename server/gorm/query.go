@@ -15,60 +15,220 @@
 package gorm
 
 import (
+	"fmt"
 	"log"
+	"reflect"
+	"strconv"
 
+	"github.com/apigee/registry/server/models"
 	"github.com/apigee/registry/server/storage"
 )
 
 // Query represents a query in a storage provider.
 type Query struct {
 	Kind         string
-	Offset       int
-	Order        string
+	Limit        int
 	Requirements []*Requirement
+	Conditions   []*Condition
+	// Orders are the model fields used to order results before keys, if any.
+	Orders []*Order
+	// Cursor selects results after those returned by a previous query.
+	Cursor *Condition
+	// Locking is true if results are locked until the current transaction ends.
+	Locking bool
+	client  *Client
 }
 
-// Requirement adds an equality filter to a query.
+// Order sorts query results by a model field.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Requirement adds a comparison filter to a query.
 type Requirement struct {
-	Name  string
-	Value interface{}
+	Name     string
+	Operator string
+	Value    interface{}
+}
+
+// Clause returns the SQL condition for a requirement.
+func (r *Requirement) Clause() string {
+	return r.Name + " " + r.Operator + " ?"
 }
 
 // NewQuery creates a new query.
 func (c *Client) NewQuery(kind string) storage.Query {
 	return &Query{
-		Kind: kind,
+		Kind:   kind,
+		client: c,
 	}
 }
 
 // Require adds a filter to a query that requires a field to have a specified value.
 func (q *Query) Require(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: columnName(name), Operator: "=", Value: value})
+	return q
+}
+
+// After adds a filter to a query that requires a field to be greater than a specified value.
+func (q *Query) After(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: columnName(name), Operator: ">", Value: value})
+	return q
+}
+
+// Before adds a filter to a query that requires a field to be less than a specified value.
+func (q *Query) Before(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: columnName(name), Operator: "<", Value: value})
+	return q
+}
+
+func columnName(name string) string {
 	switch name {
 	case "ProjectID":
-		name = "project_id"
+		return "project_id"
 	case "ApiID":
-		name = "api_id"
+		return "api_id"
 	case "VersionID":
-		name = "version_id"
+		return "version_id"
 	case "SpecID":
-		name = "spec_id"
+		return "spec_id"
+	case "DeploymentID":
+		return "deployment_id"
+	case "ArtifactID":
+		return "artifact_id"
+	case "ID":
+		return "id"
+	case "Dispatched":
+		return "dispatched"
+	case "Deleted":
+		return "deleted"
+	case "Done":
+		return "done"
+	case "Hash":
+		return "hash"
+	case "CreateTime":
+		return "create_time"
 	default:
 		log.Fatalf("UNEXPECTED REQUIRE TYPE: %s", name)
 	}
-	q.Requirements = append(q.Requirements, &Requirement{Name: name, Value: value})
+	return name
+}
+
+// Ascending orders query results by a field in ascending order.
+// Results are ordered by the fields of successive calls before their keys.
+func (q *Query) Ascending(field string) storage.Query {
+	q.Orders = append(q.Orders, &Order{Field: field})
 	return q
 }
 
+// Descending orders query results by a field in descending order.
+// Results are ordered by the fields of successive calls before their keys.
 func (q *Query) Descending(field string) storage.Query {
-	switch field {
-	case "RevisionCreateTime":
-		q.Order = "revision_create_time desc"
-	}
+	q.Orders = append(q.Orders, &Order{Field: field, Descending: true})
+	return q
+}
 
+func (q *Query) ApplyLimit(limit int32) storage.Query {
+	q.Limit = int(limit)
 	return q
 }
 
-func (q *Query) ApplyOffset(offset int32) storage.Query {
-	q.Offset = int(offset)
+// ForUpdate locks the results of a query until the current transaction ends.
+func (q *Query) ForUpdate() storage.Query {
+	q.Locking = true
 	return q
 }
+
+// StartAfter limits results to those that follow the entity with the specified key.
+// For queries that are ordered by fields, orderValues are the entity's values of those fields.
+func (q *Query) StartAfter(key string, orderValues ...interface{}) storage.Query {
+	if q.Kind == models.ChangeEntityName {
+		id, _ := strconv.ParseInt(key, 10, 64)
+		q.Cursor = q.after(id, orderValues)
+	} else {
+		q.Cursor = q.after(key, orderValues)
+	}
+	return q
+}
+
+func (q *Query) table() string {
+	return q.client.db.NamingStrategy.TableName(q.Kind)
+}
+
+// keyColumn returns the qualified name of the column that uniquely identifies results.
+func (q *Query) keyColumn() string {
+	if q.Kind == models.ChangeEntityName {
+		return q.table() + ".id"
+	}
+	return q.table() + ".key"
+}
+
+// orderColumn returns the qualified name of the column that stores an ordered field.
+func (q *Query) orderColumn(o *Order) string {
+	return q.table() + "." + q.client.db.NamingStrategy.ColumnName(q.table(), o.Field)
+}
+
+// orderClause returns the SQL ordering of query results.
+// Results are always ordered by key last so that they can be read in batches.
+func (q *Query) orderClause() string {
+	clause := ""
+	for _, o := range q.Orders {
+		clause += q.orderColumn(o)
+		if o.Descending {
+			clause += " desc"
+		}
+		clause += ", "
+	}
+	return clause + q.keyColumn()
+}
+
+// after returns a condition that selects results that follow the entity with a key and order values.
+// Results follow the entity if they differ in the first ordered field that they don't share with it.
+func (q *Query) after(key interface{}, orderValues []interface{}) *Condition {
+	if len(orderValues) != len(q.Orders) {
+		orderValues = make([]interface{}, len(q.Orders))
+	}
+
+	sql := q.keyColumn() + " > ?"
+	args := []interface{}{key}
+	for i := len(q.Orders) - 1; i >= 0; i-- {
+		column, op := q.orderColumn(q.Orders[i]), ">"
+		if q.Orders[i].Descending {
+			op = "<"
+		}
+		sql = fmt.Sprintf("(%s %s ? OR (%s = ? AND %s))", column, op, column, sql)
+		args = append([]interface{}{orderValues[i], orderValues[i]}, args...)
+	}
+
+	return &Condition{SQL: sql, Args: args}
+}
+
+// afterValue returns a condition that selects results that follow a model value.
+func (q *Query) afterValue(v reflect.Value) *Condition {
+	var key interface{}
+	if f := v.FieldByName("Key"); f.IsValid() {
+		key = f.Interface()
+	} else {
+		key = v.FieldByName("ID").Interface()
+	}
+
+	orderValues := make([]interface{}, 0, len(q.Orders))
+	for _, o := range q.Orders {
+		orderValues = append(orderValues, v.FieldByName(o.Field).Interface())
+	}
+	return q.after(key, orderValues)
+}
+
+// cursorConditions returns the conditions of a query along with any cursors
+// that select results after those that were previously read.
+func (q *Query) cursorConditions(after *Condition) []*Condition {
+	conditions := q.Conditions
+	if q.Cursor != nil {
+		conditions = append(conditions[:len(conditions):len(conditions)], q.Cursor)
+	}
+	if after != nil {
+		conditions = append(conditions[:len(conditions):len(conditions)], after)
+	}
+	return conditions
+}
@@ -0,0 +1,377 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import "time"
+
+// This file contains snapshots of the database schema that are used by migrations.
+// Snapshots must not be changed after their migrations are released; schema
+// changes should be made with new migrations and, if needed, new snapshots.
+
+// Version 1: resource tables.
+
+type v1Project struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	DisplayName string
+	Description string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
+
+func (v1Project) TableName() string { return "projects" }
+
+type v1Api struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	DisplayName        string
+	Description        string
+	CreateTime         time.Time
+	UpdateTime         time.Time
+	Availability       string
+	RecommendedVersion string
+	Labels             []byte
+	Annotations        []byte
+}
+
+func (v1Api) TableName() string { return "apis" }
+
+type v1Version struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	ApiID       string
+	VersionID   string
+	DisplayName string
+	Description string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+	State       string
+	Labels      []byte
+	Annotations []byte
+}
+
+func (v1Version) TableName() string { return "versions" }
+
+type v1Spec struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	VersionID          string
+	SpecID             string
+	RevisionID         string
+	Description        string
+	CreateTime         time.Time
+	RevisionCreateTime time.Time
+	RevisionUpdateTime time.Time
+	MimeType           string
+	SizeInBytes        int32
+	Hash               string
+	FileName           string
+	SourceURI          string
+	Labels             []byte
+	Annotations        []byte
+}
+
+func (v1Spec) TableName() string { return "specs" }
+
+type v1Blob struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	ApiID       string
+	VersionID   string
+	SpecID      string
+	RevisionID  string
+	ArtifactID  string
+	Hash        string
+	SizeInBytes int32
+	Contents    []byte
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
+
+func (v1Blob) TableName() string { return "blobs" }
+
+type v1Artifact struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	ApiID       string
+	VersionID   string
+	SpecID      string
+	ArtifactID  string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+	MimeType    string
+	SizeInBytes int32
+	Hash        string
+}
+
+func (v1Artifact) TableName() string { return "artifacts" }
+
+type v1SpecRevisionTag struct {
+	Key        string `gorm:"primaryKey"`
+	ProjectID  string
+	ApiID      string
+	VersionID  string
+	SpecID     string
+	RevisionID string
+	Tag        string
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+func (v1SpecRevisionTag) TableName() string { return "spec_revision_tags" }
+
+// Version 2: change log.
+
+type v2Change struct {
+	ID         int64 `gorm:"primaryKey;autoIncrement"`
+	Change     int32
+	Resource   string
+	ChangeTime time.Time
+	Dispatched bool
+}
+
+func (v2Change) TableName() string { return "changes" }
+
+// Version 3: label index.
+
+type v3Label struct {
+	Kind      string `gorm:"primaryKey"`
+	EntityKey string `gorm:"primaryKey"`
+	Label     string `gorm:"primaryKey"`
+	Value     string
+	ProjectID string
+	ApiID     string
+	VersionID string
+	SpecID    string
+}
+
+func (v3Label) TableName() string { return "labels" }
+
+// Version 4: shared blob contents.
+
+type v4BlobContents struct {
+	Key         string `gorm:"primaryKey"`
+	SizeInBytes int32
+	Contents    []byte
+	CreateTime  time.Time
+}
+
+func (v4BlobContents) TableName() string { return "blob_contents" }
+
+// v4Blob adds an index of the hashes that blobs use to refer to their contents.
+type v4Blob struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	ApiID       string
+	VersionID   string
+	SpecID      string
+	RevisionID  string
+	ArtifactID  string
+	Hash        string `gorm:"index:idx_blobs_hash"`
+	SizeInBytes int32
+	Contents    []byte
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
+
+func (v4Blob) TableName() string { return "blobs" }
+
+// Version 5: artifact revisions.
+
+type v5Artifact struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	VersionID          string
+	SpecID             string
+	ArtifactID         string
+	RevisionID         string
+	CreateTime         time.Time
+	UpdateTime         time.Time
+	RevisionCreateTime time.Time
+	MimeType           string
+	SizeInBytes        int32
+	Hash               string
+}
+
+func (v5Artifact) TableName() string { return "artifacts" }
+
+type v5ArtifactRevision struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	VersionID          string
+	SpecID             string
+	ArtifactID         string
+	RevisionID         string
+	CreateTime         time.Time
+	UpdateTime         time.Time
+	RevisionCreateTime time.Time
+	MimeType           string
+	SizeInBytes        int32
+	Hash               string
+}
+
+func (v5ArtifactRevision) TableName() string { return "artifact_revisions" }
+
+type v5ArtifactRevisionTag struct {
+	Key        string `gorm:"primaryKey"`
+	ProjectID  string
+	ApiID      string
+	VersionID  string
+	SpecID     string
+	ArtifactID string
+	RevisionID string
+	Tag        string
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+func (v5ArtifactRevisionTag) TableName() string { return "artifact_revision_tags" }
+
+// Version 6: deployments.
+
+type v6Deployment struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	DeploymentID       string
+	RevisionID         string
+	DisplayName        string
+	Description        string
+	CreateTime         time.Time
+	RevisionCreateTime time.Time
+	RevisionUpdateTime time.Time
+	ApiSpecRevision    string
+	EndpointURI        string
+	Environment        string
+	Gateway            string
+	AccessGuidance     string
+	Labels             []byte
+	Annotations        []byte
+}
+
+func (v6Deployment) TableName() string { return "deployments" }
+
+type v6DeploymentRevisionTag struct {
+	Key          string `gorm:"primaryKey"`
+	ProjectID    string
+	ApiID        string
+	DeploymentID string
+	RevisionID   string
+	Tag          string
+	CreateTime   time.Time
+	UpdateTime   time.Time
+}
+
+func (v6DeploymentRevisionTag) TableName() string { return "deployment_revision_tags" }
+
+// Version 7: completed requests.
+
+type v7Request struct {
+	Key        string `gorm:"primaryKey"`
+	Method     string
+	Hash       string
+	Response   []byte
+	CreateTime time.Time
+}
+
+func (v7Request) TableName() string { return "requests" }
+
+// Version 8: soft deletion.
+
+type v8Api struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	DisplayName        string
+	Description        string
+	CreateTime         time.Time
+	UpdateTime         time.Time
+	Availability       string
+	RecommendedVersion string
+	Labels             []byte
+	Annotations        []byte
+	Deleted            bool `gorm:"default:false"`
+	DeleteTime         time.Time
+	PurgeTime          time.Time
+}
+
+func (v8Api) TableName() string { return "apis" }
+
+type v8Version struct {
+	Key         string `gorm:"primaryKey"`
+	ProjectID   string
+	ApiID       string
+	VersionID   string
+	DisplayName string
+	Description string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+	State       string
+	Labels      []byte
+	Annotations []byte
+	Deleted     bool `gorm:"default:false"`
+	DeleteTime  time.Time
+	PurgeTime   time.Time
+}
+
+func (v8Version) TableName() string { return "versions" }
+
+type v8Spec struct {
+	Key                string `gorm:"primaryKey"`
+	ProjectID          string
+	ApiID              string
+	VersionID          string
+	SpecID             string
+	RevisionID         string
+	Description        string
+	CreateTime         time.Time
+	RevisionCreateTime time.Time
+	RevisionUpdateTime time.Time
+	MimeType           string
+	SizeInBytes        int32
+	Hash               string
+	FileName           string
+	SourceURI          string
+	Labels             []byte
+	Annotations        []byte
+	Deleted            bool `gorm:"default:false"`
+	DeleteTime         time.Time
+	PurgeTime          time.Time
+}
+
+func (v8Spec) TableName() string { return "specs" }
+
+// Version 9: long-running operations.
+
+type v9Operation struct {
+	Key             string `gorm:"primaryKey"`
+	Verb            string
+	Target          string
+	CreateTime      time.Time
+	UpdateTime      time.Time
+	EndTime         time.Time
+	Done            bool
+	CancelRequested bool
+	ProgressPercent int32
+	StatusMessage   string
+	Error           []byte
+	Response        []byte
+}
+
+func (v9Operation) TableName() string { return "operations" }
@@ -0,0 +1,271 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides a storage client that keeps all entities in memory.
+// It requires no database and is intended for tests and ephemeral registries.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage"
+)
+
+// errNotFound is returned when an entity does not exist.
+var errNotFound = errors.New("entity not found")
+
+// store holds the entities of all clients that share it.
+type store struct {
+	mu sync.RWMutex
+	// tables contains the entities of each kind, indexed by key.
+	tables map[string]map[string]reflect.Value
+	// lastChangeID is the ID of the most recently stored change.
+	lastChangeID int64
+}
+
+// Client is a storage client that keeps entities in memory.
+// Clients are safe for concurrent use.
+type Client struct {
+	store *store
+	// tx is set for clients created by Transaction, which holds the store's lock.
+	tx *transaction
+}
+
+// transaction records how to revert the changes made during a transaction.
+type transaction struct {
+	undo []func()
+}
+
+// NewClient creates a client with an empty store.
+func NewClient() *Client {
+	return &Client{
+		store: &store{tables: make(map[string]map[string]reflect.Value)},
+	}
+}
+
+// Close closes the client. Entities remain available to other clients of the same store.
+func (c *Client) Close() {}
+
+func (c *Client) rlock() {
+	if c.tx == nil {
+		c.store.mu.RLock()
+	}
+}
+
+func (c *Client) runlock() {
+	if c.tx == nil {
+		c.store.mu.RUnlock()
+	}
+}
+
+func (c *Client) lock() {
+	if c.tx == nil {
+		c.store.mu.Lock()
+	}
+}
+
+func (c *Client) unlock() {
+	if c.tx == nil {
+		c.store.mu.Unlock()
+	}
+}
+
+// IsNotFound returns true if an error is due to an entity not being found.
+func (c *Client) IsNotFound(err error) bool {
+	return err == errNotFound
+}
+
+// Get gets an entity using the storage client.
+func (c *Client) Get(ctx context.Context, k storage.Key, v interface{}) error {
+	c.rlock()
+	defer c.runlock()
+
+	stored, ok := c.store.tables[k.(*Key).Kind][k.(*Key).Name]
+	if !ok {
+		return errNotFound
+	}
+	return load(stored, v)
+}
+
+// GetForUpdate gets an entity. Entities don't need to be locked, because transactions are serialized.
+func (c *Client) GetForUpdate(ctx context.Context, k storage.Key, v interface{}) error {
+	return c.Get(ctx, k, v)
+}
+
+// Create puts an entity unless an entity with the same key exists.
+func (c *Client) Create(ctx context.Context, k storage.Key, v interface{}) (bool, error) {
+	c.lock()
+	defer c.unlock()
+
+	if _, ok := c.store.tables[k.(*Key).Kind][k.(*Key).Name]; ok {
+		return false, nil
+	}
+
+	value := reflect.ValueOf(v)
+	f := value.Elem().FieldByName("Key")
+	if !f.IsValid() {
+		return false, fmt.Errorf("unsupported entity type %T", v)
+	}
+	f.SetString(k.(*Key).Name)
+
+	c.set(k.(*Key).Kind, k.(*Key).Name, clone(value.Elem()))
+	return true, nil
+}
+
+// Put puts an entity using the storage client.
+func (c *Client) Put(ctx context.Context, k storage.Key, v interface{}) (storage.Key, error) {
+	c.lock()
+	defer c.unlock()
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported entity type %T", v)
+	}
+
+	key := k.(*Key)
+	switch r := v.(type) {
+	case *models.Change:
+		// Changes are identified by sequential IDs that are assigned when they are created.
+		if r.ID == 0 {
+			c.store.lastChangeID++
+			r.ID = c.store.lastChangeID
+		}
+		key = &Key{Kind: models.ChangeEntityName, Name: strconv.FormatInt(r.ID, 10)}
+	default:
+		f := value.Elem().FieldByName("Key")
+		if !f.IsValid() {
+			return nil, fmt.Errorf("unsupported entity type %T", v)
+		}
+		f.SetString(key.Name)
+	}
+
+	c.set(key.Kind, key.Name, clone(value.Elem()))
+	return key, nil
+}
+
+// set stores an entity, recording how to restore the previous one if the client is in a transaction.
+func (c *Client) set(kind, name string, v reflect.Value) {
+	table, ok := c.store.tables[kind]
+	if !ok {
+		table = make(map[string]reflect.Value)
+		c.store.tables[kind] = table
+	}
+
+	if c.tx != nil {
+		previous, existed := table[name]
+		c.tx.undo = append(c.tx.undo, func() {
+			if existed {
+				table[name] = previous
+			} else {
+				delete(table, name)
+			}
+		})
+	}
+	table[name] = v
+}
+
+// remove deletes an entity, recording how to restore it if the client is in a transaction.
+func (c *Client) remove(kind, name string) {
+	table := c.store.tables[kind]
+	previous, existed := table[name]
+	if !existed {
+		return
+	}
+
+	if c.tx != nil {
+		c.tx.undo = append(c.tx.undo, func() {
+			table[name] = previous
+		})
+	}
+	delete(table, name)
+}
+
+// Delete deletes an entity using the storage client.
+func (c *Client) Delete(ctx context.Context, k storage.Key) error {
+	c.lock()
+	defer c.unlock()
+
+	switch k.(*Key).Kind {
+	case storage.ProjectEntityName, storage.ApiEntityName, storage.VersionEntityName,
+		storage.SpecEntityName, storage.SpecRevisionTagEntityName, models.BlobEntityName,
+		models.BlobContentsEntityName, storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName, storage.ArtifactRevisionTagEntityName,
+		storage.DeploymentEntityName, storage.DeploymentRevisionTagEntityName,
+		models.RequestEntityName, models.OperationEntityName:
+		c.remove(k.(*Key).Kind, k.(*Key).Name)
+		return nil
+	default:
+		return fmt.Errorf("invalid key type: %s", k.(*Key).Kind)
+	}
+}
+
+// Transaction runs fn with a client whose operations are committed together.
+// Transactions are serialized, and other clients wait until they are complete.
+func (c *Client) Transaction(ctx context.Context, fn func(context.Context, storage.Client) error) error {
+	if c.tx != nil {
+		// Nested transactions are reverted to the point where they began.
+		savepoint := len(c.tx.undo)
+		if err := fn(ctx, c); err != nil {
+			c.tx.revert(savepoint)
+			return err
+		}
+		return nil
+	}
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	tx := &Client{store: c.store, tx: &transaction{}}
+	if err := fn(ctx, tx); err != nil {
+		tx.tx.revert(0)
+		return err
+	}
+	return nil
+}
+
+// revert undoes the changes made after the savepoint, most recent first.
+func (t *transaction) revert(savepoint int) {
+	for i := len(t.undo) - 1; i >= savepoint; i-- {
+		t.undo[i]()
+	}
+	t.undo = t.undo[:savepoint]
+}
+
+// clone returns a copy of a model that shares no memory with the original.
+func clone(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+	for i := 0; i < copied.NumField(); i++ {
+		if f := copied.Field(i); f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8 && !f.IsNil() {
+			f.SetBytes(append([]byte(nil), f.Bytes()...))
+		}
+	}
+	return copied
+}
+
+// load copies a stored model into v, which must point to a model of the same type.
+func load(stored reflect.Value, v interface{}) error {
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Ptr || dst.Elem().Type() != stored.Type() {
+		return fmt.Errorf("cannot load %s into %T", stored.Type(), v)
+	}
+	dst.Elem().Set(clone(stored))
+	return nil
+}
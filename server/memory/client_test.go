@@ -0,0 +1,256 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+// keys runs a query and returns the names in the keys of its results.
+func keys(t *testing.T, c *Client, q storage.Query, v interface{}) []string {
+	t.Helper()
+	var got []string
+	it := c.Run(context.Background(), q)
+	for {
+		k, err := it.Next(v)
+		if err == iterator.Done {
+			return got
+		} else if err != nil {
+			t.Fatalf("Next() returned error: %s", err)
+		}
+		got = append(got, k.(*Key).Name)
+	}
+}
+
+func putApi(t *testing.T, c storage.Client, id string, labels map[string]string) *models.Api {
+	t.Helper()
+	ctx := context.Background()
+	name := names.Api{ProjectID: "p", ApiID: id}
+	api, err := models.NewApi(name, &rpc.Api{DisplayName: id, Labels: labels})
+	if err != nil {
+		t.Fatalf("Setup: NewApi(%s) returned error: %s", name, err)
+	}
+	if _, err := c.Put(ctx, c.NewKey(storage.ApiEntityName, name.String()), api); err != nil {
+		t.Fatalf("Setup: Put(%s) returned error: %s", name, err)
+	}
+	return api
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+	defer c.Close()
+
+	project := &models.Project{ProjectID: "p", Description: "original"}
+	k := c.NewKey(storage.ProjectEntityName, project.Name())
+	if _, err := c.Put(ctx, k, project); err != nil {
+		t.Fatalf("Put(%s) returned error: %s", k, err)
+	}
+
+	// Stored models must not change when the caller's copy does.
+	project.Description = "modified"
+
+	got := new(models.Project)
+	if err := c.Get(ctx, k, got); err != nil {
+		t.Fatalf("Get(%s) returned error: %s", k, err)
+	}
+	if got.Description != "original" || got.Key != project.Name() {
+		t.Errorf("Get(%s) returned %+v, want description %q and key %q", k, got, "original", project.Name())
+	}
+
+	if err := c.Get(ctx, k, new(models.Api)); err == nil {
+		t.Errorf("Get(%s) into an api returned no error", k)
+	}
+
+	if err := c.Delete(ctx, k); err != nil {
+		t.Fatalf("Delete(%s) returned error: %s", k, err)
+	}
+	if err := c.Get(ctx, k, got); !c.IsNotFound(err) {
+		t.Errorf("Get(%s) after Delete returned %v, want not found", k, err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+	defer c.Close()
+
+	k := c.NewKey(models.BlobContentsEntityName, "hash")
+	for i, want := range []bool{true, false} {
+		contents := &models.BlobContents{Contents: []byte(fmt.Sprintf("contents %d", i))}
+		if created, err := c.Create(ctx, k, contents); err != nil {
+			t.Fatalf("Create(%s) returned error: %s", k, err)
+		} else if created != want {
+			t.Errorf("Create(%s) returned created=%t, want %t", k, created, want)
+		}
+	}
+
+	// Entities that already exist are left unchanged.
+	got := new(models.BlobContents)
+	if err := c.GetForUpdate(ctx, k, got); err != nil {
+		t.Fatalf("GetForUpdate(%s) returned error: %s", k, err)
+	} else if string(got.Contents) != "contents 0" || got.Key != "hash" {
+		t.Errorf("GetForUpdate(%s) returned %+v, want the first contents", k, got)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	c := NewClient()
+	for _, id := range []string{"c", "a", "d", "b"} {
+		putApi(t, c, id, nil)
+	}
+	putApi(t, c, "e", map[string]string{"env": "prod"})
+
+	q := c.NewQuery(storage.ApiEntityName).Require("ProjectID", "p")
+	want := []string{"projects/p/apis/a", "projects/p/apis/b", "projects/p/apis/c", "projects/p/apis/d", "projects/p/apis/e"}
+	if got := keys(t, c, q, new(models.Api)); !cmp.Equal(want, got) {
+		t.Errorf("Run() returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	q = c.NewQuery(storage.ApiEntityName).Descending("DisplayName").StartAfter("projects/p/apis/d", "d").ApplyLimit(2)
+	want = []string{"projects/p/apis/c", "projects/p/apis/b"}
+	if got := keys(t, c, q, new(models.Api)); !cmp.Equal(want, got) {
+		t.Errorf("Run() with descending order and cursor returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	q = c.NewQuery(storage.ApiEntityName)
+	if !q.ApplyFilter(&filtering.Condition{Operator: filtering.Equal, Field: "Labels", Type: filtering.StringMap, Key: "env", Value: "prod"}) {
+		t.Fatalf("ApplyFilter() returned false for a label condition")
+	}
+	want = []string{"projects/p/apis/e"}
+	if got := keys(t, c, q, new(models.Api)); !cmp.Equal(want, got) {
+		t.Errorf("Run() with label filter returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+	putApi(t, c, "a", nil)
+
+	failure := errors.New("failure")
+	err := c.Transaction(ctx, func(ctx context.Context, tx storage.Client) error {
+		putApi(t, tx, "b", nil)
+		if err := tx.Delete(ctx, tx.NewKey(storage.ApiEntityName, "projects/p/apis/a")); err != nil {
+			t.Fatalf("Delete() returned error: %s", err)
+		}
+		// A failed nested transaction only reverts its own changes.
+		_ = tx.Transaction(ctx, func(ctx context.Context, tx storage.Client) error {
+			putApi(t, tx, "c", nil)
+			return failure
+		})
+		if got := keys(t, tx.(*Client), tx.NewQuery(storage.ApiEntityName), new(models.Api)); !cmp.Equal(got, []string{"projects/p/apis/b"}) {
+			t.Errorf("Run() in transaction returned %v, want [projects/p/apis/b]", got)
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("Transaction() returned %v, want %v", err, failure)
+	}
+
+	want := []string{"projects/p/apis/a"}
+	if got := keys(t, c, c.NewQuery(storage.ApiEntityName), new(models.Api)); !cmp.Equal(want, got) {
+		t.Errorf("Run() after failed transaction returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+
+	for i := 0; i < 12; i++ {
+		change := models.NewChange(rpc.Notification_CREATED, "projects/p")
+		if _, err := c.Put(ctx, c.NewKey(models.ChangeEntityName, ""), change); err != nil {
+			t.Fatalf("Put(change) returned error: %s", err)
+		}
+		if change.ID != int64(i+1) {
+			t.Fatalf("Put(change) assigned ID %d, want %d", change.ID, i+1)
+		}
+	}
+
+	// Change IDs are compared as numbers rather than strings.
+	q := c.NewQuery(models.ChangeEntityName).After("ID", int64(9))
+	want := []string{"10", "11", "12"}
+	if got := keys(t, c, q, new(models.Change)); !cmp.Equal(want, got) {
+		t.Errorf("Run() returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	q = c.NewQuery(models.ChangeEntityName).Before("ID", int64(3))
+	want = []string{"1", "2"}
+	if got := keys(t, c, q, new(models.Change)); !cmp.Equal(want, got) {
+		t.Errorf("Run() returned unexpected keys (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestSpecs(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+
+	name := names.Spec{ProjectID: "p", ApiID: "a", VersionID: "v", SpecID: "s"}
+	created := time.Now()
+	for i, revision := range []string{"r1", "r2", "r3"} {
+		spec := &models.Spec{
+			ProjectID:          name.ProjectID,
+			ApiID:              name.ApiID,
+			VersionID:          name.VersionID,
+			SpecID:             name.SpecID,
+			RevisionID:         revision,
+			RevisionCreateTime: created.Add(time.Duration(i) * time.Second),
+		}
+		k := c.NewKey(storage.SpecEntityName, spec.RevisionName())
+		if _, err := c.Put(ctx, k, spec); err != nil {
+			t.Fatalf("Setup: Put(%s) returned error: %s", k, err)
+		}
+		blob := models.NewBlobForSpec(spec, []byte(revision))
+		if _, err := c.Put(ctx, c.NewKey(models.BlobEntityName, spec.RevisionName()), blob); err != nil {
+			t.Fatalf("Setup: Put(blob) returned error: %s", err)
+		}
+	}
+
+	var got []string
+	it := c.GetRecentSpecRevisions(ctx, c.NewQuery(storage.SpecEntityName))
+	for spec := new(models.Spec); ; {
+		if _, err := it.Next(spec); err == iterator.Done {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() returned error: %s", err)
+		}
+		got = append(got, spec.RevisionID)
+	}
+	if want := []string{"r3"}; !cmp.Equal(want, got) {
+		t.Errorf("GetRecentSpecRevisions() returned unexpected revisions (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	if err := c.DeleteChildrenOfVersion(ctx, name.Version()); err != nil {
+		t.Fatalf("DeleteChildrenOfVersion() returned error: %s", err)
+	}
+	for _, kind := range []string{storage.SpecEntityName, models.BlobEntityName} {
+		if got := keys(t, c, c.NewQuery(kind), new(models.Spec)); len(got) != 0 {
+			t.Errorf("Run(%s) after DeleteChildrenOfVersion returned %v, want none", kind, got)
+		}
+	}
+}
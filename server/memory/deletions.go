@@ -0,0 +1,125 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage"
+)
+
+// DeleteAllMatches deletes all entities matching a query.
+func (c *Client) DeleteAllMatches(ctx context.Context, q storage.Query) error {
+	c.lock()
+	defer c.unlock()
+
+	query := q.(*Query)
+	for name, v := range c.store.tables[query.Kind] {
+		if query.matches(v) {
+			c.remove(query.Kind, name)
+		}
+	}
+	return nil
+}
+
+// DeleteChildrenOfProject deletes all the children of a project.
+func (c *Client) DeleteChildrenOfProject(ctx context.Context, project names.Project) error {
+	for _, entityName := range []string{
+		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
+		models.BlobEntityName,
+		storage.SpecEntityName,
+		storage.SpecRevisionTagEntityName,
+		storage.VersionEntityName,
+		storage.DeploymentEntityName,
+		storage.DeploymentRevisionTagEntityName,
+		storage.ApiEntityName,
+	} {
+		q := c.NewQuery(entityName)
+		q = q.Require("ProjectID", project.ProjectID)
+		if err := c.DeleteAllMatches(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChildrenOfApi deletes all the children of a api.
+func (c *Client) DeleteChildrenOfApi(ctx context.Context, api names.Api) error {
+	for _, entityName := range []string{
+		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
+		models.BlobEntityName,
+		storage.SpecEntityName,
+		storage.SpecRevisionTagEntityName,
+		storage.VersionEntityName,
+		storage.DeploymentEntityName,
+		storage.DeploymentRevisionTagEntityName,
+	} {
+		q := c.NewQuery(entityName)
+		q = q.Require("ProjectID", api.ProjectID)
+		q = q.Require("ApiID", api.ApiID)
+		if err := c.DeleteAllMatches(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChildrenOfVersion deletes all the children of a version.
+func (c *Client) DeleteChildrenOfVersion(ctx context.Context, version names.Version) error {
+	for _, entityName := range []string{
+		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
+		models.BlobEntityName,
+		storage.SpecEntityName,
+		storage.SpecRevisionTagEntityName,
+	} {
+		q := c.NewQuery(entityName)
+		q = q.Require("ProjectID", version.ProjectID)
+		q = q.Require("ApiID", version.ApiID)
+		q = q.Require("VersionID", version.VersionID)
+		if err := c.DeleteAllMatches(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChildrenOfSpec deletes all the children of a spec.
+func (c *Client) DeleteChildrenOfSpec(ctx context.Context, spec names.Spec) error {
+	for _, entityName := range []string{
+		storage.ArtifactEntityName,
+		storage.ArtifactRevisionEntityName,
+		storage.ArtifactRevisionTagEntityName,
+		models.BlobEntityName,
+		storage.SpecRevisionTagEntityName,
+	} {
+		q := c.NewQuery(entityName)
+		q = q.Require("ProjectID", spec.ProjectID)
+		q = q.Require("ApiID", spec.ApiID)
+		q = q.Require("VersionID", spec.VersionID)
+		q = q.Require("SpecID", spec.SpecID)
+		if err := c.DeleteAllMatches(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
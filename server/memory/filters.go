@@ -0,0 +1,136 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
+)
+
+// ApplyFilter adds a filter condition to a query if it can be evaluated on stored models.
+func (q *Query) ApplyFilter(c *filtering.Condition) bool {
+	if !q.supports(c) {
+		return false
+	}
+
+	q.Conditions = append(q.Conditions, c)
+	return true
+}
+
+// supports returns true if a condition can be evaluated on the query's models.
+func (q *Query) supports(c *filtering.Condition) bool {
+	switch c.Operator {
+	case filtering.And, filtering.Or, filtering.Not:
+		for _, operand := range c.Operands {
+			if !q.supports(operand) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if c.Type == filtering.StringMap {
+		switch q.Kind {
+		case storage.ApiEntityName, storage.VersionEntityName, storage.SpecEntityName:
+		default:
+			return false
+		}
+	}
+
+	switch c.Value.(type) {
+	case string, int64, time.Time:
+		return true
+	case nil:
+		return c.Operator == filtering.Has
+	}
+	return false
+}
+
+// satisfies returns true if a model satisfies a filter condition.
+func satisfies(v reflect.Value, c *filtering.Condition) bool {
+	switch c.Operator {
+	case filtering.And:
+		return satisfies(v, c.Operands[0]) && satisfies(v, c.Operands[1])
+	case filtering.Or:
+		return satisfies(v, c.Operands[0]) || satisfies(v, c.Operands[1])
+	case filtering.Not:
+		return !satisfies(v, c.Operands[0])
+	}
+
+	var value interface{}
+	if c.Type == filtering.StringMap {
+		labels, err := labelsOf(v)
+		if err != nil {
+			return false
+		}
+		label, ok := labels[c.Key]
+		if !ok || c.Operator == filtering.Has {
+			return ok
+		}
+		value = label
+	} else {
+		f := v.FieldByName(c.Field)
+		if !f.IsValid() {
+			return false
+		}
+		value = f.Interface()
+	}
+
+	switch c.Operator {
+	case filtering.StartsWith:
+		s, ok := value.(string)
+		return ok && strings.HasPrefix(s, c.Value.(string))
+	case filtering.Contains:
+		s, ok := value.(string)
+		return ok && strings.Contains(s, c.Value.(string))
+	}
+
+	cmp := compare(value, c.Value)
+	switch c.Operator {
+	case filtering.Equal:
+		return cmp == 0
+	case filtering.NotEqual:
+		return cmp != 0
+	case filtering.Less:
+		return cmp < 0
+	case filtering.LessEqual:
+		return cmp <= 0
+	case filtering.Greater:
+		return cmp > 0
+	case filtering.GreaterEqual:
+		return cmp >= 0
+	}
+	return false
+}
+
+// labelsOf returns the labels of a model that stores them.
+func labelsOf(v reflect.Value) (map[string]string, error) {
+	model := reflect.New(v.Type())
+	model.Elem().Set(v)
+	method := model.MethodByName("LabelsMap")
+	if !method.IsValid() {
+		return nil, nil
+	}
+
+	results := method.Call(nil)
+	if err, _ := results[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return results[0].Interface().(map[string]string), nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/apigee/registry/server/storage"
+	"google.golang.org/api/iterator"
+)
+
+// Iterator can be used to iterate through results of a query.
+// Results are read when the query is run, so later changes are not visible to the iterator.
+type Iterator struct {
+	client *Client
+	kind   string
+	values []reflect.Value
+	index  int
+}
+
+// Next gets the next value from the iterator.
+func (it *Iterator) Next(v interface{}) (storage.Key, error) {
+	if it.index >= len(it.values) {
+		return nil, iterator.Done
+	}
+
+	value := it.values[it.index]
+	if err := load(value, v); err != nil {
+		return nil, fmt.Errorf("unsupported iterator type: %s", err)
+	}
+	it.index++
+
+	return it.client.NewKey(it.kind, fmt.Sprint(keyOf(value))), nil
+}
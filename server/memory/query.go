@@ -0,0 +1,332 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/storage"
+	"github.com/apigee/registry/server/storage/filtering"
+)
+
+// Query represents a query in a storage provider.
+type Query struct {
+	Kind         string
+	Limit        int
+	Requirements []*Requirement
+	// Conditions are filter conditions that results must satisfy.
+	Conditions []*filtering.Condition
+	// Orders are the model fields used to order results before keys, if any.
+	Orders []*Order
+	// Cursor selects results after those returned by a previous query.
+	Cursor *Cursor
+}
+
+// Requirement adds a comparison filter to a query.
+type Requirement struct {
+	Name     string
+	Operator string
+	Value    interface{}
+}
+
+// Order sorts query results by a model field.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Cursor identifies the last result of a previous query.
+type Cursor struct {
+	Key         interface{}
+	OrderValues []interface{}
+}
+
+// NewQuery creates a new query.
+func (c *Client) NewQuery(kind string) storage.Query {
+	return &Query{Kind: kind}
+}
+
+// Require adds a filter to a query that requires a field to have a specified value.
+func (q *Query) Require(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: name, Operator: "=", Value: value})
+	return q
+}
+
+// After adds a filter to a query that requires a field to be greater than a specified value.
+func (q *Query) After(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: name, Operator: ">", Value: value})
+	return q
+}
+
+// Before adds a filter to a query that requires a field to be less than a specified value.
+func (q *Query) Before(name string, value interface{}) storage.Query {
+	q.Requirements = append(q.Requirements, &Requirement{Name: name, Operator: "<", Value: value})
+	return q
+}
+
+// Ascending orders query results by a field in ascending order.
+func (q *Query) Ascending(field string) storage.Query {
+	q.Orders = append(q.Orders, &Order{Field: field})
+	return q
+}
+
+// Descending orders query results by a field in descending order.
+func (q *Query) Descending(field string) storage.Query {
+	q.Orders = append(q.Orders, &Order{Field: field, Descending: true})
+	return q
+}
+
+func (q *Query) ApplyLimit(limit int32) storage.Query {
+	q.Limit = int(limit)
+	return q
+}
+
+// ForUpdate returns the query unchanged. Results don't need to be locked, because transactions are serialized.
+func (q *Query) ForUpdate() storage.Query {
+	return q
+}
+
+// StartAfter limits results to those that follow the entity with the specified key.
+// For queries that are ordered by fields, orderValues are the entity's values of those fields.
+func (q *Query) StartAfter(key string, orderValues ...interface{}) storage.Query {
+	if q.Kind == models.ChangeEntityName {
+		id, _ := strconv.ParseInt(key, 10, 64)
+		q.Cursor = &Cursor{Key: id, OrderValues: orderValues}
+	} else {
+		q.Cursor = &Cursor{Key: key, OrderValues: orderValues}
+	}
+	return q
+}
+
+// keyOf returns the value that uniquely identifies a model.
+func keyOf(v reflect.Value) interface{} {
+	if f := v.FieldByName("Key"); f.IsValid() {
+		return f.Interface()
+	}
+	return v.FieldByName("ID").Interface()
+}
+
+// matches returns true if a model satisfies the requirements of a query.
+func (q *Query) matches(v reflect.Value) bool {
+	for _, r := range q.Requirements {
+		f := v.FieldByName(r.Name)
+		if !f.IsValid() {
+			return false
+		}
+		c := compare(f.Interface(), r.Value)
+		if (r.Operator == "=" && c != 0) || (r.Operator == ">" && c <= 0) || (r.Operator == "<" && c >= 0) {
+			return false
+		}
+	}
+	for _, c := range q.Conditions {
+		if !satisfies(v, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// less returns true if model a is ordered before model b.
+func (q *Query) less(a, b reflect.Value) bool {
+	for _, o := range q.Orders {
+		c := compare(a.FieldByName(o.Field).Interface(), b.FieldByName(o.Field).Interface())
+		if o.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return compare(keyOf(a), keyOf(b)) < 0
+}
+
+// follows returns true if a model is ordered after the query's cursor.
+func (q *Query) follows(v reflect.Value) bool {
+	if q.Cursor == nil {
+		return true
+	}
+
+	if len(q.Cursor.OrderValues) == len(q.Orders) {
+		for i, o := range q.Orders {
+			c := compare(v.FieldByName(o.Field).Interface(), q.Cursor.OrderValues[i])
+			if o.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c > 0
+			}
+		}
+	}
+	return compare(keyOf(v), q.Cursor.Key) > 0
+}
+
+// results returns the ordered models that satisfy a query, starting after its cursor.
+func (q *Query) results(candidates []reflect.Value) []reflect.Value {
+	results := make([]reflect.Value, 0)
+	for _, v := range candidates {
+		if q.matches(v) && q.follows(v) {
+			results = append(results, v)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return q.less(results[i], results[j])
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+// compare returns a negative number, zero or a positive number if a is less than, equal to or greater than b.
+// Values of different types are compared by their types' names.
+func compare(a, b interface{}) int {
+	if a, ok := toInt64(a); ok {
+		if b, ok := toInt64(b); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case b:
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	ta, tb := reflect.TypeOf(a).String(), reflect.TypeOf(b).String()
+	switch {
+	case ta < tb:
+		return -1
+	case ta > tb:
+		return 1
+	}
+	return 0
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Run runs a query using the storage client, returning an iterator.
+func (c *Client) Run(ctx context.Context, q storage.Query) storage.Iterator {
+	c.rlock()
+	defer c.runlock()
+
+	query := q.(*Query)
+	candidates := make([]reflect.Value, 0, len(c.store.tables[query.Kind]))
+	for _, v := range c.store.tables[query.Kind] {
+		candidates = append(candidates, v)
+	}
+	return &Iterator{client: c, kind: query.Kind, values: query.results(candidates)}
+}
+
+// GetRecentSpecRevisions runs a query for specs that only returns their most recent revisions.
+func (c *Client) GetRecentSpecRevisions(ctx context.Context, q storage.Query) storage.Iterator {
+	c.rlock()
+	defer c.runlock()
+
+	// Find the creation time of the most recent revision of each spec.
+	recent := make(map[string]time.Time)
+	for _, v := range c.store.tables[storage.SpecEntityName] {
+		spec := v.Interface().(models.Spec)
+		if t, ok := recent[spec.Name()]; !ok || spec.RevisionCreateTime.After(t) {
+			recent[spec.Name()] = spec.RevisionCreateTime
+		}
+	}
+
+	candidates := make([]reflect.Value, 0, len(recent))
+	for _, v := range c.store.tables[storage.SpecEntityName] {
+		spec := v.Interface().(models.Spec)
+		if spec.RevisionCreateTime.Equal(recent[spec.Name()]) {
+			candidates = append(candidates, v)
+		}
+	}
+
+	query := q.(*Query)
+	return &Iterator{client: c, kind: storage.SpecEntityName, values: query.results(candidates)}
+}
+
+// GetRecentDeploymentRevisions runs a query for deployments that only returns their most recent revisions.
+func (c *Client) GetRecentDeploymentRevisions(ctx context.Context, q storage.Query) storage.Iterator {
+	c.rlock()
+	defer c.runlock()
+
+	// Find the creation time of the most recent revision of each deployment.
+	recent := make(map[string]time.Time)
+	for _, v := range c.store.tables[storage.DeploymentEntityName] {
+		deployment := v.Interface().(models.Deployment)
+		if t, ok := recent[deployment.Name()]; !ok || deployment.RevisionCreateTime.After(t) {
+			recent[deployment.Name()] = deployment.RevisionCreateTime
+		}
+	}
+
+	candidates := make([]reflect.Value, 0, len(recent))
+	for _, v := range c.store.tables[storage.DeploymentEntityName] {
+		deployment := v.Interface().(models.Deployment)
+		if deployment.RevisionCreateTime.Equal(recent[deployment.Name()]) {
+			candidates = append(candidates, v)
+		}
+	}
+
+	query := q.(*Query)
+	return &Iterator{client: c, kind: storage.DeploymentEntityName, values: query.results(candidates)}
+}
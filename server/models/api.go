@@ -36,6 +36,9 @@ type Api struct {
 	RecommendedVersion string    // Recommended API version.
 	Labels             []byte    // Serialized labels.
 	Annotations        []byte    // Serialized annotations.
+	Deleted            bool      // True if the api was deleted and hasn't been purged.
+	DeleteTime         time.Time // Deletion time.
+	PurgeTime          time.Time // Time that the deleted api will be purged.
 }
 
 // NewApi initializes a new resource.
@@ -95,6 +98,12 @@ func (api *Api) Message() (message *rpc.Api, err error) {
 		return nil, err
 	}
 
+	if api.Deleted {
+		message.DeleteTime = timestamppb.New(api.DeleteTime)
+		message.PurgeTime = timestamppb.New(api.PurgeTime)
+	}
+
+	message.Etag = etag(message)
 	return message, nil
 }
 
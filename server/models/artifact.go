@@ -24,32 +24,38 @@ import (
 )
 
 // Artifact is the storage-side representation of an artifact.
+// Artifacts are stored with their current revision, and every revision
+// (including the current one) is also stored as an ArtifactRevision.
 type Artifact struct {
-	Key         string    `gorm:"primaryKey"`
-	ProjectID   string    // Project associated with artifact (required).
-	ApiID       string    // Api associated with artifact (if appropriate).
-	VersionID   string    // Version associated with artifact (if appropriate).
-	SpecID      string    // Spec associated with artifact (if appropriate).
-	ArtifactID  string    // Artifact identifier (required).
-	CreateTime  time.Time // Creation time.
-	UpdateTime  time.Time // Time of last change.
-	MimeType    string    // MIME type of artifact
-	SizeInBytes int32     // Size of the spec.
-	Hash        string    // A hash of the spec.
+	Key                string    `gorm:"primaryKey"`
+	ProjectID          string    // Project associated with artifact (required).
+	ApiID              string    // Api associated with artifact (if appropriate).
+	VersionID          string    // Version associated with artifact (if appropriate).
+	SpecID             string    // Spec associated with artifact (if appropriate).
+	ArtifactID         string    // Artifact identifier (required).
+	RevisionID         string    // Uniquely identifies a revision of an artifact.
+	CreateTime         time.Time // Creation time.
+	UpdateTime         time.Time // Time of last change.
+	RevisionCreateTime time.Time // Revision creation time.
+	MimeType           string    // MIME type of artifact
+	SizeInBytes        int32     // Size of the spec.
+	Hash               string    // A hash of the spec.
 }
 
 // NewArtifact initializes a new resource.
 func NewArtifact(name names.Artifact, body *rpc.Artifact) *Artifact {
 	now := time.Now().Round(time.Microsecond)
 	artifact := &Artifact{
-		ProjectID:  name.ProjectID(),
-		ApiID:      name.ApiID(),
-		VersionID:  name.VersionID(),
-		SpecID:     name.SpecID(),
-		ArtifactID: name.ArtifactID(),
-		CreateTime: now,
-		UpdateTime: now,
-		MimeType:   body.GetMimeType(),
+		ProjectID:          name.ProjectID(),
+		ApiID:              name.ApiID(),
+		VersionID:          name.VersionID(),
+		SpecID:             name.SpecID(),
+		ArtifactID:         name.ArtifactID(),
+		RevisionID:         newRevisionID(),
+		CreateTime:         now,
+		UpdateTime:         now,
+		RevisionCreateTime: now,
+		MimeType:           body.GetMimeType(),
 	}
 
 	if body.GetContents() != nil {
@@ -80,14 +86,158 @@ func (artifact *Artifact) Name() string {
 	}
 }
 
+// Replace returns a replacement for the artifact with the contents of a message.
+// The replacement is a new revision if the contents are changed.
+func (artifact *Artifact) Replace(body *rpc.Artifact) *Artifact {
+	now := time.Now().Round(time.Microsecond)
+	replacement := *artifact
+	replacement.Key = ""
+	replacement.MimeType = body.GetMimeType()
+	replacement.UpdateTime = now
+
+	if hash := hashForBytes(body.GetContents()); hash != artifact.Hash {
+		replacement.Hash = hash
+		replacement.SizeInBytes = int32(len(body.GetContents()))
+		replacement.RevisionID = newRevisionID()
+		replacement.RevisionCreateTime = now
+	}
+
+	return &replacement
+}
+
+// RevisionName returns the resource name of the artifact revision.
+func (artifact *Artifact) RevisionName() string {
+	return fmt.Sprintf("%s@%s", artifact.Name(), artifact.RevisionID)
+}
+
+// Revision returns the current revision of the artifact.
+func (artifact *Artifact) Revision() *ArtifactRevision {
+	return &ArtifactRevision{
+		ProjectID:          artifact.ProjectID,
+		ApiID:              artifact.ApiID,
+		VersionID:          artifact.VersionID,
+		SpecID:             artifact.SpecID,
+		ArtifactID:         artifact.ArtifactID,
+		RevisionID:         artifact.RevisionID,
+		CreateTime:         artifact.CreateTime,
+		UpdateTime:         artifact.UpdateTime,
+		RevisionCreateTime: artifact.RevisionCreateTime,
+		MimeType:           artifact.MimeType,
+		SizeInBytes:        artifact.SizeInBytes,
+		Hash:               artifact.Hash,
+	}
+}
+
 // Message returns an RPC message representing the artifact.
 func (artifact *Artifact) Message() *rpc.Artifact {
-	return &rpc.Artifact{
-		Name:       artifact.Name(),
-		MimeType:   artifact.MimeType,
-		SizeBytes:  artifact.SizeInBytes,
-		Hash:       artifact.Hash,
-		CreateTime: timestamppb.New(artifact.CreateTime),
-		UpdateTime: timestamppb.New(artifact.UpdateTime),
+	message := &rpc.Artifact{
+		Name:               artifact.Name(),
+		MimeType:           artifact.MimeType,
+		SizeBytes:          artifact.SizeInBytes,
+		Hash:               artifact.Hash,
+		RevisionId:         artifact.RevisionID,
+		CreateTime:         timestamppb.New(artifact.CreateTime),
+		UpdateTime:         timestamppb.New(artifact.UpdateTime),
+		RevisionCreateTime: timestamppb.New(artifact.RevisionCreateTime),
+	}
+	message.Etag = etag(message)
+	return message
+}
+
+// ArtifactRevision is the storage-side representation of an artifact revision.
+type ArtifactRevision struct {
+	Key                string    `gorm:"primaryKey"`
+	ProjectID          string    // Project associated with artifact (required).
+	ApiID              string    // Api associated with artifact (if appropriate).
+	VersionID          string    // Version associated with artifact (if appropriate).
+	SpecID             string    // Spec associated with artifact (if appropriate).
+	ArtifactID         string    // Artifact identifier (required).
+	RevisionID         string    // Uniquely identifies a revision of an artifact.
+	CreateTime         time.Time // Creation time of the artifact.
+	UpdateTime         time.Time // Time of last change.
+	RevisionCreateTime time.Time // Revision creation time.
+	MimeType           string    // MIME type of artifact
+	SizeInBytes        int32     // Size of the artifact.
+	Hash               string    // A hash of the artifact.
+}
+
+// Artifact returns the artifact as it was when the revision was current.
+func (r *ArtifactRevision) Artifact() *Artifact {
+	return &Artifact{
+		ProjectID:          r.ProjectID,
+		ApiID:              r.ApiID,
+		VersionID:          r.VersionID,
+		SpecID:             r.SpecID,
+		ArtifactID:         r.ArtifactID,
+		RevisionID:         r.RevisionID,
+		CreateTime:         r.CreateTime,
+		UpdateTime:         r.UpdateTime,
+		RevisionCreateTime: r.RevisionCreateTime,
+		MimeType:           r.MimeType,
+		SizeInBytes:        r.SizeInBytes,
+		Hash:               r.Hash,
 	}
 }
+
+// Name returns the resource name of the artifact.
+func (r *ArtifactRevision) Name() string {
+	return r.Artifact().Name()
+}
+
+// RevisionName returns the resource name of the artifact revision.
+func (r *ArtifactRevision) RevisionName() string {
+	return r.Artifact().RevisionName()
+}
+
+// Rollback returns a new current revision of the artifact with the contents of this revision.
+func (r *ArtifactRevision) Rollback() *Artifact {
+	now := time.Now().Round(time.Microsecond)
+	artifact := r.Artifact()
+	artifact.RevisionID = newRevisionID()
+	artifact.UpdateTime = now
+	artifact.RevisionCreateTime = now
+	return artifact
+}
+
+// Message returns an RPC message representing the artifact revision.
+func (r *ArtifactRevision) Message(name string) *rpc.Artifact {
+	message := r.Artifact().Message()
+	message.Name = name
+	return message
+}
+
+// ArtifactRevisionTag is the storage-side representation of an artifact revision tag.
+type ArtifactRevisionTag struct {
+	Key        string    `gorm:"primaryKey"`
+	ProjectID  string    // Project associated with artifact (required).
+	ApiID      string    // Api associated with artifact (if appropriate).
+	VersionID  string    // Version associated with artifact (if appropriate).
+	SpecID     string    // Spec associated with artifact (if appropriate).
+	ArtifactID string    // Artifact identifier (required).
+	RevisionID string    // Uniquely identifies a revision of an artifact.
+	Tag        string    // The tag to use for the revision.
+	CreateTime time.Time // Creation time.
+	UpdateTime time.Time // Time of last change.
+}
+
+// NewArtifactRevisionTag initializes a new revision tag from a given revision name and tag string.
+func NewArtifactRevisionTag(name names.ArtifactRevision, tag string) *ArtifactRevisionTag {
+	now := time.Now().Round(time.Microsecond)
+	artifact := name.Artifact()
+	return &ArtifactRevisionTag{
+		ProjectID:  artifact.ProjectID(),
+		ApiID:      artifact.ApiID(),
+		VersionID:  artifact.VersionID(),
+		SpecID:     artifact.SpecID(),
+		ArtifactID: artifact.ArtifactID(),
+		RevisionID: name.RevisionID,
+		Tag:        tag,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+}
+
+func (t *ArtifactRevisionTag) String() string {
+	a := &Artifact{ProjectID: t.ProjectID, ApiID: t.ApiID, VersionID: t.VersionID, SpecID: t.SpecID, ArtifactID: t.ArtifactID}
+	return fmt.Sprintf("%s@%s", a.Name(), t.Tag)
+}
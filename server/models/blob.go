@@ -19,22 +19,46 @@ import "time"
 // BlobEntityName is used to represent blobs in storage.
 const BlobEntityName = "Blob"
 
+// BlobContentsEntityName is used to represent blob contents in storage.
+const BlobContentsEntityName = "BlobContents"
+
 // Blob is the storage-side representation of a blob.
+// Blobs refer to their contents by hash, so that blobs with the same
+// contents share a single copy of them.
 type Blob struct {
 	Key         string    `gorm:"primaryKey"`
 	ProjectID   string    // Uniquely identifies a project.
 	ApiID       string    // Uniquely identifies an api within a project.
 	VersionID   string    // Uniquely identifies a version within a api.
 	SpecID      string    // Uniquely identifies a spec within a version.
-	RevisionID  string    // Uniquely identifies a revision of a spec.
+	RevisionID  string    // Uniquely identifies a revision of a spec or artifact.
 	ArtifactID  string    // Uniquely identifies an artifact on a resource.
 	Hash        string    // Hash of the blob contents.
 	SizeInBytes int32     // Size of the blob contents.
-	Contents    []byte    // The contents of the blob.
+	Contents    []byte    // The contents of the blob. Only set when contents are read.
 	CreateTime  time.Time // Creation time.
 	UpdateTime  time.Time // Time of last change.
 }
 
+// BlobContents is the storage-side representation of the contents of one or more blobs.
+// Contents are kept until no blob with their hash remains.
+type BlobContents struct {
+	Key         string    `gorm:"primaryKey"` // Hash of the contents.
+	SizeInBytes int32     // Size of the contents.
+	Contents    []byte    // The contents.
+	CreateTime  time.Time // Creation time.
+}
+
+// NewBlobContents creates a new BlobContents object to store the contents of a blob.
+func NewBlobContents(blob *Blob) *BlobContents {
+	return &BlobContents{
+		Key:         blob.Hash,
+		SizeInBytes: blob.SizeInBytes,
+		Contents:    blob.Contents,
+		CreateTime:  time.Now().Round(time.Microsecond),
+	}
+}
+
 // NewBlobForSpec creates a new Blob object to store spec contents.
 func NewBlobForSpec(spec *Spec, contents []byte) *Blob {
 	now := time.Now().Round(time.Microsecond)
@@ -60,6 +84,7 @@ func NewBlobForArtifact(artifact *Artifact, contents []byte) *Blob {
 		ApiID:       artifact.ApiID,
 		VersionID:   artifact.VersionID,
 		SpecID:      artifact.SpecID,
+		RevisionID:  artifact.RevisionID,
 		ArtifactID:  artifact.ArtifactID,
 		Hash:        hashForBytes(contents),
 		SizeInBytes: int32(len(contents)),
@@ -68,3 +93,8 @@ func NewBlobForArtifact(artifact *Artifact, contents []byte) *Blob {
 		UpdateTime:  now,
 	}
 }
+
+// NewBlobForArtifactRevision creates a new Blob object to store the contents of an artifact revision.
+func NewBlobForArtifactRevision(revision *ArtifactRevision, contents []byte) *Blob {
+	return NewBlobForArtifact(revision.Artifact(), contents)
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ChangeEntityName is used to represent changes in storage.
+const ChangeEntityName = "Change"
+
+// Change is the storage-side representation of a change to a resource.
+// Changes are recorded with the modifications they describe and form an
+// ordered log that can be used to deliver notifications.
+type Change struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement"` // Position of the change in the log.
+	Change     int32     // The type of change.
+	Resource   string    // Name of the changed resource.
+	ChangeTime time.Time // Time of the change.
+	Dispatched bool      // True if the change has been sent to the configured notifier.
+}
+
+// NewChange creates a new Change object for a change to the named resource.
+func NewChange(change rpc.Notification_Change, resource string) *Change {
+	return &Change{
+		Change:     int32(change),
+		Resource:   resource,
+		ChangeTime: time.Now().Round(time.Microsecond),
+	}
+}
+
+// Message returns the notification representation of a change.
+func (c *Change) Message() *rpc.Notification {
+	return &rpc.Notification{
+		Change:     rpc.Notification_Change(c.Change),
+		Resource:   c.Resource,
+		ChangeTime: timestamppb.New(c.ChangeTime),
+	}
+}
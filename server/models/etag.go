@@ -0,0 +1,28 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// etag returns a checksum of a message representing the stored state of a resource.
+// Messages include the time of the last change, so etags change whenever resources are modified.
+func etag(message proto.Message) string {
+	// Messages are built from stored values that were valid when they were saved,
+	// so they can always be serialized.
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	return hashForBytes(b)
+}
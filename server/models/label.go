@@ -0,0 +1,32 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+// LabelEntityName is used to represent labels in storage.
+const LabelEntityName = "Label"
+
+// Label is the storage-side representation of a label on a resource.
+// Labels are serialized with the resources that they describe and are
+// also stored individually so that storage can select resources by label.
+type Label struct {
+	Kind      string `gorm:"primaryKey"` // Entity name of the labeled resource.
+	EntityKey string `gorm:"primaryKey"` // Storage key of the labeled resource.
+	Label     string `gorm:"primaryKey"` // The label key.
+	Value     string // The label value.
+	ProjectID string // Uniquely identifies a project.
+	ApiID     string // Uniquely identifies an api within a project.
+	VersionID string // Uniquely identifies a version within a api.
+	SpecID    string // Uniquely identifies a spec within a version.
+}
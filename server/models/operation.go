@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/longrunning"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// OperationEntityName is used to represent long-running operations in storage.
+const OperationEntityName = "Operation"
+
+// Operation is the storage-side representation of a long-running operation.
+type Operation struct {
+	Key             string    `gorm:"primaryKey"` // Resource name of the operation.
+	Verb            string    // Name of the action performed by the operation.
+	Target          string    // Resource name of the resource that the operation acts on.
+	CreateTime      time.Time // Creation time.
+	UpdateTime      time.Time // Time of the last progress report.
+	EndTime         time.Time // Time the operation finished.
+	Done            bool      // True if the operation finished.
+	CancelRequested bool      // True if cancellation was requested.
+	ProgressPercent int32     // Estimated percentage of the work that is done.
+	StatusMessage   string    // Description of the current step.
+	Error           []byte    // Serialized status of a failed operation.
+	Response        []byte    // Serialized response of a successful operation.
+}
+
+// NewOperation initializes a new operation that performs an action on a target resource.
+func NewOperation(verb, target string) *Operation {
+	now := time.Now().Round(time.Microsecond)
+	return &Operation{
+		Key:        "operations/" + uuid.New().String(),
+		Verb:       verb,
+		Target:     target,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+}
+
+// Name returns the resource name of the operation.
+func (o *Operation) Name() string {
+	return o.Key
+}
+
+// Message returns the operation as a google.longrunning.Operation message.
+func (o *Operation) Message() (*longrunning.Operation, error) {
+	metadata := &rpc.OperationMetadata{
+		CreateTime:      timestamppb.New(o.CreateTime),
+		UpdateTime:      timestamppb.New(o.UpdateTime),
+		Target:          o.Target,
+		Verb:            o.Verb,
+		StatusMessage:   o.StatusMessage,
+		CancelRequested: o.CancelRequested,
+		ProgressPercent: o.ProgressPercent,
+	}
+	if o.Done {
+		metadata.EndTime = timestamppb.New(o.EndTime)
+	}
+
+	m, err := anypb.New(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &longrunning.Operation{
+		Name:     o.Key,
+		Metadata: m,
+		Done:     o.Done,
+	}
+
+	switch {
+	case !o.Done:
+	case o.Error != nil:
+		s := new(statuspb.Status)
+		if err := proto.Unmarshal(o.Error, s); err != nil {
+			return nil, err
+		}
+		message.Result = &longrunning.Operation_Error{Error: s}
+	default:
+		r := new(anypb.Any)
+		if err := proto.Unmarshal(o.Response, r); err != nil {
+			return nil, err
+		}
+		message.Result = &longrunning.Operation_Response{Response: r}
+	}
+
+	return message, nil
+}
+
+// Progress records the progress of an unfinished operation.
+func (o *Operation) Progress(percent int32, message string) {
+	o.UpdateTime = time.Now().Round(time.Microsecond)
+	o.ProgressPercent = percent
+	o.StatusMessage = message
+}
+
+// Finish records the result of an operation, which is its response if err is nil.
+func (o *Operation) Finish(response proto.Message, err error) error {
+	if err != nil {
+		b, err := proto.Marshal(status.Convert(err).Proto())
+		if err != nil {
+			return err
+		}
+		o.Error = b
+	} else {
+		r, err := anypb.New(response)
+		if err != nil {
+			return err
+		}
+		b, err := proto.Marshal(r)
+		if err != nil {
+			return err
+		}
+		o.Response = b
+		o.ProgressPercent = 100
+	}
+
+	o.Done = true
+	o.EndTime = time.Now().Round(time.Microsecond)
+	o.UpdateTime = o.EndTime
+	o.StatusMessage = ""
+	return nil
+}
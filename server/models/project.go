@@ -54,13 +54,15 @@ func (p *Project) Name() string {
 
 // Message returns a message representing a project.
 func (p *Project) Message() *rpc.Project {
-	return &rpc.Project{
+	message := &rpc.Project{
 		Name:        p.Name(),
 		DisplayName: p.DisplayName,
 		Description: p.Description,
 		CreateTime:  timestamppb.New(p.CreateTime),
 		UpdateTime:  timestamppb.New(p.UpdateTime),
 	}
+	message.Etag = etag(message)
+	return message
 }
 
 // Update modifies a project using the contents of a message.
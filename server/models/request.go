@@ -0,0 +1,67 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestEntityName is used to represent completed requests in storage.
+const RequestEntityName = "Request"
+
+// Request is the storage-side representation of a completed request.
+// Requests are kept for a limited time so that retries can be answered
+// with the original response instead of being repeated.
+type Request struct {
+	Key        string    `gorm:"primaryKey"` // The request ID provided by the caller.
+	Method     string    // Full name of the request message.
+	Hash       string    // Hash of the request message.
+	Response   []byte    // The serialized response message.
+	CreateTime time.Time // Time the request was completed.
+}
+
+// NewRequest creates a new Request object recording the response to a request.
+func NewRequest(id string, request, response proto.Message) (*Request, error) {
+	b, err := proto.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Key:        id,
+		Method:     string(request.ProtoReflect().Descriptor().FullName()),
+		Hash:       requestHash(request),
+		Response:   b,
+		CreateTime: time.Now().Round(time.Microsecond),
+	}, nil
+}
+
+// Matches returns true if a request is the same as the recorded request.
+func (r *Request) Matches(request proto.Message) bool {
+	return r.Method == string(request.ProtoReflect().Descriptor().FullName()) && r.Hash == requestHash(request)
+}
+
+// LoadResponse reads the recorded response into a message of the response type.
+func (r *Request) LoadResponse(response proto.Message) error {
+	return proto.Unmarshal(r.Response, response)
+}
+
+// requestHash returns a checksum of a request message.
+func requestHash(request proto.Message) string {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	return hashForBytes(b)
+}
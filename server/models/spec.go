@@ -45,6 +45,9 @@ type Spec struct {
 	SourceURI          string    // The original source URI of the spec.
 	Labels             []byte    // Serialized labels.
 	Annotations        []byte    // Serialized annotations.
+	Deleted            bool      // True if the spec was deleted and hasn't been purged.
+	DeleteTime         time.Time // Deletion time.
+	PurgeTime          time.Time // Time that the deleted spec will be purged.
 }
 
 // NewSpec initializes a new resource.
@@ -122,7 +125,7 @@ func (s *Spec) RevisionName() string {
 // BasicMessage returns the basic view of the spec resource as an RPC message.
 func (s *Spec) BasicMessage(name string) (message *rpc.ApiSpec, err error) {
 	message = &rpc.ApiSpec{
-		Name:               name,
+		Name:               s.Name(),
 		Filename:           s.FileName,
 		Description:        s.Description,
 		Hash:               s.Hash,
@@ -145,6 +148,14 @@ func (s *Spec) BasicMessage(name string) (message *rpc.ApiSpec, err error) {
 		return nil, err
 	}
 
+	if s.Deleted {
+		message.DeleteTime = timestamppb.New(s.DeleteTime)
+		message.PurgeTime = timestamppb.New(s.PurgeTime)
+	}
+
+	// Etags are computed from stored state, so they don't depend on the name used to request it.
+	message.Etag = etag(message)
+	message.Name = name
 	return message, nil
 }
 
@@ -36,6 +36,9 @@ type Version struct {
 	State       string    // Lifecycle stage.
 	Labels      []byte    // Serialized labels.
 	Annotations []byte    // Serialized annotations.
+	Deleted     bool      // True if the version was deleted and hasn't been purged.
+	DeleteTime  time.Time // Deletion time.
+	PurgeTime   time.Time // Time that the deleted version will be purged.
 }
 
 // NewVersion initializes a new resource.
@@ -95,6 +98,12 @@ func (v *Version) Message() (message *rpc.ApiVersion, err error) {
 		return nil, err
 	}
 
+	if v.Deleted {
+		message.DeleteTime = timestamppb.New(v.DeleteTime)
+		message.PurgeTime = timestamppb.New(v.PurgeTime)
+	}
+
+	message.Etag = etag(message)
 	return message, nil
 }
 
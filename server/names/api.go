@@ -53,6 +53,14 @@ func (a Api) Version(id string) Version {
 }
 
 // Artifact returns an artifact with the provided ID and this resource as its parent.
+func (a Api) Deployment(id string) Deployment {
+	return Deployment{
+		ProjectID:    a.ProjectID,
+		ApiID:        a.ApiID,
+		DeploymentID: id,
+	}
+}
+
 func (a Api) Artifact(id string) Artifact {
 	return Artifact{
 		name: apiArtifact{
@@ -0,0 +1,65 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package names
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var artifactRevisionRegexp = regexp.MustCompile(fmt.Sprintf("^(projects/%s(?:/apis/%s(?:/versions/%s(?:/specs/%s)?)?)?/artifacts/%s)@%s$", identifier, identifier, identifier, identifier, identifier, revisionTag))
+
+// ArtifactRevision represents a resource name for an artifact revision.
+type ArtifactRevision struct {
+	artifact   Artifact
+	RevisionID string
+}
+
+// Revision returns the name of a revision of the artifact.
+func (a Artifact) Revision(id string) ArtifactRevision {
+	return ArtifactRevision{
+		artifact:   a,
+		RevisionID: id,
+	}
+}
+
+// Artifact returns the artifact for this resource.
+func (r ArtifactRevision) Artifact() Artifact {
+	return r.artifact
+}
+
+func (r ArtifactRevision) String() string {
+	return normalize(fmt.Sprintf("%s@%s", r.artifact, r.RevisionID))
+}
+
+// ParseArtifactRevision parses the name of an artifact revision.
+func ParseArtifactRevision(name string) (ArtifactRevision, error) {
+	if !artifactRevisionRegexp.MatchString(name) {
+		return ArtifactRevision{}, fmt.Errorf("invalid artifact revision name %q: must match %q", name, artifactRevisionRegexp)
+	}
+
+	m := artifactRevisionRegexp.FindStringSubmatch(name)
+	artifact, err := ParseArtifact(m[1])
+	if err != nil {
+		return ArtifactRevision{}, err
+	}
+
+	return artifact.Revision(m[len(m)-1]), nil
+}
+
+// ArtifactRevisionRegexp returns a regular expression that matches an artifact revision resource name.
+func ArtifactRevisionRegexp() *regexp.Regexp {
+	return artifactRevisionRegexp
+}
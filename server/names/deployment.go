@@ -0,0 +1,92 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package names
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var deploymentRegexp = regexp.MustCompile(fmt.Sprintf("^projects/%s/apis/%s/deployments/%s$", identifier, identifier, identifier))
+
+type Deployment struct {
+	ProjectID    string
+	ApiID        string
+	DeploymentID string
+}
+
+func (d Deployment) Validate() error {
+	r := DeploymentRegexp()
+	if name := d.String(); !r.MatchString(name) {
+		return fmt.Errorf("invalid deployment name %q: must match %q", name, r)
+	}
+
+	return validateID(d.DeploymentID)
+}
+
+func (d Deployment) Project() Project {
+	return d.Api().Project()
+}
+
+func (d Deployment) Api() Api {
+	return Api{
+		ProjectID: d.ProjectID,
+		ApiID:     d.ApiID,
+	}
+}
+
+func (d Deployment) Revision(id string) DeploymentRevision {
+	return DeploymentRevision{
+		ProjectID:    d.ProjectID,
+		ApiID:        d.ApiID,
+		DeploymentID: d.DeploymentID,
+		RevisionID:   id,
+	}
+}
+
+func (d Deployment) Normal() Deployment {
+	return Deployment{
+		ProjectID:    normalize(d.ProjectID),
+		ApiID:        normalize(d.ApiID),
+		DeploymentID: normalize(d.DeploymentID),
+	}
+}
+
+func (d Deployment) String() string {
+	return normalize(fmt.Sprintf("projects/%s/apis/%s/deployments/%s", d.ProjectID, d.ApiID, d.DeploymentID))
+}
+
+func DeploymentsRegexp() *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("^projects/%s/apis/%s/deployments$", identifier, identifier))
+}
+
+func DeploymentRegexp() *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("^projects/%s/apis/%s/deployments/%s(@%s)?$", identifier, identifier, identifier, revisionTag))
+}
+
+func ParseDeployment(name string) (Deployment, error) {
+	if !deploymentRegexp.MatchString(name) {
+		return Deployment{}, fmt.Errorf("invalid deployment name %q: must match %q", name, deploymentRegexp)
+	}
+
+	m := deploymentRegexp.FindStringSubmatch(name)
+	deployment := Deployment{
+		ProjectID:    m[1],
+		ApiID:        m[2],
+		DeploymentID: m[3],
+	}
+
+	return deployment, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package names
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var deploymentRevisionRegexp = regexp.MustCompile(fmt.Sprintf("^projects/%s/apis/%s/deployments/%s@%s$", identifier, identifier, identifier, revisionTag))
+
+type DeploymentRevision struct {
+	ProjectID    string
+	ApiID        string
+	DeploymentID string
+	RevisionID   string
+}
+
+func (d DeploymentRevision) Deployment() Deployment {
+	return Deployment{
+		ProjectID:    d.ProjectID,
+		ApiID:        d.ApiID,
+		DeploymentID: d.DeploymentID,
+	}
+}
+
+func (d DeploymentRevision) String() string {
+	return normalize(fmt.Sprintf("projects/%s/apis/%s/deployments/%s@%s", d.ProjectID, d.ApiID, d.DeploymentID, d.RevisionID))
+}
+
+func ParseDeploymentRevision(name string) (DeploymentRevision, error) {
+	if !deploymentRevisionRegexp.MatchString(name) {
+		return DeploymentRevision{}, fmt.Errorf("invalid deployment revision name %q: must match %q", name, deploymentRevisionRegexp)
+	}
+
+	m := deploymentRevisionRegexp.FindStringSubmatch(name)
+	revision := DeploymentRevision{
+		ProjectID:    m[1],
+		ApiID:        m[2],
+		DeploymentID: m[3],
+		RevisionID:   m[4],
+	}
+
+	return revision, nil
+}
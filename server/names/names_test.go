@@ -138,6 +138,35 @@ func TestResourceNames(t *testing.T) {
 				"projects/123/apis/ 123",
 			},
 		},
+		{
+			name:   "deployments",
+			regexp: DeploymentsRegexp(),
+			pass: []string{
+				"projects/google/apis/sample/deployments",
+				"projects/-/apis/-/deployments",
+			},
+			fail: []string{
+				"-",
+				"projects/google/apis/sample/versions/v1/deployments",
+			},
+		},
+		{
+			name:   "deployment",
+			regexp: DeploymentRegexp(),
+			pass: []string{
+				"projects/google/apis/sample/deployments/prod@1234abcd",
+				"projects/google/apis/sample/deployments/prod",
+				"projects/-/apis/-/deployments/-",
+				"projects/1-2-3/apis/abc/deployments/123",
+			},
+			fail: []string{
+				"-",
+				"invalid",
+				"projects/123/apis/abc/deployments/",
+				"projects/123/apis/abc/versions/123/deployments/abc",
+				"projects/123/apis/abc/deployments/ 123",
+			},
+		},
 		{
 			name:   "artifacts",
 			regexp: ArtifactsRegexp(),
@@ -164,6 +193,22 @@ func TestResourceNames(t *testing.T) {
 				"-",
 			},
 		},
+		{
+			name:   "artifact revision",
+			regexp: ArtifactRevisionRegexp(),
+			pass: []string{
+				"projects/google/apis/sample/versions/v1/specs/openapi.yaml/artifacts/test-artifact@1234abcd",
+				"projects/google/apis/sample/versions/v1/artifacts/test-artifact@1234abcd",
+				"projects/google/apis/sample/artifacts/test-artifact@1234abcd",
+				"projects/google/artifacts/test-artifact@my-tag",
+			},
+			fail: []string{
+				"-",
+				"projects/google/artifacts/test-artifact",
+				"projects/google/artifacts/test-artifact@",
+				"projects/google/artifacts/test-artifact@1234abcd/contents",
+			},
+		},
 	}
 	for _, g := range groups {
 		for _, path := range g.pass {
@@ -180,3 +225,48 @@ func TestResourceNames(t *testing.T) {
 		}
 	}
 }
+
+func TestParseArtifactRevision(t *testing.T) {
+	name := "projects/google/apis/sample/versions/v1/specs/openapi.yaml/artifacts/test-artifact@1234abcd"
+	r, err := ParseArtifactRevision(name)
+	if err != nil {
+		t.Fatalf("ParseArtifactRevision(%q) returned error: %s", name, err)
+	}
+	if r.String() != name {
+		t.Errorf("ParseArtifactRevision(%q) returned %q", name, r)
+	}
+	if got := r.Artifact(); got.SpecID() != "openapi.yaml" || got.ArtifactID() != "test-artifact" {
+		t.Errorf("ParseArtifactRevision(%q) returned unexpected artifact %q", name, got)
+	}
+	if r.RevisionID != "1234abcd" {
+		t.Errorf("ParseArtifactRevision(%q) returned revision ID %q, want %q", name, r.RevisionID, "1234abcd")
+	}
+
+	if _, err := ParseArtifactRevision("projects/google/artifacts/test-artifact"); err == nil {
+		t.Errorf("ParseArtifactRevision returned nil error for an artifact name without a revision")
+	}
+}
+
+func TestParseDeploymentRevision(t *testing.T) {
+	name := "projects/google/apis/sample/deployments/prod@1234abcd"
+	r, err := ParseDeploymentRevision(name)
+	if err != nil {
+		t.Fatalf("ParseDeploymentRevision(%q) returned error: %s", name, err)
+	}
+	if r.String() != name {
+		t.Errorf("ParseDeploymentRevision(%q) returned %q", name, r)
+	}
+	if got, want := r.Deployment().String(), "projects/google/apis/sample/deployments/prod"; got != want {
+		t.Errorf("ParseDeploymentRevision(%q) returned deployment %q, want %q", name, got, want)
+	}
+	if r.RevisionID != "1234abcd" {
+		t.Errorf("ParseDeploymentRevision(%q) returned revision ID %q, want %q", name, r.RevisionID, "1234abcd")
+	}
+
+	if _, err := ParseDeployment(name); err == nil {
+		t.Errorf("ParseDeployment(%q) returned nil error for a deployment revision name", name)
+	}
+	if _, err := ParseDeploymentRevision("projects/google/apis/sample/deployments/prod"); err == nil {
+		t.Errorf("ParseDeploymentRevision returned nil error for a deployment name without a revision")
+	}
+}
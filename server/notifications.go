@@ -17,62 +17,155 @@ package server
 import (
 	"context"
 	"log"
+	"time"
 
-	"cloud.google.com/go/pubsub"
 	"github.com/apigee/registry/rpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/types/known/timestamppb"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/apigee/registry/server/notify"
 )
 
-const verbose = false
-
+// TopicName is the Pub/Sub topic used for notifications unless another is configured.
 const TopicName = "registry-events"
 
-var notificationTotal int
+const (
+	// outboxInterval is the time between attempts to dispatch changes that previously failed.
+	outboxInterval = 30 * time.Second
+	// outboxBatchSize is the number of changes read from the outbox at a time.
+	outboxBatchSize = 100
+)
 
-func (s *RegistryServer) notify(ctx context.Context, change rpc.Notification_Change, resource string) error {
-	if !s.notifyEnabled || s.projectID == "" {
-		return nil
+// notifierConfig returns the notifier configuration for a server, if notifications are enabled.
+// The top-level notify and project settings select Pub/Sub notifications for backward compatibility.
+func notifierConfig(config Config) (notify.Config, bool) {
+	c := config.Notifications
+	if c.Type == "" && config.Notify && config.ProjectID != "" {
+		c.Type = notify.PubSub
+		c.Project = config.ProjectID
 	}
 
-	client, err := pubsub.NewClient(ctx, s.projectID)
-	if err != nil {
-		return err
+	if c.Type == notify.PubSub {
+		if c.Project == "" {
+			c.Project = config.ProjectID
+		}
+		if c.Topic == "" {
+			c.Topic = TopicName
+		}
 	}
-	defer client.Close()
 
-	if _, err := client.CreateTopic(ctx, TopicName); err != nil && status.Code(err) != codes.AlreadyExists {
-		return err
-	}
+	return c, c.Type != ""
+}
 
-	topic := client.Topic(TopicName)
-	defer topic.Stop()
+// commit runs fn in a transaction that also records a change to the named resource.
+// When the transaction succeeds, the change is sent to watchers and queued for the notifier.
+func (s *RegistryServer) commit(ctx context.Context, db dao.DAO, change rpc.Notification_Change, resource string, fn func(db dao.DAO) error) error {
+	return s.commitChange(ctx, db, func(db dao.DAO) (*models.Change, error) {
+		if err := fn(db); err != nil {
+			return nil, err
+		}
+		return models.NewChange(change, resource), nil
+	})
+}
 
-	msg, err := protojson.Marshal(&rpc.Notification{
-		Change:     change,
-		Resource:   resource,
-		ChangeTime: timestamppb.Now(),
+// commitChange runs fn in a transaction that also records the change returned by fn.
+// It is used when the type of change isn't known until the transaction has begun.
+func (s *RegistryServer) commitChange(ctx context.Context, db dao.DAO, fn func(db dao.DAO) (*models.Change, error)) error {
+	return s.commitChanges(ctx, db, func(db dao.DAO) ([]*models.Change, error) {
+		c, err := fn(db)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.Change{c}, nil
 	})
-	if err != nil {
+}
+
+// commitChanges runs fn in a transaction that also records the changes returned by fn.
+// It is used by requests that change several resources together.
+func (s *RegistryServer) commitChanges(ctx context.Context, db dao.DAO, fn func(db dao.DAO) ([]*models.Change, error)) error {
+	var changes []*models.Change
+	if err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+		var err error
+		if changes, err = fn(db); err != nil {
+			return err
+		}
+		// Changes are timed when they are recorded, just before they are committed,
+		// so that readers of the log can tell how long a change may have been uncommitted.
+		now := time.Now().Round(time.Microsecond)
+		for _, c := range changes {
+			c.ChangeTime = now
+			// Without a notifier, there is nothing to dispatch and the change is only kept in the log.
+			c.Dispatched = s.notifier == nil
+			if err := db.SaveChange(ctx, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	result := topic.Publish(ctx, &pubsub.Message{
-		Data: msg,
-	})
+	// Watchers are served in-process and don't depend on notifier configuration.
+	for _, c := range changes {
+		s.watchers.Notify(ctx, c.Message())
+	}
+
+	if s.notifier != nil && len(changes) > 0 {
+		select {
+		case s.outbox <- struct{}{}:
+		default: // A dispatch is already pending.
+		}
+	}
+	return nil
+}
+
+// dispatchChanges sends undispatched changes to the notifier until the context is cancelled.
+// Changes are dispatched when they are committed and periodically to retry failures.
+func (s *RegistryServer) dispatchChanges(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.drainOutbox(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to dispatch changes: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.outbox:
+		case <-ticker.C:
+		}
+	}
+}
 
-	id, err := result.Get(ctx)
+// drainOutbox sends undispatched changes to the notifier in the order they were made.
+// Delivery is at-least-once: changes are marked as dispatched after the notifier reports
+// that their destination accepted them, and changes that fail are sent again later.
+func (s *RegistryServer) drainOutbox(ctx context.Context) error {
+	client, err := s.getStorageClient(ctx)
 	if err != nil {
 		return err
 	}
+	db := dao.NewDAO(client, s.blobs)
 
-	notificationTotal++
-	if verbose {
-		log.Printf("^^ [%03d] %+s", notificationTotal, msg)
-		log.Printf("Published a message with a message ID: %s", id)
-	}
+	for {
+		changes, err := db.ListUndispatchedChanges(ctx, outboxBatchSize)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		for i := range changes {
+			if err := s.notifier.Notify(ctx, changes[i].Message()); err != nil {
+				return err
+			}
+
+			changes[i].Dispatched = true
+			if err := db.SaveChange(ctx, &changes[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(changes) < outboxBatchSize {
+			return nil
+		}
+	}
 }
@@ -0,0 +1,59 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// LogFileNotifier appends notifications to a file as newline-delimited JSON.
+type LogFileNotifier struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewLogFile creates a notifier that appends to the file at path, creating it if necessary.
+func NewLogFile(path string) (*LogFileNotifier, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &LogFileNotifier{file: f}, nil
+}
+
+// Notify appends a notification to the file.
+func (l *LogFileNotifier) Notify(ctx context.Context, n *rpc.Notification) error {
+	line, err := protojson.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (l *LogFileNotifier) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apigee/registry/rpc"
+)
+
+// MemoryNotifier fans notifications out to in-process subscribers.
+// It is used to serve watch requests and to observe notifications in tests.
+type MemoryNotifier struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives notifications selected by its filter.
+type Subscription struct {
+	// C receives notifications. It is closed when the subscription ends.
+	C <-chan *rpc.Notification
+
+	events     chan *rpc.Notification
+	filter     func(*rpc.Notification) bool
+	overflowed bool
+}
+
+// Overflowed returns true if the subscription was ended because its buffer filled.
+// It should only be called after C has been closed.
+func (s *Subscription) Overflowed() bool {
+	return s.overflowed
+}
+
+// NewMemory creates an in-memory notifier with no subscribers.
+func NewMemory() *MemoryNotifier {
+	return &MemoryNotifier{
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// Subscribe registers a subscriber for notifications selected by filter.
+// A nil filter selects all notifications. Subscribers that fall more than
+// buffer notifications behind are disconnected.
+func (m *MemoryNotifier) Subscribe(buffer int, filter func(*rpc.Notification) bool) *Subscription {
+	events := make(chan *rpc.Notification, buffer)
+	s := &Subscription{
+		C:      events,
+		events: events,
+		filter: filter,
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscribers[s] = true
+	return s
+}
+
+// Unsubscribe removes a subscriber and closes its channel if it is still open.
+func (m *MemoryNotifier) Unsubscribe(s *Subscription) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.subscribers[s] {
+		delete(m.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Subscribers returns the number of active subscribers.
+func (m *MemoryNotifier) Subscribers() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.subscribers)
+}
+
+// Notify sends a notification to all matching subscribers without blocking.
+func (m *MemoryNotifier) Notify(ctx context.Context, n *rpc.Notification) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for s := range m.subscribers {
+		if s.filter != nil && !s.filter(n) {
+			continue
+		}
+
+		select {
+		case s.events <- n:
+		default:
+			s.overflowed = true
+			delete(m.subscribers, s)
+			close(s.events)
+		}
+	}
+	return nil
+}
+
+// Close ends all subscriptions.
+func (m *MemoryNotifier) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for s := range m.subscribers {
+		delete(m.subscribers, s)
+		close(s.events)
+	}
+	return nil
+}
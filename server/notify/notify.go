@@ -0,0 +1,105 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+// This is the notification protocol used by the Registry server to report changes.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+)
+
+// Notifier delivers notifications about registry changes to subscribers.
+type Notifier interface {
+	// Notify delivers a notification. It returns after the notification is accepted
+	// by its destination, so that notifications that return errors can be sent again.
+	// Notifications are sent by the server's outbox, so delivery can be slow.
+	Notify(ctx context.Context, n *rpc.Notification) error
+	// Close releases any resources held by the notifier.
+	Close() error
+}
+
+const (
+	// PubSub publishes notifications to a Google Cloud Pub/Sub topic.
+	PubSub = "pubsub"
+	// Webhook posts notifications to an HTTP endpoint.
+	Webhook = "webhook"
+	// Log appends notifications to a local file.
+	Log = "log"
+)
+
+// Config configures a notifier.
+type Config struct {
+	// Type selects the notifier implementation: pubsub, webhook, or log.
+	Type string `yaml:"type"`
+	// Project is the GCP project that owns the Pub/Sub topic.
+	Project string `yaml:"project"`
+	// Topic is the name of the Pub/Sub topic.
+	Topic string `yaml:"topic"`
+	// URL is the webhook endpoint that receives notifications.
+	URL string `yaml:"url"`
+	// Secret is used to sign webhook payloads with HMAC-SHA256.
+	Secret string `yaml:"secret"`
+	// Retries is the number of times a failed webhook delivery is retried.
+	Retries int `yaml:"retries"`
+	// Timeout is the maximum duration of a single webhook delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// Path is the file that notifications are appended to.
+	Path string `yaml:"path"`
+}
+
+// Validate returns an error if the configuration can't be used to create a notifier.
+func (c Config) Validate() error {
+	switch c.Type {
+	case PubSub:
+		if c.Project == "" {
+			return fmt.Errorf("invalid project %q: pubsub notifications require a GCP project ID", c.Project)
+		}
+	case Webhook:
+		if c.URL == "" {
+			return fmt.Errorf("invalid url %q: webhook notifications require a URL", c.URL)
+		} else if c.Retries < 0 {
+			return fmt.Errorf("invalid retries %d: must not be negative", c.Retries)
+		}
+	case Log:
+		if c.Path == "" {
+			return fmt.Errorf("invalid path %q: log notifications require a file path", c.Path)
+		}
+	default:
+		return fmt.Errorf("invalid type %q: must be one of [%s, %s, %s]", c.Type, PubSub, Webhook, Log)
+	}
+	return nil
+}
+
+// New creates a notifier from a configuration.
+func New(ctx context.Context, c Config) (Notifier, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case PubSub:
+		return NewPubSub(ctx, c.Project, c.Topic)
+	case Webhook:
+		return NewWebhook(c.URL, c.Secret, c.Retries, c.Timeout), nil
+	case Log:
+		return NewLogFile(c.Path)
+	default:
+		return nil, fmt.Errorf("unsupported notifier type %q", c.Type)
+	}
+}
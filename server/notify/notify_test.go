@@ -0,0 +1,231 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+		valid  bool
+	}{
+		{"pubsub", Config{Type: PubSub, Project: "my-project"}, true},
+		{"pubsub without project", Config{Type: PubSub}, false},
+		{"webhook", Config{Type: Webhook, URL: "http://localhost"}, true},
+		{"webhook without url", Config{Type: Webhook}, false},
+		{"webhook with negative retries", Config{Type: Webhook, URL: "http://localhost", Retries: -1}, false},
+		{"log", Config{Type: Log, Path: "/tmp/notifications.log"}, true},
+		{"log without path", Config{Type: Log}, false},
+		{"unknown type", Config{Type: "carrier-pigeon"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := test.config.Validate(); (err == nil) != test.valid {
+				t.Errorf("Validate(%+v) returned error %v, want valid=%t", test.config, err, test.valid)
+			}
+		})
+	}
+}
+
+func TestWebhookSignsAndRetries(t *testing.T) {
+	const secret = "shared-secret"
+	want := &rpc.Notification{
+		Change:   rpc.Notification_CREATED,
+		Resource: "projects/my-project/apis/my-api",
+	}
+
+	var (
+		mutex    sync.Mutex
+		attempts int
+		received = make(chan *rpc.Notification, 1)
+	)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts++
+		n := attempts
+		mutex.Unlock()
+
+		// Fail the first delivery to exercise retries.
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %s", err)
+		}
+		if got, want := r.Header.Get(SignatureHeader), Sign([]byte(secret), body); got != want {
+			t.Errorf("Webhook signature is %q, want %q", got, want)
+		}
+
+		n2 := new(rpc.Notification)
+		if err := protojson.Unmarshal(body, n2); err != nil {
+			t.Errorf("Failed to unmarshal notification: %s", err)
+		}
+		received <- n2
+	}))
+	defer endpoint.Close()
+
+	w := NewWebhook(endpoint.URL, secret, 2, time.Second)
+	defer w.Close()
+
+	// Notifications are delivered before Notify returns.
+	if err := w.Notify(context.Background(), want); err != nil {
+		t.Fatalf("Notify(%+v) returned error: %s", want, err)
+	}
+
+	select {
+	case got := <-received:
+		if !cmp.Equal(want, got, protocmp.Transform()) {
+			t.Errorf("Webhook received unexpected diff (-want +got):\n%s", cmp.Diff(want, got, protocmp.Transform()))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Webhook did not receive notification")
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	l, err := NewLogFile(path)
+	if err != nil {
+		t.Fatalf("NewLogFile(%q) returned error: %s", path, err)
+	}
+
+	resources := []string{"projects/a", "projects/b"}
+	for _, r := range resources {
+		if err := l.Notify(context.Background(), &rpc.Notification{Resource: r}); err != nil {
+			t.Fatalf("Notify(%q) returned error: %s", r, err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() returned error: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %q: %s", path, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != len(resources) {
+		t.Fatalf("Log file contains %d lines, want %d", len(lines), len(resources))
+	}
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %s", i, err)
+		}
+		if entry["resource"] != resources[i] {
+			t.Errorf("Line %d has resource %v, want %q", i, entry["resource"], resources[i])
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	all := m.Subscribe(10, nil)
+	apis := m.Subscribe(10, func(n *rpc.Notification) bool {
+		return strings.Contains(n.GetResource(), "/apis/")
+	})
+	slow := m.Subscribe(1, nil)
+
+	m.Notify(ctx, &rpc.Notification{Resource: "projects/p"})
+	m.Notify(ctx, &rpc.Notification{Resource: "projects/p/apis/a"})
+
+	if got := len(all.C); got != 2 {
+		t.Errorf("Unfiltered subscriber received %d notifications, want 2", got)
+	}
+	if got := len(apis.C); got != 1 {
+		t.Errorf("Filtered subscriber received %d notifications, want 1", got)
+	}
+
+	// The slow subscriber's buffer overflowed on the second notification.
+	<-slow.C
+	if _, ok := <-slow.C; ok || !slow.Overflowed() {
+		t.Errorf("Slow subscriber was not disconnected after overflowing")
+	}
+	if got := m.Subscribers(); got != 2 {
+		t.Errorf("Subscribers() returned %d, want 2", got)
+	}
+
+	m.Unsubscribe(apis)
+	m.Close()
+	if got := m.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() returned %d after Close(), want 0", got)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer endpoint.Close()
+
+	w := NewWebhook(endpoint.URL, "", 2, time.Second)
+	defer w.Close()
+
+	// Notifications that aren't accepted must be reported, so that they can be sent again.
+	if err := w.Notify(context.Background(), &rpc.Notification{Resource: "projects/my-project"}); err == nil {
+		t.Errorf("Notify() succeeded, want error for a rejected notification")
+	}
+}
+
+func TestWebhookCloseInterruptsRetries(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer endpoint.Close()
+
+	// Without interruption, the retries would take more than four minutes.
+	w := NewWebhook(endpoint.URL, "", 10, time.Second)
+	notified := make(chan error, 1)
+	go func() {
+		notified <- w.Notify(context.Background(), &rpc.Notification{Resource: "projects/my-project"})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() returned error: %s", err)
+	}
+	select {
+	case err := <-notified:
+		if err == nil {
+			t.Errorf("Notify() succeeded, want error for an interrupted delivery")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Notify() did not return after Close() while a delivery was waiting to be retried")
+	}
+}
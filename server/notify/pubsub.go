@@ -0,0 +1,82 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const verbose = false
+
+// PubSubNotifier publishes notifications to a Google Cloud Pub/Sub topic.
+// The client and topic are created once and shared by all notifications.
+type PubSubNotifier struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// NewPubSub creates a notifier that publishes to the named topic, creating it if necessary.
+func NewPubSub(ctx context.Context, projectID, topicName string) (*PubSubNotifier, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := client.CreateTopic(ctx, topicName); err != nil && status.Code(err) != codes.AlreadyExists {
+		client.Close()
+		return nil, err
+	}
+
+	return &PubSubNotifier{
+		client: client,
+		topic:  client.Topic(topicName),
+	}, nil
+}
+
+// Notify publishes a notification and waits until it is accepted by Pub/Sub.
+func (p *PubSubNotifier) Notify(ctx context.Context, n *rpc.Notification) error {
+	msg, err := protojson.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	result := p.topic.Publish(ctx, &pubsub.Message{
+		Data: msg,
+	})
+
+	id, err := result.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to publish notification for %q: %s", n.GetResource(), err)
+	}
+	if verbose {
+		log.Printf("^^ %+s", msg)
+		log.Printf("Published a message with a message ID: %s", id)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the Pub/Sub client.
+func (p *PubSubNotifier) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
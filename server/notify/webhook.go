@@ -0,0 +1,174 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// SignatureHeader is the HTTP header that carries the payload signature.
+	// Its value has the form "sha256=<hex-encoded HMAC-SHA256 of the body>".
+	SignatureHeader = "X-Registry-Signature"
+
+	defaultWebhookTimeout = 10 * time.Second
+	initialWebhookBackoff = 500 * time.Millisecond
+)
+
+// WebhookNotifier posts notifications as JSON to an HTTP endpoint.
+// Notifications are posted by the server's outbox, which doesn't delay registry mutations.
+type WebhookNotifier struct {
+	url     string
+	secret  []byte
+	retries int
+	client  *http.Client
+	once    sync.Once
+	// stopped is closed by Close to interrupt deliveries, including those waiting to be retried.
+	stopped chan struct{}
+}
+
+// NewWebhook creates a notifier that posts to the provided URL.
+// If secret is non-empty, each payload is signed with HMAC-SHA256.
+func NewWebhook(url, secret string, retries int, timeout time.Duration) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &WebhookNotifier{
+		url:     url,
+		secret:  []byte(secret),
+		retries: retries,
+		client:  &http.Client{Timeout: timeout},
+		stopped: make(chan struct{}),
+	}
+}
+
+// Notify posts a notification, retrying failed requests with exponential backoff.
+// It returns after the endpoint accepts the notification or the last attempt fails.
+func (w *WebhookNotifier) Notify(ctx context.Context, n *rpc.Notification) error {
+	body, err := protojson.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	// Deliveries are cancelled when the caller's context is cancelled or the notifier is closed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := w.deliver(ctx, body); err != nil {
+		return fmt.Errorf("failed to deliver webhook notification for %q to %s: %s", n.GetResource(), w.url, err)
+	}
+	return nil
+}
+
+// Close interrupts deliveries that are in progress. Notifications can't be sent after the notifier is closed.
+func (w *WebhookNotifier) Close() error {
+	w.once.Do(func() { close(w.stopped) })
+	return nil
+}
+
+// errWebhookClosed is returned for deliveries that are interrupted by Close.
+var errWebhookClosed = errors.New("webhook notifier is closed")
+
+// deliver posts a payload, retrying with exponential backoff on failure.
+func (w *WebhookNotifier) deliver(ctx context.Context, body []byte) error {
+	backoff := initialWebhookBackoff
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return w.interrupted(ctx)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		} else if ctx.Err() != nil {
+			return w.interrupted(ctx)
+		}
+
+		var retry bool
+		if retry, err = w.post(ctx, body); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// interrupted returns the reason that a delivery's context was cancelled.
+func (w *WebhookNotifier) interrupted(ctx context.Context) error {
+	select {
+	case <-w.stopped:
+		return errWebhookClosed
+	default:
+		return ctx.Err()
+	}
+}
+
+// post sends a single request. It reports whether a failed request should be retried.
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil && ctx.Err() != nil {
+		return false, w.interrupted(ctx)
+	} else if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for a payload.
+// Receivers can verify payloads by computing the same value with their copy of the secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
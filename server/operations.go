@@ -0,0 +1,229 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// operationHeartbeat is how often running operations record that they are still running.
+const operationHeartbeat = time.Minute
+
+// operationTimeout is how long an unfinished operation can go without recording progress
+// before it is considered abandoned, e.g. because the server running it was stopped.
+const operationTimeout = 5 * operationHeartbeat
+
+// operationRetention is how long finished operations are kept.
+const operationRetention = 30 * 24 * time.Hour
+
+// progressFunc records the progress of an operation. It fails with Canceled
+// when the operation should stop because it was cancelled.
+type progressFunc func(percent int32, message string) error
+
+// operationFunc performs the work of an operation and returns its response.
+type operationFunc func(ctx context.Context, db dao.DAO, progress progressFunc) (proto.Message, error)
+
+// operationRun is an operation that is running in this server.
+type operationRun struct {
+	name   string
+	cancel context.CancelFunc
+
+	// mu guards reason, which is the error recorded if the run is stopped.
+	mu     sync.Mutex
+	reason error
+}
+
+// stop cancels the run, which will fail with reason unless it already finished.
+func (r *operationRun) stop(reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reason == nil {
+		r.reason = reason
+	}
+	r.cancel()
+}
+
+// stopReason returns the error that the run was stopped with, if any.
+func (r *operationRun) stopReason() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reason
+}
+
+// startOperation records a new operation and runs fn in the background.
+// It returns the operation, which can be polled with the Operations service.
+func (s *RegistryServer) startOperation(ctx context.Context, db dao.DAO, verb, target string, fn operationFunc) (*longrunning.Operation, error) {
+	op := models.NewOperation(verb, target)
+	if err := db.SaveOperation(ctx, op); err != nil {
+		return nil, err
+	}
+
+	message, err := op.Message()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Operations outlive the requests that start them.
+	runCtx, cancel := context.WithCancel(context.Background())
+	run := &operationRun{name: op.Name(), cancel: cancel}
+
+	s.operationsMutex.Lock()
+	s.operations[run.name] = run
+	s.operationsMutex.Unlock()
+
+	s.operationWorkers.Add(1)
+	go func() {
+		defer s.operationWorkers.Done()
+		defer func() {
+			s.operationsMutex.Lock()
+			delete(s.operations, run.name)
+			s.operationsMutex.Unlock()
+		}()
+		defer cancel()
+
+		s.runOperation(runCtx, db, run, fn)
+	}()
+
+	return message, nil
+}
+
+// runOperation performs an operation and records its result.
+func (s *RegistryServer) runOperation(ctx context.Context, db dao.DAO, run *operationRun, fn operationFunc) {
+	// Progress is saved even if the run is cancelled, so it doesn't use the run's context.
+	storeCtx := context.Background()
+
+	// Heartbeats also notice cancellations requested through other servers.
+	done := make(chan struct{})
+	var heartbeats sync.WaitGroup
+	heartbeats.Add(1)
+	go func() {
+		defer heartbeats.Done()
+		ticker := time.NewTicker(operationHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := s.updateOperation(storeCtx, db, run, func(op *models.Operation) error {
+				op.UpdateTime = time.Now().Round(time.Microsecond)
+				return nil
+			}); err != nil && status.Code(err) != codes.Canceled {
+				log.Printf("Failed to update operation %s: %s", run.name, err)
+			}
+		}
+	}()
+
+	progress := func(percent int32, message string) error {
+		if err := ctx.Err(); err != nil {
+			return status.Error(codes.Canceled, "operation was cancelled")
+		}
+		return s.updateOperation(storeCtx, db, run, func(op *models.Operation) error {
+			op.Progress(percent, message)
+			return nil
+		})
+	}
+
+	response, err := fn(ctx, db, progress)
+	close(done)
+	heartbeats.Wait()
+	if reason := run.stopReason(); reason != nil && err != nil {
+		err = reason
+	}
+
+	if saveErr := s.updateOperation(storeCtx, db, run, func(op *models.Operation) error {
+		return op.Finish(response, err)
+	}); saveErr != nil {
+		log.Printf("Failed to record the result of operation %s: %s", run.name, saveErr)
+	} else if err != nil && s.loggingLevel >= loggingError {
+		log.Printf("Operation %s failed: %s", run.name, err)
+	}
+}
+
+// updateOperation applies update to the stored state of a running operation.
+// It stops the run and fails with Canceled if cancellation was requested.
+func (s *RegistryServer) updateOperation(ctx context.Context, db dao.DAO, run *operationRun, update func(*models.Operation) error) error {
+	var cancelled bool
+	err := db.Transaction(ctx, func(ctx context.Context, db dao.DAO) error {
+		op, err := db.GetOperation(ctx, run.name)
+		if err != nil {
+			return err
+		}
+
+		if err := update(op); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		// Operations that finish are recorded even if cancellation was requested too late to stop them.
+		cancelled = op.CancelRequested && !op.Done
+		return db.SaveOperation(ctx, op)
+	})
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		run.stop(status.Error(codes.Canceled, "operation was cancelled"))
+		return status.Error(codes.Canceled, "operation was cancelled")
+	}
+	return nil
+}
+
+// runningOperation returns the run of an operation if it is running in this server.
+func (s *RegistryServer) runningOperation(name string) (*operationRun, bool) {
+	s.operationsMutex.Lock()
+	defer s.operationsMutex.Unlock()
+	run, ok := s.operations[name]
+	return run, ok
+}
+
+// stopOperations stops the operations running in this server and waits for them to record their results.
+func (s *RegistryServer) stopOperations() {
+	s.operationsMutex.Lock()
+	for _, run := range s.operations {
+		run.stop(status.Error(codes.Aborted, "operation was stopped because its server stopped"))
+	}
+	s.operationsMutex.Unlock()
+
+	s.operationWorkers.Wait()
+}
+
+// checkAbandoned fails an unfinished operation that hasn't recorded progress within the
+// operation timeout and isn't running in this server. Its server must have stopped before it finished.
+func (s *RegistryServer) checkAbandoned(ctx context.Context, db dao.DAO, op *models.Operation) (*models.Operation, error) {
+	if op.Done || time.Since(op.UpdateTime) < operationTimeout {
+		return op, nil
+	} else if _, ok := s.runningOperation(op.Name()); ok {
+		return op, nil
+	}
+
+	if err := op.Finish(nil, status.Error(codes.Aborted, "operation was abandoned by its server")); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := db.SaveOperation(ctx, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultRequestWindow is how long completed requests are remembered if no retention is configured.
+const defaultRequestWindow = 24 * time.Hour
+
+// requestIDRegexp matches valid request IDs, which are stored as database keys.
+var requestIDRegexp = regexp.MustCompile("^[!-~]{1,128}$")
+
+func validateRequestID(id string) error {
+	if !requestIDRegexp.MatchString(id) {
+		return fmt.Errorf("invalid request_id %q: must be at most 128 printable ASCII characters", id)
+	}
+	return nil
+}
+
+// idempotentRequest is a request message with an ID that identifies retries of the request.
+type idempotentRequest interface {
+	proto.Message
+	GetRequestId() string
+}
+
+// requestWindow returns how long completed requests are remembered.
+func (s *RegistryServer) requestWindow() time.Duration {
+	if s.requestRetention > 0 {
+		return s.requestRetention
+	}
+	return defaultRequestWindow
+}
+
+// replayRequest finds the response of a completed request with the ID of req.
+// If the request was completed within the request window, its response is loaded
+// into response and replayRequest returns true. Requests without IDs are never replayed.
+func (s *RegistryServer) replayRequest(ctx context.Context, db dao.DAO, req idempotentRequest, response proto.Message) (bool, error) {
+	id := req.GetRequestId()
+	if id == "" {
+		return false, nil
+	} else if err := validateRequestID(id); err != nil {
+		return false, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	completed, err := db.GetRequest(ctx, id)
+	if isNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if s.requestExpired(completed) {
+		return false, nil
+	} else if !completed.Matches(req) {
+		return false, status.Errorf(codes.InvalidArgument, "invalid request_id %q: it was used for a different request", id)
+	}
+
+	if err := completed.LoadResponse(response); err != nil {
+		return false, status.Error(codes.Internal, err.Error())
+	}
+	return true, nil
+}
+
+// recordRequest saves the response of a request with an ID so that retries can be replayed.
+// It is called in the transaction that completes the request, and fails with Aborted if
+// a concurrent request with the same ID was completed first. Retries will then replay it.
+func (s *RegistryServer) recordRequest(ctx context.Context, db dao.DAO, req idempotentRequest, response proto.Message) error {
+	id := req.GetRequestId()
+	if id == "" {
+		return nil
+	}
+
+	completed, err := models.NewRequest(id, req, response)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	// The request is inserted unless one with the same ID exists, so that concurrent
+	// requests with the same ID can't both record their responses.
+	if created, err := db.CreateRequest(ctx, completed); err != nil {
+		return err
+	} else if created {
+		return nil
+	}
+
+	// An existing request is only replaced after it has expired. It is locked so that it
+	// can't be replaced or expired by another transaction until this one ends.
+	locked := db.ForUpdate()
+	if existing, err := locked.GetRequest(ctx, id); err == nil && !s.requestExpired(existing) {
+		return status.Errorf(codes.Aborted, "request %q was completed by a concurrent request", id)
+	} else if isNotFound(err) {
+		if created, err := db.CreateRequest(ctx, completed); err != nil {
+			return err
+		} else if !created {
+			return status.Errorf(codes.Aborted, "request %q was completed by a concurrent request", id)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return db.SaveRequest(ctx, completed)
+}
+
+// requestExpired returns true if a request was completed before the request window.
+func (s *RegistryServer) requestExpired(r *models.Request) bool {
+	return r.CreateTime.Before(time.Now().Add(-s.requestWindow()))
+}
+
+// expireRequests periodically deletes requests that were completed before the request window
+// until the context is cancelled.
+func (s *RegistryServer) expireRequests(ctx context.Context) {
+	interval := s.requestWindow()
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client, err := s.getStorageClient(ctx)
+		if err != nil {
+			log.Printf("Failed to delete expired requests: %s", err)
+			continue
+		}
+
+		db := dao.NewDAO(client, s.blobs)
+		if _, err := db.DeleteRequestsBefore(ctx, time.Now().Add(-s.requestWindow())); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to delete expired requests: %s", err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestCreateApiRequestID(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	req := &rpc.CreateApiRequest{
+		Parent:    "projects/my-project",
+		ApiId:     "my-api",
+		Api:       &rpc.Api{DisplayName: "My API"},
+		RequestId: "6f5e3a3c-0d4b-4a3e-9f57-1c7f1b1f0e52",
+	}
+	created, err := server.CreateApi(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateApi(%+v) returned error: %s", req, err)
+	}
+
+	// Retries of the request should return the original response instead of failing.
+	retried, err := server.CreateApi(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateApi(%+v) retry returned error: %s", req, err)
+	} else if diff := cmp.Diff(created, retried, protocmp.Transform()); diff != "" {
+		t.Errorf("CreateApi(%+v) retry returned unexpected diff (-want +got):\n%s", req, diff)
+	}
+
+	// Request IDs must not be reused for different requests.
+	other := &rpc.CreateApiRequest{
+		Parent:    "projects/my-project",
+		ApiId:     "other-api",
+		Api:       &rpc.Api{},
+		RequestId: req.GetRequestId(),
+	}
+	if _, err := server.CreateApi(ctx, other); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateApi(%+v) returned status code %q, want %q: %v", other, status.Code(err), codes.InvalidArgument, err)
+	}
+
+	// Requests without IDs aren't replayed.
+	req.RequestId = ""
+	if _, err := server.CreateApi(ctx, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.AlreadyExists, err)
+	}
+
+	req.RequestId = "invalid request id"
+	if _, err := server.CreateApi(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.InvalidArgument, err)
+	}
+}
+
+func TestUpdateApiSpecRequestID(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name:     "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+		Contents: []byte("first"),
+	})
+
+	req := &rpc.UpdateApiSpecRequest{
+		ApiSpec: &rpc.ApiSpec{
+			Name:     "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+			Contents: []byte("second"),
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"contents"}},
+		RequestId:  "update-1",
+	}
+	updated, err := server.UpdateApiSpec(ctx, req)
+	if err != nil {
+		t.Fatalf("UpdateApiSpec(%+v) returned error: %s", req, err)
+	}
+
+	retried, err := server.UpdateApiSpec(ctx, req)
+	if err != nil {
+		t.Fatalf("UpdateApiSpec(%+v) retry returned error: %s", req, err)
+	} else if diff := cmp.Diff(updated, retried, protocmp.Transform()); diff != "" {
+		t.Errorf("UpdateApiSpec(%+v) retry returned unexpected diff (-want +got):\n%s", req, diff)
+	}
+
+	// Retries should not create additional revisions.
+	revisions, err := server.ListApiSpecRevisions(ctx, &rpc.ListApiSpecRevisionsRequest{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+	})
+	if err != nil {
+		t.Fatalf("ListApiSpecRevisions() returned error: %s", err)
+	} else if len(revisions.GetApiSpecs()) != 2 {
+		t.Errorf("ListApiSpecRevisions() returned %d revisions, want 2", len(revisions.GetApiSpecs()))
+	}
+}
+
+func TestTagApiSpecRevisionRequestID(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedSpecs(ctx, t, server, &rpc.ApiSpec{
+		Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec",
+	})
+
+	spec, err := server.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: "projects/my-project/apis/my-api/versions/v1/specs/my-spec"})
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec() returned error: %s", err)
+	}
+
+	req := &rpc.TagApiSpecRevisionRequest{
+		Name:      spec.GetName() + "@" + spec.GetRevisionId(),
+		Tag:       "my-tag",
+		RequestId: "tag-1",
+	}
+	tagged, err := server.TagApiSpecRevision(ctx, req)
+	if err != nil {
+		t.Fatalf("TagApiSpecRevision(%+v) returned error: %s", req, err)
+	}
+
+	retried, err := server.TagApiSpecRevision(ctx, req)
+	if err != nil {
+		t.Fatalf("TagApiSpecRevision(%+v) retry returned error: %s", req, err)
+	} else if diff := cmp.Diff(tagged, retried, protocmp.Transform()); diff != "" {
+		t.Errorf("TagApiSpecRevision(%+v) retry returned unexpected diff (-want +got):\n%s", req, diff)
+	}
+}
+
+func TestUpdateApiRequestIDConcurrently(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{Name: "projects/my-project/apis/my-api"})
+
+	// Concurrent retries either complete the request, replay it, or fail with Aborted so that they can be retried.
+	req := &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
+			Name:        "projects/my-project/apis/my-api",
+			DisplayName: "Updated",
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"display_name"}},
+		RequestId:  "update-1",
+	}
+	const count = 8
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			_, err := server.UpdateApi(ctx, req)
+			errs <- err
+		}()
+	}
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil && status.Code(err) != codes.Aborted {
+			t.Errorf("UpdateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.Aborted, err)
+		}
+	}
+
+	// The recorded response is replayed by later retries.
+	want, err := server.GetApi(ctx, &rpc.GetApiRequest{Name: req.Api.GetName()})
+	if err != nil {
+		t.Fatalf("GetApi() returned error: %s", err)
+	}
+	got, err := server.UpdateApi(ctx, req)
+	if err != nil {
+		t.Fatalf("UpdateApi(%+v) retry returned error: %s", req, err)
+	} else if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("UpdateApi(%+v) retry returned unexpected diff (-want +got):\n%s", req, diff)
+	}
+}
+
+func TestRequestIDExpiry(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	server.requestRetention = time.Millisecond
+	seedProjects(ctx, t, server, &rpc.Project{Name: "projects/my-project"})
+
+	req := &rpc.CreateApiRequest{
+		Parent:    "projects/my-project",
+		ApiId:     "my-api",
+		Api:       &rpc.Api{},
+		RequestId: "create-1",
+	}
+	if _, err := server.CreateApi(ctx, req); err != nil {
+		t.Fatalf("CreateApi(%+v) returned error: %s", req, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// Requests aren't replayed after they expire.
+	if _, err := server.CreateApi(ctx, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateApi(%+v) returned status code %q, want %q: %v", req, status.Code(err), codes.AlreadyExists, err)
+	}
+
+	client, err := server.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("Setup: getStorageClient() returned error: %s", err)
+	}
+	db := dao.NewDAO(client, server.blobs)
+	if deleted, err := db.DeleteRequestsBefore(ctx, time.Now()); err != nil {
+		t.Fatalf("DeleteRequestsBefore() returned error: %s", err)
+	} else if deleted != 1 {
+		t.Errorf("DeleteRequestsBefore() deleted %d requests, want 1", deleted)
+	}
+	if _, err := db.GetRequest(ctx, req.GetRequestId()); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRequest(%q) returned status code %q, want %q: %v", req.GetRequestId(), status.Code(err), codes.NotFound, err)
+	}
+}
+
+func TestDeleteRequestsBefore(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	client, err := server.getStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("Setup: getStorageClient() returned error: %s", err)
+	}
+	db := dao.NewDAO(client, server.blobs)
+
+	// Expired requests span several deletion batches, and newer requests are kept.
+	cutoff := time.Now().Round(time.Microsecond)
+	const expired = 250
+	for i := 0; i < expired+1; i++ {
+		request := &models.Request{
+			Key:        fmt.Sprintf("request-%d", i),
+			CreateTime: cutoff.Add(-time.Duration(expired-i) * time.Second),
+		}
+		if i == expired {
+			request.CreateTime = cutoff.Add(time.Second)
+		}
+		if err := db.SaveRequest(ctx, request); err != nil {
+			t.Fatalf("Setup: SaveRequest(%q) returned error: %s", request.Key, err)
+		}
+	}
+
+	if deleted, err := db.DeleteRequestsBefore(ctx, cutoff); err != nil {
+		t.Fatalf("DeleteRequestsBefore() returned error: %s", err)
+	} else if deleted != expired {
+		t.Errorf("DeleteRequestsBefore() deleted %d requests, want %d", deleted, expired)
+	}
+	if _, err := db.GetRequest(ctx, "request-0"); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRequest(%q) returned status code %q, want %q: %v", "request-0", status.Code(err), codes.NotFound, err)
+	}
+	id := fmt.Sprintf("request-%d", expired)
+	if _, err := db.GetRequest(ctx, id); err != nil {
+		t.Errorf("GetRequest(%q) returned error: %s", id, err)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/dao"
+	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/retention"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retentionPageSize is the number of resources listed per page while enforcing retention policies.
+const retentionPageSize = 1000
+
+// retentionPolicy returns the retention policy for the specs of an API.
+func (s *RegistryServer) retentionPolicy(ctx context.Context, db dao.DAO, name names.Api) (retention.Policy, error) {
+	api, err := db.GetApi(ctx, name)
+	if err != nil {
+		return retention.Policy{}, err
+	}
+
+	labels, err := api.LabelsMap()
+	if err != nil {
+		return retention.Policy{}, status.Error(codes.Internal, err.Error())
+	}
+
+	policy, err := s.retention.Policy(name.ProjectID, labels)
+	if err != nil {
+		return retention.Policy{}, status.Errorf(codes.FailedPrecondition, "api %q has an invalid retention policy: %s", name, err)
+	}
+
+	return policy, nil
+}
+
+// pruneSpecRevisions deletes the revisions of a spec that the policy doesn't keep.
+// It returns the names of the expired revisions, which aren't deleted if validateOnly is set.
+func (s *RegistryServer) pruneSpecRevisions(ctx context.Context, db dao.DAO, name names.Spec, policy retention.Policy, validateOnly bool) ([]string, error) {
+	revisionNames := make([]string, 0)
+	if !policy.Enabled() {
+		return revisionNames, nil
+	}
+
+	tags, err := db.ListSpecRevisionTags(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		tagged[t.RevisionID] = true
+	}
+
+	revisions := make([]retention.Revision, 0)
+	opts := dao.PageOptions{Size: retentionPageSize}
+	for {
+		listing, err := db.ListSpecRevisions(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range listing.Specs {
+			revisions = append(revisions, retention.Revision{
+				Name:       r.RevisionName(),
+				CreateTime: r.RevisionCreateTime,
+				Tagged:     tagged[r.RevisionID],
+			})
+		}
+
+		if listing.Token == "" {
+			break
+		}
+		opts.Token = listing.Token
+	}
+
+	for _, r := range policy.Expired(revisions, time.Now()) {
+		revisionNames = append(revisionNames, r.Name)
+		if validateOnly {
+			continue
+		}
+
+		rev, err := names.ParseSpecRevision(r.Name)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if err := s.commit(ctx, db, rpc.Notification_DELETED, r.Name, func(db dao.DAO) error {
+			// Tags of the revision are deleted with it so they don't refer to a missing revision.
+			for i := range tags {
+				if tags[i].RevisionID == rev.RevisionID {
+					if err := db.DeleteSpecRevisionTag(ctx, &tags[i]); err != nil {
+						return err
+					}
+				}
+			}
+			return db.DeleteSpecRevision(ctx, rev)
+		}); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return revisionNames, nil
+}
+
+// pruneArtifactRevisions deletes the revisions of an artifact that the artifact retention policy doesn't keep.
+// It is called with the transaction that creates a new revision of the artifact.
+func (s *RegistryServer) pruneArtifactRevisions(ctx context.Context, db dao.DAO, name names.Artifact) error {
+	tags, err := db.ListArtifactRevisionTags(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	tagged := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		tagged[t.RevisionID] = true
+	}
+
+	revisions := make([]retention.Revision, 0)
+	opts := dao.PageOptions{Size: retentionPageSize}
+	for {
+		listing, err := db.ListArtifactRevisions(ctx, name, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range listing.Revisions {
+			revisions = append(revisions, retention.Revision{
+				Name:       r.RevisionName(),
+				CreateTime: r.RevisionCreateTime,
+				Tagged:     tagged[r.RevisionID],
+			})
+		}
+
+		if listing.Token == "" {
+			break
+		}
+		opts.Token = listing.Token
+	}
+
+	for _, r := range s.retention.ArtifactPolicy().Expired(revisions, time.Now()) {
+		rev, err := names.ParseArtifactRevision(r.Name)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := db.DeleteArtifactRevision(ctx, rev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sweepRevisions enforces retention policies periodically until the context is cancelled.
+func (s *RegistryServer) sweepRevisions(ctx context.Context) {
+	ticker := time.NewTicker(s.retention.SweepInterval())
+	defer ticker.Stop()
+	for {
+		if err := s.sweep(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to enforce retention policies: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep prunes the revisions of every spec in the registry.
+// Failures to prune individual specs are logged and don't stop the sweep.
+func (s *RegistryServer) sweep(ctx context.Context) error {
+	client, err := s.getStorageClient(ctx)
+	if err != nil {
+		return err
+	}
+	db := dao.NewDAO(client, s.blobs)
+
+	// Policies are computed once per API in each sweep.
+	type apiPolicy struct {
+		policy retention.Policy
+		err    error
+	}
+	policies := make(map[string]apiPolicy)
+
+	parent := names.Version{ProjectID: "-", ApiID: "-", VersionID: "-"}
+	opts := dao.PageOptions{Size: retentionPageSize}
+	for {
+		listing, err := db.ListSpecs(ctx, parent, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, spec := range listing.Specs {
+			name, err := names.ParseSpec(spec.Name())
+			if err != nil {
+				return err
+			}
+
+			api := name.Api()
+			p, ok := policies[api.String()]
+			if !ok {
+				p.policy, p.err = s.retentionPolicy(ctx, db, api)
+				policies[api.String()] = p
+				if p.err != nil {
+					log.Printf("Skipping specs of %s: %s", api, p.err)
+				}
+			}
+			if p.err != nil {
+				continue
+			}
+
+			pruned, err := s.pruneSpecRevisions(ctx, db, name, p.policy, false)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Printf("Failed to prune revisions of %s: %s", name, err)
+			} else if len(pruned) > 0 && s.loggingLevel >= loggingInfo {
+				log.Printf("Pruned %d revisions of %s", len(pruned), name)
+			}
+		}
+
+		if listing.Token == "" {
+			return nil
+		}
+		opts.Token = listing.Token
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention selects the spec and artifact revisions that are deleted by retention policies.
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// These labels on APIs override the policy of their project for the specs of the API.
+const (
+	// RevisionsLabel sets the number of most recent revisions to keep.
+	RevisionsLabel = "registry-retain-revisions"
+	// DaysLabel sets the age in days of the revisions to keep.
+	DaysLabel = "registry-retain-days"
+	// TaggedLabel is "true" to keep tagged revisions or "false" to delete them.
+	TaggedLabel = "registry-retain-tagged"
+)
+
+const defaultInterval = time.Hour
+
+// DefaultArtifactRevisions is the number of revisions of each artifact that are kept by default.
+const DefaultArtifactRevisions = 10
+
+// Policy describes the revisions of each spec to keep.
+// A revision is kept if it satisfies any of the policy's conditions,
+// and the most recent revision of a spec is always kept.
+type Policy struct {
+	// Revisions is the number of most recent revisions to keep.
+	Revisions int `yaml:"keep_revisions"`
+	// Days keeps revisions that were created within this number of days.
+	Days int `yaml:"keep_days"`
+	// Tagged keeps revisions that have tags.
+	Tagged bool `yaml:"keep_tagged"`
+}
+
+// Enabled returns true if the policy deletes any revisions.
+// Policies without a number of revisions or days keep all revisions.
+func (p Policy) Enabled() bool {
+	return p.Revisions > 0 || p.Days > 0
+}
+
+// Rule applies a policy to the specs in a project.
+type Rule struct {
+	// Project is the ID of the project, or "*" to apply the policy to projects without rules.
+	Project string `yaml:"project"`
+	Policy  `yaml:",inline"`
+}
+
+// Config configures retention policies and the sweeper that enforces them.
+type Config struct {
+	// Interval is the time between sweeps. If unspecified, sweeps run hourly.
+	Interval time.Duration `yaml:"interval"`
+	// Rules are the policies of projects.
+	Rules []Rule `yaml:"rules"`
+	// ArtifactRevisions is the number of most recent revisions of each artifact to keep.
+	// If unspecified, DefaultArtifactRevisions are kept.
+	ArtifactRevisions int `yaml:"artifact_revisions"`
+}
+
+// Enabled returns true if revisions should be swept periodically.
+func (c Config) Enabled() bool {
+	return c.Interval > 0 || len(c.Rules) > 0
+}
+
+// SweepInterval returns the time between sweeps.
+func (c Config) SweepInterval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultInterval
+}
+
+// Validate returns an error if the configuration contains invalid rules.
+func (c Config) Validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid interval %s: must not be negative", c.Interval)
+	} else if c.ArtifactRevisions < 0 {
+		return fmt.Errorf("invalid artifact_revisions %d: must not be negative", c.ArtifactRevisions)
+	}
+
+	projects := make(map[string]bool, len(c.Rules))
+	for _, r := range c.Rules {
+		if r.Project == "" {
+			return fmt.Errorf("invalid rule %+v: project must be a project ID or %q", r, "*")
+		} else if projects[r.Project] {
+			return fmt.Errorf("invalid rule %+v: project %q has more than one rule", r, r.Project)
+		} else if r.Revisions < 0 || r.Days < 0 {
+			return fmt.Errorf("invalid rule %+v: keep_revisions and keep_days must not be negative", r)
+		}
+		projects[r.Project] = true
+	}
+	return nil
+}
+
+// Policy returns the policy for the specs of an API in a project, which
+// is the project's rule with any overrides from the labels of the API.
+func (c Config) Policy(projectID string, apiLabels map[string]string) (Policy, error) {
+	var p Policy
+	for _, r := range c.Rules {
+		if r.Project == projectID {
+			p = r.Policy
+			break
+		} else if r.Project == "*" {
+			p = r.Policy
+		}
+	}
+
+	if v, ok := apiLabels[RevisionsLabel]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Policy{}, fmt.Errorf("invalid %s label %q: must be a non-negative integer", RevisionsLabel, v)
+		}
+		p.Revisions = n
+	}
+
+	if v, ok := apiLabels[DaysLabel]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Policy{}, fmt.Errorf("invalid %s label %q: must be a non-negative integer", DaysLabel, v)
+		}
+		p.Days = n
+	}
+
+	if v, ok := apiLabels[TaggedLabel]; ok {
+		tagged, err := strconv.ParseBool(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid %s label %q: must be true or false", TaggedLabel, v)
+		}
+		p.Tagged = tagged
+	}
+
+	return p, nil
+}
+
+// ArtifactPolicy returns the policy for the revisions of artifacts, which are
+// pruned whenever a new revision is created. Tagged revisions are always kept.
+func (c Config) ArtifactPolicy() Policy {
+	p := Policy{Revisions: c.ArtifactRevisions, Tagged: true}
+	if p.Revisions == 0 {
+		p.Revisions = DefaultArtifactRevisions
+	}
+	return p
+}
+
+// Revision describes a revision of a spec or artifact.
+type Revision struct {
+	Name       string
+	CreateTime time.Time
+	Tagged     bool
+}
+
+// Expired returns the revisions of a spec or artifact that the policy doesn't keep, from newest to oldest.
+func (p Policy) Expired(revisions []Revision, now time.Time) []Revision {
+	expired := make([]Revision, 0)
+	if !p.Enabled() {
+		return expired
+	}
+
+	sorted := append([]Revision(nil), revisions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreateTime.After(sorted[j].CreateTime)
+	})
+
+	cutoff := now.AddDate(0, 0, -p.Days)
+	for i, r := range sorted {
+		switch {
+		case i == 0:
+		case i < p.Revisions:
+		case p.Days > 0 && r.CreateTime.After(cutoff):
+		case p.Tagged && r.Tagged:
+		default:
+			expired = append(expired, r)
+		}
+	}
+	return expired
+}
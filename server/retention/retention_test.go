@@ -0,0 +1,183 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	// Revisions are listed out of order to check that they are sorted by creation time.
+	revisions := []Revision{
+		{Name: "r3", CreateTime: daysAgo(30)},
+		{Name: "r1", CreateTime: daysAgo(200), Tagged: true},
+		{Name: "r5", CreateTime: daysAgo(1)},
+		{Name: "r2", CreateTime: daysAgo(100)},
+		{Name: "r4", CreateTime: daysAgo(10)},
+	}
+
+	tests := []struct {
+		desc   string
+		policy Policy
+		want   []string
+	}{
+		{
+			desc:   "disabled",
+			policy: Policy{Tagged: true},
+			want:   []string{},
+		},
+		{
+			desc:   "keep last revisions",
+			policy: Policy{Revisions: 2},
+			want:   []string{"r3", "r2", "r1"},
+		},
+		{
+			desc:   "keep recent revisions",
+			policy: Policy{Days: 90},
+			want:   []string{"r2", "r1"},
+		},
+		{
+			desc:   "keep recent and tagged revisions",
+			policy: Policy{Days: 90, Tagged: true},
+			want:   []string{"r2"},
+		},
+		{
+			desc:   "keep last or recent revisions",
+			policy: Policy{Revisions: 4, Days: 5},
+			want:   []string{"r1"},
+		},
+		{
+			desc:   "always keep most recent revision",
+			policy: Policy{Days: 0, Revisions: 0},
+			want:   []string{},
+		},
+		{
+			desc:   "keep only most recent revision",
+			policy: Policy{Revisions: 1},
+			want:   []string{"r4", "r3", "r2", "r1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, r := range test.policy.Expired(revisions, now) {
+				got = append(got, r.Name)
+			}
+			if !cmp.Equal(test.want, got) {
+				t.Errorf("Expired() returned unexpected revisions (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	config := Config{
+		Rules: []Rule{
+			{Project: "*", Policy: Policy{Revisions: 100}},
+			{Project: "my-project", Policy: Policy{Revisions: 10, Tagged: true}},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		project string
+		labels  map[string]string
+		want    Policy
+		wantErr bool
+	}{
+		{
+			desc:    "project rule",
+			project: "my-project",
+			want:    Policy{Revisions: 10, Tagged: true},
+		},
+		{
+			desc:    "default rule",
+			project: "other-project",
+			want:    Policy{Revisions: 100},
+		},
+		{
+			desc:    "label overrides",
+			project: "my-project",
+			labels:  map[string]string{RevisionsLabel: "0", DaysLabel: "90", TaggedLabel: "false"},
+			want:    Policy{Days: 90},
+		},
+		{
+			desc:    "invalid revisions label",
+			project: "my-project",
+			labels:  map[string]string{RevisionsLabel: "-1"},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid tagged label",
+			project: "my-project",
+			labels:  map[string]string{TaggedLabel: "always"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := config.Policy(test.project, test.labels)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Policy(%q, %v) returned error %v, want error: %t", test.project, test.labels, err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("Policy(%q, %v) returned %+v, want %+v", test.project, test.labels, got, test.want)
+			}
+		})
+	}
+
+	if (Config{}).Enabled() {
+		t.Errorf("Enabled() returned true for an empty configuration")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{}, true},
+		{Config{Interval: time.Minute, Rules: []Rule{{Project: "p", Policy: Policy{Revisions: 1}}}}, true},
+		{Config{Interval: -time.Minute}, false},
+		{Config{Rules: []Rule{{Policy: Policy{Revisions: 1}}}}, false},
+		{Config{Rules: []Rule{{Project: "p", Policy: Policy{Days: -1}}}}, false},
+		{Config{Rules: []Rule{{Project: "p"}, {Project: "p"}}}, false},
+		{Config{ArtifactRevisions: 3}, true},
+		{Config{ArtifactRevisions: -1}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.config.Validate(); (err == nil) != test.valid {
+			t.Errorf("Validate(%+v) returned %v, want valid=%t", test.config, err, test.valid)
+		}
+	}
+}
+
+func TestArtifactPolicy(t *testing.T) {
+	if got, want := (Config{}).ArtifactPolicy(), (Policy{Revisions: DefaultArtifactRevisions, Tagged: true}); got != want {
+		t.Errorf("ArtifactPolicy() returned %+v, want %+v", got, want)
+	}
+	if got, want := (Config{ArtifactRevisions: 3}).ArtifactPolicy(), (Policy{Revisions: 3, Tagged: true}); got != want {
+		t.Errorf("ArtifactPolicy() returned %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/retention"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// seedRevisions creates a spec with the given number of revisions and returns their IDs from oldest to newest.
+func seedRevisions(ctx context.Context, t *testing.T, s *RegistryServer, name string, count int) []string {
+	t.Helper()
+
+	seedSpecs(ctx, t, s, &rpc.ApiSpec{Name: name})
+	getReq := &rpc.GetApiSpecRequest{Name: name}
+	first, err := s.GetApiSpec(ctx, getReq)
+	if err != nil {
+		t.Fatalf("Setup: GetApiSpec(%+v) returned error: %s", getReq, err)
+	}
+
+	ids := []string{first.GetRevisionId()}
+	for i := 1; i < count; i++ {
+		req := &rpc.UpdateApiSpecRequest{
+			ApiSpec: &rpc.ApiSpec{
+				Name:     name,
+				Contents: []byte(fmt.Sprintf(`{"openapi": "3.0.0", "info": {"title": "My API", "version": "v%d"}, "paths": {}}`, i)),
+			},
+		}
+
+		spec, err := s.UpdateApiSpec(ctx, req)
+		if err != nil {
+			t.Fatalf("Setup: UpdateApiSpec(%+v) returned error: %s", req, err)
+		}
+		ids = append(ids, spec.GetRevisionId())
+	}
+	return ids
+}
+
+func listRevisionIDs(ctx context.Context, t *testing.T, s *RegistryServer, name string) []string {
+	t.Helper()
+
+	req := &rpc.ListApiSpecRevisionsRequest{Name: name}
+	resp, err := s.ListApiSpecRevisions(ctx, req)
+	if err != nil {
+		t.Fatalf("ListApiSpecRevisions(%+v) returned error: %s", req, err)
+	}
+
+	ids := make([]string, 0)
+	for _, spec := range resp.GetApiSpecs() {
+		ids = append(ids, spec.GetRevisionId())
+	}
+	return ids
+}
+
+func TestPruneApiSpecRevisions(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+
+	const spec = "projects/my-project/apis/my-api/versions/v1/specs/my-spec"
+	seedApis(ctx, t, server, &rpc.Api{
+		Name: "projects/my-project/apis/my-api",
+		Labels: map[string]string{
+			retention.RevisionsLabel: "2",
+			retention.TaggedLabel:    "true",
+		},
+	})
+	ids := seedRevisions(ctx, t, server, spec, 5)
+
+	tagReq := &rpc.TagApiSpecRevisionRequest{
+		Name: fmt.Sprintf("%s@%s", spec, ids[1]),
+		Tag:  "prod",
+	}
+	if _, err := server.TagApiSpecRevision(ctx, tagReq); err != nil {
+		t.Fatalf("Setup: TagApiSpecRevision(%+v) returned error: %s", tagReq, err)
+	}
+
+	// The two most recent revisions and the tagged revision are kept.
+	want := []string{fmt.Sprintf("%s@%s", spec, ids[2]), fmt.Sprintf("%s@%s", spec, ids[0])}
+
+	t.Run("validate only", func(t *testing.T) {
+		req := &rpc.PruneApiSpecRevisionsRequest{Name: spec, ValidateOnly: true}
+		got, err := server.PruneApiSpecRevisions(ctx, req)
+		if err != nil {
+			t.Fatalf("PruneApiSpecRevisions(%+v) returned error: %s", req, err)
+		}
+		if !cmp.Equal(want, got.GetRevisionNames()) {
+			t.Errorf("PruneApiSpecRevisions(%+v) returned unexpected revisions (-want +got):\n%s", req, cmp.Diff(want, got.GetRevisionNames()))
+		}
+
+		if got := listRevisionIDs(ctx, t, server, spec); len(got) != len(ids) {
+			t.Errorf("PruneApiSpecRevisions(%+v) deleted revisions, got %d revisions, want %d", req, len(got), len(ids))
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req := &rpc.PruneApiSpecRevisionsRequest{Name: spec}
+		got, err := server.PruneApiSpecRevisions(ctx, req)
+		if err != nil {
+			t.Fatalf("PruneApiSpecRevisions(%+v) returned error: %s", req, err)
+		}
+		if !cmp.Equal(want, got.GetRevisionNames()) {
+			t.Errorf("PruneApiSpecRevisions(%+v) returned unexpected revisions (-want +got):\n%s", req, cmp.Diff(want, got.GetRevisionNames()))
+		}
+
+		wantIDs := []string{ids[4], ids[3], ids[1]}
+		if got := listRevisionIDs(ctx, t, server, spec); !cmp.Equal(wantIDs, got) {
+			t.Errorf("PruneApiSpecRevisions(%+v) kept unexpected revisions (-want +got):\n%s", req, cmp.Diff(wantIDs, got))
+		}
+
+		getReq := &rpc.GetApiSpecRequest{Name: spec + "@prod"}
+		if got, err := server.GetApiSpec(ctx, getReq); err != nil {
+			t.Fatalf("GetApiSpec(%+v) returned error: %s", getReq, err)
+		} else if got.GetRevisionId() != ids[1] {
+			t.Errorf("GetApiSpec(%+v) returned revision %q, want %q", getReq, got.GetRevisionId(), ids[1])
+		}
+	})
+}
+
+func TestPruneApiSpecRevisionsResponseCodes(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	seedApis(ctx, t, server, &rpc.Api{
+		Name:   "projects/my-project/apis/invalid",
+		Labels: map[string]string{retention.DaysLabel: "forever"},
+	})
+	seedSpecs(ctx, t, server,
+		&rpc.ApiSpec{Name: "projects/my-project/apis/invalid/versions/v1/specs/s"},
+		&rpc.ApiSpec{Name: "projects/my-project/apis/unlabeled/versions/v1/specs/s"},
+	)
+
+	tests := []struct {
+		desc string
+		req  *rpc.PruneApiSpecRevisionsRequest
+		want codes.Code
+	}{
+		{
+			desc: "invalid name",
+			req:  &rpc.PruneApiSpecRevisionsRequest{Name: "projects/my-project/apis/unlabeled"},
+			want: codes.InvalidArgument,
+		},
+		{
+			desc: "missing spec",
+			req:  &rpc.PruneApiSpecRevisionsRequest{Name: "projects/my-project/apis/unlabeled/versions/v1/specs/missing"},
+			want: codes.NotFound,
+		},
+		{
+			desc: "invalid policy",
+			req:  &rpc.PruneApiSpecRevisionsRequest{Name: "projects/my-project/apis/invalid/versions/v1/specs/s"},
+			want: codes.FailedPrecondition,
+		},
+		{
+			desc: "no policy",
+			req:  &rpc.PruneApiSpecRevisionsRequest{Name: "projects/my-project/apis/unlabeled/versions/v1/specs/s"},
+			want: codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := server.PruneApiSpecRevisions(ctx, test.req); status.Code(err) != test.want {
+				t.Errorf("PruneApiSpecRevisions(%+v) returned status code %q, want %q: %v", test.req, status.Code(err), test.want, err)
+			}
+		})
+	}
+}
+
+func TestRetentionSweep(t *testing.T) {
+	ctx := context.Background()
+	server := defaultTestServer(t)
+	server.retention = retention.Config{
+		Rules: []retention.Rule{
+			{Project: "my-project", Policy: retention.Policy{Revisions: 2}},
+		},
+	}
+
+	seedApis(ctx, t, server, &rpc.Api{
+		Name:   "projects/my-project/apis/keep-all",
+		Labels: map[string]string{retention.RevisionsLabel: "0"},
+	})
+	pruned := seedRevisions(ctx, t, server, "projects/my-project/apis/a/versions/v1/specs/s", 4)
+	kept := seedRevisions(ctx, t, server, "projects/my-project/apis/keep-all/versions/v1/specs/s", 4)
+	other := seedRevisions(ctx, t, server, "projects/other-project/apis/a/versions/v1/specs/s", 4)
+
+	if err := server.sweep(ctx); err != nil {
+		t.Fatalf("sweep() returned error: %s", err)
+	}
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"projects/my-project/apis/a/versions/v1/specs/s", []string{pruned[3], pruned[2]}},
+		{"projects/my-project/apis/keep-all/versions/v1/specs/s", []string{kept[3], kept[2], kept[1], kept[0]}},
+		{"projects/other-project/apis/a/versions/v1/specs/s", []string{other[3], other[2], other[1], other[0]}},
+	}
+
+	for _, test := range tests {
+		if got := listRevisionIDs(ctx, t, server, test.spec); !cmp.Equal(test.want, got) {
+			t.Errorf("sweep() kept unexpected revisions of %s (-want +got):\n%s", test.spec, cmp.Diff(test.want, got))
+		}
+	}
+}
@@ -16,15 +16,23 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/blobs"
 	"github.com/apigee/registry/server/gorm"
+	"github.com/apigee/registry/server/memory"
+	"github.com/apigee/registry/server/notify"
+	"github.com/apigee/registry/server/retention"
 	"github.com/apigee/registry/server/storage"
 
+	"google.golang.org/genproto/googleapis/longrunning"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
@@ -44,28 +52,86 @@ const (
 
 // Config configures the registry server.
 type Config struct {
-	Database  string `yaml:"database"`
-	DBConfig  string `yaml:"dbconfig"`
-	Log       string `yaml:"log"`
-	Notify    bool   `yaml:"notify"`
-	ProjectID string `yaml:"project"`
+	Database      string           `yaml:"database"`
+	DBConfig      string           `yaml:"dbconfig"`
+	Pool          gorm.PoolConfig  `yaml:"pool"`
+	AutoMigrate   bool             `yaml:"auto_migrate"`
+	Blobs         blobs.Config     `yaml:"blobs"`
+	Log           string           `yaml:"log"`
+	Notify        bool             `yaml:"notify"`
+	ProjectID     string           `yaml:"project"`
+	Notifications notify.Config    `yaml:"notifications"`
+	Retention     retention.Config `yaml:"retention"`
+	// RequestRetention is how long responses are kept for requests with request IDs,
+	// which are replayed when the requests are retried. If unspecified, they are kept for a day.
+	RequestRetention time.Duration `yaml:"request_retention"`
+	// PurgeAfter is how long deleted apis, versions and specs are kept before they are purged.
+	// If unspecified, they are kept for 30 days.
+	PurgeAfter time.Duration `yaml:"purge_after"`
+	// Notifier overrides the configured notifications when set.
+	// It allows notifiers to be provided programmatically, e.g. in tests.
+	Notifier notify.Notifier `yaml:"-"`
 }
 
 // RegistryServer implements a Registry server.
 type RegistryServer struct {
-	database      string
-	dbConfig      string
-	notifyEnabled bool
-	loggingLevel  LogLevel
-	projectID     string
+	database     string
+	dbConfig     string
+	pool         gorm.PoolConfig
+	autoMigrate  bool
+	loggingLevel LogLevel
+	notifier     notify.Notifier
+	watchers     *notify.MemoryNotifier
+	outbox       chan struct{}
+	// blobs keeps the contents of specs and artifacts if they aren't kept in the database.
+	blobs    blobs.Store
+	blobsErr error
+	// retention configures the policies that limit the number of revisions kept for each spec.
+	retention retention.Config
+	// requestRetention is how long completed requests are remembered.
+	requestRetention time.Duration
+	// purgeAfter is how long deleted resources can be undeleted.
+	purgeAfter time.Duration
+
+	// operationsMutex guards operations, which are the long-running operations running in this server.
+	operationsMutex  sync.Mutex
+	operations       map[string]*operationRun
+	operationWorkers sync.WaitGroup
+
+	// clientMutex guards client, which is shared by all request handlers.
+	clientMutex sync.Mutex
+	client      storage.Client
 }
 
 func New(config Config) *RegistryServer {
 	s := &RegistryServer{
-		database:      config.Database,
-		dbConfig:      config.DBConfig,
-		notifyEnabled: config.Notify,
-		projectID:     config.ProjectID,
+		database:         config.Database,
+		dbConfig:         config.DBConfig,
+		pool:             config.Pool,
+		autoMigrate:      config.AutoMigrate,
+		notifier:         config.Notifier,
+		watchers:         notify.NewMemory(),
+		outbox:           make(chan struct{}, 1),
+		retention:        config.Retention,
+		requestRetention: config.RequestRetention,
+		purgeAfter:       config.PurgeAfter,
+		operations:       make(map[string]*operationRun),
+	}
+
+	if s.notifier == nil {
+		if c, ok := notifierConfig(config); ok {
+			n, err := notify.New(context.Background(), c)
+			if err != nil {
+				log.Printf("Notifications are disabled, failed to create %s notifier: %s", c.Type, err)
+			} else {
+				s.notifier = n
+			}
+		}
+	}
+
+	s.blobs, s.blobsErr = blobs.New(config.Blobs)
+	if s.blobsErr != nil {
+		log.Printf("Failed to create %s blob store: %s", config.Blobs.Type, s.blobsErr)
 	}
 
 	if s.database == "" {
@@ -91,30 +157,145 @@ func New(config Config) *RegistryServer {
 	return s
 }
 
+// getStorageClient returns the storage client shared by all request handlers.
+// The client is created when it is first needed.
 func (s *RegistryServer) getStorageClient(ctx context.Context) (storage.Client, error) {
-	return gorm.NewClient(ctx, s.database, s.dbConfig)
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	if s.client == nil {
+		c, err := s.newStorageClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.client = c
+	}
+
+	return s.client, nil
+}
+
+// newStorageClient creates a storage client for the configured database.
+func (s *RegistryServer) newStorageClient(ctx context.Context) (storage.Client, error) {
+	// Contents must not be saved in the database when the configured blob store is unavailable.
+	if s.blobsErr != nil {
+		return nil, fmt.Errorf("failed to create blob store: %s", s.blobsErr)
+	}
+
+	if s.database == "memory" {
+		// Entities are kept until the server is closed.
+		return memory.NewClient(), nil
+	}
+
+	if s.autoMigrate {
+		if err := migrate(ctx, s.database, s.dbConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := gorm.NewClient(ctx, s.database, s.dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ConfigurePool(s.pool); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
 }
 
-// if we had one client per handler, this would close the client.
-func (s *RegistryServer) releaseStorageClient(client storage.Client) {
-	client.Close()
+// migrate applies all pending schema migrations to a database.
+func migrate(ctx context.Context, database, dbConfig string) error {
+	m, err := gorm.NewMigrator(ctx, database, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	applied, err := m.Up(ctx, 0)
+	for _, v := range applied {
+		log.Printf("Applied schema migration %d: %s", v.Version, v.Description)
+	}
+	return err
+}
+
+// Close stops running operations and closes the storage client shared by request handlers.
+func (s *RegistryServer) Close() {
+	s.stopOperations()
+
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
 }
 
 // Start runs the Registry server using the provided listener.
 // It blocks until the context is cancelled.
 func (s *RegistryServer) Start(ctx context.Context, listener net.Listener) {
 	var (
-		logInterceptor = grpc.UnaryInterceptor(s.logHandler)
-		grpcServer     = grpc.NewServer(logInterceptor)
+		logInterceptor       = grpc.UnaryInterceptor(s.logHandler)
+		streamLogInterceptor = grpc.StreamInterceptor(s.streamLogHandler)
+		grpcServer           = grpc.NewServer(logInterceptor, streamLogInterceptor)
 	)
 
 	reflection.Register(grpcServer)
 	rpc.RegisterRegistryServer(grpcServer, s)
+	longrunning.RegisterOperationsServer(grpcServer, s)
+
+	// Connect to the database before serving so that requests don't wait for setup.
+	if _, err := s.getStorageClient(ctx); err != nil {
+		log.Printf("Failed to connect to database: %s", err)
+	}
 
 	go grpcServer.Serve(listener)
 
+	var workers sync.WaitGroup
+	if s.notifier != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.dispatchChanges(ctx)
+		}()
+	}
+
+	if s.retention.Enabled() {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.sweepRevisions(ctx)
+		}()
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		s.expireRequests(ctx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		s.purgeDeleted(ctx)
+	}()
+
 	// Block until the context is cancelled.
 	<-ctx.Done()
+
+	// The notifier and storage client must not be used after they are closed.
+	workers.Wait()
+
+	if s.notifier != nil {
+		if err := s.notifier.Close(); err != nil {
+			log.Printf("Failed to close notifier: %s", err)
+		}
+	}
+	s.watchers.Close()
+
+	// Handlers must not use the storage client after it is closed.
+	grpcServer.Stop()
+	s.Close()
 }
 
 func (s *RegistryServer) logHandler(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
@@ -133,6 +314,18 @@ func (s *RegistryServer) logHandler(ctx context.Context, req interface{}, info *
 	return resp, err
 }
 
+func (s *RegistryServer) streamLogHandler(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	method := filepath.Base(info.FullMethod)
+	if s.loggingLevel >= loggingInfo {
+		log.Printf(">> %s", method)
+	}
+	err := handler(srv, ss)
+	if err != nil && status.Code(err) != codes.Canceled && s.loggingLevel >= loggingError {
+		log.Printf("[%s] %s", method, err.Error())
+	}
+	return err
+}
+
 func isNotFound(err error) bool {
 	return status.Code(err) == codes.NotFound
 }
@@ -20,6 +20,7 @@ import (
 	"sync"
 	"testing"
 
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -29,20 +30,40 @@ const (
 	postgresDriver   = "postgres"
 	postgresTestUser = "registry_tester"
 	postgresDBConfig = "host=localhost port=5432 user=registry_tester dbname=registry_test sslmode=disable"
+	mysqlDriver      = "mysql"
+	mysqlDBConfig    = "registry_tester@tcp(localhost:3306)/registry_test?parseTime=true"
 )
 
 var (
 	sharedStorage sync.Mutex
 	usePostgres   = false
+	useMySQL      = false
+	useMemory     = false
 )
 
 func init() {
 	flag.BoolVar(&usePostgres, "postgresql", false, "perform server tests using postgresql")
+	flag.BoolVar(&useMySQL, "mysql", false, "perform server tests using mysql")
+	flag.BoolVar(&useMemory, "memory", false, "perform server tests using in-memory storage")
 }
 
 func defaultTestServer(t *testing.T) *RegistryServer {
 	t.Helper()
 
+	if useMemory {
+		return serverWithMemory(t)
+	}
+
+	if useMySQL {
+		server, err := serverWithMySQL(t)
+		if err != nil {
+			t.Errorf("Setup: failed to get server with mysql: %s", err)
+			t.Log("Falling back to server with SQLite storage")
+			return serverWithSQLite(t)
+		}
+		return server
+	}
+
 	if !usePostgres {
 		return serverWithSQLite(t)
 	}
@@ -58,10 +79,18 @@ func defaultTestServer(t *testing.T) *RegistryServer {
 }
 
 func serverWithSQLite(t *testing.T) *RegistryServer {
-	return New(Config{
+	s := New(Config{
 		Database: "sqlite3",
 		DBConfig: fmt.Sprintf("%s/registry.db", t.TempDir()),
 	})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func serverWithMemory(t *testing.T) *RegistryServer {
+	s := New(Config{Database: "memory"})
+	t.Cleanup(s.Close)
+	return s
 }
 
 func serverWithPostgres(t *testing.T) (*RegistryServer, error) {
@@ -72,10 +101,13 @@ func serverWithPostgres(t *testing.T) (*RegistryServer, error) {
 		return nil, fmt.Errorf("failed to reset database: %s", err)
 	}
 
-	return New(Config{
-		Database: postgresDriver,
-		DBConfig: postgresDBConfig,
-	}), nil
+	s := New(Config{
+		Database:    postgresDriver,
+		DBConfig:    postgresDBConfig,
+		AutoMigrate: true,
+	})
+	t.Cleanup(s.Close)
+	return s, nil
 }
 
 func resetPostgres() error {
@@ -101,3 +133,47 @@ func resetPostgres() error {
 
 	return nil
 }
+
+func serverWithMySQL(t *testing.T) (*RegistryServer, error) {
+	sharedStorage.Lock()
+	t.Cleanup(sharedStorage.Unlock)
+
+	if err := resetMySQL(); err != nil {
+		return nil, fmt.Errorf("failed to reset database: %s", err)
+	}
+
+	s := New(Config{
+		Database:    mysqlDriver,
+		DBConfig:    mysqlDBConfig,
+		AutoMigrate: true,
+	})
+	t.Cleanup(s.Close)
+	return s, nil
+}
+
+func resetMySQL() error {
+	db, err := gorm.Open(mysql.Open(mysqlDBConfig), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect: %s", err)
+	}
+
+	var tables []string
+	if err := db.Raw("SHOW TABLES").Scan(&tables).Error; err != nil {
+		return fmt.Errorf("failed to list tables: %s", err)
+	}
+	for _, table := range tables {
+		if err := db.Migrator().DropTable(table); err != nil {
+			return fmt.Errorf("failed to drop table %q: %s", table, err)
+		}
+	}
+
+	if sqlDB, err := db.DB(); err != nil {
+		return fmt.Errorf("failed to get database for closing: %s", err)
+	} else if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close test database: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filtering
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Operator is the type of comparison or combination made by a condition.
+type Operator int
+
+const (
+	And          Operator = iota
+	Or           Operator = iota
+	Not          Operator = iota
+	Equal        Operator = iota
+	NotEqual     Operator = iota
+	Less         Operator = iota
+	LessEqual    Operator = iota
+	Greater      Operator = iota
+	GreaterEqual Operator = iota
+	StartsWith   Operator = iota
+	Contains     Operator = iota
+	Has          Operator = iota
+)
+
+// Condition is a storage-independent representation of a filter expression,
+// which allows storage to select matching values without evaluating CEL.
+type Condition struct {
+	Operator Operator
+	// Operands are the conditions combined by And, Or and Not.
+	Operands []*Condition
+	// Field is the storage name of the field being compared.
+	Field string
+	// Type is the type of the field being compared.
+	Type FieldType
+	// Key is the map key being compared for fields of StringMap type.
+	Key string
+	// Value is the string, int64, or time.Time value that the field is compared to.
+	Value interface{}
+}
+
+var comparisons = map[string]Operator{
+	operators.Equals:        Equal,
+	operators.NotEquals:     NotEqual,
+	operators.Less:          Less,
+	operators.LessEquals:    LessEqual,
+	operators.Greater:       Greater,
+	operators.GreaterEquals: GreaterEqual,
+}
+
+// reversed contains the operators to use when comparison operands are swapped.
+var reversed = map[Operator]Operator{
+	Equal:        Equal,
+	NotEqual:     NotEqual,
+	Less:         Greater,
+	LessEqual:    GreaterEqual,
+	Greater:      Less,
+	GreaterEqual: LessEqual,
+}
+
+// conjuncts splits an expression into conditions that must all be true.
+func conjuncts(e *exprpb.Expr, fields []Field) []*Condition {
+	if call := e.GetCallExpr(); call != nil && call.GetFunction() == operators.LogicalAnd && len(call.GetArgs()) == 2 {
+		return append(conjuncts(call.GetArgs()[0], fields), conjuncts(call.GetArgs()[1], fields)...)
+	}
+	return []*Condition{condition(e, fields)}
+}
+
+// condition converts an expression into a condition, or returns nil if it can't be converted.
+func condition(e *exprpb.Expr, fields []Field) *Condition {
+	if sel := e.GetSelectExpr(); sel != nil && sel.GetTestOnly() {
+		// has(labels.key)
+		if f, ok := field(sel.GetOperand(), fields); ok && f.Type == StringMap {
+			return &Condition{Operator: Has, Field: f.StorageName, Type: f.Type, Key: sel.GetField()}
+		}
+		return nil
+	}
+
+	call := e.GetCallExpr()
+	if call == nil {
+		return nil
+	}
+	args := call.GetArgs()
+
+	switch fn := call.GetFunction(); fn {
+	case operators.LogicalAnd, operators.LogicalOr:
+		if len(args) != 2 {
+			return nil
+		}
+		left, right := condition(args[0], fields), condition(args[1], fields)
+		if left == nil || right == nil {
+			return nil
+		}
+		op := And
+		if fn == operators.LogicalOr {
+			op = Or
+		}
+		return &Condition{Operator: op, Operands: []*Condition{left, right}}
+	case operators.LogicalNot:
+		if len(args) != 1 {
+			return nil
+		}
+		if operand := condition(args[0], fields); operand != nil {
+			return &Condition{Operator: Not, Operands: []*Condition{operand}}
+		}
+		return nil
+	case operators.In:
+		// "key" in labels
+		if len(args) != 2 {
+			return nil
+		}
+		key, ok := constant(args[0]).(string)
+		if f, isField := field(args[1], fields); ok && isField && f.Type == StringMap {
+			return &Condition{Operator: Has, Field: f.StorageName, Type: f.Type, Key: key}
+		}
+		return nil
+	case overloads.StartsWith, overloads.Contains:
+		if call.GetTarget() == nil || len(args) != 1 {
+			return nil
+		}
+		value, ok := constant(args[0]).(string)
+		if !ok || !utf8.ValidString(value) {
+			return nil
+		}
+		c := comparison(call.GetTarget(), fields)
+		if c == nil || (c.Type != String && c.Type != StringMap) {
+			return nil
+		}
+		c.Operator, c.Value = StartsWith, value
+		if fn == overloads.Contains {
+			c.Operator = Contains
+		}
+		return c
+	}
+
+	op, ok := comparisons[call.GetFunction()]
+	if !ok || len(args) != 2 {
+		return nil
+	}
+
+	c, value := comparison(args[0], fields), constant(args[1])
+	if c == nil {
+		c, value, op = comparison(args[1], fields), constant(args[0]), reversed[op]
+	}
+	if c == nil || !compatible(c.Type, value) {
+		return nil
+	}
+
+	c.Operator, c.Value = op, value
+	return c
+}
+
+// comparison returns a partial condition for a field or map value that can be compared, or nil.
+func comparison(e *exprpb.Expr, fields []Field) *Condition {
+	if f, ok := field(e, fields); ok && f.Type != StringMap {
+		return &Condition{Field: f.StorageName, Type: f.Type}
+	}
+
+	// labels.key
+	if sel := e.GetSelectExpr(); sel != nil && !sel.GetTestOnly() {
+		if f, ok := field(sel.GetOperand(), fields); ok && f.Type == StringMap {
+			return &Condition{Field: f.StorageName, Type: f.Type, Key: sel.GetField()}
+		}
+	}
+
+	// labels["key"]
+	if call := e.GetCallExpr(); call != nil && call.GetFunction() == operators.Index && len(call.GetArgs()) == 2 {
+		key, ok := constant(call.GetArgs()[1]).(string)
+		if f, isField := field(call.GetArgs()[0], fields); ok && isField && f.Type == StringMap {
+			return &Condition{Field: f.StorageName, Type: f.Type, Key: key}
+		}
+	}
+
+	return nil
+}
+
+// field returns the field referenced by an identifier expression, if it is stored.
+func field(e *exprpb.Expr, fields []Field) (Field, bool) {
+	ident := e.GetIdentExpr()
+	if ident == nil {
+		return Field{}, false
+	}
+
+	for _, f := range fields {
+		if f.Name == ident.GetName() {
+			return f, f.StorageName != ""
+		}
+	}
+
+	return Field{}, false
+}
+
+// constant returns the value of a constant expression, or nil if the expression isn't supported.
+func constant(e *exprpb.Expr) interface{} {
+	if c := e.GetConstExpr(); c != nil {
+		switch v := c.GetConstantKind().(type) {
+		case *exprpb.Constant_StringValue:
+			return v.StringValue
+		case *exprpb.Constant_Int64Value:
+			return v.Int64Value
+		}
+		return nil
+	}
+
+	// timestamp("2021-01-01T00:00:00Z")
+	if call := e.GetCallExpr(); call != nil && call.GetFunction() == overloads.TypeConvertTimestamp && len(call.GetArgs()) == 1 {
+		if s, ok := constant(call.GetArgs()[0]).(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t
+			}
+		}
+	}
+
+	return nil
+}
+
+func compatible(t FieldType, value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return t == String || t == StringMap
+	case int64:
+		return t == Int
+	case time.Time:
+		return t == Timestamp
+	default:
+		return false
+	}
+}
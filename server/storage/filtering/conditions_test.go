@@ -0,0 +1,108 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filtering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testFields = []Field{
+	{Name: "name", Type: String},
+	{Name: "mime_type", Type: String, StorageName: "MimeType"},
+	{Name: "size_bytes", Type: Int, StorageName: "SizeInBytes"},
+	{Name: "create_time", Type: Timestamp, StorageName: "CreateTime"},
+	{Name: "labels", Type: StringMap, StorageName: "Labels"},
+}
+
+func TestConjuncts(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []*Condition
+	}{
+		{
+			filter: "",
+			want:   nil,
+		},
+		{
+			filter: "mime_type == 'text/plain'",
+			want: []*Condition{
+				{Operator: Equal, Field: "MimeType", Type: String, Value: "text/plain"},
+			},
+		},
+		{
+			filter: "100 < size_bytes && size_bytes <= 200",
+			want: []*Condition{
+				{Operator: Greater, Field: "SizeInBytes", Type: Int, Value: int64(100)},
+				{Operator: LessEqual, Field: "SizeInBytes", Type: Int, Value: int64(200)},
+			},
+		},
+		{
+			filter: "create_time > timestamp('2021-01-01T00:00:00Z')",
+			want: []*Condition{
+				{Operator: Greater, Field: "CreateTime", Type: Timestamp, Value: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			filter: "mime_type.startsWith('application/') || mime_type.contains('openapi')",
+			want: []*Condition{
+				{Operator: Or, Operands: []*Condition{
+					{Operator: StartsWith, Field: "MimeType", Type: String, Value: "application/"},
+					{Operator: Contains, Field: "MimeType", Type: String, Value: "openapi"},
+				}},
+			},
+		},
+		{
+			filter: "labels.env == 'prod' && labels['team'] != 'a' && !('tier' in labels) && has(labels.owner)",
+			want: []*Condition{
+				{Operator: Equal, Field: "Labels", Type: StringMap, Key: "env", Value: "prod"},
+				{Operator: NotEqual, Field: "Labels", Type: StringMap, Key: "team", Value: "a"},
+				{Operator: Not, Operands: []*Condition{
+					{Operator: Has, Field: "Labels", Type: StringMap, Key: "tier"},
+				}},
+				{Operator: Has, Field: "Labels", Type: StringMap, Key: "owner"},
+			},
+		},
+		{
+			// Fields without storage names and unsupported functions can't be converted.
+			filter: "name == 'projects/p' && mime_type.endsWith('json') && size_bytes > 0",
+			want: []*Condition{
+				nil,
+				nil,
+				{Operator: Greater, Field: "SizeInBytes", Type: Int, Value: int64(0)},
+			},
+		},
+		{
+			// Disjunctions can only be converted when both operands can be converted.
+			filter: "name == 'projects/p' || size_bytes > 0",
+			want:   []*Condition{nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			filter, err := NewFilter(test.filter, testFields)
+			if err != nil {
+				t.Fatalf("NewFilter(%q) returned error: %s", test.filter, err)
+			}
+
+			if got := filter.Conjuncts(); !cmp.Equal(test.want, got) {
+				t.Errorf("Conjuncts() returned unexpected diff (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
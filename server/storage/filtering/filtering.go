@@ -35,10 +35,21 @@ const (
 type Field struct {
 	Name string
 	Type FieldType
+	// StorageName is the name of the model field that stores values of this field.
+	// Conditions on fields without a storage name can only be evaluated in memory.
+	StorageName string
 }
 
 type Filter struct {
-	program cel.Program
+	program   cel.Program
+	conjuncts []*Condition
+}
+
+// Conjuncts returns storage conditions for the top-level conjuncts of the filter expression,
+// which must all be true for the filter to match. Conjuncts that can't be represented as
+// conditions are nil. The result is empty for filters that match everything.
+func (f *Filter) Conjuncts() []*Condition {
+	return f.conjuncts
 }
 
 func (f *Filter) Matches(model map[string]interface{}) (bool, error) {
@@ -95,5 +106,5 @@ func NewFilter(filter string, fields []Field) (Filter, error) {
 		return Filter{}, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	return Filter{program: prg}, nil
+	return Filter{program: prg, conjuncts: conjuncts(ast.Expr(), fields)}, nil
 }
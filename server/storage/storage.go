@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/apigee/registry/server/names"
+	"github.com/apigee/registry/server/storage/filtering"
 )
 
 const (
@@ -33,15 +34,29 @@ const (
 	SpecEntityName = "Spec"
 	// SpecRevisionTagEntityName is the storage entity name for API spec revision tag resources.
 	SpecRevisionTagEntityName = "SpecRevisionTag"
+	// DeploymentEntityName is the storage entity name for API deployment resources.
+	DeploymentEntityName = "Deployment"
+	// DeploymentRevisionTagEntityName is the storage entity name for API deployment revision tag resources.
+	DeploymentRevisionTagEntityName = "DeploymentRevisionTag"
 	// ArtifactEntityName is the storage entity name for artifact resources.
 	ArtifactEntityName = "Artifact"
+	// ArtifactRevisionEntityName is the storage entity name for artifact revision resources.
+	ArtifactRevisionEntityName = "ArtifactRevision"
+	// ArtifactRevisionTagEntityName is the storage entity name for artifact revision tag resources.
+	ArtifactRevisionTagEntityName = "ArtifactRevisionTag"
 )
 
 type Client interface {
 	Close()
 
 	Get(ctx context.Context, k Key, v interface{}) error
+	// GetForUpdate gets an entity and locks it until the current transaction ends.
+	// Transactions that lock the same entity wait for the lock and then get its latest state.
+	GetForUpdate(ctx context.Context, k Key, v interface{}) error
 	Put(ctx context.Context, k Key, v interface{}) (Key, error)
+	// Create puts an entity unless an entity with the same key exists, which is left unchanged.
+	// It reports whether the entity was created.
+	Create(ctx context.Context, k Key, v interface{}) (bool, error)
 	Delete(ctx context.Context, k Key) error
 	Run(ctx context.Context, q Query) Iterator
 
@@ -56,7 +71,14 @@ type Client interface {
 	DeleteAllMatches(ctx context.Context, q Query) error
 	DeleteChildrenOfSpec(ctx context.Context, spec names.Spec) error
 
-	GetRecentSpecRevisions(ctx context.Context, offset int32, projectID, apiID, versionID string) Iterator
+	// GetRecentSpecRevisions runs a query for specs that only returns their most recent revisions.
+	GetRecentSpecRevisions(ctx context.Context, q Query) Iterator
+	// GetRecentDeploymentRevisions runs a query for deployments that only returns their most recent revisions.
+	GetRecentDeploymentRevisions(ctx context.Context, q Query) Iterator
+
+	// Transaction runs fn with a client whose operations are committed together.
+	// All operations are rolled back if fn returns an error.
+	Transaction(ctx context.Context, fn func(context.Context, Client) error) error
 }
 
 type Key interface {
@@ -65,8 +87,22 @@ type Key interface {
 
 type Query interface {
 	Require(name string, value interface{}) Query
+	After(name string, value interface{}) Query
+	Before(name string, value interface{}) Query
+	// Ascending and Descending order results by a field. Results are ordered
+	// by the fields of successive calls, then by key.
+	Ascending(field string) Query
 	Descending(field string) Query
-	ApplyOffset(int32) Query
+	// StartAfter limits results to those that follow the entity with the specified key.
+	// For queries that are ordered by fields, orderValues are the entity's values of those fields.
+	StartAfter(key string, orderValues ...interface{}) Query
+	ApplyLimit(int32) Query
+	// ForUpdate locks the results of a query until the current transaction ends,
+	// and returns their latest state after waiting for any locks held by other transactions.
+	ForUpdate() Query
+	// ApplyFilter adds a filter condition to a query and returns true if the
+	// condition can be evaluated by storage. Otherwise the query is unchanged.
+	ApplyFilter(*filtering.Condition) bool
 }
 
 type Iterator interface {
@@ -0,0 +1,93 @@
+// Copyright 2021 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// watcherBufferSize is the number of notifications that can be queued for a
+// watcher before it is considered too slow and disconnected.
+const watcherBufferSize = 256
+
+// watchCollections lists the collection names that may appear in a watch pattern,
+// keyed by the collection that is allowed to precede them.
+var watchCollections = map[string][]string{
+	"projects": {"apis", "artifacts"},
+	"apis":     {"versions", "deployments", "artifacts"},
+	"versions": {"specs", "artifacts"},
+	"specs":    {"artifacts"},
+}
+
+// watchPattern is a parsed resource name pattern used to select notifications.
+type watchPattern []string
+
+// parseWatchPattern parses a resource name pattern, which is a resource name
+// that may use "-" in place of any resource ID.
+func parseWatchPattern(pattern string) (watchPattern, error) {
+	segments := strings.Split(pattern, "/")
+	if len(segments) < 2 || len(segments)%2 != 0 || segments[0] != "projects" {
+		return nil, fmt.Errorf("invalid pattern %q: must be a resource name beginning with projects/", pattern)
+	}
+
+	for i := 0; i < len(segments); i += 2 {
+		if i > 0 && !containsString(watchCollections[segments[i-2]], segments[i]) {
+			return nil, fmt.Errorf("invalid pattern %q: unexpected collection %q", pattern, segments[i])
+		}
+		if segments[i+1] == "" {
+			return nil, fmt.Errorf("invalid pattern %q: resource IDs must not be empty", pattern)
+		}
+		segments[i+1] = strings.ToLower(segments[i+1])
+	}
+
+	return watchPattern(segments), nil
+}
+
+// Matches returns true if the named resource or one of its parents matches the pattern.
+// Revision IDs are ignored, so spec revisions match patterns for their specs.
+func (p watchPattern) Matches(resource string) bool {
+	segments := strings.Split(resource, "/")
+	if len(segments) < len(p) {
+		return false
+	}
+
+	for i, want := range p {
+		got := segments[i]
+		if i%2 == 1 {
+			if j := strings.Index(got, "@"); j >= 0 {
+				got = got[:j]
+			}
+			if want == "-" {
+				continue
+			}
+			got = strings.ToLower(got)
+		}
+		if got != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}